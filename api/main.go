@@ -3,101 +3,171 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"log"
+	"log/slog"
 	"os"
+	"pratbacknd/internal/config"
+	"pratbacknd/internal/gateway"
+	"pratbacknd/internal/logging"
+	"pratbacknd/internal/metrics"
+	"pratbacknd/internal/objectstore"
 	"pratbacknd/internal/secret"
 	"pratbacknd/internal/server"
 	"pratbacknd/internal/storage"
+	"pratbacknd/internal/tracing"
 	"pratbacknd/internal/utils"
+	"time"
 
-	firebase "firebase.google.com/go"
-	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/ssm"
-	chiadapter "github.com/awslabs/aws-lambda-go-api-proxy/chi"
-	"google.golang.org/api/option"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
-// var lambdaHandler *chiadapter.
-var chiLambda *chiadapter.ChiLambda
+// handler serves the API Gateway and load balancer events, and the
+// scheduled events releasing the expired guest carts
+var handler *telemetryHandler
+
+// telemetryHandler logs the metrics recorded during each invocation in the
+// CloudWatch embedded metric format, nothing scrapes a Lambda, and exports
+// the spans before the Lambda is frozen.
+type telemetryHandler struct {
+	handler           lambda.Handler
+	releaseGuestCarts func(ctx context.Context) (int, error)
+	registry          *metrics.Registry
+	namespace         string
+	traces            *sdktrace.TracerProvider
+}
 
-func init() {
-	// allow origins
-	ao, found := os.LookupEnv("ALLOWED_ORIGIN")
-	if !found {
-		log.Fatalf("Could not find ALLOWED_ORIGIN")
+func (h *telemetryHandler) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	defer func() {
+		err := h.registry.WriteEMF(os.Stdout, h.namespace, time.Now())
+		if err != nil {
+			slog.ErrorContext(ctx, "writing the metrics", "error", err)
+		}
+		err = h.traces.ForceFlush(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "exporting the spans", "error", err)
+		}
+	}()
+	if scheduled(payload) {
+		released, err := h.releaseGuestCarts(ctx)
+		slog.InfoContext(ctx, "released the expired guest carts", "carts", released)
+		return nil, err
 	}
+	return h.handler.Invoke(ctx, payload)
+}
 
-	// set firebase
-	app, err := setupFireBaseApp()
-	if err != nil {
-		log.Fatalf("Could not create firebase app : %s", err)
+// scheduled returns true for the events of an EventBridge schedule.
+func scheduled(payload []byte) bool {
+	var event struct {
+		Source     string `json:"source"`
+		DetailType string `json:"detail-type"`
 	}
+	err := json.Unmarshal(payload, &event)
+	return err == nil && event.Source == "aws.events" && event.DetailType == "Scheduled Event"
+}
 
-	// set authClient
-	authClient, err := app.Auth(context.Background())
+func init() {
+	cfg, err := config.Load(flag.NewFlagSet("api", flag.ContinueOnError), os.Args[1:], config.Defaults())
+	if err != nil {
+		log.Fatalf("Could not load the config : %s", err)
+	}
+	// the standard logger writes through the JSON logger too
+	logger := logging.New(os.Stdout, cfg.Server.Level())
+	slog.SetDefault(logger)
+	exporter, err := tracing.NewExporter(cfg.Server.TraceExporter, os.Stdout)
 	if err != nil {
-		log.Fatalf("Could not build auth client : %s", err)
+		log.Fatalf("Could not create the trace exporter : %s", err)
+	}
+	traces := tracing.NewProvider("ecommerce-api", exporter)
+	otel.SetTracerProvider(traces)
+	if cfg.Images.Dir != "" {
+		log.Fatalf("Could not serve the images of %s : only the local server serves an images directory", cfg.Images.Dir)
+	}
+
+	// token verifiers
+	var verifier server.TokenVerifier
+	var cartTokenSecrets server.CartTokenSecrets
+	switch cfg.Auth.Provider {
+	case config.AuthFirebase:
+		secrets, err := secret.New(cfg.Secrets)
+		if err != nil {
+			log.Fatalf("Could not create the secrets provider : %s", err)
+		}
+		// the cart token secrets and the firebase credentials are read
+		// again when rotated
+		cartTokenSecrets, err = server.NewParametersCartTokenSecrets(context.Background(), secrets, cfg.Secrets.Name)
+		if err != nil {
+			log.Fatalf("Could not load secrets : %s", err)
+		}
+		verifier, err = server.NewFirebaseTokenVerifier(context.Background(), secrets, cfg.Secrets.Name)
+		if err != nil {
+			log.Fatalf("Could not build auth client : %s", err)
+		}
+	case config.AuthLocal:
+		verifier, err = server.NewLocalTokenVerifier([]byte(cfg.Auth.Secret))
+		if err != nil {
+			log.Fatalf("Could not create the token verifier : %s", err)
+		}
+		cartTokenSecrets = server.StaticCartTokenSecret(cfg.Auth.CartTokenSecret)
 	}
 
 	// set db
-	storage, err := storage.NewDynamo("ecommerce-dev")
+	registry := metrics.NewRegistry()
+	// nothing scrapes a Lambda, the metrics are flushed as EMF logs
+	registry.EnableEMF()
+	db, err := storage.New(cfg.Storage)
 	if err != nil {
-		log.Fatalf("Could not create storage interface")
+		log.Fatalf("Could not create storage interface : %s", err)
+	}
+
+	// product images
+	var images objectstore.ObjectStore
+	if cfg.Images.Bucket != "" {
+		images, err = objectstore.NewS3(cfg.Images.Bucket, cfg.Images.BaseURL)
+		if err != nil {
+			log.Fatalf("Could not create image store : %s", err)
+		}
+	}
+
+	// without an order system, the reviews are open to every user or closed
+	var deliveries server.DeliveryChecker
+	if cfg.Reviews.OpenToAll {
+		deliveries = server.OpenReviews{}
 	}
 
 	server, err := server.New(
 		server.Config{
-			Storage:            storage,
-			AllowedOrigins:     ao,
+			Storage:            storage.NewInstrumented(db, registry),
+			AllowedOrigins:     cfg.Server.AllowedOrigins,
 			UUIDGen:            utils.UUIDV4{},
-			FirebaseAuthClient: authClient,
+			FirebaseAuthClient: verifier,
+			CartTokenSecrets:   cartTokenSecrets,
+			ObjectStore:        images,
+			DeliveryChecker:    deliveries,
+			SearchIndexTTL:     cfg.Server.SearchIndexTTL,
+			Logger:             logger,
+			Metrics:            registry,
 		},
 	)
 	if err != nil {
 		log.Fatalf("Could not create server : %s", err)
 	}
 
-	chiLambda = chiadapter.New(server.Mux)
-}
-
-func setupFireBaseApp() (*firebase.App, error) {
-	parameterStoreName, found := os.LookupEnv("PARAMETER_STORE_NAME")
-	if !found {
-		log.Fatalf("Could not find PARAMETER_STORE_NAME")
-	}
-
-	ssmClient := ssm.New(session.Must(session.NewSession()))
-	outSSM, err := ssmClient.GetParameter(&ssm.GetParameterInput{
-		Name:           aws.String(parameterStoreName),
-		WithDecryption: aws.Bool(true),
-	})
+	gatewayHandler, err := gateway.NewHandler(server.Mux, cfg.Server.LambdaEvent)
 	if err != nil {
-		log.Fatalf("Could not access SSM : %s", err)
+		log.Fatalf("Could not create the lambda handler : %s", err)
 	}
-
-	parameterRawValue := *outSSM.Parameter.Value
-	var secrets secret.Parameters
-	err = json.Unmarshal([]byte(parameterRawValue), &secrets)
-	if err != nil {
-		log.Fatalf("Could ont unmarshall secrets: %s", err)
+	handler = &telemetryHandler{
+		handler:           gatewayHandler,
+		releaseGuestCarts: server.ReleaseExpiredGuestCarts,
+		registry:          registry,
+		namespace:         cfg.Server.MetricsNamespace,
+		traces:            traces,
 	}
-
-	jsonCreds, err := json.Marshal(secrets.Google)
-	if err != nil {
-		log.Fatalf("Could not marshall Google secrets: %s", err)
-	}
-	opt := option.WithCredentialsJSON(jsonCreds)
-
-	return firebase.NewApp(context.Background(), nil, opt)
-}
-
-func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	return chiLambda.ProxyWithContext(ctx, req)
 }
 
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(handler)
 }
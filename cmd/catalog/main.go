@@ -0,0 +1,133 @@
+// Command catalog imports and exports the products of the DynamoDB table.
+//
+//	catalog import [-config file] [-table name] [-format csv|ndjson] [-dry-run] file
+//	catalog export [-config file] [-table name] [-format csv|ndjson] [file]
+//
+// The storage is configured like the server commands, from the -config file,
+// the environment (TABLE_NAME...) or the flags, and defaults to the table of
+// the deployed API. The format defaults to the extension of the file, the
+// export is written to the standard output without a file.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"pratbacknd/internal/catalog"
+	"pratbacknd/internal/config"
+	"pratbacknd/internal/storage"
+	"pratbacknd/internal/utils"
+	"time"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "import":
+		runImport(os.Args[2:])
+	case "export":
+		runExport(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: catalog import|export [flags] [file]")
+	os.Exit(2)
+}
+
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", "", "csv or ndjson, from the file extension by default")
+	dryRun := flags.Bool("dry-run", false, "report the changes without writing them")
+	db := newStorage(flags, args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatalf("Could not open the feed : %s", err)
+	}
+	defer file.Close()
+
+	rows, err := catalog.Decode(file, fileFormat(*format, flags.Arg(0)))
+	if err != nil {
+		log.Fatalf("Could not read the feed : %s", err)
+	}
+
+	report, err := catalog.NewImporter(db, utils.UUIDV4{}).Import(context.Background(), rows, *dryRun, time.Now())
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	if err != nil {
+		log.Fatalf("Could not import the products : %s", err)
+	}
+	if report.Failed > 0 {
+		log.Fatalf("%d invalid rows, no product was imported", report.Failed)
+	}
+}
+
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "", "csv or ndjson, from the file extension by default")
+	db := newStorage(flags, args)
+	if flags.NArg() > 1 {
+		usage()
+	}
+
+	rows, err := catalog.Export(context.Background(), db, time.Now())
+	if err != nil {
+		log.Fatalf("Could not export the products : %s", err)
+	}
+
+	var out io.Writer = os.Stdout
+	if flags.NArg() == 1 {
+		file, err := os.Create(flags.Arg(0))
+		if err != nil {
+			log.Fatalf("Could not create the file : %s", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	err = catalog.Encode(out, fileFormat(*format, flags.Arg(0)), rows)
+	if err != nil {
+		log.Fatalf("Could not write the products : %s", err)
+	}
+}
+
+func fileFormat(format string, path string) string {
+	if format != "" {
+		return catalog.FormatOf(format)
+	}
+	return catalog.FormatOf(filepath.Ext(path))
+}
+
+// newStorage parses the flags with the settings of the config and builds
+// the storage they configure.
+func newStorage(flags *flag.FlagSet, args []string) storage.Storage {
+	defaults := config.Defaults()
+	// the command serves no request, only the storage settings are used
+	defaults.Server.AllowedOrigins = "*"
+	defaults.Auth = config.Auth{Provider: config.AuthLocal, Secret: "unused"}
+
+	cfg, err := config.Load(flags, args, defaults)
+	if err != nil {
+		log.Fatalf("Could not load the config : %s", err)
+	}
+	s, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatalf("Could not create storage interface : %s", err)
+	}
+	return s
+}
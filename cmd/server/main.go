@@ -0,0 +1,232 @@
+// Command server serves the API over HTTP outside of Lambda, with an
+// in-memory or a DynamoDB storage, and local ID tokens unless -auth firebase.
+// The settings are described by -help, see the config package. It listens
+// on localhost and its built-in secrets are refused with another storage
+// than memory, set -auth-secret and -cart-token-secret then.
+//
+//	go run ./cmd/server -storage memory -images-dir ./images
+//	go run ./cmd/server -token user-1   # prints an ID token for user-1
+//	go run ./cmd/server -token ops -admin   # an ID token for the /admin routes
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"pratbacknd/internal/config"
+	"pratbacknd/internal/logging"
+	"pratbacknd/internal/metrics"
+	"pratbacknd/internal/objectstore"
+	"pratbacknd/internal/secret"
+	"pratbacknd/internal/server"
+	"pratbacknd/internal/storage"
+	"pratbacknd/internal/tracing"
+	"pratbacknd/internal/utils"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+// the built-in secrets are public, they only protect the data of the memory
+// storage which is gone with the process
+const (
+	localAuthSecret      = "local-auth-secret"
+	localCartTokenSecret = "local-cart-secret"
+)
+
+func main() {
+	defaults := config.Defaults()
+	defaults.Server.Addr = "localhost:8080"
+	defaults.Server.AllowedOrigins = "http://localhost:5173"
+	defaults.Server.PublicURL = "http://localhost:8080"
+	defaults.Storage.Kind = config.StorageMemory
+	defaults.Auth = config.Auth{
+		Provider:        config.AuthLocal,
+		Secret:          localAuthSecret,
+		CartTokenSecret: localCartTokenSecret,
+	}
+
+	flags := flag.NewFlagSet("server", flag.ExitOnError)
+	token := flags.String("token", "", "print an ID token for this user id and exit")
+	admin := flags.Bool("admin", false, "give the admin claim to the token of -token")
+	cfg, err := config.Load(flags, os.Args[1:], defaults)
+	if err != nil {
+		log.Fatalf("Could not load the config : %s", err)
+	}
+	err = checkLocalSecrets(cfg)
+	if err != nil {
+		log.Fatalf("Could not load the config : %s", err)
+	}
+	// the standard logger writes through the JSON logger too
+	logger := logging.New(os.Stdout, cfg.Server.Level())
+	slog.SetDefault(logger)
+
+	exporter, err := tracing.NewExporter(cfg.Server.TraceExporter, os.Stdout)
+	if err != nil {
+		log.Fatalf("Could not create the trace exporter : %s", err)
+	}
+	traces := tracing.NewProvider("ecommerce-server", exporter)
+	otel.SetTracerProvider(traces)
+
+	var verifier server.TokenVerifier
+	var cartTokenSecrets server.CartTokenSecrets = server.StaticCartTokenSecret(cfg.Auth.CartTokenSecret)
+	switch cfg.Auth.Provider {
+	case config.AuthLocal:
+		local, err := server.NewLocalTokenVerifier([]byte(cfg.Auth.Secret))
+		if err != nil {
+			log.Fatalf("Could not create the token verifier : %s", err)
+		}
+		if *token != "" {
+			var claims map[string]interface{}
+			if *admin {
+				claims = map[string]interface{}{"admin": true}
+			}
+			signed, err := local.Sign(*token, claims)
+			if err != nil {
+				log.Fatalf("Could not sign the token : %s", err)
+			}
+			fmt.Println(signed)
+			return
+		}
+		verifier = local
+	case config.AuthFirebase:
+		if *token != "" {
+			log.Fatalf("Could not sign the token : the tokens are signed by firebase")
+		}
+		secrets, err := secret.New(cfg.Secrets)
+		if err != nil {
+			log.Fatalf("Could not create the secrets provider : %s", err)
+		}
+		cartTokenSecrets, err = server.NewParametersCartTokenSecrets(context.Background(), secrets, cfg.Secrets.Name)
+		if err != nil {
+			log.Fatalf("Could not load secrets : %s", err)
+		}
+		verifier, err = server.NewFirebaseTokenVerifier(context.Background(), secrets, cfg.Secrets.Name)
+		if err != nil {
+			log.Fatalf("Could not build auth client : %s", err)
+		}
+	}
+
+	registry := metrics.NewRegistry()
+	db, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatalf("Could not create storage interface : %s", err)
+	}
+
+	// the local store serves the images under /images and the metrics are
+	// scraped from /metrics
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry.Handler())
+	var images objectstore.ObjectStore
+	switch {
+	case cfg.Images.Dir != "":
+		local, err := objectstore.NewLocal(cfg.Images.Dir, cfg.Server.PublicURL+"/images", []byte(cfg.Auth.Secret))
+		if err != nil {
+			log.Fatalf("Could not create image store : %s", err)
+		}
+		mux.Handle("/images/", http.StripPrefix("/images", local.Handler()))
+		images = local
+	case cfg.Images.Bucket != "":
+		images, err = objectstore.NewS3(cfg.Images.Bucket, cfg.Images.BaseURL)
+		if err != nil {
+			log.Fatalf("Could not create image store : %s", err)
+		}
+	}
+
+	// without an order system, the reviews are open to every user or closed
+	var deliveries server.DeliveryChecker
+	if cfg.Reviews.OpenToAll {
+		deliveries = server.OpenReviews{}
+	}
+
+	srv, err := server.New(server.Config{
+		Storage:            storage.NewInstrumented(db, registry),
+		AllowedOrigins:     cfg.Server.AllowedOrigins,
+		UUIDGen:            utils.UUIDV4{},
+		FirebaseAuthClient: verifier,
+		CartTokenSecrets:   cartTokenSecrets,
+		ObjectStore:        images,
+		DeliveryChecker:    deliveries,
+		SearchIndexTTL:     cfg.Server.SearchIndexTTL,
+		Logger:             logger,
+		Metrics:            registry,
+	})
+	if err != nil {
+		log.Fatalf("Could not create server : %s", err)
+	}
+	mux.Handle("/", srv.Mux)
+
+	httpServer := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		logger.Info("listening", "addr", cfg.Server.Addr, "storage", cfg.Storage.Kind)
+		err := httpServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Could not serve : %s", err)
+		}
+	}()
+
+	go releaseGuestCarts(ctx, srv)
+
+	<-ctx.Done()
+	logger.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	err = httpServer.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Fatalf("Could not shut down gracefully : %s", err)
+	}
+	err = traces.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Fatalf("Could not export the last spans : %s", err)
+	}
+}
+
+// releaseGuestCarts releases the expired guest carts every hour until ctx
+// is done.
+func releaseGuestCarts(ctx context.Context, srv *server.Server) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			released, err := srv.ReleaseExpiredGuestCarts(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "releasing the expired guest carts", "error", err)
+			}
+			slog.InfoContext(ctx, "released the expired guest carts", "carts", released)
+		}
+	}
+}
+
+// checkLocalSecrets refuses the built-in secrets in use with a storage that
+// outlives the process: the auth secret signs the local ID tokens and the
+// image uploads, the cart token secret the guest carts of the local auth.
+func checkLocalSecrets(cfg config.Config) error {
+	if cfg.Storage.Kind == config.StorageMemory {
+		return nil
+	}
+	if cfg.Auth.Secret == localAuthSecret && (cfg.Auth.Provider == config.AuthLocal || cfg.Images.Dir != "") {
+		return errors.New("the built-in auth secret is only accepted with the memory storage, set AUTH_SECRET")
+	}
+	if cfg.Auth.CartTokenSecret == localCartTokenSecret && cfg.Auth.Provider == config.AuthLocal {
+		return errors.New("the built-in cart token secret is only accepted with the memory storage, set CART_TOKEN_SECRET")
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"pratbacknd/internal/storage"
+	"pratbacknd/internal/types"
+	"pratbacknd/internal/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func eur(amount int64) types.Money {
+	return types.Money{Amount: amount, Currency: "EUR"}
+}
+
+func TestDecode(t *testing.T) {
+	t.Run("csv", func(t *testing.T) {
+		// Given
+		feed := "sku,name,currency,priceVatExcluded,vat,totalPrice,categoryIds,weight\n" +
+			"A-1,Camera,eur,1000,200,1200,c1;c2,350\n" +
+			"A-2,Lens,EUR,ten,0,0,,\n"
+
+		// When
+		rows, err := Decode(strings.NewReader(feed), FormatCSV)
+
+		// Then
+		assert.NoError(t, err)
+		assert.Len(t, rows, 2)
+		assert.Equal(t, Row{
+			Line: 1, SKU: "A-1", Name: "Camera", Currency: "EUR",
+			PriceVATExcluded: 1000, VAT: 200, TotalPrice: 1200,
+			CategoryIDs: []string{"c1", "c2"}, Weight: 350,
+		}, rows[0])
+		assert.Equal(t, 2, rows[1].Line)
+		assert.EqualError(t, rows[1].parseError, "priceVatExcluded must be an amount in minor units")
+	})
+
+	t.Run("unknown column", func(t *testing.T) {
+		_, err := Decode(strings.NewReader("sku,colour\nA-1,red\n"), FormatCSV)
+		assert.Error(t, err)
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		// Given
+		feed := `{"sku":"A-1","name":"Camera"}` + "\n\n" + `{"sku":"A-2","color":"red"}` + "\n"
+
+		// When
+		rows, err := Decode(strings.NewReader(feed), FormatNDJSON)
+
+		// Then
+		assert.NoError(t, err)
+		assert.Len(t, rows, 2)
+		assert.Equal(t, "Camera", rows[0].Name)
+		assert.NoError(t, rows[0].parseError)
+		assert.Equal(t, 2, rows[1].Line, "blank lines are not counted")
+		assert.Error(t, rows[1].parseError)
+	})
+}
+
+func TestEncode_roundTrip(t *testing.T) {
+	rows := []Row{{
+		ID: "p1", SKU: "A-1", Name: "Camera, black", Currency: "EUR",
+		PriceVATExcluded: 1000, VAT: 200, TotalPrice: 1200,
+		CategoryIDs: []string{"c1", "c2"}, Weight: 350,
+	}}
+	for _, format := range []string{FormatCSV, FormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NoError(t, Encode(&buf, format, rows))
+
+			decoded, err := Decode(&buf, format)
+			assert.NoError(t, err)
+			rows[0].Line = 1
+			assert.Equal(t, rows, decoded)
+		})
+	}
+}
+
+func TestImporter_Import(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	camera := types.Product{
+		ID: "p1", SKU: "A-1", Name: "Camera", Version: 3,
+		PriceVATExcluded: eur(1000), VAT: eur(200), TotalPrice: eur(1200),
+	}
+	legacy := types.Product{
+		ID: "p2", Name: "Lens",
+		PriceVATExcluded: eur(500), VAT: eur(100), TotalPrice: eur(600),
+	}
+
+	t.Run("dry run reports the changes", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().Products(gomock.Any()).Return([]types.Product{camera, legacy}, nil)
+		mockedUUID := utils.NewMockUUIDGenerator(ctrl)
+		mockedUUID.EXPECT().Generate().Return("p3")
+
+		rows := []Row{
+			{Line: 1, SKU: "A-1", Name: "Camera", Currency: "EUR", PriceVATExcluded: 900, VAT: 180, TotalPrice: 1080},
+			{Line: 2, ID: "p2", SKU: "B-1"},
+			{Line: 3, SKU: "C-1", Name: "Tripod", Currency: "EUR", PriceVATExcluded: 50, VAT: 10, TotalPrice: 60},
+		}
+
+		// When
+		report, err := NewImporter(mockedStorage, mockedUUID).Import(context.Background(), rows, true, now)
+
+		// Then
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 2, report.Updated)
+		assert.Equal(t, ActionUpdate, report.Rows[0].Action)
+		assert.Equal(t, []Change{
+			{Field: "priceVatExcluded", From: int64(1000), To: int64(900)},
+			{Field: "vat", From: int64(200), To: int64(180)},
+			{Field: "totalPrice", From: int64(1200), To: int64(1080)},
+		}, report.Rows[0].Changes)
+		assert.Equal(t, []Change{{Field: "sku", From: "", To: "B-1"}}, report.Rows[1].Changes, "legacy products are matched by id")
+		assert.Equal(t, "p3", report.Rows[2].ProductID)
+		assert.False(t, report.Rows[2].Applied)
+	})
+
+	t.Run("invalid rows block the import", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().Products(gomock.Any()).Return([]types.Product{camera}, nil)
+		mockedUUID := utils.NewMockUUIDGenerator(ctrl)
+
+		rows := []Row{
+			{Line: 1, SKU: "A-1", Name: "New name"},
+			{Line: 2, SKU: "A-1", Currency: "USD", PriceVATExcluded: 1, VAT: 0, TotalPrice: 1},
+			{Line: 3, SKU: "C-1", Name: "Tripod", Currency: "EUR", PriceVATExcluded: 50, VAT: 10, TotalPrice: 70},
+			{Line: 4, ID: "unknown"},
+		}
+
+		// When
+		report, err := NewImporter(mockedStorage, mockedUUID).Import(context.Background(), rows, false, now)
+
+		// Then
+		assert.NoError(t, err)
+		assert.Equal(t, 3, report.Failed)
+		assert.Equal(t, ActionUpdate, report.Rows[0].Action)
+		assert.Equal(t, "currency cannot change from EUR", report.Rows[1].Error)
+		assert.Equal(t, "totalPrice must be priceVatExcluded plus vat", report.Rows[2].Error)
+		assert.Equal(t, "product unknown not found", report.Rows[3].Error)
+	})
+
+	t.Run("duplicate sku", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().Products(gomock.Any()).Return([]types.Product{camera}, nil)
+		mockedUUID := utils.NewMockUUIDGenerator(ctrl)
+
+		rows := []Row{{Line: 1, SKU: "A-1"}, {Line: 2, SKU: "A-1"}}
+
+		// When
+		report, err := NewImporter(mockedStorage, mockedUUID).Import(context.Background(), rows, false, now)
+
+		// Then
+		assert.NoError(t, err)
+		assert.Equal(t, ActionUnchanged, report.Rows[0].Action)
+		assert.Equal(t, "product p1 already imported on line 1", report.Rows[1].Error)
+	})
+
+	t.Run("writes the changes", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().Products(gomock.Any()).Return([]types.Product{camera, legacy}, nil)
+		mockedUUID := utils.NewMockUUIDGenerator(ctrl)
+		gomock.InOrder(
+			mockedUUID.EXPECT().Generate().Return("p3"),
+			mockedUUID.EXPECT().Generate().Return("price1"),
+		)
+
+		mockedStorage.EXPECT().UpdatePriceSchedule(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, p types.Product, entry types.PriceHistoryEntry) error {
+				assert.Equal(t, uint(3), p.Version, "the price is checked against the loaded product")
+				assert.Equal(t, eur(1080), p.RegularPrice(now).TotalPrice)
+				assert.Equal(t, "price1", entry.ID)
+				return nil
+			})
+		mockedStorage.EXPECT().UpdateProduct(gomock.Any(), storage.UpdateProductInput{
+			ProductId: "p2", SKU: "B-1", Name: "Lens",
+		}).Return(nil)
+		mockedStorage.EXPECT().CreateProduct(gomock.Any(), types.Product{
+			ID: "p3", SKU: "C-1", Name: "Tripod",
+			PriceVATExcluded: eur(50), VAT: eur(10), TotalPrice: eur(60),
+		}).Return(nil)
+
+		rows := []Row{
+			{Line: 1, SKU: "A-1", Currency: "EUR", PriceVATExcluded: 900, VAT: 180, TotalPrice: 1080},
+			{Line: 2, ID: "p2", SKU: "B-1"},
+			{Line: 3, SKU: "C-1", Name: "Tripod", Currency: "EUR", PriceVATExcluded: 50, VAT: 10, TotalPrice: 60},
+		}
+
+		// When
+		report, err := NewImporter(mockedStorage, mockedUUID).Import(context.Background(), rows, false, now)
+
+		// Then
+		assert.NoError(t, err)
+		for _, row := range report.Rows {
+			assert.True(t, row.Applied)
+		}
+	})
+}
+
+func TestExport(t *testing.T) {
+	// Given
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	saleEnd := now.Add(time.Hour)
+	mockedStorage := storage.NewMockStorage(ctrl)
+	mockedStorage.EXPECT().Products(gomock.Any()).Return([]types.Product{
+		{ID: "p2", SKU: "B-1", TotalPrice: eur(10)},
+		{
+			ID: "p1", SKU: "A-1", TotalPrice: eur(1200),
+			PriceSchedule: []types.ScheduledPrice{{ID: "sale", TotalPrice: eur(600), StartsAt: now, EndsAt: &saleEnd}},
+		},
+	}, nil)
+
+	// When
+	rows, err := Export(context.Background(), mockedStorage, now)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, "A-1", rows[0].SKU, "the rows are sorted by sku")
+	assert.Equal(t, int64(1200), rows[0].TotalPrice, "sales are left out")
+	assert.Equal(t, []string{}, rows[0].CategoryIDs)
+}
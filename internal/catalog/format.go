@@ -0,0 +1,294 @@
+// Package catalog imports and exports the products in bulk, as CSV or
+// NDJSON rows.
+package catalog
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"pratbacknd/internal/types"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// categorySeparator separates the category ids of a CSV cell
+const categorySeparator = ";"
+
+// Columns of the CSV files, in order.
+var Columns = []string{
+	"id", "sku", "name", "shortDescription", "description", "image",
+	"currency", "priceVatExcluded", "vat", "totalPrice", "categoryIds", "weight",
+}
+
+// Row is a product of a feed, the amounts are in minor units of the
+// currency. A row matches a product by SKU, or by id when it has no SKU.
+// The empty values keep the current content of the product, and the prices
+// are kept when the currency is empty.
+type Row struct {
+	// Line of the row in the feed, starting at 1 for the first product
+	Line             int      `json:"-"`
+	ID               string   `json:"id,omitempty"`
+	SKU              string   `json:"sku"`
+	Name             string   `json:"name"`
+	ShortDescription string   `json:"shortDescription"`
+	Description      string   `json:"description"`
+	Image            string   `json:"image"`
+	Currency         string   `json:"currency"`
+	PriceVATExcluded int64    `json:"priceVatExcluded"`
+	VAT              int64    `json:"vat"`
+	TotalPrice       int64    `json:"totalPrice"`
+	CategoryIDs      []string `json:"categoryIds"`
+	Weight           uint     `json:"weight"`
+	// parseError is set when the row could not be read
+	parseError error
+}
+
+// ContentType returns the media type of a format.
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv"
+	}
+	return "application/x-ndjson"
+}
+
+// FormatOf returns the format of a media type or a file extension, NDJSON
+// when it is unknown.
+func FormatOf(s string) string {
+	s = strings.ToLower(s)
+	if strings.Contains(s, "csv") {
+		return FormatCSV
+	}
+	return FormatNDJSON
+}
+
+// ProductRow returns the row of a product, with its regular price at t.
+func ProductRow(p types.Product, t time.Time) Row {
+	price := p.RegularPrice(t)
+	categories := p.CategoryIDs
+	if categories == nil {
+		categories = []string{}
+	}
+	return Row{
+		ID:               p.ID,
+		SKU:              p.SKU,
+		Name:             p.Name,
+		ShortDescription: p.ShortDescription,
+		Description:      p.Description,
+		Image:            p.Image,
+		Currency:         price.TotalPrice.Currency,
+		PriceVATExcluded: price.PriceVATExcluded.Amount,
+		VAT:              price.VAT.Amount,
+		TotalPrice:       price.TotalPrice.Amount,
+		CategoryIDs:      categories,
+		Weight:           p.Weight,
+	}
+}
+
+// Product returns the product described by the row.
+func (r Row) Product() types.Product {
+	return types.Product{
+		ID:               r.ID,
+		SKU:              r.SKU,
+		Name:             r.Name,
+		ShortDescription: r.ShortDescription,
+		Description:      r.Description,
+		Image:            r.Image,
+		PriceVATExcluded: types.Money{Amount: r.PriceVATExcluded, Currency: r.Currency},
+		VAT:              types.Money{Amount: r.VAT, Currency: r.Currency},
+		TotalPrice:       types.Money{Amount: r.TotalPrice, Currency: r.Currency},
+		CategoryIDs:      r.CategoryIDs,
+		Weight:           r.Weight,
+	}
+}
+
+// Decode reads the rows of a feed, the rows that cannot be read are
+// returned with a parse error reported by the import.
+func Decode(r io.Reader, format string) ([]Row, error) {
+	if format == FormatCSV {
+		return decodeCSV(r)
+	}
+	return decodeNDJSON(r)
+}
+
+// Encode writes the rows in the format.
+func Encode(w io.Writer, format string, rows []Row) error {
+	if format == FormatCSV {
+		return encodeCSV(w, rows)
+	}
+	enc := json.NewEncoder(w)
+	for _, row := range rows {
+		err := enc.Encode(row)
+		if err != nil {
+			return fmt.Errorf("error - encoding row: %w", err)
+		}
+	}
+	return nil
+}
+
+func decodeNDJSON(r io.Reader) ([]Row, error) {
+	rows := make([]Row, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		line++
+		var row Row
+		dec := json.NewDecoder(strings.NewReader(text))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&row)
+		if err != nil {
+			row = Row{parseError: fmt.Errorf("invalid json: %w", err)}
+		}
+		row.Line = line
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error - reading ndjson: %w", err)
+	}
+	return rows, nil
+}
+
+func decodeCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error - reading csv header: %w", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.TrimSpace(name)] = i
+	}
+	for name := range index {
+		if !contains(Columns, name) {
+			return nil, fmt.Errorf("error - unknown csv column %q", name)
+		}
+	}
+	if _, found := index["sku"]; !found {
+		if _, found := index["id"]; !found {
+			return nil, errors.New("error - csv needs a sku or an id column")
+		}
+	}
+
+	rows := make([]Row, 0)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rows = append(rows, Row{Line: line, parseError: err})
+				continue
+			}
+			return nil, fmt.Errorf("error - reading csv: %w", err)
+		}
+		row, err := csvRow(index, record)
+		if err != nil {
+			row = Row{parseError: err}
+		}
+		row.Line = line
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func csvRow(index map[string]int, record []string) (Row, error) {
+	cell := func(name string) string {
+		i, found := index[name]
+		if !found || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	integer := func(name string) (int64, error) {
+		if cell(name) == "" {
+			return 0, nil
+		}
+		v, err := strconv.ParseInt(cell(name), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s must be an amount in minor units", name)
+		}
+		return v, nil
+	}
+
+	row := Row{
+		ID:               cell("id"),
+		SKU:              cell("sku"),
+		Name:             cell("name"),
+		ShortDescription: cell("shortDescription"),
+		Description:      cell("description"),
+		Image:            cell("image"),
+		Currency:         strings.ToUpper(cell("currency")),
+	}
+	var err error
+	if row.PriceVATExcluded, err = integer("priceVatExcluded"); err != nil {
+		return Row{}, err
+	}
+	if row.VAT, err = integer("vat"); err != nil {
+		return Row{}, err
+	}
+	if row.TotalPrice, err = integer("totalPrice"); err != nil {
+		return Row{}, err
+	}
+	if cell("weight") != "" {
+		weight, err := strconv.ParseUint(cell("weight"), 10, 32)
+		if err != nil {
+			return Row{}, errors.New("weight must be a number of grams")
+		}
+		row.Weight = uint(weight)
+	}
+	for _, id := range strings.Split(cell("categoryIds"), categorySeparator) {
+		if id = strings.TrimSpace(id); id != "" {
+			row.CategoryIDs = append(row.CategoryIDs, id)
+		}
+	}
+	return row, nil
+}
+
+func encodeCSV(w io.Writer, rows []Row) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write(Columns)
+	if err != nil {
+		return fmt.Errorf("error - writing csv: %w", err)
+	}
+	for _, r := range rows {
+		err = writer.Write([]string{
+			r.ID, r.SKU, r.Name, r.ShortDescription, r.Description, r.Image,
+			r.Currency,
+			strconv.FormatInt(r.PriceVATExcluded, 10),
+			strconv.FormatInt(r.VAT, 10),
+			strconv.FormatInt(r.TotalPrice, 10),
+			strings.Join(r.CategoryIDs, categorySeparator),
+			strconv.FormatUint(uint64(r.Weight), 10),
+		})
+		if err != nil {
+			return fmt.Errorf("error - writing csv: %w", err)
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
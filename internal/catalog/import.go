@@ -0,0 +1,338 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pratbacknd/internal/storage"
+	"pratbacknd/internal/types"
+	"pratbacknd/internal/utils"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// actions of the rows of an import
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionUnchanged = "unchanged"
+	ActionError     = "error"
+)
+
+// Report describes an import, row by row. Nothing is written when a row is
+// in error or when it is a dry run.
+type Report struct {
+	DryRun    bool        `json:"dryRun"`
+	Created   int         `json:"created"`
+	Updated   int         `json:"updated"`
+	Unchanged int         `json:"unchanged"`
+	Failed    int         `json:"failed"`
+	Rows      []RowReport `json:"rows"`
+}
+
+type RowReport struct {
+	Line      int      `json:"line"`
+	SKU       string   `json:"sku,omitempty"`
+	ProductID string   `json:"productId,omitempty"`
+	Action    string   `json:"action"`
+	Changes   []Change `json:"changes,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	// Applied is set once the row is written
+	Applied bool `json:"applied"`
+}
+
+// Change is a field of a product changed by a row.
+type Change struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// Importer upserts the rows of a feed into the catalog.
+type Importer struct {
+	storage storage.Storage
+	uuidGen utils.UUIDGenerator
+}
+
+func NewImporter(s storage.Storage, uuidGen utils.UUIDGenerator) *Importer {
+	return &Importer{storage: s, uuidGen: uuidGen}
+}
+
+// plan is the change of the catalog for a row
+type plan struct {
+	row     Row
+	current types.Product
+	next    types.Product
+	report  RowReport
+	// price and content are set when they change, categories when the
+	// categories are replaced
+	price      bool
+	content    bool
+	categories bool
+}
+
+// Import validates all the rows before writing any of them, the rows are
+// then written in order. A storage failure stops the import, the report
+// tells which rows were applied.
+func (i *Importer) Import(ctx context.Context, rows []Row, dryRun bool, now time.Time) (Report, error) {
+	products, err := i.storage.Products(ctx)
+	if err != nil {
+		return Report{}, fmt.Errorf("error - fetching products: %w", err)
+	}
+	bySKU := make(map[string]types.Product, len(products))
+	byID := make(map[string]types.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+		if p.SKU != "" {
+			bySKU[p.SKU] = p
+		}
+	}
+
+	report := Report{DryRun: dryRun, Rows: make([]RowReport, 0, len(rows))}
+	plans := make([]plan, 0, len(rows))
+	seen := make(map[string]int, len(rows))
+	for _, row := range rows {
+		pl, err := i.plan(row, bySKU, byID, now)
+		if err == nil {
+			err = duplicate(seen, "product "+pl.next.ID, row.Line)
+		}
+		if err == nil && pl.next.SKU != "" {
+			err = duplicate(seen, "sku "+pl.next.SKU, row.Line)
+		}
+		if err != nil {
+			pl.report.Action = ActionError
+			pl.report.Error = err.Error()
+			pl.report.Changes = nil
+		}
+		switch pl.report.Action {
+		case ActionCreate:
+			report.Created++
+		case ActionUpdate:
+			report.Updated++
+		case ActionUnchanged:
+			report.Unchanged++
+		default:
+			report.Failed++
+		}
+		plans = append(plans, pl)
+		report.Rows = append(report.Rows, pl.report)
+	}
+
+	if dryRun || report.Failed > 0 {
+		return report, nil
+	}
+
+	for n, pl := range plans {
+		err = i.apply(ctx, pl, now)
+		if err != nil {
+			return report, fmt.Errorf("error - importing line %d: %w", pl.row.Line, err)
+		}
+		report.Rows[n].Applied = pl.report.Action != ActionUnchanged
+	}
+	return report, nil
+}
+
+// plan matches the row with a product and computes the changes.
+func (i *Importer) plan(row Row, bySKU map[string]types.Product, byID map[string]types.Product, now time.Time) (plan, error) {
+	pl := plan{row: row, report: RowReport{Line: row.Line, SKU: row.SKU}}
+	if row.parseError != nil {
+		return pl, row.parseError
+	}
+	if row.SKU == "" && row.ID == "" {
+		return pl, errors.New("sku or id is required")
+	}
+
+	current, found := bySKU[row.SKU]
+	if row.SKU == "" || !found {
+		// the products created before the SKUs are matched by id
+		current, found = byID[row.ID]
+		if row.ID != "" && !found {
+			return pl, fmt.Errorf("product %s not found", row.ID)
+		}
+		if found && row.SKU != "" && current.SKU != "" {
+			return pl, fmt.Errorf("product %s already has the sku %s", row.ID, current.SKU)
+		}
+	} else if row.ID != "" && row.ID != current.ID {
+		return pl, fmt.Errorf("sku %s belongs to the product %s", row.SKU, current.ID)
+	}
+
+	if !found {
+		pl.next = row.Product()
+		err := pl.next.Validate()
+		if err != nil {
+			return pl, err
+		}
+		pl.next.ID = i.uuidGen.Generate()
+		pl.report.ProductID = pl.next.ID
+		pl.report.Action = ActionCreate
+		return pl, nil
+	}
+
+	pl.current = current
+	pl.report.ProductID = current.ID
+	regular := current.RegularPrice(now)
+	from := current
+	from.PriceVATExcluded, from.VAT, from.TotalPrice = regular.PriceVATExcluded, regular.VAT, regular.TotalPrice
+
+	next := from
+	if row.Currency != "" {
+		if regular.TotalPrice.Currency != "" && row.Currency != regular.TotalPrice.Currency {
+			return pl, fmt.Errorf("currency cannot change from %s", regular.TotalPrice.Currency)
+		}
+		price := row.Product()
+		next.PriceVATExcluded, next.VAT, next.TotalPrice = price.PriceVATExcluded, price.VAT, price.TotalPrice
+	}
+	merge(&next.SKU, row.SKU)
+	merge(&next.Name, row.Name)
+	merge(&next.ShortDescription, row.ShortDescription)
+	merge(&next.Description, row.Description)
+	merge(&next.Image, row.Image)
+	if row.CategoryIDs != nil {
+		next.CategoryIDs = row.CategoryIDs
+	}
+	if row.Weight != 0 {
+		next.Weight = row.Weight
+	}
+	err := next.Validate()
+	if err != nil {
+		return pl, err
+	}
+
+	pl.next = next
+	pl.report.Changes = diff(from, next)
+	pl.report.Action = ActionUnchanged
+	for _, c := range pl.report.Changes {
+		pl.report.Action = ActionUpdate
+		switch c.Field {
+		case "priceVatExcluded", "vat", "totalPrice":
+			pl.price = true
+		case "categoryIds":
+			pl.categories = true
+			pl.content = true
+		default:
+			pl.content = true
+		}
+	}
+	return pl, nil
+}
+
+// apply writes the change of a row.
+func (i *Importer) apply(ctx context.Context, pl plan, now time.Time) error {
+	switch pl.report.Action {
+	case ActionCreate:
+		return i.storage.CreateProduct(ctx, pl.next)
+	case ActionUpdate:
+	default:
+		return nil
+	}
+
+	if pl.price {
+		// a new price is a permanent price starting now, as when it is
+		// changed by the admins
+		price := types.ScheduledPrice{
+			ID:               i.uuidGen.Generate(),
+			PriceVATExcluded: pl.next.PriceVATExcluded,
+			VAT:              pl.next.VAT,
+			TotalPrice:       pl.next.TotalPrice,
+			StartsAt:         now,
+		}
+		p := pl.current
+		p.SchedulePrice(price, now)
+		err := i.storage.UpdatePriceSchedule(ctx, p, price.HistoryEntry(p.ID, now))
+		if err != nil {
+			return err
+		}
+	}
+	if !pl.content {
+		return nil
+	}
+
+	input := storage.UpdateProductInput{
+		ProductId:        pl.next.ID,
+		SKU:              pl.next.SKU,
+		Name:             pl.next.Name,
+		Image:            pl.next.Image,
+		ShortDescription: pl.next.ShortDescription,
+		Description:      pl.next.Description,
+	}
+	if pl.next.Weight != pl.current.Weight {
+		input.Weight = &pl.next.Weight
+	}
+	if pl.categories {
+		input.CategoryIDs = pl.next.CategoryIDs
+		if input.CategoryIDs == nil {
+			input.CategoryIDs = []string{}
+		}
+	}
+	return i.storage.UpdateProduct(ctx, input)
+}
+
+// Export returns the rows of the whole catalog sorted by SKU, with the
+// regular prices at now.
+func Export(ctx context.Context, s storage.Storage, now time.Time) ([]Row, error) {
+	products, err := s.Products(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error - fetching products: %w", err)
+	}
+	rows := make([]Row, 0, len(products))
+	for _, p := range products {
+		rows = append(rows, ProductRow(p, now))
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].SKU != rows[j].SKU {
+			return rows[i].SKU < rows[j].SKU
+		}
+		return rows[i].ID < rows[j].ID
+	})
+	return rows, nil
+}
+
+// duplicate records the key of a row, it fails when an earlier row has it.
+func duplicate(seen map[string]int, key string, line int) error {
+	if first, found := seen[key]; found {
+		return fmt.Errorf("%s already imported on line %d", key, first)
+	}
+	seen[key] = line
+	return nil
+}
+
+func merge(field *string, value string) {
+	if value = strings.TrimSpace(value); value != "" {
+		*field = value
+	}
+}
+
+// diff lists the fields of a feed changed between the products.
+func diff(from types.Product, to types.Product) []Change {
+	fields := []struct {
+		name     string
+		from, to interface{}
+	}{
+		{"sku", from.SKU, to.SKU},
+		{"name", from.Name, to.Name},
+		{"shortDescription", from.ShortDescription, to.ShortDescription},
+		{"description", from.Description, to.Description},
+		{"image", from.Image, to.Image},
+		{"priceVatExcluded", from.PriceVATExcluded.Amount, to.PriceVATExcluded.Amount},
+		{"vat", from.VAT.Amount, to.VAT.Amount},
+		{"totalPrice", from.TotalPrice.Amount, to.TotalPrice.Amount},
+		{"categoryIds", nonNil(from.CategoryIDs), nonNil(to.CategoryIDs)},
+		{"weight", from.Weight, to.Weight},
+	}
+	changes := make([]Change, 0)
+	for _, f := range fields {
+		if !reflect.DeepEqual(f.from, f.to) {
+			changes = append(changes, Change{Field: f.name, From: f.from, To: f.to})
+		}
+	}
+	return changes
+}
+
+func nonNil(ids []string) []string {
+	if ids == nil {
+		return []string{}
+	}
+	return ids
+}
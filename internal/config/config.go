@@ -0,0 +1,379 @@
+// Package config loads the configuration of the commands. A value comes from
+// the defaults of the command, then the YAML or JSON file of -config (or
+// CONFIG_FILE), then the environment, then the flags.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	StorageMemory = "memory"
+	StorageDynamo = "dynamo"
+
+	AuthFirebase = "firebase"
+	AuthLocal    = "local"
+
+	SecretsSSM     = "ssm"
+	SecretsManager = "secretsmanager"
+	SecretsEnv     = "env"
+	SecretsFile    = "file"
+
+	EventAuto = "auto"
+	EventREST = "rest"
+	EventHTTP = "http"
+	EventALB  = "alb"
+
+	TracesNone   = "none"
+	TracesStdout = "stdout"
+)
+
+// MaxCartDistinctItems is the largest cart the storage clears or merges in
+// a single DynamoDB transaction
+const MaxCartDistinctItems = 32
+
+type Config struct {
+	Server  Server  `yaml:"server"`
+	Storage Storage `yaml:"storage"`
+	Auth    Auth    `yaml:"auth"`
+	Secrets Secrets `yaml:"secrets"`
+	Images  Images  `yaml:"images"`
+	Reviews Reviews `yaml:"reviews"`
+}
+
+type Server struct {
+	// Addr is the address the local server listens on
+	Addr           string `yaml:"addr"`
+	AllowedOrigins string `yaml:"allowedOrigins"`
+	// PublicURL is the URL the server is reached at
+	PublicURL string `yaml:"publicUrl"`
+	// ShutdownTimeout is the time given to the requests in flight on
+	// shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	// LambdaEvent is the event received by the Lambda: EventREST from an
+	// API Gateway REST API, EventHTTP from an HTTP API, EventALB from a load
+	// balancer, or EventAuto to detect it from each payload
+	LambdaEvent string `yaml:"lambdaEvent"`
+	// LogLevel is the minimum level of the logs: debug, info, warn or error
+	LogLevel string `yaml:"logLevel"`
+	// MetricsNamespace is the CloudWatch namespace of the metrics logged by
+	// the Lambda
+	MetricsNamespace string `yaml:"metricsNamespace"`
+	// TraceExporter exports the spans: TracesStdout writes them as JSON
+	// lines, TracesNone drops them
+	TraceExporter string `yaml:"traceExporter"`
+	// SearchIndexTTL is the time the search index of an instance is kept
+	// before it is reloaded with the writes of the other instances
+	SearchIndexTTL time.Duration `yaml:"searchIndexTtl"`
+}
+
+type Storage struct {
+	// Kind is StorageMemory or StorageDynamo
+	Kind  string `yaml:"kind"`
+	Table string `yaml:"table"`
+	// CartMaxDistinctItems keeps a cart small enough to be cleared or
+	// merged in a single transaction, from 1 to MaxCartDistinctItems
+	CartMaxDistinctItems int `yaml:"cartMaxDistinctItems"`
+}
+
+type Auth struct {
+	// Provider is AuthFirebase, with the credentials kept in the secrets,
+	// or AuthLocal, with tokens signed by Secret
+	Provider string `yaml:"provider"`
+	Secret   string `yaml:"secret"`
+	// CartTokenSecret signs the guest cart tokens of the local provider,
+	// firebase deployments keep it in the secrets
+	CartTokenSecret string `yaml:"cartTokenSecret"`
+}
+
+type Secrets struct {
+	// Provider is SecretsSSM, SecretsManager, SecretsEnv or SecretsFile
+	Provider string `yaml:"provider"`
+	// Name is the secret holding the firebase credentials and the cart
+	// token secret
+	Name string `yaml:"name"`
+	// Dir is the directory of the file provider
+	Dir string `yaml:"dir"`
+	// TTL is the time the secrets are cached for, 0 reads them at each use
+	TTL time.Duration `yaml:"ttl"`
+}
+
+type Images struct {
+	// Bucket is the S3 bucket of the product images
+	Bucket  string `yaml:"bucket"`
+	BaseURL string `yaml:"baseUrl"`
+	// Dir is the local directory of the product images, served by the local
+	// server under /images
+	Dir string `yaml:"dir"`
+}
+
+type Reviews struct {
+	// OpenToAll lets every signed in user review every product, for the
+	// shops without an order system telling who received a product
+	OpenToAll bool `yaml:"openToAll"`
+}
+
+// Defaults returns the configuration of the deployed API.
+func Defaults() Config {
+	return Config{
+		Server: Server{
+			Addr:             ":8080",
+			ShutdownTimeout:  10 * time.Second,
+			LambdaEvent:      EventAuto,
+			LogLevel:         "info",
+			MetricsNamespace: "ecommerce",
+			TraceExporter:    TracesNone,
+			SearchIndexTTL:   5 * time.Minute,
+		},
+		Storage: Storage{
+			Kind:                 StorageDynamo,
+			Table:                "ecommerce-dev",
+			CartMaxDistinctItems: 30,
+		},
+		Auth: Auth{
+			Provider: AuthFirebase,
+		},
+		Secrets: Secrets{
+			Provider: SecretsSSM,
+			TTL:      5 * time.Minute,
+		},
+	}
+}
+
+// Errors are all the problems found while loading a configuration.
+type Errors []error
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "invalid config: " + strings.Join(messages, "; ")
+}
+
+// setting is a value of the configuration with its env var and flag
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	value flag.Value
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"addr", "ADDR", "address the local server listens on", (*stringValue)(&c.Server.Addr)},
+		{"allowed-origin", "ALLOWED_ORIGIN", "origin allowed by CORS", (*stringValue)(&c.Server.AllowedOrigins)},
+		{"public-url", "PUBLIC_URL", "URL the server is reached at", (*stringValue)(&c.Server.PublicURL)},
+		{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "time given to the requests in flight on shutdown", (*durationValue)(&c.Server.ShutdownTimeout)},
+		{"lambda-event", "LAMBDA_EVENT", "event received by the Lambda, auto, rest, http or alb", (*stringValue)(&c.Server.LambdaEvent)},
+		{"log-level", "LOG_LEVEL", "minimum level of the logs, debug, info, warn or error", (*stringValue)(&c.Server.LogLevel)},
+		{"metrics-namespace", "METRICS_NAMESPACE", "CloudWatch namespace of the metrics logged by the Lambda", (*stringValue)(&c.Server.MetricsNamespace)},
+		{"trace-exporter", "TRACE_EXPORTER", "exporter of the spans, none or stdout", (*stringValue)(&c.Server.TraceExporter)},
+		{"search-index-ttl", "SEARCH_INDEX_TTL", "time the search index is kept before it is reloaded from the storage", (*durationValue)(&c.Server.SearchIndexTTL)},
+		{"storage", "STORAGE", "storage of the data, memory or dynamo", (*stringValue)(&c.Storage.Kind)},
+		{"table", "TABLE_NAME", "DynamoDB table of the dynamo storage", (*stringValue)(&c.Storage.Table)},
+		{"cart-max-distinct-items", "CART_MAX_DISTINCT_ITEMS", "maximum number of products in a cart, from 1 to 32", (*intValue)(&c.Storage.CartMaxDistinctItems)},
+		{"auth", "AUTH_PROVIDER", "verifier of the ID tokens, firebase or local", (*stringValue)(&c.Auth.Provider)},
+		{"auth-secret", "AUTH_SECRET", "secret signing the local ID tokens", (*stringValue)(&c.Auth.Secret)},
+		{"cart-token-secret", "CART_TOKEN_SECRET", "secret signing the guest cart tokens of the local auth", (*stringValue)(&c.Auth.CartTokenSecret)},
+		{"secrets-provider", "SECRETS_PROVIDER", "source of the secrets, ssm, secretsmanager, env or file", (*stringValue)(&c.Secrets.Provider)},
+		{"secrets-name", "SECRETS_NAME", "secret holding the firebase credentials and the cart token secret", (*stringValue)(&c.Secrets.Name)},
+		{"secrets-dir", "SECRETS_DIR", "directory of the file secrets provider", (*stringValue)(&c.Secrets.Dir)},
+		{"secrets-ttl", "SECRETS_TTL", "time the secrets are cached for, 0 reads them at each use", (*durationValue)(&c.Secrets.TTL)},
+		{"images-bucket", "IMAGES_BUCKET", "S3 bucket of the product images", (*stringValue)(&c.Images.Bucket)},
+		{"images-base-url", "IMAGES_BASE_URL", "URL the images of the bucket are served from", (*stringValue)(&c.Images.BaseURL)},
+		{"images-dir", "IMAGES_DIR", "local directory of the product images", (*stringValue)(&c.Images.Dir)},
+		{"reviews-open-to-all", "REVIEWS_OPEN_TO_ALL", "let every signed in user review every product, without checking the deliveries", (*boolValue)(&c.Reviews.OpenToAll)},
+	}
+}
+
+// Load registers the settings on flags, parses args and returns the
+// validated configuration. Every problem of the configuration is returned at
+// once in Errors.
+func Load(flags *flag.FlagSet, args []string, defaults Config) (Config, error) {
+	cfg := defaults
+	settings := cfg.settings()
+
+	path := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML or JSON configuration file (env CONFIG_FILE)")
+	values := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		values[s.flag] = flags.String(s.flag, "", fmt.Sprintf("%s (env %s, default %q)", s.usage, s.env, s.value.String()))
+	}
+	err := flags.Parse(args)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var errs Errors
+	if *path != "" {
+		err = cfg.readFile(*path)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, s := range settings {
+		if v, found := os.LookupEnv(s.env); found {
+			err = s.value.Set(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			}
+		}
+	}
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, s := range settings {
+		if set[s.flag] {
+			err = s.value.Set(*values[s.flag])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", s.flag, err))
+			}
+		}
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		errs = append(errs, err.(Errors)...)
+	}
+	if len(errs) > 0 {
+		return Config{}, errs
+	}
+	return cfg, nil
+}
+
+func (c *Config) readFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error - reading the config file: %w", err)
+	}
+	// JSON is valid YAML
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	err = decoder.Decode(c)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error - parsing the config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Level returns the slog level of LogLevel.
+func (s Server) Level() slog.Level {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(s.LogLevel))
+	return level
+}
+
+// Validate returns Errors listing every invalid value, or nil.
+func (c Config) Validate() error {
+	var errs Errors
+	invalid := func(format string, a ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, a...))
+	}
+
+	if c.Server.Addr == "" {
+		invalid("the address is required")
+	}
+	if c.Server.AllowedOrigins == "" {
+		invalid("the allowed origin is required")
+	}
+	if c.Server.PublicURL != "" && !isAbsoluteURL(c.Server.PublicURL) {
+		invalid("the public url %q is not an absolute url", c.Server.PublicURL)
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		invalid("the shutdown timeout must be positive")
+	}
+
+	switch c.Server.LambdaEvent {
+	case EventAuto, EventREST, EventHTTP, EventALB:
+	default:
+		invalid("unknown lambda event %q, expected %s, %s, %s or %s", c.Server.LambdaEvent, EventAuto, EventREST, EventHTTP, EventALB)
+	}
+
+	var level slog.Level
+	if level.UnmarshalText([]byte(c.Server.LogLevel)) != nil {
+		invalid("unknown log level %q, expected debug, info, warn or error", c.Server.LogLevel)
+	}
+	if c.Server.MetricsNamespace == "" {
+		invalid("the metrics namespace is required")
+	}
+	switch c.Server.TraceExporter {
+	case TracesNone, TracesStdout:
+	default:
+		invalid("unknown trace exporter %q, expected %s or %s", c.Server.TraceExporter, TracesNone, TracesStdout)
+	}
+
+	if c.Server.SearchIndexTTL <= 0 {
+		invalid("the search index ttl must be positive")
+	}
+
+	switch c.Storage.Kind {
+	case StorageMemory:
+	case StorageDynamo:
+		if c.Storage.Table == "" {
+			invalid("the dynamo storage needs a table")
+		}
+	default:
+		invalid("unknown storage %q, expected %s or %s", c.Storage.Kind, StorageMemory, StorageDynamo)
+	}
+	if c.Storage.CartMaxDistinctItems < 1 || c.Storage.CartMaxDistinctItems > MaxCartDistinctItems {
+		invalid("the cart max distinct items must be between 1 and %d", MaxCartDistinctItems)
+	}
+
+	switch c.Auth.Provider {
+	case AuthFirebase:
+		if c.Secrets.Name == "" {
+			invalid("the firebase auth needs the secrets name")
+		}
+	case AuthLocal:
+		if c.Auth.Secret == "" {
+			invalid("the local auth needs a secret")
+		}
+	default:
+		invalid("unknown auth provider %q, expected %s or %s", c.Auth.Provider, AuthFirebase, AuthLocal)
+	}
+
+	switch c.Secrets.Provider {
+	case SecretsSSM, SecretsManager, SecretsEnv:
+	case SecretsFile:
+		if c.Secrets.Dir == "" {
+			invalid("the file secrets provider needs a directory")
+		}
+	default:
+		invalid("unknown secrets provider %q, expected %s, %s, %s or %s", c.Secrets.Provider, SecretsSSM, SecretsManager, SecretsEnv, SecretsFile)
+	}
+	if c.Secrets.TTL < 0 {
+		invalid("the secrets ttl can't be negative")
+	}
+
+	if c.Images.Bucket != "" && c.Images.Dir != "" {
+		invalid("the images are either in a bucket or in a directory")
+	}
+	if c.Images.BaseURL != "" && !isAbsoluteURL(c.Images.BaseURL) {
+		invalid("the images base url %q is not an absolute url", c.Images.BaseURL)
+	}
+	if c.Images.Dir != "" && c.Server.PublicURL == "" {
+		invalid("the images directory needs the public url")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func isAbsoluteURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func validDefaults() Config {
+	defaults := Defaults()
+	defaults.Server.AllowedOrigins = "http://localhost:5173"
+	defaults.Secrets.Name = "/ecommerce/dev/secrets"
+	return defaults
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		// when
+		cfg, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, validDefaults())
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, validDefaults(), cfg)
+	})
+
+	t.Run("file then env then flags", func(t *testing.T) {
+		// given
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		content := "storage:\n  table: from-file\n  cartMaxDistinctItems: 10\nserver:\n  shutdownTimeout: 3s\n  addr: :9000\n"
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		t.Setenv("TABLE_NAME", "from-env")
+		t.Setenv("ADDR", ":9001")
+		t.Setenv("REVIEWS_OPEN_TO_ALL", "true")
+
+		// when
+		cfg, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", path, "-addr", ":9002"}, validDefaults())
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, 10, cfg.Storage.CartMaxDistinctItems)
+		assert.Equal(t, 3*time.Second, cfg.Server.ShutdownTimeout)
+		assert.Equal(t, "from-env", cfg.Storage.Table)
+		assert.Equal(t, ":9002", cfg.Server.Addr)
+		assert.True(t, cfg.Reviews.OpenToAll)
+	})
+
+	t.Run("JSON file", func(t *testing.T) {
+		// given
+		path := filepath.Join(t.TempDir(), "config.json")
+		assert.NoError(t, os.WriteFile(path, []byte(`{"auth": {"provider": "local", "secret": "s"}}`), 0o600))
+		t.Setenv("CONFIG_FILE", path)
+
+		// when
+		cfg, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, validDefaults())
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, AuthLocal, cfg.Auth.Provider)
+		assert.Equal(t, "s", cfg.Auth.Secret)
+	})
+
+	t.Run("returns every error", func(t *testing.T) {
+		// given
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		assert.NoError(t, os.WriteFile(path, []byte("storage:\n  tabel: typo\n"), 0o600))
+		t.Setenv("CART_MAX_DISTINCT_ITEMS", "many")
+
+		// when
+		_, err := Load(flag.NewFlagSet("test", flag.ContinueOnError),
+			[]string{"-config", path, "-storage", "sql", "-shutdown-timeout", "soon", "-auth", "local"}, Defaults())
+
+		// then
+		errs, ok := err.(Errors)
+		assert.True(t, ok)
+		assert.Len(t, errs, 6)
+		assert.Contains(t, err.Error(), "tabel")
+		assert.Contains(t, err.Error(), `CART_MAX_DISTINCT_ITEMS: invalid integer "many"`)
+		assert.Contains(t, err.Error(), `-shutdown-timeout: invalid duration "soon"`)
+		assert.Contains(t, err.Error(), "the allowed origin is required")
+		assert.Contains(t, err.Error(), `unknown storage "sql"`)
+		assert.Contains(t, err.Error(), "the local auth needs a secret")
+	})
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		err    string
+	}{
+		{"dynamo needs a table", func(c *Config) { c.Storage.Table = "" }, "the dynamo storage needs a table"},
+		{"memory needs no table", func(c *Config) { c.Storage.Kind, c.Storage.Table = StorageMemory, "" }, ""},
+		{"firebase needs the secrets name", func(c *Config) { c.Secrets.Name = "" }, "the firebase auth needs the secrets name"},
+		{"file secrets need a directory", func(c *Config) { c.Secrets.Provider = SecretsFile }, "the file secrets provider needs a directory"},
+		{"unknown secrets provider", func(c *Config) { c.Secrets.Provider = "vault" }, `unknown secrets provider "vault", expected ssm, secretsmanager, env or file`},
+		{"negative cart limit", func(c *Config) { c.Storage.CartMaxDistinctItems = -1 }, "the cart max distinct items must be between 1 and 32"},
+		{"no cart limit", func(c *Config) { c.Storage.CartMaxDistinctItems = 0 }, "the cart max distinct items must be between 1 and 32"},
+		{"cart limit above a transaction", func(c *Config) { c.Storage.CartMaxDistinctItems = 33 }, "the cart max distinct items must be between 1 and 32"},
+		{"cart limit of a transaction", func(c *Config) { c.Storage.CartMaxDistinctItems = 32 }, ""},
+		{"images in one place", func(c *Config) { c.Images, c.Server.PublicURL = Images{Bucket: "b", Dir: "d"}, "http://localhost" }, "the images are either in a bucket or in a directory"},
+		{"images dir needs the public url", func(c *Config) { c.Images.Dir = "d" }, "the images directory needs the public url"},
+		{"unknown lambda event", func(c *Config) { c.Server.LambdaEvent = "sqs" }, `unknown lambda event "sqs", expected auto, rest, http or alb`},
+		{"unknown log level", func(c *Config) { c.Server.LogLevel = "verbose" }, `unknown log level "verbose", expected debug, info, warn or error`},
+		{"no metrics namespace", func(c *Config) { c.Server.MetricsNamespace = "" }, "the metrics namespace is required"},
+		{"no search index ttl", func(c *Config) { c.Server.SearchIndexTTL = 0 }, "the search index ttl must be positive"},
+		{"unknown trace exporter", func(c *Config) { c.Server.TraceExporter = "jaeger" }, `unknown trace exporter "jaeger", expected none or stdout`},
+		{"relative public url", func(c *Config) { c.Server.PublicURL = "localhost" }, `the public url "localhost" is not an absolute url`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// given
+			cfg := validDefaults()
+			test.modify(&cfg)
+
+			// when
+			err := cfg.Validate()
+
+			// then
+			if test.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, "invalid config: "+test.err)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
+)
+
+// serveALB serves an event of the load balancer. When the target group
+// enables multi value headers the event and the response carry the
+// multi-value fields only.
+func serveALB(ctx context.Context, handler http.Handler, event events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	req, err := albRequest(ctx, event)
+	if err != nil {
+		return events.ALBTargetGroupResponse{}, err
+	}
+
+	w := core.NewProxyResponseWriter()
+	handler.ServeHTTP(http.ResponseWriter(w), req)
+	proxied, err := w.GetProxyResponse()
+	if err != nil {
+		return events.ALBTargetGroupResponse{}, fmt.Errorf("error - converting the response: %w", err)
+	}
+
+	response := events.ALBTargetGroupResponse{
+		StatusCode:        proxied.StatusCode,
+		StatusDescription: fmt.Sprintf("%d %s", proxied.StatusCode, http.StatusText(proxied.StatusCode)),
+		Body:              proxied.Body,
+		IsBase64Encoded:   proxied.IsBase64Encoded,
+	}
+	if multiValue(event) {
+		response.MultiValueHeaders = proxied.MultiValueHeaders
+	} else {
+		response.Headers = make(map[string]string, len(proxied.MultiValueHeaders))
+		for name, values := range proxied.MultiValueHeaders {
+			response.Headers[name] = strings.Join(values, ", ")
+		}
+	}
+	return response, nil
+}
+
+// albTraceID returns the trace ID the load balancer adds to the request.
+func albTraceID(event events.ALBTargetGroupRequest) string {
+	for name, v := range event.Headers {
+		if strings.EqualFold(name, "X-Amzn-Trace-Id") {
+			return v
+		}
+	}
+	for name, values := range event.MultiValueHeaders {
+		if strings.EqualFold(name, "X-Amzn-Trace-Id") && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+func multiValue(event events.ALBTargetGroupRequest) bool {
+	return event.MultiValueHeaders != nil || event.MultiValueQueryStringParameters != nil
+}
+
+func albRequest(ctx context.Context, event events.ALBTargetGroupRequest) (*http.Request, error) {
+	body := []byte(event.Body)
+	if event.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(event.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error - decoding the body: %w", err)
+		}
+		body = decoded
+	}
+
+	headers := http.Header{}
+	query := event.MultiValueQueryStringParameters
+	if multiValue(event) {
+		for name, values := range event.MultiValueHeaders {
+			for _, v := range values {
+				headers.Add(name, v)
+			}
+		}
+	} else {
+		for name, v := range event.Headers {
+			headers.Set(name, v)
+		}
+		query = make(map[string][]string, len(event.QueryStringParameters))
+		for name, v := range event.QueryStringParameters {
+			query[name] = []string{v}
+		}
+	}
+
+	target := event.Path
+	if !strings.HasPrefix(target, "/") {
+		target = "/" + target
+	}
+	if len(query) > 0 {
+		target += "?" + rawQuery(query)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, event.HTTPMethod, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error - converting the ALB event: %w", err)
+	}
+	req.Header = headers
+	req.Host = headers.Get("Host")
+	req.RequestURI = req.URL.RequestURI()
+	if forwarded := headers.Get("X-Forwarded-For"); forwarded != "" {
+		req.RemoteAddr = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return req, nil
+}
+
+// rawQuery joins the parameters, the load balancer passes them as they were
+// sent so they are already escaped.
+func rawQuery(query map[string][]string) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(query))
+	for _, name := range names {
+		for _, v := range query[name] {
+			parts = append(parts, name+"="+v)
+		}
+	}
+	return strings.Join(parts, "&")
+}
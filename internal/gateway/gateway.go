@@ -0,0 +1,127 @@
+// Package gateway adapts the Lambda events of API Gateway and of the
+// Application Load Balancer to an http.Handler.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"pratbacknd/internal/config"
+	"pratbacknd/internal/logging"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
+	"github.com/awslabs/aws-lambda-go-api-proxy/httpadapter"
+)
+
+// Handler is a lambda.Handler serving the events with an http.Handler.
+type Handler struct {
+	event string
+	rest  *httpadapter.HandlerAdapter
+	http  *httpadapter.HandlerAdapterV2
+	alb   http.Handler
+}
+
+// NewHandler serves the events of the kind event with handler, one of the
+// config events. With config.EventAuto the kind of each payload is detected.
+func NewHandler(handler http.Handler, event string) (*Handler, error) {
+	switch event {
+	case config.EventAuto, config.EventREST, config.EventHTTP, config.EventALB:
+	default:
+		return nil, fmt.Errorf("error - unknown lambda event %q", event)
+	}
+	return &Handler{
+		event: event,
+		rest:  httpadapter.New(sourceAddr(handler)),
+		http:  httpadapter.NewV2(sourceAddr(handler)),
+		alb:   handler,
+	}, nil
+}
+
+// sourceAddr makes the address of the client seen by API Gateway the peer
+// address of the request, the load balancer requests get it from
+// X-Forwarded-For.
+func sourceAddr(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rc, ok := core.GetAPIGatewayV2ContextFromContext(r.Context()); ok {
+			r.RemoteAddr = rc.HTTP.SourceIP
+		} else if rc, ok := core.GetAPIGatewayContextFromContext(r.Context()); ok {
+			r.RemoteAddr = rc.Identity.SourceIP
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handler) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	event := h.event
+	if event == config.EventAuto {
+		event = detect(payload)
+	}
+
+	var response interface{}
+	var err error
+	switch event {
+	case config.EventHTTP:
+		var req events.APIGatewayV2HTTPRequest
+		err = json.Unmarshal(payload, &req)
+		if err != nil {
+			return nil, fmt.Errorf("error - unmarshalling the HTTP API event: %w", err)
+		}
+		ctx = withRequestIDs(ctx, req.RequestContext.RequestID)
+		response, err = h.http.ProxyWithContext(ctx, req)
+	case config.EventALB:
+		var req events.ALBTargetGroupRequest
+		err = json.Unmarshal(payload, &req)
+		if err != nil {
+			return nil, fmt.Errorf("error - unmarshalling the ALB event: %w", err)
+		}
+		ctx = withRequestIDs(ctx, albTraceID(req))
+		response, err = serveALB(ctx, h.alb, req)
+	default:
+		var req events.APIGatewayProxyRequest
+		err = json.Unmarshal(payload, &req)
+		if err != nil {
+			return nil, fmt.Errorf("error - unmarshalling the REST API event: %w", err)
+		}
+		ctx = withRequestIDs(ctx, req.RequestContext.RequestID)
+		response, err = h.rest.ProxyWithContext(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(response)
+}
+
+// withRequestIDs puts the IDs of the invocation and of the gateway request
+// in the context, for the logs.
+func withRequestIDs(ctx context.Context, gatewayID string) context.Context {
+	ids := logging.RequestIDs{Gateway: gatewayID}
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		ids.Lambda = lc.AwsRequestID
+	}
+	return logging.WithRequestIDs(ctx, ids)
+}
+
+// detect returns the kind of the event: the load balancer sets the elb
+// context, the HTTP API sets the version 2.0 of its payload format.
+func detect(payload []byte) string {
+	var probe struct {
+		Version        string `json:"version"`
+		RequestContext struct {
+			ELB *json.RawMessage `json:"elb"`
+		} `json:"requestContext"`
+	}
+	err := json.Unmarshal(payload, &probe)
+	switch {
+	case err != nil:
+		return config.EventREST
+	case probe.RequestContext.ELB != nil:
+		return config.EventALB
+	case probe.Version == "2.0":
+		return config.EventHTTP
+	default:
+		return config.EventREST
+	}
+}
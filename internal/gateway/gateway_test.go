@@ -0,0 +1,138 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"pratbacknd/internal/config"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+// echo answers with the request it received
+var echo = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Add("Set-Cookie", "a=1")
+	w.Header().Add("Set-Cookie", "b=2")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, "%s %s %s %s", r.Method, r.URL.RequestURI(), r.Header.Get("Authorization"), body)
+})
+
+func TestHandler_Invoke(t *testing.T) {
+	handler, err := NewHandler(echo, config.EventAuto)
+	assert.NoError(t, err)
+
+	t.Run("REST API", func(t *testing.T) {
+		// given
+		payload := `{"resource": "/products", "path": "/products", "httpMethod": "POST",
+			"headers": {"Authorization": "Bearer t"}, "queryStringParameters": {"lang": "fr"}, "body": "{}"}`
+
+		// when
+		out, err := handler.Invoke(context.Background(), []byte(payload))
+
+		// then
+		assert.NoError(t, err)
+		var response events.APIGatewayProxyResponse
+		assert.NoError(t, json.Unmarshal(out, &response))
+		assert.Equal(t, http.StatusCreated, response.StatusCode)
+		assert.Equal(t, "POST /products?lang=fr Bearer t {}", response.Body)
+	})
+
+	t.Run("HTTP API", func(t *testing.T) {
+		// given
+		payload := `{"version": "2.0", "routeKey": "$default", "rawPath": "/products", "rawQueryString": "lang=fr",
+			"headers": {"authorization": "Bearer t"}, "requestContext": {"http": {"method": "POST", "path": "/products"}},
+			"body": "e30=", "isBase64Encoded": true}`
+
+		// when
+		out, err := handler.Invoke(context.Background(), []byte(payload))
+
+		// then
+		assert.NoError(t, err)
+		var response events.APIGatewayV2HTTPResponse
+		assert.NoError(t, json.Unmarshal(out, &response))
+		assert.Equal(t, http.StatusCreated, response.StatusCode)
+		assert.Equal(t, "POST /products?lang=fr Bearer t {}", response.Body)
+	})
+
+	t.Run("ALB", func(t *testing.T) {
+		// given
+		payload := `{"requestContext": {"elb": {"targetGroupArn": "arn"}}, "httpMethod": "POST", "path": "/products",
+			"queryStringParameters": {"q": "red%20shoes"}, "headers": {"authorization": "Bearer t"}, "body": "{}"}`
+
+		// when
+		out, err := handler.Invoke(context.Background(), []byte(payload))
+
+		// then
+		assert.NoError(t, err)
+		var response events.ALBTargetGroupResponse
+		assert.NoError(t, json.Unmarshal(out, &response))
+		assert.Equal(t, http.StatusCreated, response.StatusCode)
+		assert.Equal(t, "201 Created", response.StatusDescription)
+		assert.Equal(t, "POST /products?q=red%20shoes Bearer t {}", response.Body)
+		assert.Equal(t, "a=1, b=2", response.Headers["Set-Cookie"])
+		assert.Nil(t, response.MultiValueHeaders)
+	})
+
+	t.Run("ALB with multi value headers", func(t *testing.T) {
+		// given
+		payload := `{"requestContext": {"elb": {"targetGroupArn": "arn"}}, "httpMethod": "GET", "path": "/products",
+			"multiValueQueryStringParameters": {"id": ["1", "2"]}, "multiValueHeaders": {"authorization": ["Bearer t"]}}`
+
+		// when
+		out, err := handler.Invoke(context.Background(), []byte(payload))
+
+		// then
+		assert.NoError(t, err)
+		var response events.ALBTargetGroupResponse
+		assert.NoError(t, json.Unmarshal(out, &response))
+		assert.Equal(t, "GET /products?id=1&id=2 Bearer t ", response.Body)
+		assert.Equal(t, []string{"a=1", "b=2"}, response.MultiValueHeaders["Set-Cookie"])
+		assert.Nil(t, response.Headers)
+	})
+
+	t.Run("client address", func(t *testing.T) {
+		// given
+		handler, err := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, r.RemoteAddr)
+		}), config.EventAuto)
+		assert.NoError(t, err)
+		payload := `{"version": "2.0", "rawPath": "/cart", "headers": {"x-forwarded-for": "10.0.0.1, 203.0.113.7"},
+			"requestContext": {"http": {"method": "PUT", "path": "/cart", "sourceIp": "203.0.113.7"}}}`
+
+		// when
+		out, err := handler.Invoke(context.Background(), []byte(payload))
+
+		// then
+		assert.NoError(t, err)
+		var response events.APIGatewayV2HTTPResponse
+		assert.NoError(t, json.Unmarshal(out, &response))
+		assert.Equal(t, "203.0.113.7", response.Body, "the address seen by API Gateway")
+	})
+}
+
+func TestNewHandler(t *testing.T) {
+	t.Run("configured event", func(t *testing.T) {
+		// given
+		handler, err := NewHandler(echo, config.EventREST)
+		assert.NoError(t, err)
+
+		// when
+		out, err := handler.Invoke(context.Background(), []byte(`{"version": "2.0", "path": "/products", "httpMethod": "GET"}`))
+
+		// then
+		assert.NoError(t, err)
+		var response events.APIGatewayProxyResponse
+		assert.NoError(t, json.Unmarshal(out, &response))
+		assert.Equal(t, "GET /products  ", response.Body, "the payload is not detected")
+	})
+
+	t.Run("unknown event", func(t *testing.T) {
+		_, err := NewHandler(echo, "sqs")
+		assert.Error(t, err)
+	})
+}
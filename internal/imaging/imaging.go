@@ -0,0 +1,167 @@
+// Package imaging validates the uploaded product images and builds their
+// renditions.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+
+	// decoders of the accepted formats
+	_ "image/gif"
+	_ "image/png"
+)
+
+// MaxSize is the largest accepted upload, in bytes
+const MaxSize = 5 << 20
+
+// maxPixels protects the decoder against images with huge dimensions
+const maxPixels = 40_000_000
+
+// jpegQuality of the renditions
+const jpegQuality = 85
+
+var (
+	ErrorUnsupportedType = errors.New("image type must be jpeg, png or gif")
+	ErrorTooLarge        = fmt.Errorf("image cannot be larger than %d bytes", MaxSize)
+	ErrorInvalidImage    = errors.New("image cannot be decoded")
+)
+
+// ContentTypes are the accepted image types
+var ContentTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// Rendition is a resized copy of an image, the image fits in a square of
+// Size pixels.
+type Rendition struct {
+	Name string
+	Size int
+}
+
+var Renditions = []Rendition{
+	{Name: "thumbnail", Size: 150},
+	{Name: "medium", Size: 600},
+}
+
+// Info describes a valid image.
+type Info struct {
+	ContentType string
+	Size        int
+	Width       int
+	Height      int
+}
+
+// ValidateUpload checks the type and size announced by a client before an
+// upload.
+func ValidateUpload(contentType string, size int) error {
+	if _, found := ContentTypes[contentType]; !found {
+		return ErrorUnsupportedType
+	}
+	if size <= 0 || size > MaxSize {
+		return ErrorTooLarge
+	}
+	return nil
+}
+
+// Decode checks the actual content of an uploaded image, the type is
+// sniffed from the bytes and not trusted from the client.
+func Decode(body []byte) (image.Image, Info, error) {
+	if len(body) > MaxSize {
+		return nil, Info{}, ErrorTooLarge
+	}
+	contentType := http.DetectContentType(body)
+	if _, found := ContentTypes[contentType]; !found {
+		return nil, Info{}, ErrorUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		return nil, Info{}, ErrorInvalidImage
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, Info{}, ErrorTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		return nil, Info{}, ErrorInvalidImage
+	}
+	return img, Info{
+		ContentType: contentType,
+		Size:        len(body),
+		Width:       config.Width,
+		Height:      config.Height,
+	}, nil
+}
+
+// Render resizes the image to fit in a square of size pixels and encodes it
+// as a jpeg, smaller images are not enlarged.
+func Render(img image.Image, size int) ([]byte, int, int, error) {
+	resized := fit(img, size)
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: jpegQuality})
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("error - encoding the rendition: %w", err)
+	}
+	b := resized.Bounds()
+	return buf.Bytes(), b.Dx(), b.Dy(), nil
+}
+
+// fit downscales the image with a box filter, each pixel of the result is
+// the average of the source pixels it covers. Transparent pixels are
+// blended on white since jpeg has no alpha.
+func fit(img image.Image, size int) image.Image {
+	src := img.Bounds()
+	w, h := src.Dx(), src.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(1, h*size/src.Dx())
+		} else {
+			w, h = max(1, w*size/src.Dy()), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := src.Min.Y + y*src.Dy()/h
+		y1 := max(y0+1, src.Min.Y+(y+1)*src.Dy()/h)
+		for x := 0; x < w; x++ {
+			x0 := src.Min.X + x*src.Dx()/w
+			x1 := max(x0+1, src.Min.X+(x+1)*src.Dx()/w)
+
+			var r, g, b, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					// premultiplied colors over a white background
+					white := 0xffff - uint64(ca)
+					r += uint64(cr) + white
+					g += uint64(cg) + white
+					b += uint64(cb) + white
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: 0xff,
+			})
+		}
+	}
+	return dst
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,60 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateUpload(t *testing.T) {
+	assert.NoError(t, ValidateUpload("image/jpeg", 1000))
+	assert.ErrorIs(t, ValidateUpload("image/webp", 1000), ErrorUnsupportedType)
+	assert.ErrorIs(t, ValidateUpload("image/png", MaxSize+1), ErrorTooLarge)
+	assert.ErrorIs(t, ValidateUpload("image/png", 0), ErrorTooLarge)
+}
+
+func TestDecode(t *testing.T) {
+	t.Run("sniffs the type from the content", func(t *testing.T) {
+		// Given
+		var body bytes.Buffer
+		assert.NoError(t, png.Encode(&body, image.NewRGBA(image.Rect(0, 0, 30, 20))))
+
+		// When
+		_, info, err := Decode(body.Bytes())
+
+		// Then
+		assert.NoError(t, err)
+		assert.Equal(t, Info{ContentType: "image/png", Size: body.Len(), Width: 30, Height: 20}, info)
+	})
+
+	t.Run("refuses other content", func(t *testing.T) {
+		_, _, err := Decode([]byte("<svg></svg>"))
+		assert.ErrorIs(t, err, ErrorUnsupportedType)
+	})
+}
+
+func TestRender(t *testing.T) {
+	t.Run("downscales keeping the ratio", func(t *testing.T) {
+		_, width, height, err := Render(image.NewRGBA(image.Rect(0, 0, 300, 900)), 150)
+		assert.NoError(t, err)
+		assert.Equal(t, 50, width)
+		assert.Equal(t, 150, height)
+	})
+
+	t.Run("does not enlarge small images", func(t *testing.T) {
+		_, width, height, err := Render(image.NewRGBA(image.Rect(0, 0, 40, 20)), 150)
+		assert.NoError(t, err)
+		assert.Equal(t, 40, width)
+		assert.Equal(t, 20, height)
+	})
+
+	t.Run("blends transparency on white", func(t *testing.T) {
+		src := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+		resized := fit(src, 150)
+		assert.Equal(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, resized.At(0, 0))
+	})
+}
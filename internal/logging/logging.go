@@ -0,0 +1,85 @@
+// Package logging writes the structured JSON logs of the API and carries
+// the request logger and the request IDs in the context.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// redacted replaces the values of the sensitive attributes
+const redacted = "[REDACTED]"
+
+// sensitive are the attribute keys holding credentials or personal data,
+// compared in lower case
+var sensitive = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"token":         true,
+	"idtoken":       true,
+	"carttoken":     true,
+	"x-cart-token":  true,
+	"password":      true,
+	"secret":        true,
+	"email":         true,
+	"phone":         true,
+	"address":       true,
+}
+
+// New returns a JSON logger writing to w the records of level and above,
+// with the sensitive attributes redacted.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: Redact,
+	}))
+}
+
+// Redact replaces the value of a sensitive attribute, it is a ReplaceAttr of
+// slog.HandlerOptions.
+func Redact(groups []string, a slog.Attr) slog.Attr {
+	if sensitive[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIDsKey
+)
+
+// RequestIDs identify a request in the logs of AWS.
+type RequestIDs struct {
+	// Lambda is the ID of the Lambda invocation
+	Lambda string
+	// Gateway is the ID given by API Gateway, or the trace ID of the load
+	// balancer
+	Gateway string
+}
+
+func WithRequestIDs(ctx context.Context, ids RequestIDs) context.Context {
+	return context.WithValue(ctx, requestIDsKey, ids)
+}
+
+func RequestIDsFrom(ctx context.Context) RequestIDs {
+	ids, _ := ctx.Value(requestIDsKey).(RequestIDs)
+	return ids
+}
+
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext returns the logger of the request, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,40 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	// given
+	var out bytes.Buffer
+	logger := New(&out, slog.LevelInfo)
+
+	// when
+	logger.Debug("hidden")
+	logger.Info("login", "userId", "u1", "Authorization", "Bearer t", slog.Group("user", "email", "a@b.c"))
+
+	// then
+	var record map[string]interface{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &record), "a single JSON record")
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "u1", record["userId"])
+	assert.Equal(t, redacted, record["Authorization"])
+	assert.Equal(t, map[string]interface{}{"email": redacted}, record["user"])
+}
+
+func TestFromContext(t *testing.T) {
+	// given
+	logger := New(&bytes.Buffer{}, slog.LevelInfo)
+	ctx := WithRequestIDs(WithLogger(context.Background(), logger), RequestIDs{Lambda: "l1", Gateway: "g1"})
+
+	// then
+	assert.Same(t, logger, FromContext(ctx))
+	assert.Same(t, slog.Default(), FromContext(context.Background()))
+	assert.Equal(t, RequestIDs{Lambda: "l1", Gateway: "g1"}, RequestIDsFrom(ctx))
+}
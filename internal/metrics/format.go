@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+)
+
+// maxEMFValues is the number of values CloudWatch accepts for a metric in
+// one EMF record
+const maxEMFValues = 100
+
+// WritePrometheus writes every metric in the Prometheus text format.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	families, err := r.prometheus.Gather()
+	if err != nil {
+		return err
+	}
+	enc := expfmt.NewEncoder(w, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, f := range families {
+		err = enc.Encode(f)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *family) sortedSeries() []*series {
+	sorted := make([]*series, 0, len(f.series))
+	for _, s := range f.series {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		for k, v := range sorted[i].labelValues {
+			if v != sorted[j].labelValues[k] {
+				return v < sorted[j].labelValues[k]
+			}
+		}
+		return false
+	})
+	return sorted
+}
+
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+// WriteEMF writes, one JSON line per series, the counter increments and the
+// histogram observations since the previous call, in the CloudWatch
+// embedded metric format. The labels become the dimensions. Nothing is
+// written unless EnableEMF was called.
+func (r *Registry) WriteEMF(w io.Writer, namespace string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	enc := json.NewEncoder(w)
+	for _, f := range r.families {
+		// a metric without labels has an empty dimension set, not a null one
+		dimensions := append([]string{}, f.labels...)
+		unit := "Count"
+		if strings.HasSuffix(f.name, "_seconds") {
+			unit = "Seconds"
+		}
+		for _, s := range f.sortedSeries() {
+			var values []interface{}
+			if f.kind == kindCounter && s.pendingValue > 0 {
+				values = append(values, s.pendingValue)
+			}
+			for start := 0; start < len(s.pendingObservations); start += maxEMFValues {
+				end := start + maxEMFValues
+				if end > len(s.pendingObservations) {
+					end = len(s.pendingObservations)
+				}
+				values = append(values, s.pendingObservations[start:end])
+			}
+			s.pendingValue, s.pendingObservations = 0, nil
+
+			for _, v := range values {
+				record := map[string]interface{}{
+					"_aws": map[string]interface{}{
+						"Timestamp": now.UnixMilli(),
+						"CloudWatchMetrics": []emfDirective{{
+							Namespace:  namespace,
+							Dimensions: [][]string{dimensions},
+							Metrics:    []emfMetric{{Name: f.name, Unit: unit}},
+						}},
+					},
+					f.name: v,
+				}
+				for i, label := range f.labels {
+					record[label] = s.labelValues[i]
+				}
+				err := enc.Encode(record)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
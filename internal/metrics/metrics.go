@@ -0,0 +1,178 @@
+// Package metrics keeps counters and histograms in a Prometheus registry,
+// exposes them in the Prometheus text format and writes them as CloudWatch
+// embedded metric format (EMF) logs on Lambda, where nothing scrapes the
+// process.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// LatencyBuckets are the upper bounds, in seconds, of the latency
+// histograms.
+var LatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// maxPendingObservations bounds the observations of a series kept between
+// two EMF flushes, the next ones are only counted by Prometheus
+const maxPendingObservations = 10 * maxEMFValues
+
+const (
+	kindCounter   = "counter"
+	kindHistogram = "histogram"
+)
+
+// Registry holds the metrics of the process.
+type Registry struct {
+	prometheus *prometheus.Registry
+
+	mu       sync.Mutex
+	names    map[string]bool
+	families []*family
+	// emf keeps what happened since the last flush for WriteEMF, it is
+	// off unless EnableEMF is called
+	emf bool
+}
+
+// family is what the EMF flush needs of a metric
+type family struct {
+	name   string
+	kind   string
+	labels []string
+	series map[string]*series
+}
+
+// series holds the pending values of a label set for the next EMF flush:
+// the counter increments or the observations of a histogram
+type series struct {
+	labelValues         []string
+	pendingValue        float64
+	pendingObservations []float64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		prometheus: prometheus.NewRegistry(),
+		names:      make(map[string]bool),
+	}
+}
+
+// EnableEMF keeps the counter increments and the histogram observations
+// until WriteEMF is called, which must then be called regularly.
+func (r *Registry) EnableEMF() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.emf = true
+}
+
+// Counter registers a counter with the label names. The label values are
+// given, in the same order, when it is incremented.
+func (r *Registry) Counter(name string, help string, labels ...string) *Counter {
+	vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)
+	return &Counter{registry: r, family: r.register(name, kindCounter, labels, vec), vec: vec}
+}
+
+// Histogram registers a histogram with the bucket upper bounds and the
+// label names.
+func (r *Registry) Histogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	vec := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: sorted}, labels)
+	return &Histogram{registry: r, family: r.register(name, kindHistogram, labels, vec), vec: vec}
+}
+
+func (r *Registry) register(name string, kind string, labels []string, collector prometheus.Collector) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[name] {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.prometheus.MustRegister(collector)
+	r.names[name] = true
+	f := &family{
+		name:   name,
+		kind:   kind,
+		labels: labels,
+		series: make(map[string]*series),
+	}
+	r.families = append(r.families, f)
+	return f
+}
+
+// checkLabels panics on a wrong number of label values, like the rest of
+// the package does on a programming error
+func (f *family) checkLabels(labelValues []string) {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", f.name, len(f.labels), len(labelValues)))
+	}
+}
+
+// seriesOf returns the pending values of the label values, the registry is
+// locked
+func (f *family) seriesOf(labelValues []string) *series {
+	key := strings.Join(labelValues, "\xff")
+	s, found := f.series[key]
+	if !found {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		f.series[key] = s
+	}
+	return s
+}
+
+type Counter struct {
+	registry *Registry
+	family   *family
+	vec      *prometheus.CounterVec
+}
+
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: counter %s can't decrease", c.family.name))
+	}
+	c.family.checkLabels(labelValues)
+	c.vec.WithLabelValues(labelValues...).Add(v)
+
+	c.registry.mu.Lock()
+	defer c.registry.mu.Unlock()
+	if c.registry.emf {
+		c.family.seriesOf(labelValues).pendingValue += v
+	}
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+type Histogram struct {
+	registry *Registry
+	family   *family
+	vec      *prometheus.HistogramVec
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.family.checkLabels(labelValues)
+	h.vec.WithLabelValues(labelValues...).Observe(v)
+
+	h.registry.mu.Lock()
+	defer h.registry.mu.Unlock()
+	if !h.registry.emf {
+		return
+	}
+	s := h.family.seriesOf(labelValues)
+	if len(s.pendingObservations) < maxPendingObservations {
+		s.pendingObservations = append(s.pendingObservations, v)
+	}
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r.prometheus, promhttp.HandlerOpts{})
+}
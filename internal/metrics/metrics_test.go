@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_WritePrometheus(t *testing.T) {
+	// given
+	r := NewRegistry()
+	requests := r.Counter("requests_total", "Requests served.", "route", "status")
+	latency := r.Histogram("latency_seconds", "Latency.", []float64{0.5, 0.1}, "route")
+	requests.Inc("/products", "200")
+	requests.Add(2, "/products/{id}", "404")
+	latency.Observe(0.05, "/products")
+	latency.Observe(0.3, "/products")
+	latency.Observe(2, "/products")
+
+	// when
+	recorder := httptest.NewRecorder()
+	r.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// then
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+	assert.Equal(t, `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/products",le="0.1"} 1
+latency_seconds_bucket{route="/products",le="0.5"} 2
+latency_seconds_bucket{route="/products",le="+Inf"} 3
+latency_seconds_sum{route="/products"} 2.35
+latency_seconds_count{route="/products"} 3
+# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{route="/products",status="200"} 1
+requests_total{route="/products/{id}",status="404"} 2
+`, recorder.Body.String())
+}
+
+func TestRegistry_WriteEMF(t *testing.T) {
+	t.Run("writes what happened since the last flush", func(t *testing.T) {
+		// given
+		r := NewRegistry()
+		r.EnableEMF()
+		requests := r.Counter("requests_total", "Requests served.", "route")
+		latency := r.Histogram("latency_seconds", "Latency.", LatencyBuckets, "route")
+		requests.Inc("/products")
+		latency.Observe(0.2, "/products")
+		now := time.UnixMilli(1700000000000)
+
+		// when
+		var first, second bytes.Buffer
+		assert.NoError(t, r.WriteEMF(&first, "ecommerce", now))
+		assert.NoError(t, r.WriteEMF(&second, "ecommerce", now))
+
+		// then
+		lines := strings.Split(strings.TrimSpace(first.String()), "\n")
+		assert.Len(t, lines, 2)
+		var record map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+		assert.Equal(t, float64(1), record["requests_total"])
+		assert.Equal(t, "/products", record["route"])
+		assert.Equal(t, map[string]interface{}{
+			"Timestamp": float64(1700000000000),
+			"CloudWatchMetrics": []interface{}{map[string]interface{}{
+				"Namespace":  "ecommerce",
+				"Dimensions": []interface{}{[]interface{}{"route"}},
+				"Metrics":    []interface{}{map[string]interface{}{"Name": "requests_total", "Unit": "Count"}},
+			}},
+		}, record["_aws"])
+
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+		assert.Equal(t, []interface{}{0.2}, record["latency_seconds"])
+		assert.Contains(t, lines[1], `"Unit":"Seconds"`)
+
+		assert.Empty(t, second.String(), "nothing happened since the first flush")
+	})
+
+	t.Run("splits the observations in records of 100 values", func(t *testing.T) {
+		// given
+		r := NewRegistry()
+		r.EnableEMF()
+		latency := r.Histogram("latency_seconds", "Latency.", LatencyBuckets)
+		for i := 0; i < 150; i++ {
+			latency.Observe(0.1)
+		}
+
+		// when
+		var out bytes.Buffer
+		assert.NoError(t, r.WriteEMF(&out, "ecommerce", time.Now()))
+
+		// then
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		assert.Len(t, lines, 2)
+		var first, second struct {
+			Values []float64 `json:"latency_seconds"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &second))
+		assert.Len(t, first.Values, 100)
+		assert.Len(t, second.Values, 50)
+		assert.Contains(t, lines[0], `"Dimensions":[[]]`)
+	})
+
+	t.Run("keeps nothing unless enabled", func(t *testing.T) {
+		// given
+		r := NewRegistry()
+		requests := r.Counter("requests_total", "Requests served.")
+		latency := r.Histogram("latency_seconds", "Latency.", LatencyBuckets)
+		requests.Inc()
+		latency.Observe(0.1)
+
+		// when
+		var out bytes.Buffer
+		assert.NoError(t, r.WriteEMF(&out, "ecommerce", time.Now()))
+
+		// then
+		assert.Empty(t, out.String())
+		assert.Empty(t, latency.family.series)
+	})
+
+	t.Run("bounds the observations kept between two flushes", func(t *testing.T) {
+		// given
+		r := NewRegistry()
+		r.EnableEMF()
+		latency := r.Histogram("latency_seconds", "Latency.", LatencyBuckets)
+
+		// when
+		for i := 0; i < maxPendingObservations+10; i++ {
+			latency.Observe(0.1)
+		}
+
+		// then
+		assert.Len(t, latency.family.seriesOf(nil).pendingObservations, maxPendingObservations)
+		recorder := httptest.NewRecorder()
+		r.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		assert.Contains(t, recorder.Body.String(), "latency_seconds_count 1010", "Prometheus counts every observation")
+	})
+}
+
+func TestRegistry_Counter(t *testing.T) {
+	// given
+	r := NewRegistry()
+	c := r.Counter("requests_total", "Requests served.", "route")
+
+	// then
+	assert.Panics(t, func() { c.Inc() }, "the label values are required")
+	assert.Panics(t, func() { c.Add(-1, "/") }, "a counter never decreases")
+	assert.Panics(t, func() { r.Counter("requests_total", "Again.") }, "a name is registered once")
+}
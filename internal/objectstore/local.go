@@ -0,0 +1,189 @@
+package objectstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Local stores the objects in a directory, it is meant for development
+// where the Handler serves the objects and receives the signed uploads.
+type Local struct {
+	dir     string
+	baseURL string
+	secret  []byte
+	now     func() time.Time
+}
+
+// NewLocal stores the objects under dir, they are served from baseURL which
+// must route to Handler. The upload URLs are signed with secret.
+func NewLocal(dir string, baseURL string, secret []byte) (*Local, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("error - creating the object directory: %w", err)
+	}
+	return &Local{
+		dir:     dir,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		secret:  secret,
+		now:     time.Now,
+	}, nil
+}
+
+func (l *Local) PresignPut(_ context.Context, key string, contentType string, size int64, ttl time.Duration) (string, error) {
+	if _, err := l.path(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(l.now().Add(ttl).Unix(), 10)
+	length := strconv.FormatInt(size, 10)
+	q := url.Values{}
+	q.Set("expires", expires)
+	q.Set("contentType", contentType)
+	q.Set("size", length)
+	q.Set("signature", l.sign(key, contentType, length, expires))
+	return l.URL(key) + "?" + q.Encode(), nil
+}
+
+func (l *Local) Get(_ context.Context, key string, maxSize int64) ([]byte, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(p)
+	if err == nil && info.Size() > maxSize {
+		return nil, ErrorTooLarge
+	}
+	body, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrorNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error - reading object %s: %w", key, err)
+	}
+	return body, nil
+}
+
+func (l *Local) Put(_ context.Context, key string, body []byte, contentType string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(p), 0o755)
+	if err != nil {
+		return fmt.Errorf("error - creating directory of object %s: %w", key, err)
+	}
+	err = os.WriteFile(p, body, 0o644)
+	if err != nil {
+		return fmt.Errorf("error - writing object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (l *Local) Delete(_ context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error - deleting object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (l *Local) URL(key string) string {
+	return l.baseURL + "/" + key
+}
+
+// Handler serves the objects on GET and stores the uploads made with a
+// presigned URL on PUT, the object key is the path of the request.
+func (l *Local) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		switch r.Method {
+		case http.MethodGet:
+			body, err := l.Get(r.Context(), key, math.MaxInt64)
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			contentType := mime.TypeByExtension(filepath.Ext(key))
+			if contentType == "" {
+				contentType = http.DetectContentType(body)
+			}
+			w.Header().Set("Content-Type", contentType)
+			_, _ = w.Write(body)
+		case http.MethodPut:
+			size, ok := l.validUpload(key, r)
+			if !ok {
+				http.Error(w, "invalid signature", http.StatusForbidden)
+				return
+			}
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, size))
+			if err != nil || int64(len(body)) != size {
+				http.Error(w, "invalid body", http.StatusBadRequest)
+				return
+			}
+			err = l.Put(r.Context(), key, body, r.Header.Get("Content-Type"))
+			if err != nil {
+				slog.ErrorContext(r.Context(), "storing an upload", "key", key, "error", err)
+				http.Error(w, "error storing object", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// validUpload checks the signature, the expiry, the content type and the
+// length of a presigned upload, it returns the signed size.
+func (l *Local) validUpload(key string, r *http.Request) (int64, bool) {
+	q := r.URL.Query()
+	expires := q.Get("expires")
+	contentType := q.Get("contentType")
+	length := q.Get("size")
+	expected := l.sign(key, contentType, length, expires)
+	if !hmac.Equal([]byte(expected), []byte(q.Get("signature"))) {
+		return 0, false
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || l.now().Unix() > unix {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(length, 10, 64)
+	if err != nil || size != r.ContentLength {
+		return 0, false
+	}
+	return size, r.Header.Get("Content-Type") == contentType
+}
+
+func (l *Local) sign(key string, contentType string, size string, expires string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(key + "\n" + contentType + "\n" + size + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// path maps a key to a file of the directory, keys escaping it are refused.
+func (l *Local) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if key == "" || clean == "/" || strings.TrimPrefix(clean, "/") != key {
+		return "", fmt.Errorf("error - invalid object key %q", key)
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(clean)), nil
+}
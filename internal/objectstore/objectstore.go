@@ -0,0 +1,27 @@
+// Package objectstore stores binary objects such as product images.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrorNotFound = errors.New("object not found")
+	ErrorTooLarge = errors.New("object too large")
+)
+
+// ObjectStore keeps objects under a key and serves them at a public URL.
+type ObjectStore interface {
+	// PresignPut returns a URL the client can PUT an object of the content
+	// type and of exactly size bytes to, until the ttl expires.
+	PresignPut(ctx context.Context, key string, contentType string, size int64, ttl time.Duration) (string, error)
+	// Get reads an object, it returns ErrorTooLarge without reading it when
+	// the object is larger than maxSize bytes.
+	Get(ctx context.Context, key string, maxSize int64) ([]byte, error)
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL of an object.
+	URL(key string) string
+}
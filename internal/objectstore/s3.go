@@ -0,0 +1,108 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3 stores the objects in a bucket, they are served from baseURL (the
+// bucket website or a CDN in front of it).
+type S3 struct {
+	bucket  string
+	baseURL string
+	client  *s3.S3
+}
+
+func NewS3(bucket string, baseURL string) (*S3, error) {
+	awsSession, err := session.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("error - creating aws session: %w", err)
+	}
+	return &S3{
+		bucket:  bucket,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  s3.New(awsSession),
+	}, nil
+}
+
+func (s *S3) PresignPut(ctx context.Context, key string, contentType string, size int64, ttl time.Duration) (string, error) {
+	// the signed content length refuses uploads of another size
+	req, _ := s.client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+	})
+	req.SetContext(ctx)
+	url, err := req.Presign(ttl)
+	if err != nil {
+		return "", fmt.Errorf("error - presigning the upload: %w", err)
+	}
+	return url, nil
+}
+
+func (s *S3) Get(ctx context.Context, key string, maxSize int64) ([]byte, error) {
+	out, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, ErrorNotFound
+		}
+		return nil, fmt.Errorf("error - getting object %s: %w", key, err)
+	}
+	defer out.Body.Close()
+	if aws.Int64Value(out.ContentLength) > maxSize {
+		return nil, ErrorTooLarge
+	}
+
+	// the length is checked again on the bytes read in case it was missing
+	body, err := io.ReadAll(io.LimitReader(out.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error - reading object %s: %w", key, err)
+	}
+	if int64(len(body)) > maxSize {
+		return nil, ErrorTooLarge
+	}
+	return body, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	_, err := s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("error - putting object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("error - deleting object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3) URL(key string) string {
+	return s.baseURL + "/" + key
+}
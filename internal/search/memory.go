@@ -0,0 +1,294 @@
+package search
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"pratbacknd/internal/types"
+)
+
+// defaultLimit is the page size when the query does not set one
+const defaultLimit = 20
+
+// field is a text of the product and the weight of its terms
+type field struct {
+	text   string
+	weight float64
+}
+
+type document struct {
+	categoryIDs []string
+	// prices holds the regular price and the schedule of the product, the
+	// band is computed at query time so that the sales are searchable
+	prices types.Product
+	terms  map[string]float64
+}
+
+// Memory is an in-process SearchIndex, it is safe for concurrent use.
+type Memory struct {
+	mu sync.RWMutex
+	// documents by product id
+	docs map[string]document
+	// postings gives the weight of a term in each product
+	postings map[string]map[string]float64
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		docs:     make(map[string]document),
+		postings: make(map[string]map[string]float64),
+	}
+}
+
+func (m *Memory) Index(p types.Product) error {
+	doc := newDocument(p)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(p.ID)
+	m.add(p.ID, doc)
+	return nil
+}
+
+// Replace indexes the products in place of the indexed ones, searches see
+// either the whole previous index or the whole new one.
+func (m *Memory) Replace(products []types.Product) error {
+	docs := make(map[string]document, len(products))
+	for _, p := range products {
+		docs[p.ID] = newDocument(p)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.docs = make(map[string]document, len(docs))
+	m.postings = make(map[string]map[string]float64)
+	for id, doc := range docs {
+		m.add(id, doc)
+	}
+	return nil
+}
+
+func newDocument(p types.Product) document {
+	doc := document{
+		categoryIDs: p.CategoryIDs,
+		prices:      types.Product{TotalPrice: p.TotalPrice, PriceSchedule: p.PriceSchedule},
+		terms:       make(map[string]float64),
+	}
+	fields := []field{
+		{p.Name, weightName},
+		{p.ShortDescription, weightShortDescription},
+		{p.Description, weightDescription},
+	}
+	// translations are searchable in every locale
+	for _, t := range p.Translations {
+		fields = append(fields,
+			field{t.Name, weightName},
+			field{t.ShortDescription, weightShortDescription},
+			field{t.Description, weightDescription},
+		)
+	}
+	for _, f := range fields {
+		for _, token := range tokenize(f.text) {
+			for _, term := range terms(token) {
+				doc.terms[term] += f.weight
+			}
+		}
+	}
+	return doc
+}
+
+// add indexes the document, the index is locked
+func (m *Memory) add(productID string, doc document) {
+	m.docs[productID] = doc
+	for term, weight := range doc.terms {
+		if m.postings[term] == nil {
+			m.postings[term] = make(map[string]float64)
+		}
+		m.postings[term][productID] = weight
+	}
+}
+
+func (m *Memory) Remove(productID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(productID)
+	return nil
+}
+
+func (m *Memory) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.docs)
+}
+
+// Search matches the products containing every word of the text, exactly
+// or with a few typos.
+func (m *Memory) Search(q Query) (Result, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scores := m.match(tokenize(q.Text))
+
+	result := Result{
+		Hits: make([]Hit, 0),
+		Facets: Facets{
+			Categories: make([]FacetCount, 0),
+			PriceBands: make([]FacetCount, 0),
+		},
+	}
+
+	now := q.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	categories := make(map[string]int)
+	bands := make(map[string]int)
+	for id := range scores {
+		doc := m.docs[id]
+		for _, c := range doc.categoryIDs {
+			categories[c]++
+		}
+		band := PriceBand(doc.prices.TotalPriceAt(now).Amount)
+		bands[band]++
+
+		if q.CategoryID != "" && !contains(doc.categoryIDs, q.CategoryID) {
+			continue
+		}
+		if q.PriceBand != "" && band != q.PriceBand {
+			continue
+		}
+		result.Hits = append(result.Hits, Hit{ProductID: id, Score: scores[id]})
+	}
+
+	sort.Slice(result.Hits, func(i, j int) bool {
+		if result.Hits[i].Score != result.Hits[j].Score {
+			return result.Hits[i].Score > result.Hits[j].Score
+		}
+		return result.Hits[i].ProductID < result.Hits[j].ProductID
+	})
+	result.Total = len(result.Hits)
+	result.Hits = page(result.Hits, q.Offset, q.Limit)
+
+	for c, n := range categories {
+		result.Facets.Categories = append(result.Facets.Categories, FacetCount{Value: c, Count: n})
+	}
+	sort.Slice(result.Facets.Categories, func(i, j int) bool {
+		return result.Facets.Categories[i].Value < result.Facets.Categories[j].Value
+	})
+	for _, b := range priceBands {
+		if bands[b.name] > 0 {
+			result.Facets.PriceBands = append(result.Facets.PriceBands, FacetCount{Value: b.name, Count: bands[b.name]})
+		}
+	}
+
+	return result, nil
+}
+
+// match returns the score of the products matching every token, every
+// product matches an empty text.
+func (m *Memory) match(tokens []string) map[string]float64 {
+	scores := make(map[string]float64)
+	if len(tokens) == 0 {
+		for id := range m.docs {
+			scores[id] = 0
+		}
+		return scores
+	}
+
+	for i, token := range tokens {
+		tokenScores := m.matchToken(token)
+		if i == 0 {
+			scores = tokenScores
+			continue
+		}
+		for id := range scores {
+			if s, found := tokenScores[id]; found {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+	return scores
+}
+
+// matchToken scores the products containing one of the stems of the token,
+// the terms close to the stems are used when no product contains them.
+func (m *Memory) matchToken(token string) map[string]float64 {
+	scores := make(map[string]float64)
+	stems := terms(token)
+	for _, stem := range stems {
+		for id, weight := range m.postings[stem] {
+			if weight > scores[id] {
+				scores[id] = weight
+			}
+		}
+	}
+	if len(scores) > 0 {
+		return scores
+	}
+
+	for _, stem := range stems {
+		max := maxTypos(stem)
+		if max == 0 {
+			continue
+		}
+		for term, postings := range m.postings {
+			if distance(stem, term, max) > max {
+				continue
+			}
+			for id, weight := range postings {
+				if weight*typoPenalty > scores[id] {
+					scores[id] = weight * typoPenalty
+				}
+			}
+		}
+	}
+	return scores
+}
+
+func (m *Memory) remove(productID string) {
+	doc, found := m.docs[productID]
+	if !found {
+		return
+	}
+	for term := range doc.terms {
+		delete(m.postings[term], productID)
+		if len(m.postings[term]) == 0 {
+			delete(m.postings, term)
+		}
+	}
+	delete(m.docs, productID)
+}
+
+func page(hits []Hit, offset int, limit int) []Hit {
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if offset >= len(hits) {
+		return []Hit{}
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	end := offset + limit
+	if end > len(hits) {
+		end = len(hits)
+	}
+	return hits[offset:end]
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"pratbacknd/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testIndex(t *testing.T) *Memory {
+	index := NewMemory()
+	products := []types.Product{
+		{
+			ID:               "1",
+			Name:             "Running shoes",
+			ShortDescription: "Light shoes for trail running",
+			CategoryIDs:      []string{"shoes"},
+			TotalPrice:       types.Money{Amount: 8999, Currency: "EUR"},
+		},
+		{
+			ID:               "2",
+			Name:             "Chaussettes de randonnée",
+			ShortDescription: "Chaussettes chaudes",
+			Description:      "Idéales avec des chaussures de running",
+			CategoryIDs:      []string{"socks"},
+			TotalPrice:       types.Money{Amount: 1299, Currency: "EUR"},
+		},
+		{
+			ID:          "3",
+			Name:        "Water bottle",
+			Description: "Keeps your drinks cold while running",
+			CategoryIDs: []string{"accessories"},
+			TotalPrice:  types.Money{Amount: 1999, Currency: "EUR"},
+		},
+	}
+	for _, p := range products {
+		assert.NoError(t, index.Index(p))
+	}
+	return index
+}
+
+func TestMemory_Search(t *testing.T) {
+	t.Run("ranked by field weight", func(t *testing.T) {
+		// given
+		index := testIndex(t)
+
+		// when
+		result, err := index.Search(Query{Text: "running"})
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, 3, result.Total)
+		assert.Equal(t, "1", result.Hits[0].ProductID, "the name match should rank first")
+	})
+
+	t.Run("english stemming", func(t *testing.T) {
+		index := testIndex(t)
+
+		result, err := index.Search(Query{Text: "shoe"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Total)
+		assert.Equal(t, "1", result.Hits[0].ProductID)
+	})
+
+	t.Run("french stemming and accents", func(t *testing.T) {
+		index := testIndex(t)
+
+		result, err := index.Search(Query{Text: "chaussette randonnee"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Total)
+		assert.Equal(t, "2", result.Hits[0].ProductID)
+	})
+
+	t.Run("typo tolerance", func(t *testing.T) {
+		index := testIndex(t)
+
+		result, err := index.Search(Query{Text: "botle"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Total)
+		assert.Equal(t, "3", result.Hits[0].ProductID)
+	})
+
+	t.Run("facets and filters", func(t *testing.T) {
+		index := testIndex(t)
+
+		result, err := index.Search(Query{Text: "running", PriceBand: "10-25"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, result.Total)
+		assert.Equal(t, []FacetCount{{Value: "accessories", Count: 1}, {Value: "shoes", Count: 1}, {Value: "socks", Count: 1}}, result.Facets.Categories)
+		assert.Equal(t, []FacetCount{{Value: "10-25", Count: 2}, {Value: "50-100", Count: 1}}, result.Facets.PriceBands)
+	})
+
+	t.Run("bands apply the price of the query time", func(t *testing.T) {
+		index := NewMemory()
+		start := time.Date(2026, 11, 27, 0, 0, 0, 0, time.UTC)
+		end := start.Add(72 * time.Hour)
+		assert.NoError(t, index.Index(types.Product{
+			ID:         "1",
+			Name:       "Running shoes",
+			TotalPrice: types.Money{Amount: 8999, Currency: "EUR"},
+			PriceSchedule: []types.ScheduledPrice{
+				{ID: "sale", TotalPrice: types.Money{Amount: 4499, Currency: "EUR"}, StartsAt: start, EndsAt: &end},
+			},
+		}))
+
+		for _, c := range []struct {
+			now  time.Time
+			band string
+		}{
+			{start.Add(-time.Hour), "50-100"},
+			{start.Add(time.Hour), "25-50"},
+			{end, "50-100"},
+		} {
+			result, err := index.Search(Query{Text: "running", PriceBand: c.band, Now: c.now})
+
+			assert.NoError(t, err)
+			assert.Equal(t, 1, result.Total, "at %s", c.now)
+			assert.Equal(t, []FacetCount{{Value: c.band, Count: 1}}, result.Facets.PriceBands, "at %s", c.now)
+		}
+	})
+
+	t.Run("updated and removed products", func(t *testing.T) {
+		index := testIndex(t)
+
+		assert.NoError(t, index.Index(types.Product{ID: "1", Name: "Sandals"}))
+		assert.NoError(t, index.Remove("3"))
+		result, err := index.Search(Query{Text: "running"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Total)
+		assert.Equal(t, "2", result.Hits[0].ProductID)
+		assert.Equal(t, 2, index.Len())
+	})
+	t.Run("replaced catalog", func(t *testing.T) {
+		index := testIndex(t)
+
+		assert.NoError(t, index.Replace([]types.Product{{ID: "4", Name: "Running cap"}}))
+		result, err := index.Search(Query{Text: "running"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Total)
+		assert.Equal(t, "4", result.Hits[0].ProductID)
+		assert.Equal(t, 1, index.Len())
+	})
+}
@@ -0,0 +1,91 @@
+// Package search indexes the catalog for full-text product search.
+package search
+
+import (
+	"time"
+
+	"pratbacknd/internal/types"
+)
+
+// SearchIndex is a full-text index of the products.
+type SearchIndex interface {
+	// Index adds the product to the index or replaces it.
+	Index(p types.Product) error
+	// Remove drops the product from the index.
+	Remove(productID string) error
+	// Replace indexes exactly the products, dropping the others.
+	Replace(products []types.Product) error
+	// Search returns the products matching the query, best first.
+	Search(q Query) (Result, error)
+	// Len returns the number of indexed products.
+	Len() int
+}
+
+type Query struct {
+	Text string
+	// filters, ignored when empty
+	CategoryID string
+	PriceBand  string
+	// Now picks the scheduled prices the bands apply to, the current time
+	// when zero
+	Now time.Time
+	// pagination
+	Offset int
+	Limit  int
+}
+
+type Hit struct {
+	ProductID string  `json:"productId"`
+	Score     float64 `json:"score"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type Facets struct {
+	Categories []FacetCount `json:"categories"`
+	PriceBands []FacetCount `json:"priceBands"`
+}
+
+// Result is a page of hits, the facets and the total count are computed on
+// every matching product.
+type Result struct {
+	Total  int    `json:"total"`
+	Hits   []Hit  `json:"hits"`
+	Facets Facets `json:"facets"`
+}
+
+// field weights in the score of a product
+const (
+	weightName             = 3.0
+	weightShortDescription = 2.0
+	weightDescription      = 1.0
+	// a term found with a typo scores less than an exact one
+	typoPenalty = 0.5
+)
+
+// priceBand is a range of total price in cents, 0 means no upper bound.
+type priceBand struct {
+	name string
+	upTo int64
+}
+
+var priceBands = []priceBand{
+	{name: "0-10", upTo: 1000},
+	{name: "10-25", upTo: 2500},
+	{name: "25-50", upTo: 5000},
+	{name: "50-100", upTo: 10000},
+	{name: "100+", upTo: 0},
+}
+
+// PriceBand returns the band of a total price in cents.
+func PriceBand(amount int64) string {
+	for _, b := range priceBands {
+		if b.upTo == 0 || amount < b.upTo {
+			return b.name
+		}
+	}
+	return ""
+}
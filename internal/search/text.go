@@ -0,0 +1,165 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minTermLength drops the terms too short to be meaningful
+const minTermLength = 2
+
+var accents = map[rune]rune{
+	'à': 'a', 'â': 'a', 'ä': 'a', 'á': 'a', 'ã': 'a',
+	'ç': 'c',
+	'é': 'e', 'è': 'e', 'ê': 'e', 'ë': 'e',
+	'î': 'i', 'ï': 'i', 'í': 'i', 'ì': 'i',
+	'ô': 'o', 'ö': 'o', 'ó': 'o', 'ò': 'o', 'õ': 'o',
+	'ù': 'u', 'û': 'u', 'ü': 'u', 'ú': 'u',
+	'ÿ': 'y', 'ñ': 'n',
+}
+
+var stopWords = map[string]bool{
+	// english
+	"the": true, "and": true, "for": true, "with": true, "of": true, "in": true, "on": true, "to": true, "an": true,
+	// french
+	"le": true, "la": true, "les": true, "de": true, "des": true, "du": true, "un": true, "une": true, "et": true,
+	"en": true, "au": true, "aux": true, "pour": true, "avec": true, "sur": true,
+}
+
+// tokenize splits a text into lower case words without accents, stop words
+// are dropped.
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := make([]string, 0, len(words))
+	for _, w := range words {
+		w = strings.Map(func(r rune) rune {
+			if plain, found := accents[r]; found {
+				return plain
+			}
+			return r
+		}, w)
+		if len(w) < minTermLength || stopWords[w] {
+			continue
+		}
+		tokens = append(tokens, w)
+	}
+	return tokens
+}
+
+// terms returns the stems of a token in the supported languages.
+func terms(token string) []string {
+	en, fr := stemEnglish(token), stemFrench(token)
+	if en == fr {
+		return []string{en}
+	}
+	return []string{en, fr}
+}
+
+// stemEnglish strips the common english inflections.
+func stemEnglish(w string) string {
+	switch {
+	case len(w) > 4 && strings.HasSuffix(w, "ies"):
+		return w[:len(w)-3] + "y"
+	case len(w) > 5 && strings.HasSuffix(w, "ing"):
+		return w[:len(w)-3]
+	case len(w) > 4 && strings.HasSuffix(w, "ed"):
+		return w[:len(w)-2]
+	case len(w) > 4 && strings.HasSuffix(w, "ly"):
+		return w[:len(w)-2]
+	case len(w) > 4 && (strings.HasSuffix(w, "ches") || strings.HasSuffix(w, "shes") ||
+		strings.HasSuffix(w, "xes") || strings.HasSuffix(w, "sses")):
+		return w[:len(w)-2]
+	case len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && !strings.HasSuffix(w, "us"):
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+// frenchSuffixes are tried in order, the longest first.
+var frenchSuffixes = []struct {
+	suffix, replacement string
+}{
+	{"issements", ""}, {"issement", ""},
+	{"ations", ""}, {"ation", ""},
+	{"ements", ""}, {"ement", ""},
+	{"euses", "eu"}, {"euse", "eu"}, {"eux", "eu"},
+	{"eaux", "eau"},
+	{"aux", "al"},
+	{"ees", ""}, {"ee", ""},
+	{"es", ""},
+	{"s", ""}, {"x", ""},
+	{"e", ""},
+}
+
+// stemFrench strips the common french inflections, the stem keeps at least
+// three letters.
+func stemFrench(w string) string {
+	for _, s := range frenchSuffixes {
+		if strings.HasSuffix(w, s.suffix) && len(w)-len(s.suffix)+len(s.replacement) >= 3 {
+			return w[:len(w)-len(s.suffix)] + s.replacement
+		}
+	}
+	return w
+}
+
+// maxTypos is the edit distance tolerated for a term of the given length.
+func maxTypos(term string) int {
+	switch {
+	case len(term) >= 8:
+		return 2
+	case len(term) >= 4:
+		return 1
+	}
+	return 0
+}
+
+// distance is the Levenshtein distance between two terms, it stops as soon
+// as it exceeds max.
+func distance(a, b string, max int) int {
+	if abs(len(a)-len(b)) > max {
+		return max + 1
+	}
+
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			rowMin = minInt(rowMin, current[j])
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,102 @@
+package secret
+
+import (
+	"context"
+	"pratbacknd/internal/logging"
+	"sync"
+	"time"
+)
+
+const (
+	// readTimeout bounds a read of the provider, which does not depend on
+	// the context of the callers
+	readTimeout = 10 * time.Second
+	// retryAfter is the time the cached value is served after the provider
+	// failed, before the secret is read again
+	retryAfter = 5 * time.Second
+)
+
+// Cache keeps the secrets of a provider in memory and reads them again
+// once their TTL expired, so rotated secrets are picked up without a
+// restart. The last value is kept while the provider fails.
+type Cache struct {
+	provider SecretsProvider
+	ttl      time.Duration
+	now      func() time.Time
+
+	// mu guards the maps, it is not held while the provider is read
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	// the reads in flight by secret name, shared by the concurrent callers
+	reads map[string]*cacheRead
+}
+
+type cacheEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+type cacheRead struct {
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+func NewCache(provider SecretsProvider, ttl time.Duration) *Cache {
+	return &Cache{
+		provider: provider,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[string]cacheEntry),
+		reads:    make(map[string]*cacheRead),
+	}
+}
+
+func (c *Cache) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	c.mu.Lock()
+	entry, found := c.entries[name]
+	if found && c.now().Before(entry.expiresAt) {
+		c.mu.Unlock()
+		return entry.value, nil
+	}
+	read, reading := c.reads[name]
+	if !reading {
+		read = &cacheRead{done: make(chan struct{})}
+		c.reads[name] = read
+		go c.refresh(ctx, name, read, entry, found)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-read.done:
+		return read.value, read.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// refresh reads the secret for every caller waiting on the read, so a
+// caller giving up does not cancel it. When the provider fails the cached
+// value is kept until the read is retried, shortly after.
+func (c *Cache) refresh(ctx context.Context, name string, read *cacheRead, entry cacheEntry, found bool) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), readTimeout)
+	defer cancel()
+	value, err := c.provider.GetSecret(ctx, name)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer close(read.done)
+	delete(c.reads, name)
+	ttl := c.ttl
+	if err != nil {
+		if !found {
+			read.err = err
+			return
+		}
+		logging.FromContext(ctx).Error("refreshing a secret, keeping the cached value", "secret", name, "error", err)
+		value = entry.value
+		ttl = min(ttl, retryAfter)
+	}
+	c.entries[name] = cacheEntry{value: value, expiresAt: c.now().Add(ttl)}
+	read.value = value
+}
@@ -0,0 +1,37 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Env reads the secrets from the environment. The variable of a secret is
+// its name in upper case with the other characters than letters and digits
+// replaced by _, e.g. /ecommerce/dev/secrets is ECOMMERCE_DEV_SECRETS.
+type Env struct{}
+
+func (Env) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	variable := envVariable(name)
+	value, found := os.LookupEnv(variable)
+	if !found {
+		return nil, fmt.Errorf("error - no variable %s: %w", variable, ErrorNotFound)
+	}
+	return []byte(value), nil
+}
+
+// envVariable returns the variable of the secret name.
+func envVariable(name string) string {
+	variable := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	return strings.Trim(variable, "_")
+}
@@ -0,0 +1,33 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// File reads the secrets from the files of a directory, e.g. mounted by a
+// container runtime. The name of a secret is its path in the directory.
+type File struct {
+	dir string
+}
+
+func NewFile(dir string) File {
+	return File{dir: dir}
+}
+
+func (f File) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	// the name can't escape the directory
+	path := filepath.Join(f.dir, filepath.FromSlash(filepath.Clean("/"+name)))
+	value, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("error - no file %s: %w", path, ErrorNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error - reading the secret file: %w", err)
+	}
+	return value, nil
+}
@@ -1,5 +1,20 @@
 package secret
 
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"pratbacknd/internal/config"
+)
+
+var ErrorNotFound = errors.New("secret not found")
+
+// SecretsProvider returns the value of a secret from its name.
+type SecretsProvider interface {
+	GetSecret(ctx context.Context, name string) ([]byte, error)
+}
+
 type Parameters struct {
 	Google struct {
 		Type                    string `json:"type"`
@@ -13,4 +28,53 @@ type Parameters struct {
 		AuthProviderX509CertUrl string `json:"auth_provider_x509_cert_url"`
 		ClientX509CertUrl       string `json:"client_x509_cert_url"`
 	} `json:"google"`
+	CartTokenSecret string `json:"cart_token_secret"`
+	// PreviousCartTokenSecret is the secret replaced by the last rotation,
+	// the tokens it signed are accepted until they expire
+	PreviousCartTokenSecret string `json:"previous_cart_token_secret"`
+}
+
+// ParseParameters decodes the JSON secret holding the Parameters.
+func ParseParameters(value []byte) (Parameters, error) {
+	var p Parameters
+	err := json.Unmarshal(value, &p)
+	if err != nil {
+		return Parameters{}, fmt.Errorf("error - unmarshalling secrets: %w", err)
+	}
+	return p, nil
+}
+
+// LoadParameters reads the Parameters kept in the secret name.
+func LoadParameters(ctx context.Context, provider SecretsProvider, name string) (Parameters, error) {
+	value, err := provider.GetSecret(ctx, name)
+	if err != nil {
+		return Parameters{}, err
+	}
+	return ParseParameters(value)
+}
+
+// New returns the provider selected by the config, cached for the TTL of
+// the config.
+func New(cfg config.Secrets) (SecretsProvider, error) {
+	var provider SecretsProvider
+	var err error
+	switch cfg.Provider {
+	case config.SecretsSSM:
+		provider, err = NewSSM()
+	case config.SecretsManager:
+		provider, err = NewSecretsManager()
+	case config.SecretsEnv:
+		provider = Env{}
+	case config.SecretsFile:
+		provider = NewFile(cfg.Dir)
+	default:
+		err = fmt.Errorf("error - unknown secrets provider %q", cfg.Provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TTL > 0 {
+		provider = NewCache(provider, cfg.TTL)
+	}
+	return provider, nil
 }
@@ -0,0 +1,186 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProvider returns the values of its map and counts the reads
+type fakeProvider struct {
+	values map[string]string
+	err    error
+	reads  int
+}
+
+func (f *fakeProvider) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	f.reads++
+	if f.err != nil {
+		return nil, f.err
+	}
+	value, found := f.values[name]
+	if !found {
+		return nil, ErrorNotFound
+	}
+	return []byte(value), nil
+}
+
+func TestCache(t *testing.T) {
+	// given
+	provider := &fakeProvider{values: map[string]string{"secret": "v1"}}
+	cache := NewCache(provider, time.Minute)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	t.Run("reads the provider once per ttl", func(t *testing.T) {
+		// when
+		first, err1 := cache.GetSecret(ctx, "secret")
+		provider.values["secret"] = "v2"
+		second, err2 := cache.GetSecret(ctx, "secret")
+
+		// then
+		assert.NoError(t, err1)
+		assert.NoError(t, err2)
+		assert.Equal(t, "v1", string(first))
+		assert.Equal(t, "v1", string(second))
+		assert.Equal(t, 1, provider.reads)
+	})
+
+	t.Run("picks up the rotated value", func(t *testing.T) {
+		// given
+		now = now.Add(time.Minute)
+
+		// when
+		value, err := cache.GetSecret(ctx, "secret")
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, "v2", string(value))
+		assert.Equal(t, 2, provider.reads)
+	})
+
+	t.Run("keeps the value while the provider fails", func(t *testing.T) {
+		// given
+		now = now.Add(time.Minute)
+		provider.err = errors.New("throttled")
+
+		// when
+		value, err := cache.GetSecret(ctx, "secret")
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, "v2", string(value))
+		_, err = cache.GetSecret(ctx, "other")
+		assert.Error(t, err, "nothing to keep")
+	})
+
+	t.Run("reads again shortly after a failure", func(t *testing.T) {
+		// given
+		provider.err = nil
+		provider.values["secret"] = "v3"
+		reads := provider.reads
+		now = now.Add(retryAfter)
+
+		// when
+		value, err := cache.GetSecret(ctx, "secret")
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, "v3", string(value))
+		assert.Equal(t, reads+1, provider.reads)
+	})
+
+	t.Run("the read outlives the caller giving up", func(t *testing.T) {
+		// given a provider blocked on a read of a caller giving up
+		blocked := &blockingProvider{values: map[string]string{"slow": "v1"}, release: make(chan struct{}), started: make(chan struct{})}
+		cache := NewCache(blocked, time.Minute)
+		canceled, cancel := context.WithCancel(ctx)
+		errs := make(chan error)
+		go func() {
+			_, err := cache.GetSecret(canceled, "slow")
+			errs <- err
+		}()
+		<-blocked.started
+		cancel()
+		assert.ErrorIs(t, <-errs, context.Canceled)
+
+		// when
+		close(blocked.release)
+		value, err := cache.GetSecret(ctx, "slow")
+
+		// then
+		assert.NoError(t, err, "the read is not canceled")
+		assert.Equal(t, "v1", string(value))
+	})
+
+	t.Run("the secrets are served while one is read", func(t *testing.T) {
+		// given a provider blocked on a read
+		blocked := &blockingProvider{values: map[string]string{"slow": "v1", "fast": "v1"}, release: make(chan struct{}), started: make(chan struct{})}
+		cache := NewCache(blocked, time.Minute)
+		go func() { _, _ = cache.GetSecret(ctx, "slow") }()
+		<-blocked.started
+
+		// when
+		value, err := cache.GetSecret(ctx, "fast")
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", string(value))
+		close(blocked.release)
+	})
+}
+
+// blockingProvider blocks the reads of the "slow" secret until released
+type blockingProvider struct {
+	values  map[string]string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingProvider) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	if name == "slow" {
+		close(b.started)
+		<-b.release
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return []byte(b.values[name]), nil
+}
+
+func TestEnv(t *testing.T) {
+	// given
+	t.Setenv("ECOMMERCE_DEV_SECRETS", `{"cart_token_secret": "s"}`)
+
+	// when
+	parameters, err := LoadParameters(context.Background(), Env{}, "/ecommerce/dev/secrets")
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "s", parameters.CartTokenSecret)
+	_, err = Env{}.GetSecret(context.Background(), "/ecommerce/prod/secrets")
+	assert.ErrorIs(t, err, ErrorNotFound)
+}
+
+func TestFile(t *testing.T) {
+	// given
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "ecommerce"), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "ecommerce", "secrets"), []byte("value"), 0o600))
+	file := NewFile(dir)
+
+	// when
+	value, err := file.GetSecret(context.Background(), "/ecommerce/secrets")
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "value", string(value))
+	_, err = file.GetSecret(context.Background(), "../../etc/passwd")
+	assert.ErrorIs(t, err, ErrorNotFound, "the name stays in the directory")
+}
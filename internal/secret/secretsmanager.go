@@ -0,0 +1,44 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+)
+
+// SecretsManager reads the current version of the secrets of AWS Secrets
+// Manager, the name of a secret is its name or ARN.
+type SecretsManager struct {
+	client secretsmanageriface.SecretsManagerAPI
+}
+
+func NewSecretsManager() (*SecretsManager, error) {
+	awsSession, err := session.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("error - creating aws session: %w", err)
+	}
+	return &SecretsManager{client: secretsmanager.New(awsSession)}, nil
+}
+
+func (s *SecretsManager) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	out, err := s.client.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == secretsmanager.ErrCodeResourceNotFoundException {
+			return nil, fmt.Errorf("error - no secret %s: %w", name, ErrorNotFound)
+		}
+		return nil, fmt.Errorf("error - accessing Secrets Manager: %w", err)
+	}
+	if out.SecretString != nil {
+		return []byte(*out.SecretString), nil
+	}
+	return out.SecretBinary, nil
+}
@@ -0,0 +1,42 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// SSM reads the secrets from the SSM parameter store, the name of a secret
+// is the name of its parameter.
+type SSM struct {
+	client ssmiface.SSMAPI
+}
+
+func NewSSM() (*SSM, error) {
+	awsSession, err := session.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("error - creating aws session: %w", err)
+	}
+	return &SSM{client: ssm.New(awsSession)}, nil
+}
+
+func (s *SSM) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	out, err := s.client.GetParameterWithContext(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == ssm.ErrCodeParameterNotFound {
+			return nil, fmt.Errorf("error - no parameter %s: %w", name, ErrorNotFound)
+		}
+		return nil, fmt.Errorf("error - accessing SSM: %w", err)
+	}
+	return []byte(aws.StringValue(out.Parameter.Value)), nil
+}
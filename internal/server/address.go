@@ -0,0 +1,146 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"pratbacknd/internal/storage"
+	"pratbacknd/internal/types"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (s Server) AddressesUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	addresses, err := s.storage.Addresses(r.Context(), currentUser.ID)
+	if err != nil {
+		logger(r).Error("fetching addresses", "error", err)
+		s.errorJSON(w, errors.New("error fetching addresses"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, addresses)
+}
+
+func (s Server) AddressByIDUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	a, err := s.storage.GetAddress(r.Context(), currentUser.ID, chi.URLParam(r, "addressId"))
+	if err != nil {
+		s.addressError(w, r, err, "error getting the address")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, a)
+}
+
+func (s Server) CreateAddressUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	var a types.Address
+	err = s.readJSON(w, r, &a)
+	if err != nil {
+		logger(r).Warn("building json", "error", err)
+		s.errorJSON(w, errors.New("error reading address"), http.StatusBadRequest)
+		return
+	}
+
+	a.Normalize()
+	err = a.Validate()
+	if err != nil {
+		s.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	a.ID = s.uuidGen.Generate()
+	a.CreatedAt = time.Now().UTC()
+
+	a, err = s.storage.CreateAddress(r.Context(), currentUser.ID, a)
+	if err != nil {
+		s.addressError(w, r, err, "error persisting address")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, a)
+}
+
+func (s Server) UpdateAddressUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	var a types.Address
+	err = s.readJSON(w, r, &a)
+	if err != nil {
+		logger(r).Warn("building json", "error", err)
+		s.errorJSON(w, errors.New("error reading address"), http.StatusBadRequest)
+		return
+	}
+
+	a.ID = chi.URLParam(r, "addressId")
+	a.Normalize()
+	err = a.Validate()
+	if err != nil {
+		s.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	a, err = s.storage.UpdateAddress(r.Context(), currentUser.ID, a)
+	if err != nil {
+		s.addressError(w, r, err, "error updating the address")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, a)
+}
+
+func (s Server) DeleteAddressUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	err = s.storage.DeleteAddress(r.Context(), currentUser.ID, chi.URLParam(r, "addressId"))
+	if err != nil {
+		s.addressError(w, r, err, "error deleting the address")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, nil)
+}
+
+// addressError answers 404 for an unknown address, 422 when the address book
+// is full and 500 otherwise.
+func (s Server) addressError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	if errors.Is(err, storage.ErrorNotFound) {
+		s.errorJSON(w, errors.New("address not found"), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, storage.ErrorAddressBookFull) {
+		s.errorJSON(w, storage.ErrorAddressBookFull, http.StatusUnprocessableEntity)
+		return
+	}
+	logger(r).Error(message, "error", err)
+	s.errorJSON(w, errors.New(message), http.StatusInternalServerError)
+}
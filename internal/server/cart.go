@@ -2,10 +2,12 @@ package server
 
 import (
 	"errors"
-	"log"
 	"net/http"
 	"pratbacknd/internal/storage"
 	"pratbacknd/internal/types"
+	"time"
+
+	"github.com/go-chi/chi/v5"
 )
 
 func (s Server) GetCartUser(w http.ResponseWriter, r *http.Request) {
@@ -14,44 +16,173 @@ func (s Server) GetCartUser(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
-	cart, err := s.storage.GetCart(user.ID)
+
+	cart, err := s.storage.GetCart(r.Context(), user.ID)
 	if err != nil {
 		if errors.Is(err, storage.ErrorNotFound) {
 			w.WriteHeader(http.StatusNotFound)
 			return
 		}
-		log.Printf("error retreiving the cart %s", err)
+		logger(r).Error("retreiving the cart", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	s.writeJSON(w, http.StatusOK, cart)
+	// the cart is still useful without suggestions
+	cart.Suggestions, err = s.cartSuggestions(r.Context(), cart, s.preferences(r).Locale, time.Now())
+	if err != nil {
+		logger(r).Error("suggesting products", "error", err)
+	}
+
+	s.writeCart(w, r, cart)
 }
 
 func (s Server) UpdateCartUser(w http.ResponseWriter, r *http.Request) {
-	var input types.UpdateUserCartInput
-	err := s.readJSON(w, r, &input)
+	currentUser, err := s.currentUser(w, r)
 	if err != nil {
-		log.Printf("error - reading json: %s \n", err)
-		s.errorJSON(w, errors.New("error reading userCart"), http.StatusBadRequest)
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	cart, ok := s.updateCart(w, r, currentUser.ID)
+	if ok {
+		s.writeCart(w, r, cart)
+	}
+}
+
+func (s Server) ClearCartUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	cart, err := s.storage.ClearCart(r.Context(), currentUser.ID)
+	if err != nil {
+		s.cartError(w, r, err, "error clearing the cart")
 		return
 	}
 
+	s.writeCart(w, r, cart)
+}
+
+func (s Server) RemoveCartItemUser(w http.ResponseWriter, r *http.Request) {
 	currentUser, err := s.currentUser(w, r)
 	if err != nil {
-		log.Printf("error - retreiving current user: %s \n", err)
-		s.errorJSON(w, nil, http.StatusForbidden)
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	productId := chi.URLParam(r, "productId")
+	if productId == "" {
+		s.errorJSON(w, errors.New("error productId is mondatory"), http.StatusBadRequest)
+		return
+	}
+
+	cart, err := s.storage.RemoveCartItem(r.Context(), currentUser.ID, productId)
+	if err != nil {
+		s.cartError(w, r, err, "error removing the item from the cart")
+		return
+	}
+
+	s.writeCart(w, r, cart)
+}
+
+func (s Server) SetCartItemQuantityUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	productId := chi.URLParam(r, "productId")
+	if productId == "" {
+		s.errorJSON(w, errors.New("error productId is mondatory"), http.StatusBadRequest)
+		return
+	}
+
+	var input types.SetCartItemQuantityInput
+	err = s.readJSON(w, r, &input)
+	if err != nil {
+		logger(r).Warn("reading json", "error", err)
+		s.errorJSON(w, errors.New("error reading quantity"), http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("---> cart Input: %+v", input)
+	if input.Quantity < 0 {
+		s.errorJSON(w, errors.New("error quantity cannot be less than zero"), http.StatusBadRequest)
+		return
+	}
+
+	cart, err := s.storage.SetCartItemQuantity(r.Context(), currentUser.ID, productId, input.Quantity)
+	if err != nil {
+		s.cartError(w, r, err, "error updating the cart")
+		return
+	}
+
+	s.writeCart(w, r, cart)
+}
+
+// cartError answers 422 when a quantity limit is reached or the currency of
+// a product isn't the one of the cart, 404 when the cart or the item does
+// not exist and 500 otherwise.
+func (s Server) cartError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	var limitErr *types.LimitError
+	if errors.As(err, &limitErr) {
+		s.writeJSON(w, http.StatusUnprocessableEntity, JSONResponse{
+			Error:   true,
+			Message: limitErr.Error(),
+			Data:    limitErr,
+		})
+		return
+	}
+	if errors.Is(err, types.ErrorCurrencyMismatch) {
+		s.errorJSON(w, types.ErrorCurrencyMismatch, http.StatusUnprocessableEntity)
+		return
+	}
+	if errors.Is(err, storage.ErrorNotFound) {
+		s.errorJSON(w, errors.New("cart or item not found"), http.StatusNotFound)
+		return
+	}
+	logger(r).Error(message, "error", err)
+	s.errorJSON(w, errors.New(message), http.StatusInternalServerError)
+}
 
-	cartUpdate, err := s.storage.CreateOrUpdateCart(currentUser.ID, input.ProductID, input.Delta)
+func (s Server) getCart(w http.ResponseWriter, r *http.Request, cartID string) {
+	cart, err := s.storage.GetCart(r.Context(), cartID)
 	if err != nil {
-		log.Printf("error - updating cart: %s \n", err)
-		s.errorJSON(w, nil, http.StatusInternalServerError)
+		if errors.Is(err, storage.ErrorNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		logger(r).Error("retreiving the cart", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	s.writeJSON(w, http.StatusOK, cartUpdate)
+	s.writeCart(w, r, cart)
+}
+
+// updateCart applies the update of the request body to the cart, the error
+// is written and false returned when it fails.
+func (s Server) updateCart(w http.ResponseWriter, r *http.Request, cartID string) (types.Cart, bool) {
+	var input types.UpdateUserCartInput
+	err := s.readJSON(w, r, &input)
+	if err != nil {
+		logger(r).Warn("reading json", "error", err)
+		s.errorJSON(w, errors.New("error reading userCart"), http.StatusBadRequest)
+		return types.Cart{}, false
+	}
+
+	cartUpdate, err := s.storage.CreateOrUpdateCart(r.Context(), cartID, input.ProductID, input.Delta)
+	if err != nil {
+		s.cartError(w, r, err, "error updating the cart")
+		return types.Cart{}, false
+	}
+
+	return cartUpdate, true
 }
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"pratbacknd/internal/secret"
+)
+
+// CartTokenSecrets gives the secrets of the guest cart tokens at each use,
+// so that a rotated secret is picked up without a restart.
+type CartTokenSecrets interface {
+	// CartTokenSecrets returns the current secret, which signs the tokens,
+	// and the previous one still accepted during a rotation, if any. Guest
+	// carts are disabled while the current secret is empty.
+	CartTokenSecrets(ctx context.Context) (current []byte, previous []byte, err error)
+}
+
+// StaticCartTokenSecret is a secret that never rotates.
+type StaticCartTokenSecret []byte
+
+func (s StaticCartTokenSecret) CartTokenSecrets(ctx context.Context) ([]byte, []byte, error) {
+	return s, nil, nil
+}
+
+// ParametersCartTokenSecrets reads the cart token secrets from the
+// Parameters of the secret name, a cached provider keeps it cheap.
+type ParametersCartTokenSecrets struct {
+	secrets secret.SecretsProvider
+	name    string
+}
+
+// NewParametersCartTokenSecrets fails when the secrets can't be read.
+func NewParametersCartTokenSecrets(ctx context.Context, secrets secret.SecretsProvider, name string) (*ParametersCartTokenSecrets, error) {
+	p := &ParametersCartTokenSecrets{secrets: secrets, name: name}
+	_, _, err := p.CartTokenSecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *ParametersCartTokenSecrets) CartTokenSecrets(ctx context.Context) ([]byte, []byte, error) {
+	parameters, err := secret.LoadParameters(ctx, p.secrets, p.name)
+	if err != nil {
+		return nil, nil, err
+	}
+	var previous []byte
+	if parameters.PreviousCartTokenSecret != "" {
+		previous = []byte(parameters.PreviousCartTokenSecret)
+	}
+	return []byte(parameters.CartTokenSecret), previous, nil
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"pratbacknd/internal/catalog"
+	"time"
+)
+
+// maxCatalogSize is the largest feed accepted by the import
+const maxCatalogSize = 10 * 1024 * 1024
+
+// ImportProducts upserts the products of a CSV or NDJSON feed, nothing is
+// written when a row is invalid or with ?dryRun=true.
+func (s *Server) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	format := catalogFormat(r, r.Header.Get("Content-Type"))
+	r.Body = http.MaxBytesReader(w, r.Body, maxCatalogSize)
+
+	rows, err := catalog.Decode(r.Body, format)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.errorJSON(w, errors.New("the feed is too large"), http.StatusRequestEntityTooLarge)
+			return
+		}
+		logger(r).Error("reading the feed", "error", err)
+		s.errorJSON(w, errors.New("error reading the feed"), http.StatusBadRequest)
+		return
+	}
+
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+	report, err := catalog.NewImporter(s.storage, s.uuidGen).Import(r.Context(), rows, dryRun, time.Now())
+	if !dryRun {
+		for _, row := range report.Rows {
+			if row.Applied {
+				s.reindexProduct(r, row.ProductID)
+			}
+		}
+	}
+	if err != nil {
+		logger(r).Error("importing the products", "error", err)
+		s.writeJSON(w, http.StatusInternalServerError, JSONResponse{
+			Error:   true,
+			Message: "error importing the products",
+			Data:    report,
+		})
+		return
+	}
+	if report.Failed > 0 {
+		s.writeJSON(w, http.StatusUnprocessableEntity, JSONResponse{
+			Error:   true,
+			Message: "invalid rows, no product was imported",
+			Data:    report,
+		})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, report)
+}
+
+// ExportProducts returns the catalog in the format of the imports.
+func (s *Server) ExportProducts(w http.ResponseWriter, r *http.Request) {
+	format := catalogFormat(r, r.Header.Get("Accept"))
+
+	rows, err := catalog.Export(r.Context(), s.storage, time.Now())
+	if err != nil {
+		logger(r).Error("exporting the products", "error", err)
+		s.errorJSON(w, errors.New("error exporting the products"), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", catalog.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="products.`+format+`"`)
+	w.WriteHeader(http.StatusOK)
+	err = catalog.Encode(w, format, rows)
+	if err != nil {
+		logger(r).Error("writing the export", "error", err)
+	}
+}
+
+// catalogFormat is the ?format of the request, or the format of the media
+// type otherwise.
+func catalogFormat(r *http.Request, mediaType string) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return catalog.FormatOf(format)
+	}
+	return catalog.FormatOf(mediaType)
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"pratbacknd/internal/logging"
+	"pratbacknd/internal/secret"
+	"sync"
+
+	firebase "firebase.google.com/go"
+	"firebase.google.com/go/auth"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/option"
+)
+
+// FirebaseTokenVerifier verifies the ID tokens with firebase. The
+// credentials are read from the secrets at each verification, a cached
+// provider keeps it cheap, and the firebase client is rebuilt when they are
+// rotated.
+type FirebaseTokenVerifier struct {
+	secrets   secret.SecretsProvider
+	name      string
+	newClient func(ctx context.Context, parameters secret.Parameters) (TokenVerifier, error)
+
+	mu     sync.Mutex
+	value  []byte
+	client TokenVerifier
+}
+
+// NewFirebaseTokenVerifier builds the firebase client from the Parameters
+// of the secret name, it fails when they can't be read.
+func NewFirebaseTokenVerifier(ctx context.Context, secrets secret.SecretsProvider, name string) (*FirebaseTokenVerifier, error) {
+	v := &FirebaseTokenVerifier{
+		secrets:   secrets,
+		name:      name,
+		newClient: newFirebaseClient,
+	}
+	_, err := v.currentClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (v *FirebaseTokenVerifier) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "firebase.VerifyIDToken", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	client, err := v.currentClient(ctx)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	token, err := client.VerifyIDToken(ctx, idToken)
+	if err != nil {
+		// an invalid token is an answer of firebase, not a failure
+		span.SetAttributes(attribute.Bool("auth.rejected", true))
+	}
+	return token, err
+}
+
+// currentClient returns the client of the current credentials, the previous
+// client is kept when the secrets can't be read.
+func (v *FirebaseTokenVerifier) currentClient(ctx context.Context) (TokenVerifier, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	value, err := v.secrets.GetSecret(ctx, v.name)
+	if err != nil {
+		if v.client == nil {
+			return nil, fmt.Errorf("error - reading the firebase credentials: %w", err)
+		}
+		logging.FromContext(ctx).Error("reading the firebase credentials, keeping the current ones", "error", err)
+		return v.client, nil
+	}
+	if v.client != nil && bytes.Equal(value, v.value) {
+		return v.client, nil
+	}
+
+	parameters, err := secret.ParseParameters(value)
+	if err == nil {
+		var client TokenVerifier
+		client, err = v.newClient(ctx, parameters)
+		if err == nil {
+			v.value, v.client = value, client
+			return client, nil
+		}
+	}
+	if v.client == nil {
+		return nil, fmt.Errorf("error - building the firebase client: %w", err)
+	}
+	logging.FromContext(ctx).Error("building the firebase client, keeping the current one", "error", err)
+	return v.client, nil
+}
+
+func newFirebaseClient(ctx context.Context, parameters secret.Parameters) (TokenVerifier, error) {
+	credentials, err := json.Marshal(parameters.Google)
+	if err != nil {
+		return nil, fmt.Errorf("error - marshalling Google secrets: %w", err)
+	}
+	app, err := firebase.NewApp(ctx, nil, option.WithCredentialsJSON(credentials))
+	if err != nil {
+		return nil, fmt.Errorf("error - creating firebase app: %w", err)
+	}
+	return app.Auth(ctx)
+}
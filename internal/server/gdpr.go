@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"pratbacknd/internal/storage"
+	"pratbacknd/internal/types"
+	"time"
+)
+
+// ExportUser answers a subject access request with every personal data held
+// on the user as a JSON attachment.
+func (s Server) ExportUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	export, err := s.userExport(r.Context(), currentUser.ID)
+	if err != nil {
+		logger(r).Error("exporting the user data", "error", err)
+		s.errorJSON(w, errors.New("error exporting the user data"), http.StatusInternalServerError)
+		return
+	}
+
+	headers := http.Header{}
+	headers.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="export-%s.json"`, currentUser.ID))
+	s.writeJSON(w, http.StatusOK, export, headers)
+}
+
+// DeleteUser answers an erasure request: the cart reservations are released
+// and the personal data of the user are removed.
+func (s Server) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	err = s.storage.DeleteUserData(r.Context(), currentUser.ID)
+	if err != nil {
+		logger(r).Error("deleting the user data", "error", err)
+		s.errorJSON(w, errors.New("error deleting the user data"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, nil)
+}
+
+func (s Server) userExport(ctx context.Context, userID string) (types.UserExport, error) {
+	export := types.UserExport{
+		ExportedAt: time.Now().UTC(),
+		UserID:     userID,
+	}
+
+	profile, err := s.storage.GetProfile(ctx, userID)
+	if err != nil && !errors.Is(err, storage.ErrorNotFound) {
+		return types.UserExport{}, fmt.Errorf("error - getting the profile: %w", err)
+	}
+	if err == nil {
+		export.Profile = &profile
+	}
+
+	export.Addresses, err = s.storage.Addresses(ctx, userID)
+	if err != nil {
+		return types.UserExport{}, fmt.Errorf("error - getting the addresses: %w", err)
+	}
+
+	cart, err := s.storage.GetCart(ctx, userID)
+	if err != nil && !errors.Is(err, storage.ErrorNotFound) {
+		return types.UserExport{}, fmt.Errorf("error - getting the cart: %w", err)
+	}
+	if err == nil {
+		export.Cart = &cart
+	}
+
+	export.Wishlist, err = s.storage.Wishlist(ctx, userID)
+	if err != nil {
+		return types.UserExport{}, fmt.Errorf("error - getting the wishlist: %w", err)
+	}
+
+	export.Reviews, err = s.storage.UserReviews(ctx, userID)
+	if err != nil {
+		return types.UserExport{}, fmt.Errorf("error - getting the reviews: %w", err)
+	}
+
+	return export, nil
+}
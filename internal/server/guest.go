@@ -0,0 +1,256 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cartTokenHeader = "X-Cart-Token"
+	cartTokenCookie = "cart_token"
+	guestCartPrefix = "guest#"
+	// cartTokenMaxAge is also the time a guest cart is kept after its last
+	// update, its token can't be used anymore
+	cartTokenMaxAge = 30 * 24 * time.Hour
+)
+
+// a client address creates at most maxGuestCartsPerWindow guest carts per
+// guestCartWindow, each one holds stock until it expires
+const (
+	maxGuestCartsPerWindow = 20
+	guestCartWindow        = time.Hour
+)
+
+// guestCartLimiter counts the guest carts created by each client address in
+// the current window, the counts are dropped when the window ends.
+type guestCartLimiter struct {
+	mu       sync.Mutex
+	windowAt time.Time
+	counts   map[string]int
+}
+
+// allow counts a guest cart created by the client, it returns false once
+// the client reached the limit of the window.
+func (l *guestCartLimiter) allow(client string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.counts == nil || now.Sub(l.windowAt) >= guestCartWindow {
+		l.windowAt = now
+		l.counts = make(map[string]int)
+	}
+	if l.counts[client] >= maxGuestCartsPerWindow {
+		return false
+	}
+	l.counts[client]++
+	return true
+}
+
+// clientAddr returns the address of the client without its port.
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ReleaseExpiredGuestCarts deletes the guest carts whose token expired and
+// releases the stock they hold, it returns the number of carts deleted.
+func (s *Server) ReleaseExpiredGuestCarts(ctx context.Context) (int, error) {
+	return s.storage.ReleaseExpiredCarts(ctx, guestCartPrefix, time.Now().Add(-cartTokenMaxAge))
+}
+
+// signCartToken builds the token given to a visitor: the guest cart id and
+// the unix time the token expires at, followed by their HMAC-SHA256
+// signature.
+func signCartToken(secret []byte, guestID string, expiresAt time.Time) string {
+	payload := guestID + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyCartToken returns the guest cart id carried by a valid token that
+// hasn't expired, signed by the current or the previous secret.
+func (s *Server) verifyCartToken(ctx context.Context, token string) (string, error) {
+	if s.cartTokenSecrets == nil {
+		return "", errors.New("no cart token secret configured")
+	}
+
+	splits := strings.Split(token, ".")
+	if len(splits) != 3 || splits[0] == "" {
+		return "", errors.New("malformed cart token")
+	}
+	expiresAt, err := strconv.ParseInt(splits[1], 10, 64)
+	if err != nil {
+		return "", errors.New("malformed cart token expiry")
+	}
+
+	current, previous, err := s.cartTokenSecrets.CartTokenSecrets(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error - reading the cart token secrets: %w", err)
+	}
+	valid := false
+	for _, secret := range [][]byte{current, previous} {
+		if len(secret) > 0 && hmac.Equal([]byte(signCartToken(secret, splits[0], time.Unix(expiresAt, 0))), []byte(token)) {
+			valid = true
+		}
+	}
+	if !valid {
+		return "", errors.New("invalid cart token signature")
+	}
+	if time.Now().After(time.Unix(expiresAt, 0)) {
+		return "", errors.New("expired cart token")
+	}
+
+	return splits[0], nil
+}
+
+// cartToken reads the cart token from the header or, if absent, the cookie.
+func cartToken(r *http.Request) string {
+	if token := r.Header.Get(cartTokenHeader); token != "" {
+		return token
+	}
+	cookie, err := r.Cookie(cartTokenCookie)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// guestID returns the guest id carried by the request, an empty string if
+// the request carries no valid cart token.
+func (s *Server) guestID(r *http.Request) string {
+	token := cartToken(r)
+	if token == "" {
+		return ""
+	}
+
+	guestID, err := s.verifyCartToken(r.Context(), token)
+	if err != nil {
+		logger(r).Warn("verifying cart token", "error", err)
+		return ""
+	}
+
+	return guestID
+}
+
+// guestCartID returns the id of the guest cart bound to the request, an
+// empty string if the request carries no valid cart token.
+func (s *Server) guestCartID(r *http.Request) string {
+	guestID := s.guestID(r)
+	if guestID == "" {
+		return ""
+	}
+	return guestCartPrefix + guestID
+}
+
+// issueCartToken binds the guest cart to the response, the token expires
+// cartTokenMaxAge after its last update.
+func (s *Server) issueCartToken(w http.ResponseWriter, secret []byte, guestID string) {
+	token := signCartToken(secret, guestID, time.Now().Add(cartTokenMaxAge))
+
+	w.Header().Set(cartTokenHeader, token)
+	http.SetCookie(w, &http.Cookie{
+		Name:     cartTokenCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(cartTokenMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+}
+
+func clearCartToken(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     cartTokenCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+}
+
+func (s *Server) GetGuestCart(w http.ResponseWriter, r *http.Request) {
+	cartID := s.guestCartID(r)
+	if cartID == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	s.getCart(w, r, cartID)
+}
+
+func (s *Server) UpdateGuestCart(w http.ResponseWriter, r *http.Request) {
+	if s.cartTokenSecrets == nil {
+		s.errorJSON(w, errors.New("guest carts are disabled"), http.StatusNotFound)
+		return
+	}
+	// the secret is read before the cart is written to always issue a token
+	secret, _, err := s.cartTokenSecrets.CartTokenSecrets(r.Context())
+	if err != nil {
+		logger(r).Error("reading the cart token secrets", "error", err)
+		s.errorJSON(w, errors.New("error updating the cart"), http.StatusInternalServerError)
+		return
+	}
+	if len(secret) == 0 {
+		s.errorJSON(w, errors.New("guest carts are disabled"), http.StatusNotFound)
+		return
+	}
+
+	guestID := s.guestID(r)
+	if guestID == "" {
+		if !s.guestCarts.allow(clientAddr(r), time.Now()) {
+			logger(r).Warn("too many guest carts", "client", clientAddr(r))
+			s.errorJSON(w, errors.New("too many guest carts, retry later"), http.StatusTooManyRequests)
+			return
+		}
+		guestID = s.uuidGen.Generate()
+	}
+
+	cart, ok := s.updateCart(w, r, guestCartPrefix+guestID)
+	if !ok {
+		return
+	}
+	// the token is issued, or its expiry extended, once the cart is written
+	s.issueCartToken(w, secret, guestID)
+	s.writeCart(w, r, cart)
+}
+
+func (s *Server) MergeGuestCart(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	cartID := s.guestCartID(r)
+	if cartID == "" {
+		s.errorJSON(w, errors.New("a valid cart token is mandatory"), http.StatusBadRequest)
+		return
+	}
+
+	cart, err := s.storage.MergeCarts(r.Context(), cartID, currentUser.ID)
+	if err != nil {
+		s.cartError(w, r, err, "error merging the guest cart")
+		return
+	}
+
+	clearCartToken(w)
+	s.writeCart(w, r, cart)
+}
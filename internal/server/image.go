@@ -0,0 +1,290 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"pratbacknd/internal/imaging"
+	"pratbacknd/internal/objectstore"
+	"pratbacknd/internal/storage"
+	"pratbacknd/internal/types"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// uploadTTL is the validity of a presigned upload URL
+const uploadTTL = 15 * time.Minute
+
+// imageKeyPrefix is the folder of the images of a product, an image and
+// its renditions share the folder named after the image id.
+func imageKeyPrefix(productID string) string {
+	return "products/" + productID + "/"
+}
+
+func (s *Server) CreateImageUpload(w http.ResponseWriter, r *http.Request) {
+	if s.objectStore == nil {
+		s.errorJSON(w, errors.New("image uploads are not enabled"), http.StatusNotImplemented)
+		return
+	}
+
+	var input types.ImageUploadInput
+	err := s.readJSON(w, r, &input)
+	if err != nil {
+		logger(r).Warn("reading json", "error", err)
+		s.errorJSON(w, errors.New("error reading upload"), http.StatusBadRequest)
+		return
+	}
+
+	err = imaging.ValidateUpload(input.ContentType, input.Size)
+	if err != nil {
+		s.errorJSON(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	p, ok := s.productFromURL(w, r)
+	if !ok {
+		return
+	}
+	if len(p.Images) >= types.MaxProductImages {
+		s.errorJSON(w, fmt.Errorf("a product cannot have more than %d images", types.MaxProductImages), http.StatusUnprocessableEntity)
+		return
+	}
+
+	key := imageKeyPrefix(p.ID) + s.uuidGen.Generate() + "/original." + imaging.ContentTypes[input.ContentType]
+	url, err := s.objectStore.PresignPut(r.Context(), key, input.ContentType, int64(input.Size), uploadTTL)
+	if err != nil {
+		logger(r).Error("presigning the upload", "error", err)
+		s.errorJSON(w, errors.New("error creating the upload"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, types.ImageUpload{
+		Key:       key,
+		UploadURL: url,
+		Method:    http.MethodPut,
+		Headers:   map[string]string{"Content-Type": input.ContentType},
+	})
+}
+
+// AddProductImage validates an uploaded image, builds its renditions and
+// appends it to the gallery of the product.
+func (s *Server) AddProductImage(w http.ResponseWriter, r *http.Request) {
+	if s.objectStore == nil {
+		s.errorJSON(w, errors.New("image uploads are not enabled"), http.StatusNotImplemented)
+		return
+	}
+
+	var input types.AddImageInput
+	err := s.readJSON(w, r, &input)
+	if err != nil {
+		logger(r).Warn("reading json", "error", err)
+		s.errorJSON(w, errors.New("error reading image"), http.StatusBadRequest)
+		return
+	}
+	err = input.Validate()
+	if err != nil {
+		s.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	p, ok := s.productFromURL(w, r)
+	if !ok {
+		return
+	}
+	imageID, ok := imageIDFromKey(p.ID, input.Key)
+	if !ok {
+		s.errorJSON(w, errors.New("key does not belong to the product"), http.StatusBadRequest)
+		return
+	}
+	for _, img := range p.Images {
+		if img.ID == imageID {
+			s.errorJSON(w, errors.New("image already added"), http.StatusConflict)
+			return
+		}
+	}
+	if len(p.Images) >= types.MaxProductImages {
+		s.errorJSON(w, fmt.Errorf("a product cannot have more than %d images", types.MaxProductImages), http.StatusUnprocessableEntity)
+		return
+	}
+
+	body, err := s.objectStore.Get(r.Context(), input.Key, imaging.MaxSize)
+	if err != nil {
+		if errors.Is(err, objectstore.ErrorNotFound) {
+			s.errorJSON(w, errors.New("image was not uploaded"), http.StatusUnprocessableEntity)
+			return
+		}
+		if errors.Is(err, objectstore.ErrorTooLarge) {
+			s.deleteObjects(r, input.Key)
+			s.errorJSON(w, imaging.ErrorTooLarge, http.StatusUnprocessableEntity)
+			return
+		}
+		logger(r).Error("getting the upload", "error", err)
+		s.errorJSON(w, errors.New("error adding the image"), http.StatusInternalServerError)
+		return
+	}
+
+	img, info, err := imaging.Decode(body)
+	if err != nil {
+		// the upload is useless, the client must upload a valid image again
+		s.deleteObjects(r, input.Key)
+		s.errorJSON(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	image := types.ProductImage{
+		ID:          imageID,
+		Key:         input.Key,
+		URL:         s.objectStore.URL(input.Key),
+		Alt:         input.Alt,
+		ContentType: info.ContentType,
+		Size:        info.Size,
+		Width:       info.Width,
+		Height:      info.Height,
+		Renditions:  make(map[string]types.ImageRendition, len(imaging.Renditions)),
+	}
+	for _, rendition := range imaging.Renditions {
+		out, width, height, err := imaging.Render(img, rendition.Size)
+		if err == nil {
+			key := imageKeyPrefix(p.ID) + imageID + "/" + rendition.Name + ".jpg"
+			err = s.objectStore.Put(r.Context(), key, out, "image/jpeg")
+			image.Renditions[rendition.Name] = types.ImageRendition{
+				Key:    key,
+				URL:    s.objectStore.URL(key),
+				Width:  width,
+				Height: height,
+			}
+		}
+		if err != nil {
+			logger(r).Error("building a rendition", "rendition", rendition.Name, "error", err)
+			s.errorJSON(w, errors.New("error adding the image"), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	p.Images = append(p.Images, image)
+	s.saveImages(w, r, p)
+}
+
+// UpdateProductImages reorders the gallery and updates the alt texts.
+func (s *Server) UpdateProductImages(w http.ResponseWriter, r *http.Request) {
+	var order []types.ImageOrder
+	err := s.readJSON(w, r, &order)
+	if err != nil {
+		logger(r).Warn("reading json", "error", err)
+		s.errorJSON(w, errors.New("error reading images"), http.StatusBadRequest)
+		return
+	}
+
+	p, ok := s.productFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	p.Images, err = types.Reorder(p.Images, order)
+	if err != nil {
+		s.errorJSON(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+	s.saveImages(w, r, p)
+}
+
+func (s *Server) DeleteProductImage(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.productFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	imageID := chi.URLParam(r, "imageId")
+	images := make([]types.ProductImage, 0, len(p.Images))
+	var removed *types.ProductImage
+	for i := range p.Images {
+		if p.Images[i].ID == imageID {
+			removed = &p.Images[i]
+			continue
+		}
+		images = append(images, p.Images[i])
+	}
+	if removed == nil {
+		s.errorJSON(w, errors.New("image not found"), http.StatusNotFound)
+		return
+	}
+
+	p.Images = images
+	if !s.saveImages(w, r, p) {
+		return
+	}
+
+	// the gallery no longer references the objects, a failure only leaves
+	// orphan files behind
+	if s.objectStore != nil {
+		keys := []string{removed.Key}
+		for _, rendition := range removed.Renditions {
+			keys = append(keys, rendition.Key)
+		}
+		s.deleteObjects(r, keys...)
+	}
+}
+
+// productFromURL loads the product of the request path, it writes the
+// error response and returns false on failure.
+func (s *Server) productFromURL(w http.ResponseWriter, r *http.Request) (types.Product, bool) {
+	p, err := s.storage.GetProductById(r.Context(), chi.URLParam(r, "productId"))
+	if err != nil {
+		if errors.Is(err, storage.ErrorNotFound) {
+			s.errorJSON(w, errors.New("product not found"), http.StatusNotFound)
+			return types.Product{}, false
+		}
+		logger(r).Error("getting the product", "error", err)
+		s.errorJSON(w, errors.New("error getting the product"), http.StatusInternalServerError)
+		return types.Product{}, false
+	}
+	return p, true
+}
+
+// saveImages stores the gallery and writes the updated product.
+func (s *Server) saveImages(w http.ResponseWriter, r *http.Request, p types.Product) bool {
+	err := s.storage.UpdateProductImages(r.Context(), p.ID, p.Version, p.Images)
+	if err != nil {
+		if errors.Is(err, storage.ErrorProductChanged) {
+			s.errorJSON(w, errors.New("product was modified, retry"), http.StatusConflict)
+			return false
+		}
+		logger(r).Error("updating the images", "error", err)
+		s.errorJSON(w, errors.New("error updating the images"), http.StatusInternalServerError)
+		return false
+	}
+
+	p.Version++
+	p.Image = ""
+	if len(p.Images) > 0 {
+		p.Image = p.Images[0].URL
+	}
+	p.Format(s.preferences(r).Locale)
+	s.writeJSON(w, http.StatusOK, p)
+	return true
+}
+
+func (s *Server) deleteObjects(r *http.Request, keys ...string) {
+	for _, key := range keys {
+		err := s.objectStore.Delete(r.Context(), key)
+		if err != nil {
+			logger(r).Error("deleting an object", "key", key, "error", err)
+		}
+	}
+}
+
+// imageIDFromKey returns the id of the image uploaded under the key, the
+// key must be an original of the product created by CreateImageUpload.
+func imageIDFromKey(productID string, key string) (string, bool) {
+	rest := strings.TrimPrefix(key, imageKeyPrefix(productID))
+	if rest == key {
+		return "", false
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) != 2 || parts[0] == "" || !strings.HasPrefix(parts[1], "original.") {
+		return "", false
+	}
+	return parts[0], true
+}
@@ -2,8 +2,8 @@ package server
 
 import (
 	"errors"
-	"log"
 	"net/http"
+	"pratbacknd/internal/storage"
 )
 
 type UpdateInventoryInput struct {
@@ -15,13 +15,18 @@ func (s *Server) UpdateInventory(w http.ResponseWriter, r *http.Request) {
 	var input UpdateInventoryInput
 	err := s.readJSON(w, r, &input)
 	if err != nil {
-		log.Printf("error - building json: %s \n", err)
+		logger(r).Warn("building json", "error", err)
 		return
 	}
 
-	err = s.storage.UpdateInventory(input.ProductId, input.Delta)
+	err = s.storage.UpdateInventory(r.Context(), input.ProductId, input.Delta)
+	if errors.Is(err, storage.ErrorBackordersPending) {
+		// the units are recorded, answering an error would get them added twice
+		logger(r).Warn("updating inventory", "error", err)
+		err = nil
+	}
 	if err != nil {
-		log.Printf("error - updating inventory: %s \n", err)
+		logger(r).Error("updating inventory", "error", err)
 		s.errorJSON(w, errors.New("error updating inventory"), http.StatusInternalServerError)
 		return
 	}
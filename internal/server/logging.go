@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"pratbacknd/internal/logging"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestLog collects what the handlers learn about the request, e.g. the
+// authenticated user, for the log written once the request is served.
+type requestLog struct {
+	userID string
+}
+
+// statusRecorder keeps the status written by the handlers
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(body []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(body)
+}
+
+// RequestLogger puts a logger tagged with the request IDs in the context and
+// logs every request with its route, status, latency and user. The request
+// ID is returned in the X-Request-Id header.
+func (s *Server) RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ids := logging.RequestIDsFrom(r.Context())
+		requestID := ids.Gateway
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		w.Header().Set("X-Request-Id", requestID)
+
+		attrs := []any{slog.String("requestId", requestID)}
+		if ids.Lambda != "" {
+			attrs = append(attrs, slog.String("lambdaRequestId", ids.Lambda))
+		}
+		if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.HasTraceID() {
+			attrs = append(attrs, slog.String("traceId", spanContext.TraceID().String()))
+		}
+		requestLogger := s.logger.With(attrs...)
+		info := &requestLog{}
+		ctx := logging.WithLogger(r.Context(), requestLogger)
+		ctx = context.WithValue(ctx, requestLogKey, info)
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case recorder.status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case recorder.status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		route := routePattern(r)
+		if route == "" {
+			route = r.URL.Path
+		}
+		requestLogger.LogAttrs(ctx, level, "request",
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.Int("status", recorder.status),
+			slog.Float64("latencyMs", float64(time.Since(start).Microseconds())/1000),
+			slog.String("userId", info.userID),
+		)
+	})
+}
+
+type requestLogKeyType struct{}
+
+var requestLogKey requestLogKeyType
+
+// setLogUser records the authenticated user for the request log.
+func setLogUser(r *http.Request, userID string) {
+	if info, ok := r.Context().Value(requestLogKey).(*requestLog); ok {
+		info.userID = userID
+	}
+}
+
+// logger returns the logger of the request.
+func logger(r *http.Request) *slog.Logger {
+	return logging.FromContext(r.Context())
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
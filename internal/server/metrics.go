@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http"
+	"pratbacknd/internal/metrics"
+	"strconv"
+	"time"
+)
+
+// httpMetrics are the request counts and latencies per route and status
+type httpMetrics struct {
+	requests *metrics.Counter
+	duration *metrics.Histogram
+}
+
+func newHTTPMetrics(registry *metrics.Registry) httpMetrics {
+	return httpMetrics{
+		requests: registry.Counter("http_requests_total", "Requests served, by method, route and status.", "method", "route", "status"),
+		duration: registry.Histogram("http_request_duration_seconds", "Latency of the requests, by method, route and status.", metrics.LatencyBuckets, "method", "route", "status"),
+	}
+}
+
+// RequestMetrics counts the requests and observes their latency labeled by
+// the chi route pattern, not the path, to keep the series bounded.
+func (s *Server) RequestMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		route := routePattern(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(recorder.status)
+		s.metrics.requests.Inc(r.Method, route, status)
+		s.metrics.duration.Observe(time.Since(start).Seconds(), r.Method, route, status)
+	})
+}
@@ -2,9 +2,14 @@ package server
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"pratbacknd/internal/storage"
 	"pratbacknd/internal/types"
+	"strconv"
 	"strings"
+
+	"firebase.google.com/go/auth"
 )
 
 const (
@@ -16,10 +21,11 @@ func (s *Server) enableCORS(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", s.allowedOrigins)
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Cart-Token")
 
 		if r.Method == "OPTIONS" {
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type")
+			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Authorization, X-Cart-Token")
 			return
 		} else {
 			h.ServeHTTP(w, r)
@@ -35,27 +41,146 @@ func (s *Server) Authenticate(next http.Handler) http.Handler {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
+		setLogUser(r, un)
 		ctx := context.WithValue(r.Context(), "user", types.User{ID: un})
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// adminClaim is the custom claim of the ID tokens of the administrators
+const adminClaim = "admin"
+
 func (s *Server) AuthenticateV2(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		splits := strings.Split(authHeader, " ")
-		if len(splits) != 2 {
+		token, err := s.verifyToken(r)
+		if err != nil {
 			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
 		}
 
-		token, err := s.firebaseAuthClient.VerifyIDToken(r.Context(), splits[1])
+		user := types.User{ID: token.UID}
+		user.Email, _ = token.Claims["email"].(string)
+		user.Name, _ = token.Claims["name"].(string)
+		setLogUser(r, user.ID)
+
+		ctx := context.WithValue(r.Context(), "user", user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// AuthenticateAdmin lets through the requests authenticated by an ID token
+// with the admin custom claim.
+func (s *Server) AuthenticateAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := s.verifyToken(r)
 		if err != nil {
 			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
 		}
+		if admin, _ := token.Claims[adminClaim].(bool); !admin {
+			logger(r).Warn("admin route refused", "user", token.UID)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		setLogUser(r, token.UID)
 
 		ctx := context.WithValue(r.Context(), "user", types.User{ID: token.UID})
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// verifyToken verifies the bearer ID token of the request.
+func (s *Server) verifyToken(r *http.Request) (*auth.Token, error) {
+	splits := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(splits) != 2 || s.firebaseAuthClient == nil {
+		return nil, errors.New("no bearer token")
+	}
+	return s.firebaseAuthClient.VerifyIDToken(r.Context(), splits[1])
+}
+
+// Preferences puts the locale and currency asked by the request in the
+// context: the lang and currency query parameters first, then the
+// Accept-Language header.
+func (s *Server) Preferences(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefs := types.Preferences{
+			Locale:   types.NormalizeLocale(r.URL.Query().Get("lang")),
+			Currency: strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("currency"))),
+		}
+		if !types.ValidLocale(prefs.Locale) {
+			prefs.Locale = acceptLanguage(r.Header.Get("Accept-Language"))
+		}
+
+		ctx := context.WithValue(r.Context(), "preferences", prefs)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ProfilePreferences completes the preferences of the request with the
+// profile of the authenticated user, explicit request parameters win.
+func (s *Server) ProfilePreferences(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := s.currentUser(w, r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		profile, err := s.storage.GetProfile(r.Context(), user.ID)
+		if err != nil {
+			if !errors.Is(err, storage.ErrorNotFound) {
+				logger(r).Error("loading the profile preferences", "error", err)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		prefs := s.preferences(r)
+		if r.URL.Query().Get("lang") == "" && profile.Locale != "" {
+			prefs.Locale = profile.Locale
+		}
+		if prefs.Currency == "" {
+			prefs.Currency = profile.Currency
+		}
+
+		ctx := context.WithValue(r.Context(), "preferences", prefs)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// preferences returns the locale and currency of the request, the locale
+// falls back to the default one.
+func (s *Server) preferences(r *http.Request) types.Preferences {
+	prefs, _ := r.Context().Value("preferences").(types.Preferences)
+	if prefs.Locale == "" {
+		prefs.Locale = types.DefaultLocale
+	}
+	return prefs
+}
+
+// acceptLanguage returns the valid locale with the highest weight in an
+// Accept-Language header, or an empty string.
+func acceptLanguage(header string) string {
+	best, bestWeight := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		locale := types.NormalizeLocale(fields[0])
+		if !types.ValidLocale(locale) {
+			continue
+		}
+		weight := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err == nil {
+					weight = q
+				}
+			}
+		}
+		if weight > bestWeight {
+			best, bestWeight = locale, weight
+		}
+	}
+	return best
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"pratbacknd/internal/storage"
+	"pratbacknd/internal/types"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ProductPricesResponse is the pricing of a product seen by the admins.
+type ProductPricesResponse struct {
+	PriceVATExcluded types.Money               `json:"priceVatExcluded"`
+	VAT              types.Money               `json:"vat"`
+	TotalPrice       types.Money               `json:"totalPrice"`
+	ReferencePrice   *types.Money              `json:"referencePrice,omitempty"`
+	RegularPrice     types.Money               `json:"regularPrice"`
+	Schedule         []types.ScheduledPrice    `json:"schedule"`
+	History          []types.PriceHistoryEntry `json:"history"`
+}
+
+func (s *Server) ProductPrices(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.productFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	history, err := s.storage.PriceHistory(r.Context(), p.ID)
+	if err != nil {
+		logger(r).Error("fetching the price history", "error", err)
+		s.errorJSON(w, errors.New("error fetching the prices"), http.StatusInternalServerError)
+		return
+	}
+
+	response := ProductPricesResponse{
+		RegularPrice: p.TotalPrice,
+		Schedule:     p.PriceSchedule,
+		History:      history,
+	}
+	if response.Schedule == nil {
+		response.Schedule = make([]types.ScheduledPrice, 0)
+	}
+	p.ApplyPrice(time.Now())
+	response.PriceVATExcluded = p.PriceVATExcluded
+	response.VAT = p.VAT
+	response.TotalPrice = p.TotalPrice
+	response.ReferencePrice = p.ReferencePrice
+
+	s.writeJSON(w, http.StatusOK, response)
+}
+
+// SchedulePrice schedules a sale, or a price change when it has no end.
+func (s *Server) SchedulePrice(w http.ResponseWriter, r *http.Request) {
+	var input types.SchedulePriceInput
+	err := s.readJSON(w, r, &input)
+	if err != nil {
+		logger(r).Warn("reading json", "error", err)
+		s.errorJSON(w, errors.New("error reading price"), http.StatusBadRequest)
+		return
+	}
+
+	p, ok := s.productFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	scheduled, err := s.schedulePrice(r.Context(), p, input, time.Now())
+	if err != nil {
+		s.priceError(w, r, err)
+		return
+	}
+	s.reindexProduct(r, p.ID)
+
+	s.writeJSON(w, http.StatusOK, scheduled)
+}
+
+func (s *Server) CancelScheduledPrice(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.productFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	canceled, err := p.CancelScheduledPrice(chi.URLParam(r, "priceId"), now)
+	if err != nil {
+		if errors.Is(err, types.ErrorPriceNotFound) {
+			s.errorJSON(w, err, http.StatusNotFound)
+			return
+		}
+		s.errorJSON(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	entry := canceled.HistoryEntry(p.ID, now)
+	history, err := s.storage.PriceHistory(r.Context(), p.ID)
+	if err != nil {
+		logger(r).Error("fetching the price history", "error", err)
+		s.errorJSON(w, errors.New("error canceling the price"), http.StatusInternalServerError)
+		return
+	}
+	for _, h := range history {
+		if h.ID == canceled.ID {
+			entry = h
+		}
+	}
+	entry.CanceledAt = &now
+
+	err = s.storage.UpdatePriceSchedule(r.Context(), p, entry)
+	if err != nil {
+		s.priceError(w, r, err)
+		return
+	}
+	s.reindexProduct(r, p.ID)
+
+	s.writeJSON(w, http.StatusOK, entry)
+}
+
+// schedulePrice validates and stores a price of the product.
+func (s *Server) schedulePrice(ctx context.Context, p types.Product, input types.SchedulePriceInput, now time.Time) (types.ScheduledPrice, error) {
+	err := input.Validate(p, now)
+	if err != nil {
+		return types.ScheduledPrice{}, errInvalidPrice{err}
+	}
+
+	scheduled := input.ScheduledPrice(s.uuidGen.Generate(), now)
+	p.SchedulePrice(scheduled, now)
+
+	err = s.storage.UpdatePriceSchedule(ctx, p, scheduled.HistoryEntry(p.ID, now))
+	if err != nil {
+		return types.ScheduledPrice{}, err
+	}
+	return scheduled, nil
+}
+
+// errInvalidPrice is a price refused by the validation
+type errInvalidPrice struct {
+	err error
+}
+
+func (e errInvalidPrice) Error() string {
+	return e.err.Error()
+}
+
+func (s *Server) priceError(w http.ResponseWriter, r *http.Request, err error) {
+	var invalid errInvalidPrice
+	switch {
+	case errors.As(err, &invalid):
+		s.errorJSON(w, err, http.StatusUnprocessableEntity)
+	case errors.Is(err, storage.ErrorNotFound):
+		s.errorJSON(w, errors.New("product not found"), http.StatusNotFound)
+	case errors.Is(err, storage.ErrorProductChanged):
+		s.errorJSON(w, errors.New("product was modified, retry"), http.StatusConflict)
+	default:
+		logger(r).Error("updating the prices", "error", err)
+		s.errorJSON(w, errors.New("error updating the prices"), http.StatusInternalServerError)
+	}
+}
+
+// presentProduct prepares a product for the customers: current price,
+// display price and content in their locale.
+func presentProduct(p *types.Product, locale string, now time.Time) {
+	p.ApplyPrice(now)
+	p.Format(locale)
+	p.Localize(locale)
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"pratbacknd/internal/storage"
+	"pratbacknd/internal/types"
+	"time"
+)
+
+func (s Server) GetProfileUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	p, err := s.profile(r.Context(), currentUser)
+	if err != nil {
+		logger(r).Error("getting the profile", "error", err)
+		s.errorJSON(w, errors.New("error getting the profile"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, p)
+}
+
+func (s Server) UpdateProfileUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	var input types.UpdateProfileInput
+	err = s.readJSON(w, r, &input)
+	if err != nil {
+		logger(r).Warn("reading json", "error", err)
+		s.errorJSON(w, errors.New("error reading profile"), http.StatusBadRequest)
+		return
+	}
+
+	p, err := s.profile(r.Context(), currentUser)
+	if err != nil {
+		logger(r).Error("getting the profile", "error", err)
+		s.errorJSON(w, errors.New("error getting the profile"), http.StatusInternalServerError)
+		return
+	}
+
+	err = p.Apply(input, time.Now().UTC())
+	if err != nil {
+		s.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = s.storage.UpdateProfile(r.Context(), p)
+	if err != nil {
+		logger(r).Error("updating the profile", "error", err)
+		s.errorJSON(w, errors.New("error updating the profile"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, p)
+}
+
+// profile returns the profile of the user, it is created from the claims of
+// the identity token on first access.
+func (s Server) profile(ctx context.Context, user types.User) (types.Profile, error) {
+	p, err := s.storage.GetProfile(ctx, user.ID)
+	if err == nil {
+		return p, nil
+	}
+	if !errors.Is(err, storage.ErrorNotFound) {
+		return types.Profile{}, err
+	}
+
+	now := time.Now().UTC()
+	p = types.Profile{
+		UserID:      user.ID,
+		Email:       user.Email,
+		Name:        user.Name,
+		DisplayName: user.Name,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err = s.storage.CreateProfile(ctx, p)
+	if errors.Is(err, storage.ErrorProfileExists) {
+		// created by a concurrent request
+		return s.storage.GetProfile(ctx, user.ID)
+	}
+	if err != nil {
+		return types.Profile{}, err
+	}
+
+	return p, nil
+}
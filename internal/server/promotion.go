@@ -0,0 +1,274 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"pratbacknd/internal/storage"
+	"pratbacknd/internal/types"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (s *Server) Promotions(w http.ResponseWriter, r *http.Request) {
+	promotions, err := s.storage.Promotions(r.Context())
+	if err != nil {
+		logger(r).Error("fetching promotions", "error", err)
+		s.errorJSON(w, errors.New("error fetching promotions"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, promotions)
+}
+
+func (s *Server) PromotionByID(w http.ResponseWriter, r *http.Request) {
+	promotionId := chi.URLParam(r, "promotionId")
+
+	p, err := s.storage.GetPromotionById(r.Context(), promotionId)
+	if err != nil {
+		if errors.Is(err, storage.ErrorNotFound) {
+			s.errorJSON(w, errors.New("promotion not found"), http.StatusNotFound)
+			return
+		}
+		logger(r).Error("getting the promotion", "error", err)
+		s.errorJSON(w, errors.New("error getting the promotion"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, p)
+}
+
+func (s *Server) CreatePromotion(w http.ResponseWriter, r *http.Request) {
+	var p types.Promotion
+	err := s.readJSON(w, r, &p)
+	if err != nil {
+		logger(r).Warn("building json", "error", err)
+		s.errorJSON(w, errors.New("error reading promotion"), http.StatusBadRequest)
+		return
+	}
+
+	p.Code = types.NormalizeCode(p.Code)
+	err = p.Validate()
+	if err != nil {
+		s.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	_, err = s.storage.GetPromotionByCode(r.Context(), p.Code)
+	if err == nil {
+		s.errorJSON(w, errors.New("a promotion with this code already exists"), http.StatusConflict)
+		return
+	}
+	if !errors.Is(err, storage.ErrorNotFound) {
+		logger(r).Error("checking promotion code", "error", err)
+		s.errorJSON(w, errors.New("error persisting promotion"), http.StatusInternalServerError)
+		return
+	}
+
+	p.ID = s.uuidGen.Generate()
+	p.Uses = 0
+	p.Version = 0
+
+	err = s.storage.CreatePromotion(r.Context(), p)
+	if err != nil {
+		logger(r).Error("storing promotion", "error", err)
+		s.errorJSON(w, errors.New("error persisting promotion"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, p)
+}
+
+func (s *Server) UpdatePromotion(w http.ResponseWriter, r *http.Request) {
+	var p types.Promotion
+	err := s.readJSON(w, r, &p)
+	if err != nil {
+		logger(r).Warn("building json", "error", err)
+		s.errorJSON(w, errors.New("error reading promotion"), http.StatusBadRequest)
+		return
+	}
+
+	p.ID = chi.URLParam(r, "promotionId")
+	p.Code = types.NormalizeCode(p.Code)
+	err = p.Validate()
+	if err != nil {
+		s.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	existing, err := s.storage.GetPromotionByCode(r.Context(), p.Code)
+	if err == nil && existing.ID != p.ID {
+		s.errorJSON(w, errors.New("a promotion with this code already exists"), http.StatusConflict)
+		return
+	}
+	if err != nil && !errors.Is(err, storage.ErrorNotFound) {
+		logger(r).Error("checking promotion code", "error", err)
+		s.errorJSON(w, errors.New("error updating the promotion"), http.StatusInternalServerError)
+		return
+	}
+
+	err = s.storage.UpdatePromotion(r.Context(), p)
+	if err != nil {
+		if errors.Is(err, storage.ErrorNotFound) {
+			s.errorJSON(w, errors.New("promotion not found"), http.StatusNotFound)
+			return
+		}
+		logger(r).Error("updating the promotion", "error", err)
+		s.errorJSON(w, errors.New("error updating the promotion"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, nil)
+}
+
+func (s *Server) DeletePromotion(w http.ResponseWriter, r *http.Request) {
+	err := s.storage.DeletePromotion(r.Context(), chi.URLParam(r, "promotionId"))
+	if err != nil {
+		logger(r).Error("deleting the promotion", "error", err)
+		s.errorJSON(w, errors.New("error deleting the promotion"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, nil)
+}
+
+func (s Server) ApplyCouponUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	var input types.ApplyCouponInput
+	err = s.readJSON(w, r, &input)
+	if err != nil {
+		logger(r).Warn("reading json", "error", err)
+		s.errorJSON(w, errors.New("error reading coupon"), http.StatusBadRequest)
+		return
+	}
+
+	p, err := s.storage.GetPromotionByCode(r.Context(), input.Code)
+	if err != nil {
+		if errors.Is(err, storage.ErrorNotFound) {
+			s.errorJSON(w, errors.New("unknown coupon code"), http.StatusNotFound)
+			return
+		}
+		logger(r).Error("getting the promotion", "error", err)
+		s.errorJSON(w, errors.New("error applying the coupon"), http.StatusInternalServerError)
+		return
+	}
+
+	if !p.ActiveAt(time.Now()) || p.Exhausted() {
+		s.errorJSON(w, errors.New("coupon is not valid"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	cart, err := s.storage.ApplyCoupon(r.Context(), currentUser.ID, currentUser.ID, p)
+	if err != nil {
+		if errors.Is(err, storage.ErrorCouponUnavailable) {
+			s.errorJSON(w, errors.New("coupon usage limit reached"), http.StatusUnprocessableEntity)
+			return
+		}
+		s.cartError(w, r, err, "error applying the coupon")
+		return
+	}
+
+	s.writeCart(w, r, cart)
+}
+
+func (s Server) RemoveCouponUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	p, err := s.storage.GetPromotionByCode(r.Context(), chi.URLParam(r, "code"))
+	if err != nil {
+		s.cartError(w, r, err, "error removing the coupon")
+		return
+	}
+
+	cart, err := s.storage.RemoveCoupon(r.Context(), currentUser.ID, currentUser.ID, p)
+	if err != nil {
+		s.cartError(w, r, err, "error removing the coupon")
+		return
+	}
+
+	s.writeCart(w, r, cart)
+}
+
+// priceCart fills the cart with the current price of its products and
+// computes its totals with the promotions of its coupons and the selected
+// shipping method.
+func (s Server) priceCart(ctx context.Context, cart *types.Cart) error {
+	now := time.Now()
+	products := make(map[string]types.Product)
+	for id := range cart.Items {
+		p, err := s.storage.GetProductById(ctx, id)
+		if err != nil {
+			if errors.Is(err, storage.ErrorNotFound) {
+				continue
+			}
+			return err
+		}
+		p.ApplyPrice(now)
+		products[id] = p
+	}
+	cart.PriceItems(products)
+
+	promotions := make([]types.Promotion, 0)
+	for _, code := range cart.Coupons {
+		p, err := s.storage.GetPromotionByCode(ctx, code)
+		if err != nil {
+			if errors.Is(err, storage.ErrorNotFound) {
+				continue
+			}
+			return err
+		}
+		if p.ActiveAt(now) {
+			promotions = append(promotions, p)
+		}
+	}
+
+	totals, err := cart.ComputeTotals(promotions)
+	if err != nil {
+		return err
+	}
+	cart.Totals = &totals
+
+	if cart.ShippingMethodID == "" || cart.ShippingCountry == "" {
+		return nil
+	}
+
+	// the selected shipping is dropped from the totals when it no longer
+	// applies to the cart
+	options, err := s.shippingOptions(ctx, *cart, cart.ShippingCountry)
+	if err != nil {
+		return err
+	}
+	for _, o := range options {
+		if o.MethodID == cart.ShippingMethodID {
+			return cart.Totals.AddShipping(o)
+		}
+	}
+
+	return nil
+}
+
+// writeCart answers with the priced cart formatted for the locale of the
+// request.
+func (s Server) writeCart(w http.ResponseWriter, r *http.Request, cart types.Cart) {
+	err := s.priceCart(r.Context(), &cart)
+	if err != nil {
+		logger(r).Error("pricing the cart", "error", err)
+		s.errorJSON(w, errors.New("error pricing the cart"), http.StatusInternalServerError)
+		return
+	}
+	cart.Format(s.preferences(r).Locale)
+
+	s.writeJSON(w, http.StatusOK, cart)
+}
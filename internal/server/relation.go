@@ -0,0 +1,213 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"pratbacknd/internal/storage"
+	"pratbacknd/internal/types"
+	"sort"
+	"time"
+)
+
+const (
+	// maxRelatedProducts is the size of each group of related products
+	maxRelatedProducts = 10
+	// maxCartSuggestions is the number of products suggested with a cart
+	maxCartSuggestions = 5
+	// maxSuggestionSources is the number of products of the cart the
+	// suggestions are drawn from, it bounds the reads of a cart
+	maxSuggestionSources = 3
+)
+
+func (s *Server) RelatedProducts(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.productFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	coPurchases, err := s.storage.CoPurchases(r.Context(), p.ID, maxRelatedProducts)
+	if err != nil {
+		logger(r).Error("fetching the co-purchases", "error", err)
+		s.errorJSON(w, errors.New("error fetching related products"), http.StatusInternalServerError)
+		return
+	}
+
+	groups := map[string][]string{}
+	for _, relation := range p.Relations {
+		groups[relation.Type] = append(groups[relation.Type], relation.ProductID)
+	}
+	for _, c := range coPurchases {
+		groups["frequentlyBoughtTogether"] = append(groups["frequentlyBoughtTogether"], c.ProductID)
+	}
+
+	locale, now := s.preferences(r).Locale, time.Now()
+	products := make(map[string][]types.Product, len(groups))
+	for group, ids := range groups {
+		products[group], err = s.presentedProducts(r.Context(), ids, maxRelatedProducts, locale, now)
+		if err != nil {
+			logger(r).Error("fetching the related products", "error", err)
+			s.errorJSON(w, errors.New("error fetching related products"), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	s.writeJSON(w, http.StatusOK, types.RelatedProducts{
+		Related:                  nonNil(products[types.RelationRelated]),
+		Accessories:              nonNil(products[types.RelationAccessory]),
+		Upsells:                  nonNil(products[types.RelationUpsell]),
+		FrequentlyBoughtTogether: nonNil(products["frequentlyBoughtTogether"]),
+	})
+}
+
+func (s *Server) UpdateProductRelations(w http.ResponseWriter, r *http.Request) {
+	var relations []types.ProductRelation
+	err := s.readJSON(w, r, &relations)
+	if err != nil {
+		logger(r).Warn("reading json", "error", err)
+		s.errorJSON(w, errors.New("error reading relations"), http.StatusBadRequest)
+		return
+	}
+
+	p, ok := s.productFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	err = types.ValidateRelations(p.ID, relations)
+	if err != nil {
+		s.errorJSON(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+	for _, relation := range relations {
+		_, err = s.storage.GetProductById(r.Context(), relation.ProductID)
+		if errors.Is(err, storage.ErrorNotFound) {
+			s.errorJSON(w, fmt.Errorf("product %s not found", relation.ProductID), http.StatusUnprocessableEntity)
+			return
+		}
+		if err != nil {
+			logger(r).Error("getting the related product", "error", err)
+			s.errorJSON(w, errors.New("error updating the relations"), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = s.storage.UpdateProductRelations(r.Context(), p.ID, p.Version, relations)
+	if err != nil {
+		if errors.Is(err, storage.ErrorProductChanged) {
+			s.errorJSON(w, errors.New("product was modified, retry"), http.StatusConflict)
+			return
+		}
+		logger(r).Error("updating the relations", "error", err)
+		s.errorJSON(w, errors.New("error updating the relations"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, relations)
+}
+
+// RecordPurchase is called by the order system with the products of each
+// order, they feed the frequently bought together products.
+func (s *Server) RecordPurchase(w http.ResponseWriter, r *http.Request) {
+	var input types.RecordPurchaseInput
+	err := s.readJSON(w, r, &input)
+	if err != nil {
+		logger(r).Warn("reading json", "error", err)
+		s.errorJSON(w, errors.New("error reading purchase"), http.StatusBadRequest)
+		return
+	}
+
+	err = input.Validate()
+	if err != nil {
+		s.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = s.storage.RecordPurchase(r.Context(), input.OrderID, input.ProductIDs)
+	if err != nil {
+		logger(r).Error("recording the purchase", "error", err)
+		s.errorJSON(w, errors.New("error recording the purchase"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, nil)
+}
+
+// cartSuggestions returns the products to suggest with the cart, drawn
+// from the relations of a few of its products.
+func (s *Server) cartSuggestions(ctx context.Context, cart types.Cart, locale string, now time.Time) ([]types.Product, error) {
+	sources := make([]string, 0, len(cart.Items))
+	for id := range cart.Items {
+		sources = append(sources, id)
+	}
+	sort.Strings(sources)
+	if len(sources) > maxSuggestionSources {
+		sources = sources[:maxSuggestionSources]
+	}
+
+	relations := make(map[string][]types.ProductRelation, len(sources))
+	coPurchases := make(map[string][]types.CoPurchase, len(sources))
+	for _, id := range sources {
+		p, err := s.storage.GetProductById(ctx, id)
+		if err != nil && !errors.Is(err, storage.ErrorNotFound) {
+			return nil, err
+		}
+		relations[id] = p.Relations
+
+		coPurchases[id], err = s.storage.CoPurchases(ctx, id, maxRelatedProducts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// unavailable products are dropped, rank a few more than needed and
+	// stop reading once there are enough
+	ids := types.Suggest(cart, relations, coPurchases, 2*maxCartSuggestions)
+	suggestions := make([]types.Product, 0, maxCartSuggestions)
+	for _, id := range ids {
+		if len(suggestions) == maxCartSuggestions {
+			break
+		}
+		p, err := s.storage.GetProductById(ctx, id)
+		if errors.Is(err, storage.ErrorNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p.Available() {
+			presentProduct(&p, locale, now)
+			suggestions = append(suggestions, p)
+		}
+	}
+	return suggestions, nil
+}
+
+// presentedProducts loads the products, in order, ready for the customers,
+// the products that no longer exist are skipped.
+func (s *Server) presentedProducts(ctx context.Context, ids []string, limit int, locale string, now time.Time) ([]types.Product, error) {
+	products := make([]types.Product, 0, len(ids))
+	for _, id := range ids {
+		if len(products) == limit {
+			break
+		}
+		p, err := s.storage.GetProductById(ctx, id)
+		if errors.Is(err, storage.ErrorNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		presentProduct(&p, locale, now)
+		products = append(products, p)
+	}
+	return products, nil
+}
+
+func nonNil(products []types.Product) []types.Product {
+	if products == nil {
+		return make([]types.Product, 0)
+	}
+	return products
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"pratbacknd/internal/storage"
+	"pratbacknd/internal/types"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DeliveryChecker tells whether a user received a product in a delivered
+// order, only those users can review the product.
+type DeliveryChecker interface {
+	HasDeliveredProduct(ctx context.Context, userID string, productID string) (bool, error)
+}
+
+// OpenReviews is the DeliveryChecker of the shops without an order system:
+// every signed in user can review every product.
+type OpenReviews struct{}
+
+func (OpenReviews) HasDeliveredProduct(ctx context.Context, userID string, productID string) (bool, error) {
+	return true, nil
+}
+
+func (s Server) ProductReviews(w http.ResponseWriter, r *http.Request) {
+	reviews, err := s.storage.Reviews(r.Context(), chi.URLParam(r, "productId"), types.ReviewApproved)
+	if err != nil {
+		logger(r).Error("fetching reviews", "error", err)
+		s.errorJSON(w, errors.New("error fetching reviews"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, reviews)
+}
+
+func (s Server) CreateReviewUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	var input types.CreateReviewInput
+	err = s.readJSON(w, r, &input)
+	if err != nil {
+		logger(r).Warn("reading json", "error", err)
+		s.errorJSON(w, errors.New("error reading review"), http.StatusBadRequest)
+		return
+	}
+
+	err = input.Validate()
+	if err != nil {
+		s.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	productID := chi.URLParam(r, "productId")
+	_, err = s.storage.GetProductById(r.Context(), productID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorNotFound) {
+			s.errorJSON(w, errors.New("product not found"), http.StatusNotFound)
+			return
+		}
+		logger(r).Error("getting the product", "error", err)
+		s.errorJSON(w, errors.New("error creating the review"), http.StatusInternalServerError)
+		return
+	}
+
+	delivered, err := s.deliveryChecker.HasDeliveredProduct(r.Context(), currentUser.ID, productID)
+	if err != nil {
+		logger(r).Error("checking the deliveries", "error", err)
+		s.errorJSON(w, errors.New("error creating the review"), http.StatusInternalServerError)
+		return
+	}
+	if !delivered {
+		s.errorJSON(w, errors.New("only customers who received the product can review it"), http.StatusForbidden)
+		return
+	}
+
+	// the review is published under the name the user chose
+	profile, err := s.profile(r.Context(), currentUser)
+	if err != nil {
+		logger(r).Error("getting the profile", "error", err)
+		s.errorJSON(w, errors.New("error creating the review"), http.StatusInternalServerError)
+		return
+	}
+
+	review := types.Review{
+		ID:         s.uuidGen.Generate(),
+		ProductID:  productID,
+		UserID:     currentUser.ID,
+		AuthorName: profile.DisplayName,
+		Rating:     input.Rating,
+		Title:      strings.TrimSpace(input.Title),
+		Body:       strings.TrimSpace(input.Body),
+		Status:     types.ReviewPending,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	err = s.storage.CreateReview(r.Context(), review)
+	if err != nil {
+		if errors.Is(err, storage.ErrorReviewExists) {
+			s.errorJSON(w, err, http.StatusConflict)
+			return
+		}
+		logger(r).Error("storing review", "error", err)
+		s.errorJSON(w, errors.New("error creating the review"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, review)
+}
+
+func (s *Server) PendingReviews(w http.ResponseWriter, r *http.Request) {
+	reviews, err := s.storage.PendingReviews(r.Context())
+	if err != nil {
+		logger(r).Error("fetching pending reviews", "error", err)
+		s.errorJSON(w, errors.New("error fetching pending reviews"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, reviews)
+}
+
+func (s *Server) ModerateReview(w http.ResponseWriter, r *http.Request) {
+	var input types.ModerateReviewInput
+	err := s.readJSON(w, r, &input)
+	if err != nil {
+		logger(r).Warn("reading json", "error", err)
+		s.errorJSON(w, errors.New("error reading moderation"), http.StatusBadRequest)
+		return
+	}
+
+	err = input.Validate()
+	if err != nil {
+		s.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	review, err := s.storage.ModerateReview(r.Context(), chi.URLParam(r, "productId"), chi.URLParam(r, "reviewId"), input.Status)
+	if err != nil {
+		s.adminError(w, r, err, "error moderating the review")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, review)
+}
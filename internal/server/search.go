@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"pratbacknd/internal/search"
+	"pratbacknd/internal/types"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SearchResponse is a page of products matching a search.
+type SearchResponse struct {
+	Total    int             `json:"total"`
+	Products []types.Product `json:"products"`
+	Facets   search.Facets   `json:"facets"`
+}
+
+// defaultSearchIndexTTL is the age of the search index when the config
+// does not set one
+const defaultSearchIndexTTL = 5 * time.Minute
+
+// maxSearchLimit is the largest page of a search, a larger limit is
+// clamped to it
+const maxSearchLimit = 100
+
+// searchLoader fills the search index with the catalog and reloads it once
+// it is older than the ttl.
+type searchLoader struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	loadedAt time.Time
+}
+
+func (s Server) SearchProducts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if offset < 0 {
+		logger(r).Error("negative search offset", "offset", offset)
+		s.errorJSON(w, errors.New("error offset cannot be less than zero"), http.StatusBadRequest)
+		return
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	err := s.loadSearchIndex(r.Context())
+	if err != nil {
+		logger(r).Error("loading the search index", "error", err)
+		s.errorJSON(w, errors.New("error searching products"), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	result, err := s.searchIndex.Search(search.Query{
+		Text:       q.Get("q"),
+		CategoryID: q.Get("category"),
+		PriceBand:  q.Get("price"),
+		Now:        now,
+		Offset:     offset,
+		Limit:      limit,
+	})
+	if err != nil {
+		logger(r).Error("searching products", "error", err)
+		s.errorJSON(w, errors.New("error searching products"), http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]string, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		ids = append(ids, hit.ProductID)
+	}
+	// a hit may have been deleted since it was indexed
+	products, err := s.presentedProducts(r.Context(), ids, len(ids), s.preferences(r).Locale, now)
+	if err != nil {
+		logger(r).Error("fetching products", "error", err)
+		s.errorJSON(w, errors.New("error searching products"), http.StatusInternalServerError)
+		return
+	}
+
+	response := SearchResponse{
+		Total:    result.Total,
+		Products: products,
+		Facets:   result.Facets,
+	}
+	s.writeJSON(w, http.StatusOK, response)
+}
+
+// loadSearchIndex indexes the whole catalog on the first search, and again
+// once the index is older than the ttl. The product handlers of the process
+// keep the index in sync in between, the ttl bounds how long the writes of
+// the other instances and of cmd/catalog take to be searchable.
+func (s Server) loadSearchIndex(ctx context.Context) error {
+	s.searchLoader.mu.Lock()
+	defer s.searchLoader.mu.Unlock()
+
+	if !s.searchLoader.loadedAt.IsZero() && time.Since(s.searchLoader.loadedAt) < s.searchLoader.ttl {
+		return nil
+	}
+
+	products, err := s.storage.Products(ctx)
+	if err != nil {
+		return err
+	}
+	err = s.searchIndex.Replace(products)
+	if err != nil {
+		return err
+	}
+
+	s.searchLoader.loadedAt = time.Now()
+	return nil
+}
+
+// reindexProduct refreshes a product in the search index, a failure only
+// leaves the index stale.
+func (s Server) reindexProduct(r *http.Request, productID string) {
+	p, err := s.storage.GetProductById(r.Context(), productID)
+	if err != nil {
+		logger(r).Error("reindexing a product", "productId", productID, "error", err)
+		return
+	}
+	err = s.searchIndex.Index(p)
+	if err != nil {
+		logger(r).Error("reindexing a product", "productId", productID, "error", err)
+	}
+}
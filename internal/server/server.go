@@ -1,30 +1,71 @@
 package server
 
 import (
+	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
+	"pratbacknd/internal/metrics"
+	"pratbacknd/internal/objectstore"
+	"pratbacknd/internal/search"
 	"pratbacknd/internal/storage"
 	"pratbacknd/internal/types"
 	"pratbacknd/internal/utils"
+	"sort"
+	"time"
 
 	"firebase.google.com/go/auth"
 	"github.com/go-chi/chi/v5"
 )
 
+// TokenVerifier verifies the ID tokens sent by the clients, it is
+// implemented by the firebase auth client.
+type TokenVerifier interface {
+	VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error)
+}
+
 type Server struct {
 	Mux                *chi.Mux
 	allowedOrigins     string
 	storage            storage.Storage
 	uuidGen            utils.UUIDGenerator
-	firebaseAuthClient *auth.Client
+	firebaseAuthClient TokenVerifier
+	cartTokenSecrets   CartTokenSecrets
+	guestCarts         *guestCartLimiter
+	deliveryChecker    DeliveryChecker
+	searchIndex        search.SearchIndex
+	searchLoader       *searchLoader
+	objectStore        objectstore.ObjectStore
+	logger             *slog.Logger
+	metrics            httpMetrics
 }
 
 type Config struct {
 	AllowedOrigins     string
 	Storage            storage.Storage
 	UUIDGen            utils.UUIDGenerator
-	FirebaseAuthClient *auth.Client
+	FirebaseAuthClient TokenVerifier
+	// CartTokenSecret signs the tokens of guest carts, guest carts are
+	// disabled when it is empty and CartTokenSecrets is nil
+	CartTokenSecret []byte
+	// CartTokenSecrets resolves the secrets of the tokens at each use, it
+	// takes precedence over CartTokenSecret
+	CartTokenSecrets CartTokenSecrets
+	// DeliveryChecker allows reviews from the customers who received the
+	// product, the reviews can't be written when it is nil
+	DeliveryChecker DeliveryChecker
+	// SearchIndex is an in-memory index when nil
+	SearchIndex search.SearchIndex
+	// SearchIndexTTL is the time the search index is kept before it is
+	// reloaded from the storage, 5 minutes when 0
+	SearchIndexTTL time.Duration
+	// ObjectStore keeps the product images, image uploads are disabled
+	// when it is nil
+	ObjectStore objectstore.ObjectStore
+	// Logger writes the request logs, slog.Default() when nil
+	Logger *slog.Logger
+	// Metrics records the request metrics, a private registry when nil
+	Metrics *metrics.Registry
 }
 
 func New(config Config) (*Server, error) {
@@ -35,37 +76,152 @@ func New(config Config) (*Server, error) {
 		allowedOrigins:     config.AllowedOrigins,
 		uuidGen:            config.UUIDGen,
 		firebaseAuthClient: config.FirebaseAuthClient,
+		cartTokenSecrets:   config.CartTokenSecrets,
+		guestCarts:         &guestCartLimiter{},
+		deliveryChecker:    config.DeliveryChecker,
+		searchIndex:        config.SearchIndex,
+		searchLoader:       &searchLoader{ttl: config.SearchIndexTTL},
+		objectStore:        config.ObjectStore,
+		logger:             config.Logger,
+	}
+	if s.cartTokenSecrets == nil && len(config.CartTokenSecret) > 0 {
+		s.cartTokenSecrets = StaticCartTokenSecret(config.CartTokenSecret)
+	}
+	if s.searchIndex == nil {
+		s.searchIndex = search.NewMemory()
+	}
+	if s.searchLoader.ttl == 0 {
+		s.searchLoader.ttl = defaultSearchIndexTTL
 	}
+	if s.logger == nil {
+		s.logger = slog.Default()
+	}
+	registry := config.Metrics
+	if registry == nil {
+		registry = metrics.NewRegistry()
+	}
+	s.metrics = newHTTPMetrics(registry)
 
+	m.Use(s.Trace)
+	m.Use(s.RequestLogger)
+	m.Use(s.RequestMetrics)
 	m.Use(s.enableCORS)
+	m.Use(s.Preferences)
 
 	m.Get("/products", s.Products)
+	m.Get("/products/search", s.SearchProducts)
 	m.Get("/products/{productId}", s.ProductByID)
-	m.Post("/admin/products", s.CreateProduct)
-	m.Put("/admin/product/{productId}", s.UpdateProduct)
-
+	m.Get("/products/{productId}/reviews", s.ProductReviews)
+	m.Get("/products/{productId}/related", s.RelatedProducts)
+	if s.deliveryChecker != nil {
+		m.With(s.AuthenticateV2).Post("/products/{productId}/reviews", s.CreateReviewUser)
+	}
 	m.Get("/categories", s.Categories)
-	m.Post("/admin/categories", s.CreateCategory)
 
-	m.Put("/admin/inventory", s.UpdateInventory)
+	m.Route("/admin", func(mux chi.Router) {
+		mux.Use(s.AuthenticateAdmin)
+		mux.Post("/products", s.CreateProduct)
+		mux.Post("/products/import", s.ImportProducts)
+		mux.Get("/products/export", s.ExportProducts)
+		mux.Put("/product/{productId}", s.UpdateProduct)
+		mux.Post("/products/{productId}/images/uploads", s.CreateImageUpload)
+		mux.Post("/products/{productId}/images", s.AddProductImage)
+		mux.Put("/products/{productId}/images", s.UpdateProductImages)
+		mux.Delete("/products/{productId}/images/{imageId}", s.DeleteProductImage)
+		mux.Get("/products/{productId}/translations", s.ProductTranslations)
+		mux.Put("/products/{productId}/translations/{locale}", s.SetProductTranslation)
+		mux.Delete("/products/{productId}/translations/{locale}", s.DeleteProductTranslation)
+		mux.Get("/products/{productId}/prices", s.ProductPrices)
+		mux.Post("/products/{productId}/prices", s.SchedulePrice)
+		mux.Delete("/products/{productId}/prices/{priceId}", s.CancelScheduledPrice)
+		mux.Put("/products/{productId}/relations", s.UpdateProductRelations)
+		mux.Post("/purchases", s.RecordPurchase)
+
+		mux.Get("/reviews", s.PendingReviews)
+		mux.Put("/products/{productId}/reviews/{reviewId}", s.ModerateReview)
+
+		mux.Post("/categories", s.CreateCategory)
+		mux.Get("/categories/{categoryId}/translations", s.CategoryTranslations)
+		mux.Put("/categories/{categoryId}/translations/{locale}", s.SetCategoryTranslation)
+		mux.Delete("/categories/{categoryId}/translations/{locale}", s.DeleteCategoryTranslation)
+
+		mux.Put("/inventory", s.UpdateInventory)
+
+		mux.Get("/promotions", s.Promotions)
+		mux.Post("/promotions", s.CreatePromotion)
+		mux.Get("/promotions/{promotionId}", s.PromotionByID)
+		mux.Put("/promotions/{promotionId}", s.UpdatePromotion)
+		mux.Delete("/promotions/{promotionId}", s.DeletePromotion)
+
+		mux.Get("/shipping/zones", s.ShippingZones)
+		mux.Post("/shipping/zones", s.CreateShippingZone)
+		mux.Put("/shipping/zones/{zoneId}", s.UpdateShippingZone)
+		mux.Delete("/shipping/zones/{zoneId}", s.DeleteShippingZone)
+		mux.Get("/shipping/methods", s.ShippingMethods)
+		mux.Post("/shipping/methods", s.CreateShippingMethod)
+		mux.Put("/shipping/methods/{methodId}", s.UpdateShippingMethod)
+		mux.Delete("/shipping/methods/{methodId}", s.DeleteShippingMethod)
+	})
 
 	m.Route("/me", func(mux chi.Router) {
 		mux.Use(s.AuthenticateV2)
+		mux.Use(s.ProfilePreferences)
+		mux.Get("/profile", s.GetProfileUser)
+		mux.Put("/profile", s.UpdateProfileUser)
+		mux.Get("/export", s.ExportUser)
+		mux.Delete("/", s.DeleteUser)
 		mux.Get("/cart", s.GetCartUser)
 		mux.Put("/cart", s.UpdateCartUser)
+		mux.Delete("/cart", s.ClearCartUser)
+		mux.Put("/cart/items/{productId}", s.SetCartItemQuantityUser)
+		mux.Delete("/cart/items/{productId}", s.RemoveCartItemUser)
+		mux.Post("/cart/items/{productId}/save-for-later", s.SaveForLaterUser)
+		mux.Post("/cart/merge", s.MergeGuestCart)
+		mux.Post("/cart/coupons", s.ApplyCouponUser)
+		mux.Delete("/cart/coupons/{code}", s.RemoveCouponUser)
+		mux.Get("/cart/shipping-options", s.ShippingOptionsUser)
+		mux.Put("/cart/shipping", s.SelectShippingUser)
+		mux.Get("/wishlist", s.WishlistUser)
+		mux.Post("/wishlist", s.AddToWishlistUser)
+		mux.Delete("/wishlist/{productId}", s.RemoveFromWishlistUser)
+		mux.Post("/wishlist/{productId}/move-to-cart", s.MoveToCartUser)
+		mux.Get("/addresses", s.AddressesUser)
+		mux.Post("/addresses", s.CreateAddressUser)
+		mux.Get("/addresses/{addressId}", s.AddressByIDUser)
+		mux.Put("/addresses/{addressId}", s.UpdateAddressUser)
+		mux.Delete("/addresses/{addressId}", s.DeleteAddressUser)
+	})
+
+	m.Route("/guest", func(mux chi.Router) {
+		mux.Get("/cart", s.GetGuestCart)
+		mux.Put("/cart", s.UpdateGuestCart)
 	})
 
 	return s, nil
 }
 
 func (s *Server) Products(w http.ResponseWriter, r *http.Request) {
-	products, err := s.storage.Products()
+	products, err := s.storage.Products(r.Context())
 	if err != nil {
-		log.Printf("error - fetching products: %s \n", err)
+		logger(r).Error("fetching products", "error", err)
 		s.errorJSON(w, errors.New("error fetching products"), http.StatusInternalServerError)
 		return
 	}
 
+	locale, now := s.preferences(r).Locale, time.Now()
+	for i := range products {
+		presentProduct(&products[i], locale, now)
+	}
+
+	if r.URL.Query().Get("sort") == "rating" {
+		sort.SliceStable(products, func(i, j int) bool {
+			if products[i].Rating != products[j].Rating {
+				return products[i].Rating > products[j].Rating
+			}
+			return products[i].RatingCount > products[j].RatingCount
+		})
+	}
+
 	s.writeJSON(w, http.StatusOK, products)
 }
 
@@ -74,31 +230,41 @@ func (s *Server) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	err := s.readJSON(w, r, &p)
 
 	if err != nil {
-		log.Printf("error - building json: %s \n", err)
+		logger(r).Warn("building json", "error", err)
 		s.errorJSON(w, errors.New("error reading product"), http.StatusBadRequest)
 		return
 	}
 
 	p.ID = s.uuidGen.Generate()
 
-	err = s.storage.CreateProduct(p)
+	err = s.storage.CreateProduct(r.Context(), p)
 	if err != nil {
-		log.Printf("error - storing product: %s \n", err)
+		logger(r).Error("storing product", "error", err)
 		s.errorJSON(w, errors.New("error persisting product"), http.StatusInternalServerError)
 		return
 	}
 
+	err = s.searchIndex.Index(p)
+	if err != nil {
+		logger(r).Error("indexing product", "error", err)
+	}
+
 	s.writeJSON(w, http.StatusOK, p)
 }
 
 func (s *Server) Categories(w http.ResponseWriter, r *http.Request) {
-	categories, err := s.storage.Categories()
+	categories, err := s.storage.Categories(r.Context())
 	if err != nil {
-		log.Printf("error - fetching categories: %s \n", err)
+		logger(r).Error("fetching categories", "error", err)
 		s.errorJSON(w, errors.New("error fetching categories"), http.StatusInternalServerError)
 		return
 	}
 
+	locale := s.preferences(r).Locale
+	for i := range categories {
+		categories[i].Localize(locale)
+	}
+
 	s.writeJSON(w, http.StatusOK, categories)
 }
 
@@ -107,16 +273,16 @@ func (s *Server) CreateCategory(w http.ResponseWriter, r *http.Request) {
 	err := s.readJSON(w, r, &c)
 
 	if err != nil {
-		log.Printf("error - building json: %s \n", err)
+		logger(r).Warn("building json", "error", err)
 		s.errorJSON(w, errors.New("error reading category"), http.StatusBadRequest)
 		return
 	}
 
 	c.ID = s.uuidGen.Generate()
 
-	err = s.storage.CreateCategory(c)
+	err = s.storage.CreateCategory(r.Context(), c)
 	if err != nil {
-		log.Printf("error - storing category: %s \n", err)
+		logger(r).Error("storing category", "error", err)
 		s.errorJSON(w, errors.New("error persisting product"), http.StatusInternalServerError)
 		return
 	}
@@ -125,13 +291,21 @@ func (s *Server) CreateCategory(w http.ResponseWriter, r *http.Request) {
 }
 
 type UpdateProductInput struct {
-	Name             string      `json:"name"`
-	Image            string      `json:"image"`
-	ShortDescription string      `json:"shortDescription"`
-	Description      string      `json:"description"`
-	PriceVATExcluded types.Money `json:"priceVatExcluded"`
-	VAT              types.Money `json:"vat"`
-	TotalPrice       types.Money `json:"totalPrice"`
+	Name             string            `json:"name"`
+	Image            string            `json:"image"`
+	ShortDescription string            `json:"shortDescription"`
+	Description      string            `json:"description"`
+	PriceVATExcluded types.Money       `json:"priceVatExcluded"`
+	VAT              types.Money       `json:"vat"`
+	TotalPrice       types.Money       `json:"totalPrice"`
+	Backorder        *bool             `json:"backorder"`
+	PreOrder         *bool             `json:"preOrder"`
+	MaxBackorder     *uint             `json:"maxBackorder"`
+	AvailableAt      *time.Time        `json:"availableAt"`
+	MinPerOrder      *uint             `json:"minPerOrder"`
+	MaxPerOrder      *uint             `json:"maxPerOrder"`
+	Weight           *uint             `json:"weight"`
+	Dimensions       *types.Dimensions `json:"dimensions"`
 }
 
 func (s *Server) UpdateProduct(w http.ResponseWriter, r *http.Request) {
@@ -140,7 +314,7 @@ func (s *Server) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	err := s.readJSON(w, r, &input)
 
 	if err != nil {
-		log.Printf("error - building json: %s \n", err)
+		logger(r).Warn("building json", "error", err)
 		s.errorJSON(w, errors.New("error reading product"), http.StatusBadRequest)
 		return
 	}
@@ -151,26 +325,72 @@ func (s *Server) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = s.storage.UpdateProduct(storage.UpdateProductInput{
+	// a live price change is a permanent price starting now, so that it
+	// is kept in the price history
+	if input.PriceVATExcluded != (types.Money{}) || input.VAT != (types.Money{}) || input.TotalPrice != (types.Money{}) {
+		err = s.changePrice(r.Context(), productId, input)
+		if err != nil {
+			s.priceError(w, r, err)
+			return
+		}
+	}
+
+	err = s.storage.UpdateProduct(r.Context(), storage.UpdateProductInput{
 		ProductId:        productId,
 		Name:             input.Name,
 		Image:            input.Image,
 		ShortDescription: input.ShortDescription,
 		Description:      input.Description,
-		PriceVATExcluded: input.PriceVATExcluded,
-		VAT:              input.VAT,
-		TotalPrice:       input.TotalPrice,
+		Backorder:        input.Backorder,
+		PreOrder:         input.PreOrder,
+		MaxBackorder:     input.MaxBackorder,
+		AvailableAt:      input.AvailableAt,
+		MinPerOrder:      input.MinPerOrder,
+		MaxPerOrder:      input.MaxPerOrder,
+		Weight:           input.Weight,
+		Dimensions:       input.Dimensions,
 	})
 
 	if err != nil {
-		log.Printf("error - updating the product: %s \n", err)
+		logger(r).Error("updating the product", "error", err)
 		s.errorJSON(w, errors.New("error updating the product"), http.StatusInternalServerError)
 		return
 	}
+	s.reindexProduct(r, productId)
 
 	s.writeJSON(w, http.StatusOK, nil)
 }
 
+// changePrice replaces the current price of the product, the prices
+// missing from the input are kept.
+func (s *Server) changePrice(ctx context.Context, productID string, input UpdateProductInput) error {
+	p, err := s.storage.GetProductById(ctx, productID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	current := p
+	current.ApplyPrice(now)
+	price := types.SchedulePriceInput{
+		PriceVATExcluded: current.PriceVATExcluded,
+		VAT:              current.VAT,
+		TotalPrice:       current.TotalPrice,
+	}
+	if input.PriceVATExcluded != (types.Money{}) {
+		price.PriceVATExcluded = input.PriceVATExcluded
+	}
+	if input.VAT != (types.Money{}) {
+		price.VAT = input.VAT
+	}
+	if input.TotalPrice != (types.Money{}) {
+		price.TotalPrice = input.TotalPrice
+	}
+
+	_, err = s.schedulePrice(ctx, p, price, now)
+	return err
+}
+
 func (s *Server) currentUser(w http.ResponseWriter, r *http.Request) (types.User, error) {
 	user := r.Context().Value("user")
 	if user == nil {
@@ -187,13 +407,14 @@ func (s *Server) ProductByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	p, err := s.storage.GetProductById(productId)
+	p, err := s.storage.GetProductById(r.Context(), productId)
 
 	if err != nil {
-		log.Printf("error - getting the product: %s\n", err)
+		logger(r).Error("getting the product", "error", err)
 		s.errorJSON(w, errors.New("error getting the product"), http.StatusInternalServerError)
 		return
 	}
+	presentProduct(&p, s.preferences(r).Locale, time.Now())
 
 	s.writeJSON(w, http.StatusOK, p)
 }
@@ -2,18 +2,62 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"pratbacknd/internal/imaging"
+	"pratbacknd/internal/logging"
+	"pratbacknd/internal/metrics"
+	"pratbacknd/internal/objectstore"
+	"pratbacknd/internal/search"
+	"pratbacknd/internal/secret"
 	"pratbacknd/internal/storage"
+	"pratbacknd/internal/tracing"
 	"pratbacknd/internal/types"
 	"pratbacknd/internal/utils"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"firebase.google.com/go/auth"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 )
 
+// fakeVerifier accepts any token and authenticates it as uid with the
+// given claims.
+type fakeVerifier struct {
+	uid    string
+	claims map[string]interface{}
+}
+
+func (f fakeVerifier) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	if idToken == "" {
+		return nil, errors.New("empty token")
+	}
+	return &auth.Token{UID: f.uid, Claims: f.claims}, nil
+}
+
+// adminVerifier authenticates the tokens as an administrator
+var adminVerifier = fakeVerifier{uid: "admin", claims: map[string]interface{}{adminClaim: true}}
+
+// expectNoProfile lets the authenticated routes look up a profile that does
+// not exist.
+func expectNoProfile(mockedStorage *storage.MockStorage) {
+	mockedStorage.EXPECT().GetProfile(gomock.Any(), gomock.Any()).Return(types.Profile{}, storage.ErrorNotFound).AnyTimes()
+}
+
 func Test_CreateProduct(t *testing.T) {
 	// GIVEN
 
@@ -22,7 +66,7 @@ func Test_CreateProduct(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockedStorage := storage.NewMockStorage(ctrl)
-	mockedStorage.EXPECT().CreateProduct(gomock.Any()).Return(nil)
+	mockedStorage.EXPECT().CreateProduct(gomock.Any(), gomock.Any()).Return(nil)
 
 	// uuid gen mock
 	uuidctrl := gomock.NewController(t)
@@ -33,9 +77,10 @@ func Test_CreateProduct(t *testing.T) {
 
 	// server
 	testServer, err := New(Config{
-		AllowedOrigins: "*",
-		Storage:        mockedStorage,
-		UUIDGen:        mockedUUID,
+		FirebaseAuthClient: adminVerifier,
+		AllowedOrigins:     "*",
+		Storage:            mockedStorage,
+		UUIDGen:            mockedUUID,
 	})
 	assert.NoError(t, err, "building a server should not return an error")
 
@@ -48,6 +93,7 @@ func Test_CreateProduct(t *testing.T) {
 	assert.NoError(t, err, "building a server should not return an error")
 
 	req := httptest.NewRequest("POST", "/admin/products", bytes.NewReader(jsonProduct))
+	req.Header.Set("Authorization", "Bearer token")
 
 	// WHEN
 	testServer.Mux.ServeHTTP(recorder, req)
@@ -71,7 +117,7 @@ func Test_CreateCategory(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockedStorage := storage.NewMockStorage(ctrl)
-	mockedStorage.EXPECT().CreateCategory(gomock.Any()).Return(nil)
+	mockedStorage.EXPECT().CreateCategory(gomock.Any(), gomock.Any()).Return(nil)
 
 	// uuid gen mock
 	uuidctrl := gomock.NewController(t)
@@ -82,9 +128,10 @@ func Test_CreateCategory(t *testing.T) {
 
 	// server
 	testServer, err := New(Config{
-		AllowedOrigins: "*",
-		Storage:        mockedStorage,
-		UUIDGen:        mockedUUID,
+		FirebaseAuthClient: adminVerifier,
+		AllowedOrigins:     "*",
+		Storage:            mockedStorage,
+		UUIDGen:            mockedUUID,
 	})
 	assert.NoError(t, err, "building a server should not return an error")
 
@@ -97,6 +144,7 @@ func Test_CreateCategory(t *testing.T) {
 	assert.NoError(t, err, "building a server should not return an error")
 
 	req := httptest.NewRequest("POST", "/admin/categories", bytes.NewReader(jsonProduct))
+	req.Header.Set("Authorization", "Bearer token")
 
 	// WHEN
 	testServer.Mux.ServeHTTP(recorder, req)
@@ -131,7 +179,7 @@ func TestServer_Categories(t *testing.T) {
 			Description: "this the first category",
 		},
 	}
-	mockedStorage.EXPECT().Categories().Return(mockedResp, nil)
+	mockedStorage.EXPECT().Categories(gomock.Any()).Return(mockedResp, nil)
 
 	// server
 	testServer, err := New(Config{
@@ -165,6 +213,7 @@ func TestServer_UserCart(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockedStorage := storage.NewMockStorage(ctrl)
+	expectNoProfile(mockedStorage)
 	mockedResp := types.Cart{
 		ID:           "adil",
 		CurrencyCode: "EUR",
@@ -183,13 +232,22 @@ func TestServer_UserCart(t *testing.T) {
 
 	})
 
-	mockedStorage.EXPECT().GetCart(userId).Return(mockedResp, nil)
+	mockedStorage.EXPECT().GetCart(gomock.Any(), userId).Return(mockedResp, nil)
+	mockedStorage.EXPECT().GetProductById(gomock.Any(), "42").Return(types.Product{
+		ID:               "42",
+		ShortDescription: "product 1",
+		PriceVATExcluded: types.Money{Amount: 100, Currency: "EUR"},
+		VAT:              types.Money{Amount: 20, Currency: "EUR"},
+		TotalPrice:       types.Money{Amount: 120, Currency: "EUR"},
+	}, nil).Times(2)
+	mockedStorage.EXPECT().CoPurchases(gomock.Any(), "42", gomock.Any()).Return([]types.CoPurchase{}, nil)
 
 	// server
 	testServer, err := New(Config{
-		AllowedOrigins: "*",
-		Storage:        mockedStorage,
-		UUIDGen:        nil,
+		AllowedOrigins:     "*",
+		Storage:            mockedStorage,
+		UUIDGen:            nil,
+		FirebaseAuthClient: fakeVerifier{uid: userId},
 	})
 	assert.NoError(t, err, "building a server should not return an error")
 
@@ -204,3 +262,2070 @@ func TestServer_UserCart(t *testing.T) {
 	// Then
 	assert.Equal(t, http.StatusOK, recorder.Code)
 }
+
+func TestServer_CartSuggestions(t *testing.T) {
+	// Given a cart of 5 products
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedStorage := storage.NewMockStorage(ctrl)
+	expectNoProfile(mockedStorage)
+	cart := types.Cart{ID: "adil", CurrencyCode: "EUR", Items: map[string]types.Item{}}
+	for _, id := range []string{"p1", "p2", "p3", "p4", "p5"} {
+		cart.Items[id] = types.Item{ID: id, Quantity: 1}
+	}
+	mockedStorage.EXPECT().GetCart(gomock.Any(), "adil").Return(cart, nil)
+	mockedStorage.EXPECT().GetProductById(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id string) (types.Product, error) {
+		return types.Product{
+			ID:               id,
+			Stock:            1,
+			PriceVATExcluded: types.Money{Amount: 100, Currency: "EUR"},
+			VAT:              types.Money{Amount: 20, Currency: "EUR"},
+			TotalPrice:       types.Money{Amount: 120, Currency: "EUR"},
+		}, nil
+	}).AnyTimes()
+	for _, id := range []string{"p1", "p2", "p3"} {
+		mockedStorage.EXPECT().CoPurchases(gomock.Any(), id, gomock.Any()).Return([]types.CoPurchase{
+			{ProductID: id + "-a", Count: 2},
+			{ProductID: id + "-b", Count: 1},
+		}, nil)
+	}
+
+	testServer, err := New(Config{AllowedOrigins: "*", Storage: mockedStorage, FirebaseAuthClient: fakeVerifier{uid: "adil"}})
+	assert.NoError(t, err, "building a server should not return an error")
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/me/cart", nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	// When
+	testServer.Mux.ServeHTTP(recorder, req)
+
+	// Then only the first products of the cart are read
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var response types.Cart
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Len(t, response.Suggestions, maxCartSuggestions)
+	assert.Equal(t, "p1-a", response.Suggestions[0].ID)
+}
+
+// rotatedCartTokenSecrets is a cart token secret in the middle of a rotation
+type rotatedCartTokenSecrets struct {
+	current  []byte
+	previous []byte
+}
+
+func (r rotatedCartTokenSecrets) CartTokenSecrets(ctx context.Context) ([]byte, []byte, error) {
+	return r.current, r.previous, nil
+}
+
+func TestServer_GuestCart(t *testing.T) {
+	secret := []byte("secret")
+
+	t.Run("a new guest cart is issued a signed token", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().CreateOrUpdateCart(gomock.Any(), "guest#ABC123", "42", 1).Return(types.Cart{}, nil)
+
+		mockedUUID := utils.NewMockUUIDGenerator(ctrl)
+		mockedUUID.EXPECT().Generate().Return("ABC123")
+
+		testServer, err := New(Config{
+			AllowedOrigins:  "*",
+			Storage:         mockedStorage,
+			UUIDGen:         mockedUUID,
+			CartTokenSecret: secret,
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/guest/cart", bytes.NewReader([]byte(`{"productId":"42","delta":1}`)))
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		guestID, err := testServer.verifyCartToken(context.Background(), recorder.Header().Get(cartTokenHeader))
+		assert.NoError(t, err)
+		assert.Equal(t, "ABC123", guestID)
+	})
+
+	t.Run("no token is issued when the cart is not written", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().CreateOrUpdateCart(gomock.Any(), "guest#ABC123", "42", 1).Return(types.Cart{}, storage.ErrorNotFound)
+
+		mockedUUID := utils.NewMockUUIDGenerator(ctrl)
+		mockedUUID.EXPECT().Generate().Return("ABC123")
+
+		testServer, err := New(Config{
+			AllowedOrigins:  "*",
+			Storage:         mockedStorage,
+			UUIDGen:         mockedUUID,
+			CartTokenSecret: secret,
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/guest/cart", bytes.NewReader([]byte(`{"productId":"42","delta":1}`)))
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.NotEqual(t, http.StatusOK, recorder.Code)
+		assert.Empty(t, recorder.Header().Get(cartTokenHeader))
+		assert.Empty(t, recorder.Result().Cookies())
+	})
+
+	t.Run("an expired token is rejected", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		testServer, err := New(Config{
+			AllowedOrigins:  "*",
+			Storage:         storage.NewMockStorage(ctrl),
+			CartTokenSecret: secret,
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/guest/cart", nil)
+		req.Header.Set(cartTokenHeader, signCartToken(secret, "ABC123", time.Now().Add(-time.Minute)))
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("a forged token is rejected", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		testServer, err := New(Config{
+			AllowedOrigins:  "*",
+			Storage:         storage.NewMockStorage(ctrl),
+			CartTokenSecret: secret,
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/guest/cart", nil)
+		req.Header.Set(cartTokenHeader, "ABC123.4102444800.forged")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("the tokens of the previous secret are accepted during a rotation", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().CreateOrUpdateCart(gomock.Any(), "guest#ABC123", "42", 1).Return(types.Cart{}, nil)
+
+		testServer, err := New(Config{
+			AllowedOrigins:   "*",
+			Storage:          mockedStorage,
+			CartTokenSecrets: rotatedCartTokenSecrets{current: []byte("rotated"), previous: secret},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/guest/cart", bytes.NewReader([]byte(`{"productId":"42","delta":1}`)))
+		req.Header.Set(cartTokenHeader, signCartToken(secret, "ABC123", time.Now().Add(time.Hour)))
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then the token is issued again with the current secret
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		token := recorder.Header().Get(cartTokenHeader)
+		guestID, err := (&Server{cartTokenSecrets: StaticCartTokenSecret("rotated")}).verifyCartToken(context.Background(), token)
+		assert.NoError(t, err)
+		assert.Equal(t, "ABC123", guestID)
+		_, err = (&Server{cartTokenSecrets: StaticCartTokenSecret(secret)}).verifyCartToken(context.Background(), token)
+		assert.Error(t, err, "the previous secret no longer signs")
+	})
+
+	t.Run("a client creates a bounded number of guest carts", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().CreateOrUpdateCart(gomock.Any(), "guest#ABC123", "42", 1).Return(types.Cart{}, nil).Times(maxGuestCartsPerWindow)
+
+		mockedUUID := utils.NewMockUUIDGenerator(ctrl)
+		mockedUUID.EXPECT().Generate().Return("ABC123").Times(maxGuestCartsPerWindow)
+
+		testServer, err := New(Config{
+			AllowedOrigins:  "*",
+			Storage:         mockedStorage,
+			UUIDGen:         mockedUUID,
+			CartTokenSecret: secret,
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+		for i := 0; i < maxGuestCartsPerWindow; i++ {
+			recorder := httptest.NewRecorder()
+			testServer.Mux.ServeHTTP(recorder, httptest.NewRequest("PUT", "/guest/cart", bytes.NewReader([]byte(`{"productId":"42","delta":1}`))))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+		}
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/guest/cart", bytes.NewReader([]byte(`{"productId":"42","delta":1}`)))
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+		assert.Empty(t, recorder.Header().Get(cartTokenHeader))
+	})
+
+	t.Run("the expired guest carts are released", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().ReleaseExpiredCarts(gomock.Any(), guestCartPrefix, gomock.Any()).DoAndReturn(
+			func(ctx context.Context, prefix string, updatedBefore time.Time) (int, error) {
+				assert.WithinDuration(t, time.Now().Add(-cartTokenMaxAge), updatedBefore, time.Minute, "the carts outlive their token")
+				return 2, nil
+			})
+
+		testServer, err := New(Config{
+			AllowedOrigins:  "*",
+			Storage:         mockedStorage,
+			CartTokenSecret: secret,
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		// When
+		released, err := testServer.ReleaseExpiredGuestCarts(context.Background())
+
+		// Then
+		assert.NoError(t, err)
+		assert.Equal(t, 2, released)
+	})
+
+	t.Run("the guest cart is merged on login", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().MergeCarts(gomock.Any(), "guest#ABC123", "adil").Return(types.Cart{ID: "adil"}, nil)
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+			CartTokenSecret:    secret,
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/me/cart/merge", nil)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set(cartTokenHeader, signCartToken(secret, "ABC123", time.Now().Add(time.Hour)))
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("a merge breaking a cart limit is refused", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().MergeCarts(gomock.Any(), "guest#ABC123", "adil").Return(types.Cart{},
+			fmt.Errorf("error - checking the cart limits: %w", &types.LimitError{Limit: types.LimitMaxPerOrder, Value: 2, ProductID: "42"}))
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+			CartTokenSecret:    secret,
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/me/cart/merge", nil)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set(cartTokenHeader, signCartToken(secret, "ABC123", time.Now().Add(time.Hour)))
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"limit":"maxPerOrder"`)
+	})
+}
+
+func TestServer_CartItems(t *testing.T) {
+	t.Run("set the quantity of an item", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().SetCartItemQuantity(gomock.Any(), "adil", "42", 3).Return(types.Cart{ID: "adil"}, nil)
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/me/cart/items/42", bytes.NewReader([]byte(`{"quantity":3}`)))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("a quantity limit is reached", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		limitErr := &types.LimitError{Limit: types.LimitMaxPerOrder, Value: 2, ProductID: "42"}
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().CreateOrUpdateCart(gomock.Any(), "adil", "42", 3).Return(types.Cart{}, fmt.Errorf("error - checking the cart limits: %w", limitErr))
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/me/cart", bytes.NewReader([]byte(`{"productId":"42","delta":3}`)))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "maxPerOrder limit of 2 reached for product 42")
+	})
+
+	t.Run("remove an item which is not in the cart", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().RemoveCartItem(gomock.Any(), "adil", "42").Return(types.Cart{}, storage.ErrorNotFound)
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("DELETE", "/me/cart/items/42", nil)
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+}
+
+func TestServer_UpdatePromotion(t *testing.T) {
+	t.Run("the code is not checked when the storage fails", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().GetPromotionByCode(gomock.Any(), "WELCOME").Return(types.Promotion{}, errors.New("timeout"))
+
+		testServer, err := New(Config{
+			FirebaseAuthClient: adminVerifier,
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/admin/promotions/1", bytes.NewReader([]byte(`{"code":"welcome","type":"percentage","percentage":10}`)))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	})
+}
+
+func TestServer_ApplyCoupon(t *testing.T) {
+	t.Run("an expired coupon is rejected", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		endsAt := time.Now().Add(-time.Hour)
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().GetPromotionByCode(gomock.Any(), "WELCOME").Return(types.Promotion{
+			ID:         "1",
+			Code:       "WELCOME",
+			Type:       types.PromotionPercentage,
+			Percentage: 10,
+			EndsAt:     &endsAt,
+		}, nil)
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/me/cart/coupons", bytes.NewReader([]byte(`{"code":"WELCOME"}`)))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	})
+
+	t.Run("the cart is returned with the discount", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		promotion := types.Promotion{ID: "1", Code: "WELCOME", Type: types.PromotionPercentage, Percentage: 10}
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().GetPromotionByCode(gomock.Any(), "WELCOME").Return(promotion, nil).Times(2)
+		mockedStorage.EXPECT().ApplyCoupon(gomock.Any(), "adil", "adil", promotion).Return(types.Cart{
+			ID:      "adil",
+			Items:   map[string]types.Item{"42": {ID: "42", Quantity: 1}},
+			Coupons: []string{"WELCOME"},
+		}, nil)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "42").Return(types.Product{
+			ID:               "42",
+			PriceVATExcluded: types.Money{Amount: 1000, Currency: "EUR"},
+			VAT:              types.Money{Amount: 200, Currency: "EUR"},
+			TotalPrice:       types.Money{Amount: 1200, Currency: "EUR"},
+		}, nil)
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/me/cart/coupons", bytes.NewReader([]byte(`{"code":"WELCOME"}`)))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var cart types.Cart
+		err = json.Unmarshal(recorder.Body.Bytes(), &cart)
+		assert.NoError(t, err, "the response should be a cart")
+		assert.Equal(t, int64(1080), cart.Totals.TotalVATInc.Amount())
+		assert.Len(t, cart.Totals.Discounts, 1)
+	})
+}
+
+func TestServer_Shipping(t *testing.T) {
+	zone := types.ShippingZone{ID: "fr", Name: "France", Countries: []string{"FR"}}
+	method := types.ShippingMethod{
+		ID:        "colissimo",
+		Name:      "Colissimo",
+		ZoneID:    "fr",
+		RateBasis: types.ShippingRateByWeight,
+		Rates: []types.ShippingRate{
+			{UpTo: 1000, Price: types.Money{Amount: 600, Currency: "EUR"}},
+			{UpTo: 0, Price: types.Money{Amount: 1200, Currency: "EUR"}},
+		},
+		VATRate:           2000,
+		VolumetricDivisor: 5000,
+	}
+	// 800 g but 6 kg of volumetric weight
+	product := types.Product{
+		ID:               "42",
+		PriceVATExcluded: types.Money{Amount: 1000, Currency: "EUR"},
+		VAT:              types.Money{Amount: 200, Currency: "EUR"},
+		TotalPrice:       types.Money{Amount: 1200, Currency: "EUR"},
+		Weight:           800,
+		Dimensions:       &types.Dimensions{Length: 400, Width: 300, Height: 250},
+	}
+
+	t.Run("a zone without countries is rejected", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		testServer, err := New(Config{
+			FirebaseAuthClient: adminVerifier,
+			AllowedOrigins:     "*",
+			Storage:            storage.NewMockStorage(ctrl),
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/admin/shipping/zones", bytes.NewReader([]byte(`{"name":"France","countries":[]}`)))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("a zone is created with a generated id", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().CreateShippingZone(gomock.Any(), types.ShippingZone{ID: "ABC123", Name: "France", Countries: []string{"FR"}}).Return(nil)
+
+		mockedUUID := utils.NewMockUUIDGenerator(ctrl)
+		mockedUUID.EXPECT().Generate().Return("ABC123")
+
+		testServer, err := New(Config{
+			FirebaseAuthClient: adminVerifier,
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			UUIDGen:            mockedUUID,
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/admin/shipping/zones", bytes.NewReader([]byte(`{"name":"France","countries":["FR"]}`)))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"id":"ABC123"`)
+	})
+
+	t.Run("updating an unknown method answers not found", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().UpdateShippingMethod(gomock.Any(), method).Return(storage.ErrorNotFound)
+
+		testServer, err := New(Config{
+			FirebaseAuthClient: adminVerifier,
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		body, err := json.Marshal(method)
+		assert.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/admin/shipping/methods/colissimo", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("deleting a method", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().DeleteShippingMethod(gomock.Any(), "colissimo").Return(nil)
+
+		testServer, err := New(Config{
+			FirebaseAuthClient: adminVerifier,
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("DELETE", "/admin/shipping/methods/colissimo", nil)
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("the options are priced with the volumetric weight", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().GetCart(gomock.Any(), "adil").Return(types.Cart{
+			ID:    "adil",
+			Items: map[string]types.Item{"42": {ID: "42", Quantity: 1}},
+		}, nil)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "42").Return(product, nil)
+		mockedStorage.EXPECT().ShippingZones(gomock.Any()).Return([]types.ShippingZone{zone}, nil)
+		mockedStorage.EXPECT().ShippingMethods(gomock.Any()).Return([]types.ShippingMethod{method}, nil)
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/me/cart/shipping-options?country=fr", nil)
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var options []types.ShippingOption
+		err = json.Unmarshal(recorder.Body.Bytes(), &options)
+		assert.NoError(t, err, "the response should be a list of options")
+		assert.Len(t, options, 1)
+		assert.Equal(t, "colissimo", options[0].MethodID)
+		assert.Equal(t, int64(1200), options[0].PriceVATInc.Amount())
+	})
+
+	t.Run("a method of another zone can't be selected", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().GetCart(gomock.Any(), "adil").Return(types.Cart{
+			ID:    "adil",
+			Items: map[string]types.Item{"42": {ID: "42", Quantity: 1}},
+		}, nil)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "42").Return(product, nil)
+		mockedStorage.EXPECT().ShippingZones(gomock.Any()).Return([]types.ShippingZone{zone}, nil)
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/me/cart/shipping", bytes.NewReader([]byte(`{"country":"de","methodId":"colissimo"}`)))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	})
+
+	t.Run("the selected method is added to the total", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		cart := types.Cart{
+			ID:    "adil",
+			Items: map[string]types.Item{"42": {ID: "42", Quantity: 1}},
+		}
+		selected := cart
+		selected.ShippingCountry, selected.ShippingMethodID = "FR", "colissimo"
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().GetCart(gomock.Any(), "adil").Return(cart, nil)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "42").Return(product, nil).Times(2)
+		mockedStorage.EXPECT().ShippingZones(gomock.Any()).Return([]types.ShippingZone{zone}, nil).Times(2)
+		mockedStorage.EXPECT().ShippingMethods(gomock.Any()).Return([]types.ShippingMethod{method}, nil).Times(2)
+		mockedStorage.EXPECT().SelectShipping(gomock.Any(), "adil", "FR", "colissimo").Return(selected, nil)
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/me/cart/shipping", bytes.NewReader([]byte(`{"country":"fr","methodId":"colissimo"}`)))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var got types.Cart
+		err = json.Unmarshal(recorder.Body.Bytes(), &got)
+		assert.NoError(t, err, "the response should be a cart")
+		assert.Equal(t, int64(2400), got.Totals.TotalVATInc.Amount())
+		assert.Equal(t, "colissimo", got.Totals.Shipping.MethodID)
+	})
+}
+
+func TestServer_Addresses(t *testing.T) {
+	t.Run("an address missing a mandatory field is rejected", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		body := `{"firstName":"Adil","lastName":"Doe","line1":"1 Main St","city":"New York","postalCode":"10001","country":"us"}`
+		req := httptest.NewRequest("POST", "/me/addresses", bytes.NewReader([]byte(body)))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "region is mandatory in US")
+	})
+
+	t.Run("the address is added to the book of the user", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().CreateAddress(gomock.Any(), "adil", gomock.Any()).DoAndReturn(func(_ context.Context, userID string, a types.Address) (types.Address, error) {
+			a.Default = true
+			return a, nil
+		})
+
+		mockedUUID := utils.NewMockUUIDGenerator(ctrl)
+		mockedUUID.EXPECT().Generate().Return("ABC123")
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			UUIDGen:            mockedUUID,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		body := `{"type":"shipping","firstName":"Adil","lastName":"Doe","line1":"10 rue de Rivoli","city":"Paris","postalCode":"75001","country":"fr"}`
+		req := httptest.NewRequest("POST", "/me/addresses", bytes.NewReader([]byte(body)))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var a types.Address
+		err = json.Unmarshal(recorder.Body.Bytes(), &a)
+		assert.NoError(t, err, "the response should be an address")
+		assert.Equal(t, "ABC123", a.ID)
+		assert.Equal(t, "FR", a.Country)
+		assert.True(t, a.Default)
+	})
+}
+
+func TestServer_Profile(t *testing.T) {
+	t.Run("the profile is created from the token claims on first access", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().CreateProfile(gomock.Any(), gomock.Any()).Return(nil)
+
+		testServer, err := New(Config{
+			AllowedOrigins: "*",
+			Storage:        mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil", claims: map[string]interface{}{
+				"email": "adil@example.com",
+				"name":  "Adil",
+			}},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/me/profile", nil)
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var p types.Profile
+		err = json.Unmarshal(recorder.Body.Bytes(), &p)
+		assert.NoError(t, err, "the response should be a profile")
+		assert.Equal(t, "adil", p.UserID)
+		assert.Equal(t, "adil@example.com", p.Email)
+		assert.Equal(t, "Adil", p.DisplayName)
+	})
+
+	t.Run("the cart is formatted with the locale of the profile", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().GetProfile(gomock.Any(), "adil").Return(types.Profile{UserID: "adil", Locale: "fr-FR"}, nil)
+		mockedStorage.EXPECT().GetCart(gomock.Any(), "adil").Return(types.Cart{
+			ID:    "adil",
+			Items: map[string]types.Item{"42": {ID: "42", Quantity: 1}},
+		}, nil)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "42").Return(types.Product{
+			ID:               "42",
+			PriceVATExcluded: types.Money{Amount: 100000, Currency: "EUR"},
+			VAT:              types.Money{Amount: 20000, Currency: "EUR"},
+			TotalPrice:       types.Money{Amount: 120000, Currency: "EUR"},
+		}, nil).Times(2)
+		mockedStorage.EXPECT().CoPurchases(gomock.Any(), "42", gomock.Any()).Return([]types.CoPurchase{}, nil)
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/me/cart", nil)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Accept-Language", "en-US,en;q=0.8")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "\"displayTotalVATInc\":\"1\u202f200,00\u00a0€\"")
+	})
+}
+
+func TestServer_GDPR(t *testing.T) {
+	t.Run("the export contains the data of the user", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().Addresses(gomock.Any(), "adil").Return([]types.Address{{ID: "1", City: "Paris"}}, nil)
+		mockedStorage.EXPECT().GetCart(gomock.Any(), "adil").Return(types.Cart{}, storage.ErrorNotFound)
+		mockedStorage.EXPECT().Wishlist(gomock.Any(), "adil").Return([]types.WishlistItem{}, nil)
+		mockedStorage.EXPECT().UserReviews(gomock.Any(), "adil").Return([]types.Review{{ID: "r1", ProductID: "42", Rating: 4}}, nil)
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/me/export", nil)
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, `attachment; filename="export-adil.json"`, recorder.Header().Get("Content-Disposition"))
+		var export types.UserExport
+		err = json.Unmarshal(recorder.Body.Bytes(), &export)
+		assert.NoError(t, err, "the response should be an export")
+		assert.Equal(t, "adil", export.UserID)
+		assert.Nil(t, export.Profile)
+		assert.Nil(t, export.Cart)
+		assert.Len(t, export.Addresses, 1)
+		assert.Len(t, export.Reviews, 1)
+	})
+
+	t.Run("the data of the user are deleted", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().DeleteUserData(gomock.Any(), "adil").Return(nil)
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("DELETE", "/me", nil)
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+}
+
+func TestServer_Wishlist(t *testing.T) {
+	t.Run("the wishlist shows the price and availability of its products", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().Wishlist(gomock.Any(), "adil").Return([]types.WishlistItem{{ProductID: "42", Quantity: 1}, {ProductID: "43", Quantity: 1}}, nil)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "42").Return(types.Product{ID: "42", Stock: 0, TotalPrice: types.Money{Amount: 1000, Currency: "EUR"}}, nil)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "43").Return(types.Product{}, storage.ErrorNotFound)
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/me/wishlist", nil)
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var entries []types.WishlistEntry
+		err = json.Unmarshal(recorder.Body.Bytes(), &entries)
+		assert.NoError(t, err, "the response should be a wishlist")
+		assert.Len(t, entries, 2)
+		assert.Equal(t, "€10.00", entries[0].Product.DisplayPrice)
+		assert.False(t, entries[0].Available)
+		assert.Nil(t, entries[1].Product)
+	})
+
+	t.Run("moving to the cart reserves the saved quantity", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		expectNoProfile(mockedStorage)
+		mockedStorage.EXPECT().Wishlist(gomock.Any(), "adil").Return([]types.WishlistItem{{ProductID: "42", Quantity: 2}}, nil)
+		gomock.InOrder(
+			mockedStorage.EXPECT().CreateOrUpdateCart(gomock.Any(), "adil", "42", 2).Return(types.Cart{ID: "adil"}, nil),
+			mockedStorage.EXPECT().RemoveFromWishlist(gomock.Any(), "adil", "42").Return(nil),
+		)
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/me/wishlist/42/move-to-cart", nil)
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+}
+
+// fakeDeliveryChecker tells every product was delivered when delivered is
+// true.
+type fakeDeliveryChecker struct {
+	delivered bool
+}
+
+func (f fakeDeliveryChecker) HasDeliveredProduct(ctx context.Context, userID string, productID string) (bool, error) {
+	return f.delivered, nil
+}
+
+func TestServer_Reviews(t *testing.T) {
+	t.Run("a customer without a delivered order cannot review", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "42").Return(types.Product{ID: "42"}, nil)
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+			DeliveryChecker:    fakeDeliveryChecker{delivered: false},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/products/42/reviews", bytes.NewReader([]byte(`{"rating":5,"title":"Great"}`)))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+
+	t.Run("the review is queued for moderation", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "42").Return(types.Product{ID: "42"}, nil)
+		mockedStorage.EXPECT().GetProfile(gomock.Any(), "adil").Return(types.Profile{UserID: "adil", Name: "Adil Doe", DisplayName: "Adil D."}, nil)
+		mockedStorage.EXPECT().CreateReview(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, r types.Review) error {
+			assert.Equal(t, "adil", r.UserID)
+			assert.Equal(t, "Adil D.", r.AuthorName, "the review is published under the display name")
+			assert.Equal(t, types.ReviewPending, r.Status)
+			return nil
+		})
+
+		mockedUUID := utils.NewMockUUIDGenerator(ctrl)
+		mockedUUID.EXPECT().Generate().Return("ABC123")
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            mockedStorage,
+			UUIDGen:            mockedUUID,
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+			DeliveryChecker:    fakeDeliveryChecker{delivered: true},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/products/42/reviews", bytes.NewReader([]byte(`{"rating":5,"title":"Great"}`)))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.NotContains(t, recorder.Body.String(), "adil", "the user id should not be exposed")
+	})
+
+	t.Run("reviews can't be written without a delivery checker", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		testServer, err := New(Config{
+			AllowedOrigins:     "*",
+			Storage:            storage.NewMockStorage(ctrl),
+			FirebaseAuthClient: fakeVerifier{uid: "adil"},
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/products/42/reviews", bytes.NewReader([]byte(`{"rating":5,"title":"Great"}`)))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+	})
+
+	t.Run("products are sorted by rating", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().Products(gomock.Any()).Return([]types.Product{
+			{ID: "1", RatingCount: 2, RatingTotal: 6},
+			{ID: "2", RatingCount: 1, RatingTotal: 5},
+			{ID: "3"},
+		}, nil)
+
+		testServer, err := New(Config{
+			AllowedOrigins: "*",
+			Storage:        mockedStorage,
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/products?sort=rating", nil)
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var products []types.Product
+		err = json.Unmarshal(recorder.Body.Bytes(), &products)
+		assert.NoError(t, err, "the response should be a list of products")
+		assert.Equal(t, "2", products[0].ID)
+		assert.Equal(t, float64(3), products[1].Rating)
+		assert.Equal(t, "3", products[2].ID)
+	})
+}
+
+func TestServer_SearchProducts(t *testing.T) {
+	catalog := []types.Product{
+		{ID: "1", Name: "Running shoes", TotalPrice: types.Money{Amount: 8999, Currency: "EUR"}},
+		{ID: "2", Name: "Water bottle", TotalPrice: types.Money{Amount: 1999, Currency: "EUR"}},
+	}
+
+	t.Run("only the hits are fetched", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		// the index is filled once for both searches
+		mockedStorage.EXPECT().Products(gomock.Any()).Return(catalog, nil)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "1").Return(catalog[0], nil).Times(2)
+
+		testServer, err := New(Config{
+			AllowedOrigins: "*",
+			Storage:        mockedStorage,
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		for i := 0; i < 2; i++ {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/products/search?q=shoe", nil)
+
+			// When
+			testServer.Mux.ServeHTTP(recorder, req)
+
+			// Then
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			var response SearchResponse
+			err = json.Unmarshal(recorder.Body.Bytes(), &response)
+			assert.NoError(t, err, "the response should be a search response")
+			assert.Equal(t, 1, response.Total)
+			assert.Equal(t, "1", response.Products[0].ID)
+		}
+	})
+
+	t.Run("the index is reloaded once expired", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		gomock.InOrder(
+			mockedStorage.EXPECT().Products(gomock.Any()).Return(catalog, nil),
+			mockedStorage.EXPECT().Products(gomock.Any()).Return(catalog[1:], nil),
+		)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "1").Return(catalog[0], nil)
+
+		testServer, err := New(Config{
+			AllowedOrigins: "*",
+			Storage:        mockedStorage,
+			SearchIndexTTL: time.Nanosecond,
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		totals := make([]int, 0, 2)
+		for i := 0; i < 2; i++ {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/products/search?q=shoe", nil)
+
+			// When
+			testServer.Mux.ServeHTTP(recorder, req)
+
+			var response SearchResponse
+			err = json.Unmarshal(recorder.Body.Bytes(), &response)
+			assert.NoError(t, err, "the response should be a search response")
+			totals = append(totals, response.Total)
+		}
+
+		// Then
+		assert.Equal(t, []int{1, 0}, totals, "the product deleted elsewhere is no longer found")
+	})
+	t.Run("the page is bounded", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		many := make([]types.Product, 0, maxSearchLimit+10)
+		for i := 0; i < maxSearchLimit+10; i++ {
+			many = append(many, types.Product{ID: strconv.Itoa(i), Name: "Running shoes", TotalPrice: types.Money{Amount: 8999, Currency: "EUR"}})
+		}
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().Products(gomock.Any()).Return(many, nil)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id string) (types.Product, error) {
+			i, _ := strconv.Atoi(id)
+			return many[i], nil
+		}).Times(maxSearchLimit)
+
+		testServer, err := New(Config{
+			AllowedOrigins: "*",
+			Storage:        mockedStorage,
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/products/search?q=shoe&limit=1000000", nil)
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var response SearchResponse
+		err = json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.NoError(t, err, "the response should be a search response")
+		assert.Equal(t, maxSearchLimit+10, response.Total)
+		assert.Len(t, response.Products, maxSearchLimit)
+	})
+
+	t.Run("a negative offset is refused", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+
+		testServer, err := New(Config{
+			AllowedOrigins: "*",
+			Storage:        mockedStorage,
+		})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/products/search?q=shoe&offset=-1", nil)
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+}
+
+func TestServer_ProductImages(t *testing.T) {
+	// Given
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	images, err := objectstore.NewLocal(t.TempDir(), "http://images.test", []byte("secret"))
+	assert.NoError(t, err)
+	product := types.Product{ID: "p1", Version: 3}
+
+	mockedStorage := storage.NewMockStorage(ctrl)
+	mockedStorage.EXPECT().GetProductById(gomock.Any(), "p1").Return(product, nil).AnyTimes()
+	mockedUUID := utils.NewMockUUIDGenerator(ctrl)
+	mockedUUID.EXPECT().Generate().Return("img1").AnyTimes()
+
+	testServer, err := New(Config{
+		FirebaseAuthClient: adminVerifier,
+		AllowedOrigins:     "*",
+		Storage:            mockedStorage,
+		UUIDGen:            mockedUUID,
+		ObjectStore:        images,
+	})
+	assert.NoError(t, err, "building a server should not return an error")
+
+	// a 800x400 png
+	var pngBody bytes.Buffer
+	assert.NoError(t, png.Encode(&pngBody, image.NewRGBA(image.Rect(0, 0, 800, 400))))
+
+	var upload types.ImageUpload
+	t.Run("refuses unsupported types", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/admin/products/p1/images/uploads", bytes.NewBufferString(`{"contentType":"image/svg+xml","size":100}`))
+		req.Header.Set("Authorization", "Bearer token")
+
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	})
+
+	t.Run("presigns the upload", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/admin/products/p1/images/uploads", bytes.NewBufferString(fmt.Sprintf(`{"contentType":"image/png","size":%d}`, pngBody.Len())))
+		req.Header.Set("Authorization", "Bearer token")
+
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &upload))
+		assert.Equal(t, "products/p1/img1/original.png", upload.Key)
+	})
+
+	t.Run("refuses an upload of another size than the signed one", func(t *testing.T) {
+		uploadReq := httptest.NewRequest("PUT", strings.TrimPrefix(upload.UploadURL, "http://images.test"), bytes.NewReader(append(pngBody.Bytes(), 0)))
+		uploadReq.Header.Set("Content-Type", "image/png")
+		uploadRecorder := httptest.NewRecorder()
+
+		images.Handler().ServeHTTP(uploadRecorder, uploadReq)
+
+		assert.Equal(t, http.StatusForbidden, uploadRecorder.Code)
+	})
+
+	t.Run("adds the uploaded image with its renditions", func(t *testing.T) {
+		// upload the png with the presigned url
+		uploadReq := httptest.NewRequest("PUT", strings.TrimPrefix(upload.UploadURL, "http://images.test"), bytes.NewReader(pngBody.Bytes()))
+		uploadReq.Header.Set("Content-Type", "image/png")
+		uploadRecorder := httptest.NewRecorder()
+		images.Handler().ServeHTTP(uploadRecorder, uploadReq)
+		assert.Equal(t, http.StatusOK, uploadRecorder.Code)
+
+		var stored []types.ProductImage
+		mockedStorage.EXPECT().UpdateProductImages(gomock.Any(), "p1", uint(3), gomock.Any()).
+			DoAndReturn(func(_ context.Context, id string, version uint, images []types.ProductImage) error {
+				stored = images
+				return nil
+			})
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/admin/products/p1/images", bytes.NewBufferString(`{"key":"products/p1/img1/original.png","alt":"Front"}`))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Len(t, stored, 1)
+		assert.Equal(t, "img1", stored[0].ID)
+		assert.Equal(t, "Front", stored[0].Alt)
+		assert.Equal(t, 800, stored[0].Width)
+		assert.Equal(t, types.ImageRendition{
+			Key:    "products/p1/img1/thumbnail.jpg",
+			URL:    "http://images.test/products/p1/img1/thumbnail.jpg",
+			Width:  150,
+			Height: 75,
+		}, stored[0].Renditions["thumbnail"])
+		assert.Equal(t, 600, stored[0].Renditions["medium"].Width)
+
+		var p types.Product
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &p))
+		assert.Equal(t, "http://images.test/products/p1/img1/original.png", p.Image)
+	})
+
+	t.Run("refuses an upload that is not an image", func(t *testing.T) {
+		assert.NoError(t, images.Put(context.Background(), "products/p1/img2/original.png", []byte("not an image"), "image/png"))
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/admin/products/p1/images", bytes.NewBufferString(`{"key":"products/p1/img2/original.png"}`))
+		req.Header.Set("Authorization", "Bearer token")
+
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		_, err := images.Get(context.Background(), "products/p1/img2/original.png", imaging.MaxSize)
+		assert.ErrorIs(t, err, objectstore.ErrorNotFound, "the invalid upload should be deleted")
+	})
+
+	t.Run("refuses an upload larger than an image can be", func(t *testing.T) {
+		assert.NoError(t, images.Put(context.Background(), "products/p1/img3/original.png", make([]byte, imaging.MaxSize+1), "image/png"))
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/admin/products/p1/images", bytes.NewBufferString(`{"key":"products/p1/img3/original.png"}`))
+		req.Header.Set("Authorization", "Bearer token")
+
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		_, err := images.Get(context.Background(), "products/p1/img3/original.png", imaging.MaxSize)
+		assert.ErrorIs(t, err, objectstore.ErrorNotFound, "the upload should be deleted")
+	})
+}
+
+func TestServer_Translations(t *testing.T) {
+	product := types.Product{
+		ID:      "p1",
+		Name:    "Socks",
+		Version: 2,
+		Translations: map[string]types.ProductTranslation{
+			"fr": {Name: "Chaussettes"},
+		},
+	}
+
+	t.Run("localizes the products with the accept-language header", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "p1").Return(product, nil)
+
+		testServer, err := New(Config{AllowedOrigins: "*", Storage: mockedStorage})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/products/p1", nil)
+		req.Header.Set("Accept-Language", "fr-FR,fr;q=0.9,en;q=0.8")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var p types.Product
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &p))
+		assert.Equal(t, "Chaussettes", p.Name)
+		assert.Nil(t, p.Translations)
+	})
+
+	t.Run("localizes the categories with the lang parameter", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().Categories(gomock.Any()).Return([]types.Category{{
+			ID:           "c1",
+			Name:         "Shoes",
+			Translations: map[string]types.CategoryTranslation{"fr": {Name: "Chaussures"}},
+		}}, nil)
+
+		testServer, err := New(Config{AllowedOrigins: "*", Storage: mockedStorage})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/categories?lang=fr", nil)
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		var categories []types.Category
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &categories))
+		assert.Equal(t, "Chaussures", categories[0].Name)
+	})
+
+	t.Run("sets a product translation", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "p1").Return(product, nil).Times(2)
+		mockedStorage.EXPECT().UpdateProductTranslations(gomock.Any(), "p1", uint(2), map[string]types.ProductTranslation{
+			"fr":    {Name: "Chaussettes"},
+			"de-DE": {Name: "Socken"},
+		}).Return(nil)
+
+		testServer, err := New(Config{FirebaseAuthClient: adminVerifier, AllowedOrigins: "*", Storage: mockedStorage})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/admin/products/p1/translations/de_de", bytes.NewBufferString(`{"name":"Socken"}`))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("refuses the default locale", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		testServer, err := New(Config{FirebaseAuthClient: adminVerifier, AllowedOrigins: "*", Storage: storage.NewMockStorage(ctrl)})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/admin/products/p1/translations/en", bytes.NewBufferString(`{"name":"Socks"}`))
+		req.Header.Set("Authorization", "Bearer token")
+
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+}
+
+func TestServer_Prices(t *testing.T) {
+	eur := func(amount int64) types.Money {
+		return types.Money{Amount: amount, Currency: "EUR"}
+	}
+
+	t.Run("returns the sale price with the reference price", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		endsAt := time.Now().Add(time.Hour)
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "p1").Return(types.Product{
+			ID:               "p1",
+			PriceVATExcluded: eur(1000),
+			VAT:              eur(200),
+			TotalPrice:       eur(1200),
+			PriceSchedule: []types.ScheduledPrice{{
+				ID:               "sale",
+				PriceVATExcluded: eur(500),
+				VAT:              eur(100),
+				TotalPrice:       eur(600),
+				StartsAt:         time.Now().Add(-time.Hour),
+				EndsAt:           &endsAt,
+			}},
+		}, nil)
+
+		testServer, err := New(Config{AllowedOrigins: "*", Storage: mockedStorage})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/products/p1", nil)
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		var p types.Product
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &p))
+		assert.Equal(t, int64(600), p.TotalPrice.Amount)
+		assert.Equal(t, int64(1200), p.ReferencePrice.Amount)
+		assert.NotNil(t, p.PriceEndsAt)
+		assert.Nil(t, p.PriceSchedule, "the schedule is not public")
+	})
+
+	t.Run("schedules a sale", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "p1").Return(types.Product{ID: "p1", TotalPrice: eur(1200), Version: 4}, nil)
+		var scheduled types.Product
+		mockedStorage.EXPECT().UpdatePriceSchedule(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, p types.Product, entry types.PriceHistoryEntry) error {
+				assert.Equal(t, uint(4), p.Version)
+				assert.Len(t, p.PriceSchedule, 1)
+				assert.Equal(t, "price1", entry.ID)
+				assert.Equal(t, int64(600), entry.TotalPrice.Amount)
+				scheduled = p
+				return nil
+			})
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "p1").DoAndReturn(func(context.Context, string) (types.Product, error) {
+			return scheduled, nil
+		})
+		index := search.NewMemory()
+		mockedUUID := utils.NewMockUUIDGenerator(ctrl)
+		mockedUUID.EXPECT().Generate().Return("price1")
+
+		testServer, err := New(Config{FirebaseAuthClient: adminVerifier, AllowedOrigins: "*", Storage: mockedStorage, UUIDGen: mockedUUID, SearchIndex: index})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		endsAt := time.Now().Add(48 * time.Hour).Format(time.RFC3339)
+		body := fmt.Sprintf(`{"priceVatExcluded":{"amount":500,"currency":"EUR"},"vat":{"amount":100,"currency":"EUR"},"totalPrice":{"amount":600,"currency":"EUR"},"endsAt":%q}`, endsAt)
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/admin/products/p1/prices", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		result, err := index.Search(search.Query{PriceBand: "0-10"})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Total, "the sale is searchable")
+	})
+
+	t.Run("refuses inconsistent prices", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "p1").Return(types.Product{ID: "p1", TotalPrice: eur(1200)}, nil)
+
+		testServer, err := New(Config{FirebaseAuthClient: adminVerifier, AllowedOrigins: "*", Storage: mockedStorage})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/admin/products/p1/prices", bytes.NewBufferString(`{"priceVatExcluded":{"amount":500,"currency":"EUR"},"vat":{"amount":100,"currency":"EUR"},"totalPrice":{"amount":700,"currency":"EUR"}}`))
+		req.Header.Set("Authorization", "Bearer token")
+
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	})
+}
+
+func TestServer_RelatedProducts(t *testing.T) {
+	// Given
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedStorage := storage.NewMockStorage(ctrl)
+	mockedStorage.EXPECT().GetProductById(gomock.Any(), "camera").Return(types.Product{
+		ID: "camera",
+		Relations: []types.ProductRelation{
+			{ProductID: "battery", Type: types.RelationAccessory},
+			{ProductID: "deleted", Type: types.RelationRelated},
+		},
+	}, nil)
+	mockedStorage.EXPECT().GetProductById(gomock.Any(), "battery").Return(types.Product{ID: "battery"}, nil)
+	mockedStorage.EXPECT().GetProductById(gomock.Any(), "deleted").Return(types.Product{}, storage.ErrorNotFound)
+	mockedStorage.EXPECT().GetProductById(gomock.Any(), "sd-card").Return(types.Product{ID: "sd-card"}, nil)
+	mockedStorage.EXPECT().CoPurchases(gomock.Any(), "camera", gomock.Any()).Return([]types.CoPurchase{{ProductID: "sd-card", Count: 4}}, nil)
+
+	testServer, err := New(Config{AllowedOrigins: "*", Storage: mockedStorage})
+	assert.NoError(t, err, "building a server should not return an error")
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/products/camera/related", nil)
+
+	// When
+	testServer.Mux.ServeHTTP(recorder, req)
+
+	// Then
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var related types.RelatedProducts
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &related))
+	assert.Equal(t, "battery", related.Accessories[0].ID)
+	assert.Empty(t, related.Related, "deleted products are skipped")
+	assert.Empty(t, related.Upsells)
+	assert.Equal(t, "sd-card", related.FrequentlyBoughtTogether[0].ID)
+}
+
+func TestServer_RecordPurchase(t *testing.T) {
+	t.Run("records the products of the order", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().RecordPurchase(gomock.Any(), "order1", []string{"camera", "sd-card"}).Return(nil)
+
+		testServer, err := New(Config{FirebaseAuthClient: adminVerifier, AllowedOrigins: "*", Storage: mockedStorage})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/admin/purchases", bytes.NewBufferString(`{"orderId":"order1","productIds":["camera","sd-card"]}`))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("the order id is mandatory", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockedStorage := storage.NewMockStorage(ctrl)
+
+		testServer, err := New(Config{FirebaseAuthClient: adminVerifier, AllowedOrigins: "*", Storage: mockedStorage})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/admin/purchases", bytes.NewBufferString(`{"productIds":["camera","sd-card"]}`))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+}
+
+func TestServer_ImportExportProducts(t *testing.T) {
+	eur := func(amount int64) types.Money { return types.Money{Amount: amount, Currency: "EUR"} }
+	camera := types.Product{
+		ID: "p1", SKU: "A-1", Name: "Camera",
+		PriceVATExcluded: eur(1000), VAT: eur(200), TotalPrice: eur(1200),
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().Products(gomock.Any()).Return([]types.Product{camera}, nil)
+		mockedUUID := utils.NewMockUUIDGenerator(ctrl)
+
+		testServer, err := New(Config{FirebaseAuthClient: adminVerifier, AllowedOrigins: "*", Storage: mockedStorage, UUIDGen: mockedUUID})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		feed := "sku,name\nA-1,Camera X\n"
+		req := httptest.NewRequest("POST", "/admin/products/import?dryRun=true", strings.NewReader(feed))
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Content-Type", "text/csv")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"changes":[{"field":"name","from":"Camera","to":"Camera X"}]`)
+	})
+
+	t.Run("invalid rows", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().Products(gomock.Any()).Return([]types.Product{camera}, nil)
+		mockedUUID := utils.NewMockUUIDGenerator(ctrl)
+
+		testServer, err := New(Config{FirebaseAuthClient: adminVerifier, AllowedOrigins: "*", Storage: mockedStorage, UUIDGen: mockedUUID})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		feed := `{"sku":"A-1","name":"Camera X"}` + "\n" + `{"sku":"B-1"}` + "\n"
+		req := httptest.NewRequest("POST", "/admin/products/import?format=ndjson", strings.NewReader(feed))
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"error":"name is required"`)
+	})
+
+	t.Run("export", func(t *testing.T) {
+		// Given
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockedStorage := storage.NewMockStorage(ctrl)
+		mockedStorage.EXPECT().Products(gomock.Any()).Return([]types.Product{camera}, nil)
+
+		testServer, err := New(Config{FirebaseAuthClient: adminVerifier, AllowedOrigins: "*", Storage: mockedStorage})
+		assert.NoError(t, err, "building a server should not return an error")
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/admin/products/export?format=csv", nil)
+		req.Header.Set("Authorization", "Bearer token")
+
+		// When
+		testServer.Mux.ServeHTTP(recorder, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "text/csv", recorder.Header().Get("Content-Type"))
+		assert.Equal(t, "id,sku,name,shortDescription,description,image,currency,priceVatExcluded,vat,totalPrice,categoryIds,weight\n"+
+			"p1,A-1,Camera,,,,EUR,1000,200,1200,,0\n", recorder.Body.String())
+	})
+}
+
+func TestServer_AdminRoutes(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier fakeVerifier
+		header   string
+		status   int
+	}{
+		{name: "without a token", verifier: adminVerifier, status: http.StatusForbidden},
+		{name: "without the admin claim", verifier: fakeVerifier{uid: "adil"}, header: "Bearer token", status: http.StatusForbidden},
+		{name: "as an administrator", verifier: adminVerifier, header: "Bearer token", status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockedStorage := storage.NewMockStorage(ctrl)
+			if tt.status == http.StatusOK {
+				mockedStorage.EXPECT().Promotions(gomock.Any()).Return([]types.Promotion{}, nil)
+			}
+			testServer, err := New(Config{AllowedOrigins: "*", Storage: mockedStorage, FirebaseAuthClient: tt.verifier})
+			assert.NoError(t, err, "building a server should not return an error")
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/admin/promotions", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			// When
+			testServer.Mux.ServeHTTP(recorder, req)
+
+			// Then
+			assert.Equal(t, tt.status, recorder.Code)
+		})
+	}
+}
+
+func TestLocalTokenVerifier(t *testing.T) {
+	// given
+	verifier, err := NewLocalTokenVerifier([]byte("secret"))
+	assert.NoError(t, err)
+	token, err := verifier.Sign("user-1", map[string]interface{}{"email": "a@b.c"})
+	assert.NoError(t, err)
+
+	t.Run("accepts its own tokens", func(t *testing.T) {
+		// when
+		verified, err := verifier.VerifyIDToken(context.Background(), token)
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", verified.UID)
+		assert.Equal(t, "a@b.c", verified.Claims["email"])
+	})
+
+	t.Run("rejects tampered tokens", func(t *testing.T) {
+		// given
+		other, _ := NewLocalTokenVerifier([]byte("other"))
+		forged, _ := other.Sign("admin", nil)
+
+		// when
+		_, err := verifier.VerifyIDToken(context.Background(), forged)
+
+		// then
+		assert.Error(t, err)
+		_, err = verifier.VerifyIDToken(context.Background(), "garbage")
+		assert.Error(t, err)
+	})
+
+	t.Run("needs a secret", func(t *testing.T) {
+		_, err := NewLocalTokenVerifier(nil)
+		assert.Error(t, err)
+	})
+}
+
+// fakeSecrets returns its value, or its error
+type fakeSecrets struct {
+	value string
+	err   error
+}
+
+func (f *fakeSecrets) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	return []byte(f.value), f.err
+}
+
+func TestFirebaseTokenVerifier(t *testing.T) {
+	// given
+	secrets := &fakeSecrets{value: `{"google": {"project_id": "p1"}}`}
+	verifier := &FirebaseTokenVerifier{
+		secrets: secrets,
+		name:    "/ecommerce/dev/secrets",
+		newClient: func(ctx context.Context, parameters secret.Parameters) (TokenVerifier, error) {
+			return fakeVerifier{uid: parameters.Google.ProjectId}, nil
+		},
+	}
+	verify := func() string {
+		token, err := verifier.VerifyIDToken(context.Background(), "token")
+		assert.NoError(t, err)
+		return token.UID
+	}
+
+	// when
+	first := verify()
+	secrets.value = `{"google": {"project_id": "p2"}}`
+	rotated := verify()
+	secrets.err = errors.New("throttled")
+	failing := verify()
+
+	// then
+	assert.Equal(t, "p1", first)
+	assert.Equal(t, "p2", rotated, "the client is rebuilt with the rotated credentials")
+	assert.Equal(t, "p2", failing, "the current client is kept while the secrets fail")
+}
+
+func TestServer_RequestLogger(t *testing.T) {
+	// given
+	ctrl := gomock.NewController(t)
+	mockedStorage := storage.NewMockStorage(ctrl)
+	expectNoProfile(mockedStorage)
+	mockedStorage.EXPECT().GetCart(gomock.Any(), "u1").Return(types.Cart{}, errors.New("timeout"))
+
+	var out bytes.Buffer
+	s, err := New(Config{
+		Storage:            mockedStorage,
+		FirebaseAuthClient: fakeVerifier{uid: "u1"},
+		Logger:             logging.New(&out, slog.LevelInfo),
+	})
+	assert.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/me/cart", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	req = req.WithContext(logging.WithRequestIDs(req.Context(), logging.RequestIDs{Lambda: "l1", Gateway: "g1"}))
+	recorder := httptest.NewRecorder()
+
+	// when
+	s.Mux.ServeHTTP(recorder, req)
+
+	// then
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, "g1", recorder.Header().Get("X-Request-Id"))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2, "the handler error then the request")
+
+	var handlerLog, requestLog map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &handlerLog))
+	assert.Equal(t, "retreiving the cart", handlerLog["msg"])
+	assert.Equal(t, "timeout", handlerLog["error"])
+	assert.Equal(t, "g1", handlerLog["requestId"])
+
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &requestLog))
+	assert.Equal(t, "ERROR", requestLog["level"])
+	assert.Equal(t, "g1", requestLog["requestId"])
+	assert.Equal(t, "l1", requestLog["lambdaRequestId"])
+	assert.Equal(t, "/me/cart", requestLog["route"])
+	assert.Equal(t, float64(http.StatusInternalServerError), requestLog["status"])
+	assert.Equal(t, "u1", requestLog["userId"])
+	assert.Contains(t, requestLog, "latencyMs")
+	assert.NotContains(t, out.String(), "Bearer", "the token is never logged")
+}
+
+func TestServer_RequestMetrics(t *testing.T) {
+	// given
+	ctrl := gomock.NewController(t)
+	mockedStorage := storage.NewMockStorage(ctrl)
+	mockedStorage.EXPECT().GetProductById(gomock.Any(), "p1").Return(types.Product{}, errors.New("timeout"))
+	registry := metrics.NewRegistry()
+	s, err := New(Config{Storage: mockedStorage, Metrics: registry})
+	assert.NoError(t, err)
+
+	// when
+	s.Mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products/p1", nil))
+	s.Mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	// then
+	var out bytes.Buffer
+	assert.NoError(t, registry.WritePrometheus(&out))
+	assert.Contains(t, out.String(), `http_requests_total{method="GET",route="/products/{productId}",status="500"} 1`, "labeled by the route pattern")
+	assert.Contains(t, out.String(), `http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, out.String(), `http_request_duration_seconds_count{method="GET",route="/products/{productId}",status="500"} 1`)
+}
+
+// useInMemoryTraces records the spans of the test
+func useInMemoryTraces(t *testing.T) *tracetest.InMemoryExporter {
+	provider, exporter := tracing.NewInMemory()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return exporter
+}
+
+func TestServer_Trace(t *testing.T) {
+	// given
+	exporter := useInMemoryTraces(t)
+	ctrl := gomock.NewController(t)
+	mockedStorage := storage.NewMockStorage(ctrl)
+	mockedStorage.EXPECT().GetProductById(gomock.Any(), "p1").Return(types.Product{}, errors.New("timeout"))
+	var out bytes.Buffer
+	s, err := New(Config{Storage: mockedStorage, Logger: logging.New(&out, slog.LevelInfo)})
+	assert.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/products/p1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	// when
+	s.Mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	// then
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "GET /products/{productId}", span.Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String(), "the trace of the caller is continued")
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+	assert.Equal(t, codes.Error, span.Status.Code)
+	assert.Contains(t, span.Attributes, semconv.HTTPRoute("/products/{productId}"))
+	assert.Contains(t, span.Attributes, semconv.HTTPResponseStatusCode(http.StatusInternalServerError))
+	assert.Contains(t, out.String(), `"traceId":"4bf92f3577b34da6a3ce929d0e0e4736"`, "the logs carry the trace")
+}
+
+func TestFirebaseTokenVerifier_Trace(t *testing.T) {
+	// given
+	exporter := useInMemoryTraces(t)
+	verifier := &FirebaseTokenVerifier{
+		secrets: &fakeSecrets{value: `{"google": {"project_id": "p1"}}`},
+		name:    "/ecommerce/dev/secrets",
+		newClient: func(ctx context.Context, parameters secret.Parameters) (TokenVerifier, error) {
+			return fakeVerifier{uid: "u1"}, nil
+		},
+	}
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+
+	// when
+	_, err := verifier.VerifyIDToken(ctx, "token")
+	parent.End()
+
+	// then
+	assert.NoError(t, err)
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "firebase.VerifyIDToken", spans[0].Name)
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent.SpanID(), "the verification is a child of the request")
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"pratbacknd/internal/storage"
+	"pratbacknd/internal/types"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (s *Server) ShippingZones(w http.ResponseWriter, r *http.Request) {
+	zones, err := s.storage.ShippingZones(r.Context())
+	if err != nil {
+		logger(r).Error("fetching shipping zones", "error", err)
+		s.errorJSON(w, errors.New("error fetching shipping zones"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, zones)
+}
+
+func (s *Server) CreateShippingZone(w http.ResponseWriter, r *http.Request) {
+	var z types.ShippingZone
+	err := s.readJSON(w, r, &z)
+	if err != nil {
+		logger(r).Warn("building json", "error", err)
+		s.errorJSON(w, errors.New("error reading shipping zone"), http.StatusBadRequest)
+		return
+	}
+
+	err = z.Validate()
+	if err != nil {
+		s.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	z.ID = s.uuidGen.Generate()
+
+	err = s.storage.CreateShippingZone(r.Context(), z)
+	if err != nil {
+		logger(r).Error("storing shipping zone", "error", err)
+		s.errorJSON(w, errors.New("error persisting shipping zone"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, z)
+}
+
+func (s *Server) UpdateShippingZone(w http.ResponseWriter, r *http.Request) {
+	var z types.ShippingZone
+	err := s.readJSON(w, r, &z)
+	if err != nil {
+		logger(r).Warn("building json", "error", err)
+		s.errorJSON(w, errors.New("error reading shipping zone"), http.StatusBadRequest)
+		return
+	}
+
+	err = z.Validate()
+	if err != nil {
+		s.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	z.ID = chi.URLParam(r, "zoneId")
+
+	err = s.storage.UpdateShippingZone(r.Context(), z)
+	if err != nil {
+		s.adminError(w, r, err, "error updating the shipping zone")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, z)
+}
+
+func (s *Server) DeleteShippingZone(w http.ResponseWriter, r *http.Request) {
+	err := s.storage.DeleteShippingZone(r.Context(), chi.URLParam(r, "zoneId"))
+	if err != nil {
+		s.adminError(w, r, err, "error deleting the shipping zone")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, nil)
+}
+
+func (s *Server) ShippingMethods(w http.ResponseWriter, r *http.Request) {
+	methods, err := s.storage.ShippingMethods(r.Context())
+	if err != nil {
+		logger(r).Error("fetching shipping methods", "error", err)
+		s.errorJSON(w, errors.New("error fetching shipping methods"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, methods)
+}
+
+func (s *Server) CreateShippingMethod(w http.ResponseWriter, r *http.Request) {
+	var m types.ShippingMethod
+	err := s.readJSON(w, r, &m)
+	if err != nil {
+		logger(r).Warn("building json", "error", err)
+		s.errorJSON(w, errors.New("error reading shipping method"), http.StatusBadRequest)
+		return
+	}
+
+	err = m.Validate()
+	if err != nil {
+		s.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	m.ID = s.uuidGen.Generate()
+
+	err = s.storage.CreateShippingMethod(r.Context(), m)
+	if err != nil {
+		logger(r).Error("storing shipping method", "error", err)
+		s.errorJSON(w, errors.New("error persisting shipping method"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, m)
+}
+
+func (s *Server) UpdateShippingMethod(w http.ResponseWriter, r *http.Request) {
+	var m types.ShippingMethod
+	err := s.readJSON(w, r, &m)
+	if err != nil {
+		logger(r).Warn("building json", "error", err)
+		s.errorJSON(w, errors.New("error reading shipping method"), http.StatusBadRequest)
+		return
+	}
+
+	err = m.Validate()
+	if err != nil {
+		s.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	m.ID = chi.URLParam(r, "methodId")
+
+	err = s.storage.UpdateShippingMethod(r.Context(), m)
+	if err != nil {
+		s.adminError(w, r, err, "error updating the shipping method")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, m)
+}
+
+func (s *Server) DeleteShippingMethod(w http.ResponseWriter, r *http.Request) {
+	err := s.storage.DeleteShippingMethod(r.Context(), chi.URLParam(r, "methodId"))
+	if err != nil {
+		s.adminError(w, r, err, "error deleting the shipping method")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, nil)
+}
+
+// adminError answers 404 when the element does not exist and 500 otherwise.
+func (s *Server) adminError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	if errors.Is(err, storage.ErrorNotFound) {
+		s.errorJSON(w, errors.New("not found"), http.StatusNotFound)
+		return
+	}
+	logger(r).Error(message, "error", err)
+	s.errorJSON(w, errors.New(message), http.StatusInternalServerError)
+}
+
+func (s Server) ShippingOptionsUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	cart, err := s.storage.GetCart(r.Context(), currentUser.ID)
+	if err != nil {
+		s.cartError(w, r, err, "error retreiving the cart")
+		return
+	}
+
+	country := r.URL.Query().Get("country")
+	if country == "" {
+		country = cart.ShippingCountry
+	}
+	if country == "" {
+		s.errorJSON(w, errors.New("error country is mondatory"), http.StatusBadRequest)
+		return
+	}
+
+	err = s.priceCart(r.Context(), &cart)
+	if err != nil {
+		logger(r).Error("pricing the cart", "error", err)
+		s.errorJSON(w, errors.New("error pricing the cart"), http.StatusInternalServerError)
+		return
+	}
+
+	options, err := s.shippingOptions(r.Context(), cart, country)
+	if err != nil {
+		logger(r).Error("computing shipping options", "error", err)
+		s.errorJSON(w, errors.New("error computing shipping options"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, options)
+}
+
+func (s Server) SelectShippingUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	var input types.SelectShippingInput
+	err = s.readJSON(w, r, &input)
+	if err != nil {
+		logger(r).Warn("reading json", "error", err)
+		s.errorJSON(w, errors.New("error reading shipping selection"), http.StatusBadRequest)
+		return
+	}
+	input.Country = types.NormalizeCountry(input.Country)
+
+	cart, err := s.storage.GetCart(r.Context(), currentUser.ID)
+	if err != nil {
+		s.cartError(w, r, err, "error retreiving the cart")
+		return
+	}
+
+	err = s.priceCart(r.Context(), &cart)
+	if err != nil {
+		logger(r).Error("pricing the cart", "error", err)
+		s.errorJSON(w, errors.New("error pricing the cart"), http.StatusInternalServerError)
+		return
+	}
+
+	options, err := s.shippingOptions(r.Context(), cart, input.Country)
+	if err != nil {
+		logger(r).Error("computing shipping options", "error", err)
+		s.errorJSON(w, errors.New("error computing shipping options"), http.StatusInternalServerError)
+		return
+	}
+
+	available := false
+	for _, o := range options {
+		available = available || o.MethodID == input.MethodID
+	}
+	if !available {
+		s.errorJSON(w, errors.New("shipping method is not available for this cart"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	cart, err = s.storage.SelectShipping(r.Context(), currentUser.ID, input.Country, input.MethodID)
+	if err != nil {
+		s.cartError(w, r, err, "error selecting the shipping method")
+		return
+	}
+
+	s.writeCart(w, r, cart)
+}
+
+// shippingOptions returns the methods delivering the country with their
+// price for the priced cart.
+func (s Server) shippingOptions(ctx context.Context, cart types.Cart, country string) ([]types.ShippingOption, error) {
+	options := make([]types.ShippingOption, 0)
+	if cart.Totals == nil {
+		return options, nil
+	}
+
+	zones, err := s.storage.ShippingZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zoneIDs := make(map[string]bool)
+	for _, z := range zones {
+		if z.Contains(country) {
+			zoneIDs[z.ID] = true
+		}
+	}
+	if len(zoneIDs) == 0 {
+		return options, nil
+	}
+
+	methods, err := s.storage.ShippingMethods(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the cart value does not include the shipping already selected
+	cartValue := cart.Totals.TotalVATInc
+	if cart.Totals.Shipping != nil {
+		cartValue, err = cartValue.Subtract(cart.Totals.Shipping.PriceVATInc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, m := range methods {
+		if !zoneIDs[m.ZoneID] {
+			continue
+		}
+		if option, ok := m.Quote(cart.Weight(m.VolumetricDivisor), cartValue); ok {
+			options = append(options, option)
+		}
+	}
+
+	return options, nil
+}
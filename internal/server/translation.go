@@ -0,0 +1,191 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"pratbacknd/internal/storage"
+	"pratbacknd/internal/types"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (s *Server) ProductTranslations(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.productFromURL(w, r)
+	if !ok {
+		return
+	}
+	s.writeJSON(w, http.StatusOK, productTranslations(p))
+}
+
+func (s *Server) SetProductTranslation(w http.ResponseWriter, r *http.Request) {
+	var t types.ProductTranslation
+	err := s.readJSON(w, r, &t)
+	if err != nil {
+		logger(r).Warn("reading json", "error", err)
+		s.errorJSON(w, errors.New("error reading translation"), http.StatusBadRequest)
+		return
+	}
+	err = t.Validate()
+	if err != nil {
+		s.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	locale, ok := s.translationLocale(w, r)
+	if !ok {
+		return
+	}
+	p, ok := s.productFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	translations := productTranslations(p)
+	translations[locale] = t
+	s.saveProductTranslations(w, r, p, translations)
+}
+
+func (s *Server) DeleteProductTranslation(w http.ResponseWriter, r *http.Request) {
+	locale, ok := s.translationLocale(w, r)
+	if !ok {
+		return
+	}
+	p, ok := s.productFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	translations := productTranslations(p)
+	if _, found := translations[locale]; !found {
+		s.errorJSON(w, errors.New("translation not found"), http.StatusNotFound)
+		return
+	}
+	delete(translations, locale)
+	s.saveProductTranslations(w, r, p, translations)
+}
+
+func (s *Server) CategoryTranslations(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.categoryForTranslations(w, r)
+	if !ok {
+		return
+	}
+	s.writeJSON(w, http.StatusOK, categoryTranslations(c))
+}
+
+func (s *Server) SetCategoryTranslation(w http.ResponseWriter, r *http.Request) {
+	var t types.CategoryTranslation
+	err := s.readJSON(w, r, &t)
+	if err != nil {
+		logger(r).Warn("reading json", "error", err)
+		s.errorJSON(w, errors.New("error reading translation"), http.StatusBadRequest)
+		return
+	}
+	err = t.Validate()
+	if err != nil {
+		s.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	locale, ok := s.translationLocale(w, r)
+	if !ok {
+		return
+	}
+	c, ok := s.categoryForTranslations(w, r)
+	if !ok {
+		return
+	}
+
+	translations := categoryTranslations(c)
+	translations[locale] = t
+	s.saveCategoryTranslations(w, r, c, translations)
+}
+
+func (s *Server) DeleteCategoryTranslation(w http.ResponseWriter, r *http.Request) {
+	locale, ok := s.translationLocale(w, r)
+	if !ok {
+		return
+	}
+	c, ok := s.categoryForTranslations(w, r)
+	if !ok {
+		return
+	}
+
+	translations := categoryTranslations(c)
+	if _, found := translations[locale]; !found {
+		s.errorJSON(w, errors.New("translation not found"), http.StatusNotFound)
+		return
+	}
+	delete(translations, locale)
+	s.saveCategoryTranslations(w, r, c, translations)
+}
+
+// translationLocale reads the locale of the request path, it writes the
+// error response and returns false if it is invalid.
+func (s *Server) translationLocale(w http.ResponseWriter, r *http.Request) (string, bool) {
+	locale := types.NormalizeLocale(chi.URLParam(r, "locale"))
+	err := types.ValidateTranslationLocale(locale)
+	if err != nil {
+		s.errorJSON(w, err, http.StatusBadRequest)
+		return "", false
+	}
+	return locale, true
+}
+
+func (s *Server) categoryForTranslations(w http.ResponseWriter, r *http.Request) (types.Category, bool) {
+	c, err := s.storage.GetCategoryById(r.Context(), chi.URLParam(r, "categoryId"))
+	if err != nil {
+		if errors.Is(err, storage.ErrorNotFound) {
+			s.errorJSON(w, errors.New("category not found"), http.StatusNotFound)
+			return types.Category{}, false
+		}
+		logger(r).Error("getting the category", "error", err)
+		s.errorJSON(w, errors.New("error getting the category"), http.StatusInternalServerError)
+		return types.Category{}, false
+	}
+	return c, true
+}
+
+func (s *Server) saveProductTranslations(w http.ResponseWriter, r *http.Request, p types.Product, translations map[string]types.ProductTranslation) {
+	err := s.storage.UpdateProductTranslations(r.Context(), p.ID, p.Version, translations)
+	if err != nil {
+		if errors.Is(err, storage.ErrorProductChanged) {
+			s.errorJSON(w, errors.New("product was modified, retry"), http.StatusConflict)
+			return
+		}
+		logger(r).Error("updating the translations", "error", err)
+		s.errorJSON(w, errors.New("error updating the translations"), http.StatusInternalServerError)
+		return
+	}
+	s.reindexProduct(r, p.ID)
+
+	s.writeJSON(w, http.StatusOK, translations)
+}
+
+func (s *Server) saveCategoryTranslations(w http.ResponseWriter, r *http.Request, c types.Category, translations map[string]types.CategoryTranslation) {
+	err := s.storage.UpdateCategoryTranslations(r.Context(), c.ID, translations)
+	if err != nil {
+		if errors.Is(err, storage.ErrorNotFound) {
+			s.errorJSON(w, errors.New("category not found"), http.StatusNotFound)
+			return
+		}
+		logger(r).Error("updating the translations", "error", err)
+		s.errorJSON(w, errors.New("error updating the translations"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, translations)
+}
+
+func productTranslations(p types.Product) map[string]types.ProductTranslation {
+	if p.Translations == nil {
+		return make(map[string]types.ProductTranslation)
+	}
+	return p.Translations
+}
+
+func categoryTranslations(c types.Category) map[string]types.CategoryTranslation {
+	if c.Translations == nil {
+		return make(map[string]types.CategoryTranslation)
+	}
+	return c.Translations
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"pratbacknd/internal/storage"
+	"pratbacknd/internal/types"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (s Server) WishlistUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	s.writeWishlist(w, r, currentUser.ID)
+}
+
+func (s Server) AddToWishlistUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	var input types.AddToWishlistInput
+	err = s.readJSON(w, r, &input)
+	if err != nil {
+		logger(r).Warn("reading json", "error", err)
+		s.errorJSON(w, errors.New("error reading wishlist item"), http.StatusBadRequest)
+		return
+	}
+
+	_, err = s.storage.GetProductById(r.Context(), input.ProductID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorNotFound) {
+			s.errorJSON(w, errors.New("product not found"), http.StatusNotFound)
+			return
+		}
+		logger(r).Error("getting the product", "error", err)
+		s.errorJSON(w, errors.New("error adding the product to the wishlist"), http.StatusInternalServerError)
+		return
+	}
+
+	err = s.storage.AddToWishlist(r.Context(), currentUser.ID, types.WishlistItem{
+		ProductID: input.ProductID,
+		Quantity:  1,
+		AddedAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		logger(r).Error("adding to the wishlist", "error", err)
+		s.errorJSON(w, errors.New("error adding the product to the wishlist"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeWishlist(w, r, currentUser.ID)
+}
+
+func (s Server) RemoveFromWishlistUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	err = s.storage.RemoveFromWishlist(r.Context(), currentUser.ID, chi.URLParam(r, "productId"))
+	if err != nil {
+		if errors.Is(err, storage.ErrorNotFound) {
+			s.errorJSON(w, errors.New("product not in the wishlist"), http.StatusNotFound)
+			return
+		}
+		logger(r).Error("removing from the wishlist", "error", err)
+		s.errorJSON(w, errors.New("error removing the product from the wishlist"), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeWishlist(w, r, currentUser.ID)
+}
+
+// MoveToCartUser reserves the wishlist item through the regular cart update
+// and removes it from the wishlist once reserved.
+func (s Server) MoveToCartUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	productID := chi.URLParam(r, "productId")
+	items, err := s.storage.Wishlist(r.Context(), currentUser.ID)
+	if err != nil {
+		logger(r).Error("fetching the wishlist", "error", err)
+		s.errorJSON(w, errors.New("error moving the product to the cart"), http.StatusInternalServerError)
+		return
+	}
+
+	var item *types.WishlistItem
+	for i := range items {
+		if items[i].ProductID == productID {
+			item = &items[i]
+		}
+	}
+	if item == nil {
+		s.errorJSON(w, errors.New("product not in the wishlist"), http.StatusNotFound)
+		return
+	}
+
+	quantity := int(item.Quantity)
+	if quantity == 0 {
+		quantity = 1
+	}
+
+	cart, err := s.storage.CreateOrUpdateCart(r.Context(), currentUser.ID, productID, quantity)
+	if err != nil {
+		s.cartError(w, r, err, "error moving the product to the cart")
+		return
+	}
+
+	err = s.storage.RemoveFromWishlist(r.Context(), currentUser.ID, productID)
+	if err != nil {
+		// the product is reserved, it is only left in the wishlist
+		logger(r).Error("removing the moved product from the wishlist", "error", err)
+	}
+
+	s.writeCart(w, r, cart)
+}
+
+// SaveForLaterUser moves a cart item to the wishlist and releases its
+// reservation.
+func (s Server) SaveForLaterUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := s.currentUser(w, r)
+	if err != nil {
+		logger(r).Warn("retreiving current user", "error", err)
+		s.errorJSON(w, errors.New("no user found"), http.StatusForbidden)
+		return
+	}
+
+	cart, err := s.storage.SaveForLater(r.Context(), currentUser.ID, currentUser.ID, chi.URLParam(r, "productId"))
+	if err != nil {
+		s.cartError(w, r, err, "error saving the item for later")
+		return
+	}
+
+	s.writeCart(w, r, cart)
+}
+
+// writeWishlist answers with the wishlist of the user and the current price
+// and availability of its products.
+func (s Server) writeWishlist(w http.ResponseWriter, r *http.Request, userID string) {
+	items, err := s.storage.Wishlist(r.Context(), userID)
+	if err != nil {
+		logger(r).Error("fetching the wishlist", "error", err)
+		s.errorJSON(w, errors.New("error fetching the wishlist"), http.StatusInternalServerError)
+		return
+	}
+
+	locale, now := s.preferences(r).Locale, time.Now()
+	entries := make([]types.WishlistEntry, 0, len(items))
+	for _, item := range items {
+		entry := types.WishlistEntry{WishlistItem: item}
+		p, err := s.storage.GetProductById(r.Context(), item.ProductID)
+		if err != nil && !errors.Is(err, storage.ErrorNotFound) {
+			logger(r).Error("getting the product", "error", err)
+			s.errorJSON(w, errors.New("error fetching the wishlist"), http.StatusInternalServerError)
+			return
+		}
+		if err == nil {
+			presentProduct(&p, locale, now)
+			entry.Product = &p
+			entry.Available = p.Available()
+		}
+		entries = append(entries, entry)
+	}
+
+	s.writeJSON(w, http.StatusOK, entries)
+}
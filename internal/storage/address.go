@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pratbacknd/internal/types"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	uuid "github.com/satori/go.uuid"
+)
+
+// addresses are stored in the partition of their user
+const skAddressPrefix = "address#"
+
+var ErrorAddressBookFull = fmt.Errorf("address book cannot hold more than %d addresses", types.MaxAddresses)
+
+// Addresses returns the address book of the user, oldest first.
+func (d *Dynamo) Addresses(ctx context.Context, userID string) ([]types.Address, error) {
+	out, err := d.getElementsByPkAndSkPrefix(ctx, pkUserPrefix+userID, skAddressPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]types.Address, 0)
+	err = dynamodbattribute.UnmarshalListOfMaps(out.Items, &addresses)
+	if err != nil {
+		return nil, fmt.Errorf("error - Unmarshalling addresses: %w", err)
+	}
+
+	sort.SliceStable(addresses, func(i, j int) bool {
+		return addresses[i].CreatedAt.Before(addresses[j].CreatedAt)
+	})
+	return addresses, nil
+}
+
+func (d *Dynamo) GetAddress(ctx context.Context, userID string, addressID string) (types.Address, error) {
+	out, err := d.getElementByPkAndSk(ctx, pkUserPrefix+userID, skAddressPrefix+addressID)
+	if err != nil {
+		return types.Address{}, fmt.Errorf("error - retreiving address in db: %w", err)
+	}
+
+	if len(out.Items) == 0 {
+		return types.Address{}, fmt.Errorf("error - no address %s: %w", addressID, ErrorNotFound)
+	}
+
+	var a types.Address
+	err = dynamodbattribute.UnmarshalMap(out.Items[0], &a)
+	if err != nil {
+		return types.Address{}, fmt.Errorf("error - Unmarshalling address: %w", err)
+	}
+	return a, nil
+}
+
+// CreateAddress adds an address to the book of the user. The first address
+// of the book becomes the default one.
+func (d *Dynamo) CreateAddress(ctx context.Context, userID string, a types.Address) (types.Address, error) {
+	addresses, err := d.Addresses(ctx, userID)
+	if err != nil {
+		return types.Address{}, fmt.Errorf("error - retreiving the address book: %w", err)
+	}
+	if len(addresses) >= types.MaxAddresses {
+		return types.Address{}, ErrorAddressBookFull
+	}
+	if len(addresses) == 0 {
+		a.Default = true
+	}
+
+	err = d.saveAddress(ctx, userID, a, addresses, false)
+	if err != nil {
+		return types.Address{}, err
+	}
+	return a, nil
+}
+
+// UpdateAddress replaces an address of the user. An address stops being the
+// default one only when another address is made default.
+func (d *Dynamo) UpdateAddress(ctx context.Context, userID string, a types.Address) (types.Address, error) {
+	addresses, err := d.Addresses(ctx, userID)
+	if err != nil {
+		return types.Address{}, fmt.Errorf("error - retreiving the address book: %w", err)
+	}
+
+	var current *types.Address
+	for i := range addresses {
+		if addresses[i].ID == a.ID {
+			current = &addresses[i]
+		}
+	}
+	if current == nil {
+		return types.Address{}, fmt.Errorf("error - no address %s: %w", a.ID, ErrorNotFound)
+	}
+	a.CreatedAt = current.CreatedAt
+	a.Default = a.Default || current.Default
+
+	err = d.saveAddress(ctx, userID, a, addresses, true)
+	if err != nil {
+		return types.Address{}, err
+	}
+	return a, nil
+}
+
+// DeleteAddress removes an address of the user, when it was the default one
+// the oldest remaining address becomes the default.
+func (d *Dynamo) DeleteAddress(ctx context.Context, userID string, addressID string) error {
+	addresses, err := d.Addresses(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("error - retreiving the address book: %w", err)
+	}
+
+	var deleted *types.Address
+	remaining := make([]types.Address, 0, len(addresses))
+	for i := range addresses {
+		if addresses[i].ID == addressID {
+			deleted = &addresses[i]
+			continue
+		}
+		remaining = append(remaining, addresses[i])
+	}
+	if deleted == nil {
+		return fmt.Errorf("error - no address %s: %w", addressID, ErrorNotFound)
+	}
+
+	actions := []*dynamodb.TransactWriteItem{
+		{
+			Delete: &dynamodb.Delete{
+				TableName: &d.tableName,
+				Key:       d.addressKey(userID, addressID),
+			},
+		},
+	}
+	if deleted.Default && len(remaining) > 0 {
+		req, err := d.buildSetDefaultAddressRequest(userID, remaining[0].ID, true)
+		if err != nil {
+			return err
+		}
+		actions = append(actions, req)
+	}
+
+	_, err = d.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems:      actions,
+		ClientRequestToken: aws.String(uuid.NewV4().String()),
+	})
+	if err != nil {
+		return fmt.Errorf("error - run the transaction: %w", err)
+	}
+	return nil
+}
+
+// saveAddress puts the address and, when it is the default one, unsets the
+// default flag of the other addresses in the same transaction.
+func (d *Dynamo) saveAddress(ctx context.Context, userID string, a types.Address, addresses []types.Address, mustExist bool) error {
+	item, err := dynamodbattribute.MarshalMap(a)
+	if err != nil {
+		return fmt.Errorf("error - marshal address: %w", err)
+	}
+	for k, v := range d.addressKey(userID, a.ID) {
+		item[k] = v
+	}
+
+	condition := "attribute_not_exists(" + SortkeyAttributeName + ")"
+	if mustExist {
+		condition = "attribute_exists(" + SortkeyAttributeName + ")"
+	}
+
+	actions := []*dynamodb.TransactWriteItem{
+		{
+			Put: &dynamodb.Put{
+				TableName:           &d.tableName,
+				Item:                item,
+				ConditionExpression: aws.String(condition),
+			},
+		},
+	}
+
+	if a.Default {
+		for _, other := range addresses {
+			if other.ID == a.ID || !other.Default {
+				continue
+			}
+			req, err := d.buildSetDefaultAddressRequest(userID, other.ID, false)
+			if err != nil {
+				return err
+			}
+			actions = append(actions, req)
+		}
+	}
+
+	_, err = d.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems:      actions,
+		ClientRequestToken: aws.String(uuid.NewV4().String()),
+	})
+	if err != nil {
+		var canceled *dynamodb.TransactionCanceledException
+		if mustExist && errors.As(err, &canceled) && len(canceled.CancellationReasons) > 0 &&
+			aws.StringValue(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			return fmt.Errorf("error - no address %s: %w", a.ID, ErrorNotFound)
+		}
+		return fmt.Errorf("error - run the transaction: %w", err)
+	}
+	return nil
+}
+
+func (d Dynamo) buildSetDefaultAddressRequest(userID string, addressID string, isDefault bool) (*dynamodb.TransactWriteItem, error) {
+	condition := expression.AttributeExists(expression.Name(SortkeyAttributeName))
+	update := expression.Set(expression.Name("default"), expression.Value(isDefault))
+
+	expr, err := expression.NewBuilder().WithCondition(condition).WithUpdate(update).Build()
+	if err != nil {
+		return nil, fmt.Errorf("error - building the expression %w", err)
+	}
+
+	return &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			Key:                       d.addressKey(userID, addressID),
+			TableName:                 &d.tableName,
+			UpdateExpression:          expr.Update(),
+		},
+	}, nil
+}
+
+func (d Dynamo) addressKey(userID string, addressID string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		PartitionKeyAttributeName: {S: aws.String(pkUserPrefix + userID)},
+		SortkeyAttributeName:      {S: aws.String(skAddressPrefix + addressID)},
+	}
+}
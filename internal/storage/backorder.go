@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"pratbacknd/internal/types"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	uuid "github.com/satori/go.uuid"
+)
+
+// the products backordered by a cart are listed in the partition of its
+// user, to erase the backorders with the user data
+const skBackorderPrefix = "backorder#"
+
+// userBackorder is the element of the user partition pointing to a
+// backorder of the user
+type userBackorder struct {
+	ProductID string `dynamodbav:"productId"`
+}
+
+// putBackorderPointer lists the product in the backorders of the cart, it is
+// written ahead of the transaction creating the backorder: a pointer to a
+// missing backorder is harmless.
+func (d *Dynamo) putBackorderPointer(ctx context.Context, productID, cartID string) error {
+	err := d.putElement(ctx, pkUserPrefix+cartID, skBackorderPrefix+productID, userBackorder{ProductID: productID}, false)
+	if err != nil {
+		return fmt.Errorf("error - listing the backorder of %s: %w", productID, err)
+	}
+	return nil
+}
+
+// maxBackordersPerRestock keeps a restock transaction under the DynamoDB
+// limit of 100 actions (1 product + 2 actions per backorder).
+const maxBackordersPerRestock = 45
+
+func (d *Dynamo) backorders(ctx context.Context, productID string) ([]types.Backorder, error) {
+	out, err := d.getElementByPkAndSk(ctx, pkBackorderPrefix+productID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	backorders := make([]types.Backorder, 0)
+	err = dynamodbattribute.UnmarshalListOfMaps(out.Items, &backorders)
+	if err != nil {
+		return nil, fmt.Errorf("error - Unmarshalling backorders: %w", err)
+	}
+	return backorders, nil
+}
+
+// restock adds units to a product, serving its backorders first-in-first-out
+// and putting the remaining units back on the shelf. The first transaction
+// records every unit: the ones no backorder takes go to the shelf, and the
+// next transactions serve the remaining backorders from the shelf.
+func (d *Dynamo) restock(ctx context.Context, p types.Product, units uint) error {
+	more, err := d.restockBatch(ctx, p, units)
+	if err != nil {
+		return err
+	}
+	for more {
+		p, err = d.GetProductById(ctx, p.ID)
+		if err == nil {
+			more, err = d.restockBatch(ctx, p, 0)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrorBackordersPending, err)
+		}
+	}
+	return nil
+}
+
+// restockBatch serves up to maxBackordersPerRestock backorders from the
+// shelf and the incoming units in one transaction, it returns true when
+// backorders are left to serve from the shelf.
+func (d *Dynamo) restockBatch(ctx context.Context, p types.Product, units uint) (bool, error) {
+	backorders, err := d.backorders(ctx, p.ID)
+	if err != nil {
+		return false, fmt.Errorf("error - retrieving backorders: %w", err)
+	}
+
+	current := make(map[string]int)
+	for _, b := range backorders {
+		current[b.CartID] = b.Quantity
+	}
+
+	// never allocate more than what the product counts as backordered
+	available := p.Stock + units
+	toAllocate := available
+	if toAllocate > p.Backordered {
+		toAllocate = p.Backordered
+	}
+	allocations, _ := types.AllocateBackorders(backorders, toAllocate)
+	truncated := len(allocations) > maxBackordersPerRestock
+	if truncated {
+		allocations = allocations[:maxBackordersPerRestock]
+	}
+
+	actions := make([]*dynamodb.TransactWriteItem, 0)
+	// allocated leaves the backorder queue, served reaches the carts: an
+	// allocation is only partly served when its cart holds fewer units
+	allocated, served := uint(0), uint(0)
+	for _, a := range allocations {
+		cart, err := d.GetCart(ctx, a.CartID)
+		if err != nil {
+			return false, fmt.Errorf("error - retrieving backordered cart %s: %w", a.CartID, err)
+		}
+
+		item, found := cart.Items[p.ID]
+		if found {
+			n := uint(a.Quantity)
+			if n > item.Backordered {
+				n = item.Backordered
+			}
+			item.Backordered -= n
+			if item.Backordered == 0 {
+				item.ExpectedShipDate = nil
+			}
+			cart.Items[p.ID] = item
+			served += n
+
+			updateCartReq, err := d.buildUpdateCartRequest(cart, a.CartID)
+			if err != nil {
+				return false, fmt.Errorf("error - update cart request: %w", err)
+			}
+			actions = append(actions, updateCartReq)
+		}
+
+		backorderReq, err := d.buildAllocateBackorderRequest(a, current[a.CartID])
+		if err != nil {
+			return false, fmt.Errorf("error - build the allocate backorder request: %w", err)
+		}
+		actions = append(actions, backorderReq)
+		allocated += uint(a.Quantity)
+	}
+
+	primaryKey := map[string]*dynamodb.AttributeValue{
+		PartitionKeyAttributeName: {S: aws.String(pkProduct)},
+		SortkeyAttributeName:      {S: aws.String(p.ID)},
+	}
+	condition := expression.Name("version").Equal(expression.Value(p.Version))
+	update := expression.Set(
+		expression.Name("stock"),
+		expression.Value(available-served),
+	).Set(
+		expression.Name("backordered"),
+		expression.Value(p.Backordered-allocated),
+	).Set(
+		expression.Name("version"),
+		expression.Value(p.Version+1),
+	)
+	expr, err := expression.NewBuilder().WithCondition(condition).WithUpdate(update).Build()
+	if err != nil {
+		return false, fmt.Errorf("error - building the expression: %w", err)
+	}
+	actions = append(actions, &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			Key:                       primaryKey,
+			TableName:                 &d.tableName,
+			UpdateExpression:          expr.Update(),
+		},
+	})
+
+	_, err = d.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems:      actions,
+		ClientRequestToken: aws.String(uuid.NewV4().String()),
+	})
+	if err != nil {
+		return false, fmt.Errorf("error - run the restock transaction: %w", err)
+	}
+
+	return truncated && available > served && p.Backordered > allocated, nil
+}
+
+func backorderKey(productID, cartID string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		PartitionKeyAttributeName: {S: aws.String(pkBackorderPrefix + productID)},
+		SortkeyAttributeName:      {S: aws.String(cartID)},
+	}
+}
+
+// buildUpdateBackorderRequest adds delta units to the backorder of a cart,
+// the backorder keeps its original position in the queue.
+func (d Dynamo) buildUpdateBackorderRequest(productID, cartID string, delta int) (*dynamodb.TransactWriteItem, error) {
+	update := expression.Set(
+		expression.Name("createdAt"),
+		expression.IfNotExists(expression.Name("createdAt"), expression.Value(time.Now().UTC())),
+	).Set(
+		expression.Name("productId"),
+		expression.Value(productID),
+	).Set(
+		expression.Name("cartId"),
+		expression.Value(cartID),
+	).Add(
+		expression.Name("quantity"),
+		expression.Value(delta),
+	)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	if err != nil {
+		return nil, fmt.Errorf("error - building the expression %w", err)
+	}
+
+	return &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			Key:                       backorderKey(productID, cartID),
+			TableName:                 &d.tableName,
+			UpdateExpression:          expr.Update(),
+		},
+	}, nil
+}
+
+// buildAllocateBackorderRequest removes the allocated units from a backorder
+// and deletes it once it is fully served.
+func (d Dynamo) buildAllocateBackorderRequest(allocation types.Backorder, currentQuantity int) (*dynamodb.TransactWriteItem, error) {
+	condition := expression.Name("quantity").Equal(expression.Value(currentQuantity))
+
+	if allocation.Quantity >= currentQuantity {
+		expr, err := expression.NewBuilder().WithCondition(condition).Build()
+		if err != nil {
+			return nil, fmt.Errorf("error - building the expression %w", err)
+		}
+		return &dynamodb.TransactWriteItem{
+			Delete: &dynamodb.Delete{
+				ConditionExpression:       expr.Condition(),
+				ExpressionAttributeNames:  expr.Names(),
+				ExpressionAttributeValues: expr.Values(),
+				Key:                       backorderKey(allocation.ProductID, allocation.CartID),
+				TableName:                 &d.tableName,
+			},
+		}, nil
+	}
+
+	update := expression.Add(expression.Name("quantity"), expression.Value(-allocation.Quantity))
+	expr, err := expression.NewBuilder().WithCondition(condition).WithUpdate(update).Build()
+	if err != nil {
+		return nil, fmt.Errorf("error - building the expression %w", err)
+	}
+	return &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			Key:                       backorderKey(allocation.ProductID, allocation.CartID),
+			TableName:                 &d.tableName,
+			UpdateExpression:          expr.Update(),
+		},
+	}, nil
+}
@@ -0,0 +1,351 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pratbacknd/internal/types"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	uuid "github.com/satori/go.uuid"
+)
+
+// maxItemsPerTransaction keeps a cart transaction under the DynamoDB limit
+// of 100 actions (2 carts + up to 3 actions per product).
+const maxItemsPerTransaction = 32
+
+// checkCartLimits rejects limits that let a cart outgrow a transaction, no
+// limit at all becomes the transaction limit.
+func checkCartLimits(limits types.CartLimits) (types.CartLimits, error) {
+	if limits.MaxDistinctItems < 0 || limits.MaxDistinctItems > maxItemsPerTransaction {
+		return limits, fmt.Errorf("error - the maximum number of distinct items in a cart must be between 1 and %d, got %d", maxItemsPerTransaction, limits.MaxDistinctItems)
+	}
+	if limits.MaxDistinctItems == 0 {
+		limits.MaxDistinctItems = maxItemsPerTransaction
+	}
+	return limits, nil
+}
+
+func (d *Dynamo) getOrCreateCart(ctx context.Context, cartID string) (types.Cart, error) {
+	cart, err := d.GetCart(ctx, cartID)
+	if err != nil {
+		if errors.Is(err, ErrorNotFound) {
+			now := time.Now().UTC()
+			cart = types.Cart{
+				Version:   1,
+				UpdatedAt: &now,
+			}
+			err = d.CreateCart(ctx, cart, cartID)
+			if err != nil {
+				return types.Cart{}, fmt.Errorf("error - creating new cart: %w", err)
+			}
+			d.carts.cartCreated()
+		} else {
+			return types.Cart{}, fmt.Errorf("error - retreiving the cart: %w", err)
+		}
+	}
+
+	return cart, nil
+}
+
+// buildReserveRequests adds delta units of a product to the cart and returns
+// the requests reserving (or releasing) them in the inventory.
+func (d *Dynamo) buildReserveRequests(ctx context.Context, cart *types.Cart, cartID string, productID string, delta int) ([]*dynamodb.TransactWriteItem, error) {
+	previousItem := cart.Items[productID]
+	err := cart.UpsertItem(productID, delta)
+	if err != nil {
+		return nil, fmt.Errorf("error - adding item tp the cart: %w", err)
+	}
+
+	productDB, err := d.GetProductById(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("error - getting the product of id %s: %w", productID, err)
+	}
+
+	if delta > 0 {
+		err = cart.CheckCurrency(productDB)
+		if err != nil {
+			return nil, fmt.Errorf("error - checking the cart currency: %w", err)
+		}
+	}
+	err = cart.CheckLimits(productDB, previousItem.Quantity)
+	if err != nil {
+		return nil, fmt.Errorf("error - checking the cart limits: %w", err)
+	}
+	if _, found := cart.Items[productID]; found && previousItem.Quantity == 0 {
+		err = cart.CheckDistinctItems(d.cartLimits)
+		if err != nil {
+			return nil, fmt.Errorf("error - checking the cart limits: %w", err)
+		}
+	}
+
+	change, err := productDB.Reserve(delta, previousItem.Backordered)
+	if err != nil {
+		return nil, fmt.Errorf("error - reserving the product of id %s: %w", productID, err)
+	}
+
+	if item, found := cart.Items[productID]; found {
+		item.Backordered = uint(int(previousItem.Backordered) + change.BackorderDelta)
+		item.ExpectedShipDate = nil
+		if item.Backordered > 0 {
+			item.ExpectedShipDate = productDB.AvailableAt
+		}
+		cart.Items[productID] = item
+	}
+
+	// slice of actions in the transaction
+	actions := make([]*dynamodb.TransactWriteItem, 0)
+
+	// update stock query
+	updateStockReq, err := d.buildUpdateStockRequest(productDB, change)
+	if err != nil {
+		return nil, fmt.Errorf("error - build the update stock request: %w", err)
+	}
+	actions = append(actions, updateStockReq)
+
+	// backorder queue query
+	if change.BackorderDelta > 0 {
+		err = d.putBackorderPointer(ctx, productID, cartID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if change.BackorderDelta != 0 {
+		updateBackorderReq, err := d.buildUpdateBackorderRequest(productID, cartID, change.BackorderDelta)
+		if err != nil {
+			return nil, fmt.Errorf("error - build the update backorder request: %w", err)
+		}
+		actions = append(actions, updateBackorderReq)
+	}
+
+	return actions, nil
+}
+
+// runCartTransaction saves the cart together with the given actions in a
+// single transaction.
+func (d *Dynamo) runCartTransaction(ctx context.Context, cart types.Cart, cartID string, actions []*dynamodb.TransactWriteItem) (types.Cart, error) {
+	// update cart query
+	updateCartReq, err := d.buildUpdateCartRequest(cart, cartID)
+	if err != nil {
+		return types.Cart{}, fmt.Errorf("error - update cart request: %w", err)
+	}
+	actions = append(actions, updateCartReq)
+
+	// group that into a transaction & execute it
+	_, err = d.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems:      actions,
+		ClientRequestToken: aws.String(uuid.NewV4().String()),
+	})
+	if err != nil {
+		return types.Cart{}, fmt.Errorf("error - run the transaction: %w", err)
+	}
+
+	return cart, nil
+}
+
+// ClearCart removes every item of the cart and releases their reservations.
+func (d *Dynamo) ClearCart(ctx context.Context, cartID string) (types.Cart, error) {
+	cart, err := d.GetCart(ctx, cartID)
+	if err != nil {
+		return types.Cart{}, fmt.Errorf("error - retreiving the cart: %w", err)
+	}
+
+	if len(cart.Items) > maxItemsPerTransaction {
+		return types.Cart{}, fmt.Errorf("error - cart has too many items to be cleared at once: %d", len(cart.Items))
+	}
+
+	actions := make([]*dynamodb.TransactWriteItem, 0)
+	for productID, item := range cart.Items {
+		reqs, err := d.buildReserveRequests(ctx, &cart, cartID, productID, -int(item.Quantity))
+		if err != nil {
+			return types.Cart{}, err
+		}
+		actions = append(actions, reqs...)
+	}
+
+	return d.runCartTransaction(ctx, cart, cartID, actions)
+}
+
+// RemoveCartItem removes a product from the cart and releases its reservation.
+func (d *Dynamo) RemoveCartItem(ctx context.Context, cartID string, productID string) (types.Cart, error) {
+	cart, err := d.GetCart(ctx, cartID)
+	if err != nil {
+		return types.Cart{}, fmt.Errorf("error - retreiving the cart: %w", err)
+	}
+
+	item, found := cart.Items[productID]
+	if !found {
+		return types.Cart{}, fmt.Errorf("error - product %s is not in the cart: %w", productID, ErrorNotFound)
+	}
+
+	actions, err := d.buildReserveRequests(ctx, &cart, cartID, productID, -int(item.Quantity))
+	if err != nil {
+		return types.Cart{}, err
+	}
+
+	return d.runCartTransaction(ctx, cart, cartID, actions)
+}
+
+// SetCartItemQuantity sets the quantity of a product in the cart, reserving
+// or releasing the difference with the current quantity.
+func (d *Dynamo) SetCartItemQuantity(ctx context.Context, cartID string, productID string, quantity int) (types.Cart, error) {
+	if quantity < 0 {
+		return types.Cart{}, fmt.Errorf("error - quantity cannot be less than zero: %d", quantity)
+	}
+
+	cart, err := d.getOrCreateCart(ctx, cartID)
+	if err != nil {
+		return types.Cart{}, err
+	}
+
+	delta := quantity - int(cart.Items[productID].Quantity)
+	if delta == 0 {
+		return cart, nil
+	}
+
+	actions, err := d.buildReserveRequests(ctx, &cart, cartID, productID, delta)
+	if err != nil {
+		return types.Cart{}, err
+	}
+
+	cart, err = d.runCartTransaction(ctx, cart, cartID, actions)
+	if err == nil {
+		d.carts.unitsReserved(delta)
+	}
+	return cart, err
+}
+
+// MergeCarts folds the guest cart into the cart of the user: quantities are
+// summed, the stock is re-validated by moving every reservation from the
+// guest cart to the user cart, and the guest cart is deleted.
+func (d *Dynamo) MergeCarts(ctx context.Context, guestCartID string, userID string) (types.Cart, error) {
+	guestCart, err := d.GetCart(ctx, guestCartID)
+	if err != nil {
+		return types.Cart{}, fmt.Errorf("error - retreiving the guest cart: %w", err)
+	}
+
+	if len(guestCart.Items) > maxItemsPerTransaction {
+		return types.Cart{}, fmt.Errorf("error - guest cart has too many items to be merged: %d", len(guestCart.Items))
+	}
+
+	cart, err := d.getOrCreateCart(ctx, userID)
+	if err != nil {
+		return types.Cart{}, err
+	}
+
+	actions := make([]*dynamodb.TransactWriteItem, 0)
+	for productID, guestItem := range guestCart.Items {
+		productDB, err := d.GetProductById(ctx, productID)
+		if err != nil {
+			return types.Cart{}, fmt.Errorf("error - getting the product of id %s: %w", productID, err)
+		}
+
+		// release the guest reservation
+		released, err := productDB.Reserve(-int(guestItem.Quantity), guestItem.Backordered)
+		if err != nil {
+			return types.Cart{}, fmt.Errorf("error - releasing the guest reservation of %s: %w", productID, err)
+		}
+		intermediate := productDB
+		intermediate.Stock = released.Stock
+		intermediate.Reserved = released.Reserved
+		intermediate.Backordered = released.Backordered
+
+		// reserve the same quantity for the user
+		previousItem := cart.Items[productID]
+		err = cart.UpsertItem(productID, int(guestItem.Quantity))
+		if err != nil {
+			return types.Cart{}, fmt.Errorf("error - adding item to the cart: %w", err)
+		}
+		err = cart.CheckCurrency(productDB)
+		if err != nil {
+			return types.Cart{}, fmt.Errorf("error - checking the cart currency: %w", err)
+		}
+		err = cart.CheckLimits(productDB, previousItem.Quantity)
+		if err != nil {
+			return types.Cart{}, fmt.Errorf("error - checking the cart limits: %w", err)
+		}
+		if previousItem.Quantity == 0 {
+			err = cart.CheckDistinctItems(d.cartLimits)
+			if err != nil {
+				return types.Cart{}, fmt.Errorf("error - checking the cart limits: %w", err)
+			}
+		}
+		reserved, err := intermediate.Reserve(int(guestItem.Quantity), previousItem.Backordered)
+		if err != nil {
+			return types.Cart{}, fmt.Errorf("error - reserving the product of id %s: %w", productID, err)
+		}
+
+		item := cart.Items[productID]
+		item.Backordered = uint(int(previousItem.Backordered) + reserved.BackorderDelta)
+		item.ExpectedShipDate = nil
+		if item.Backordered > 0 {
+			item.ExpectedShipDate = productDB.AvailableAt
+		}
+		cart.Items[productID] = item
+
+		updateStockReq, err := d.buildUpdateStockRequest(productDB, reserved)
+		if err != nil {
+			return types.Cart{}, fmt.Errorf("error - build the update stock request: %w", err)
+		}
+		actions = append(actions, updateStockReq)
+
+		if released.BackorderDelta != 0 {
+			req, err := d.buildUpdateBackorderRequest(productID, guestCartID, released.BackorderDelta)
+			if err != nil {
+				return types.Cart{}, fmt.Errorf("error - build the update backorder request: %w", err)
+			}
+			actions = append(actions, req)
+		}
+		if reserved.BackorderDelta != 0 {
+			err = d.putBackorderPointer(ctx, productID, userID)
+			if err != nil {
+				return types.Cart{}, err
+			}
+			req, err := d.buildUpdateBackorderRequest(productID, userID, reserved.BackorderDelta)
+			if err != nil {
+				return types.Cart{}, fmt.Errorf("error - build the update backorder request: %w", err)
+			}
+			actions = append(actions, req)
+		}
+	}
+
+	deleteGuestCartReq, err := d.buildDeleteCartRequest(guestCart, guestCartID)
+	if err != nil {
+		return types.Cart{}, fmt.Errorf("error - delete guest cart request: %w", err)
+	}
+	actions = append(actions, deleteGuestCartReq)
+
+	return d.runCartTransaction(ctx, cart, userID, actions)
+}
+
+func (d Dynamo) buildDeleteCartRequest(cart types.Cart, cartID string) (*dynamodb.TransactWriteItem, error) {
+
+	// key
+	primaryKey := map[string]*dynamodb.AttributeValue{
+		PartitionKeyAttributeName: {S: aws.String(pkCart)},
+		SortkeyAttributeName:      {S: aws.String(cartID)},
+	}
+
+	// condition (for optimistic locking)
+	condition := expression.Name("version").Equal(expression.Value(cart.Version))
+
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return nil, fmt.Errorf("error - building the expression %w", err)
+	}
+
+	deleteCartRequest := &dynamodb.TransactWriteItem{
+		Delete: &dynamodb.Delete{
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			Key:                       primaryKey,
+			TableName:                 &d.tableName,
+		},
+	}
+
+	return deleteCartRequest, nil
+}
@@ -1,10 +1,11 @@
 package storage
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
 	"pratbacknd/internal/types"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -20,28 +21,40 @@ const (
 	pkProduct                 = "product"
 	pkCart                    = "cart"
 	pkCategory                = "category"
+	pkBackorderPrefix         = "backorder#"
+	pkUserPrefix              = "user#"
 )
 
 type Dynamo struct {
 	tableName  string
 	awsSession *session.Session
 	client     *dynamodb.DynamoDB
+	cartLimits types.CartLimits
+	carts      *cartMetrics
 }
 
-func NewDynamo(tableName string) (*Dynamo, error) {
+func NewDynamo(tableName string, cartLimits types.CartLimits) (*Dynamo, error) {
+	cartLimits, err := checkCartLimits(cartLimits)
+	if err != nil {
+		return nil, err
+	}
 	awsSession, err := session.NewSession()
 	if err != nil {
 		return nil, fmt.Errorf("error - creating aws session: %w", err)
 	}
 	dynamodbClient := dynamodb.New(awsSession)
+	traceRequests(&dynamodbClient.Handlers, tableName)
 	return &Dynamo{
 		tableName:  tableName,
 		awsSession: awsSession,
 		client:     dynamodbClient,
+		cartLimits: cartLimits,
 	}, nil
 }
 
-func (d *Dynamo) CreateProduct(p types.Product) error {
+// CreateProduct stores the product and records its price as the first
+// entry of its price history.
+func (d *Dynamo) CreateProduct(ctx context.Context, p types.Product) error {
 	item, err := dynamodbattribute.MarshalMap(p)
 	if err != nil {
 		return fmt.Errorf("error - marshal product: %w", err)
@@ -54,9 +67,26 @@ func (d *Dynamo) CreateProduct(p types.Product) error {
 		S: aws.String(p.ID),
 	}
 
-	_, err = d.client.PutItem(&dynamodb.PutItemInput{
-		TableName: &d.tableName,
-		Item:      item,
+	now := time.Now()
+	history, err := d.priceHistoryItem(types.PriceHistoryEntry{
+		ID:               p.ID,
+		ProductID:        p.ID,
+		PriceVATExcluded: p.PriceVATExcluded,
+		VAT:              p.VAT,
+		TotalPrice:       p.TotalPrice,
+		StartsAt:         now,
+		RecordedAt:       now,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = d.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Put: &dynamodb.Put{TableName: &d.tableName, Item: item}},
+			{Put: &dynamodb.Put{TableName: &d.tableName, Item: history}},
+		},
+		ClientRequestToken: aws.String(uuid.NewV4().String()),
 	})
 	if err != nil {
 		return fmt.Errorf("error - Put item in db: %w", err)
@@ -64,8 +94,8 @@ func (d *Dynamo) CreateProduct(p types.Product) error {
 	return nil
 }
 
-func (d *Dynamo) Products() ([]types.Product, error) {
-	out, err := d.getElementByPkAndSk(pkProduct, "")
+func (d *Dynamo) Products(ctx context.Context) ([]types.Product, error) {
+	out, err := d.getElementByPkAndSk(ctx, pkProduct, "")
 	if err != nil {
 		return nil, err
 	}
@@ -78,7 +108,7 @@ func (d *Dynamo) Products() ([]types.Product, error) {
 	return products, nil
 }
 
-func (d *Dynamo) CreateCategory(c types.Category) error {
+func (d *Dynamo) CreateCategory(ctx context.Context, c types.Category) error {
 	item, err := dynamodbattribute.MarshalMap(c)
 	if err != nil {
 		return fmt.Errorf("error - marshal category: %w", err)
@@ -91,7 +121,7 @@ func (d *Dynamo) CreateCategory(c types.Category) error {
 		S: aws.String(c.ID),
 	}
 
-	_, err = d.client.PutItem(&dynamodb.PutItemInput{
+	_, err = d.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
 		TableName: &d.tableName,
 		Item:      item,
 	})
@@ -101,8 +131,8 @@ func (d *Dynamo) CreateCategory(c types.Category) error {
 	return nil
 }
 
-func (d *Dynamo) Categories() ([]types.Category, error) {
-	out, err := d.getElementByPkAndSk(pkCategory, "")
+func (d *Dynamo) Categories(ctx context.Context) ([]types.Category, error) {
+	out, err := d.getElementByPkAndSk(ctx, pkCategory, "")
 	if err != nil {
 		return nil, err
 	}
@@ -115,13 +145,12 @@ func (d *Dynamo) Categories() ([]types.Category, error) {
 	return categories, nil
 }
 
-func (d *Dynamo) getElementByPkAndSk(pkAttributeValue, skAttributeValue string) (*dynamodb.QueryOutput, error) {
+func (d *Dynamo) getElementByPkAndSk(ctx context.Context, pkAttributeValue, skAttributeValue string) (*dynamodb.QueryOutput, error) {
 	keyCondition := expression.Key(PartitionKeyAttributeName).Equal(expression.Value(pkAttributeValue))
 
 	if skAttributeValue != "" {
 		sortKeyCondition := expression.Key(SortkeyAttributeName).Equal(expression.Value(skAttributeValue))
 		keyCondition = keyCondition.And(sortKeyCondition)
-		log.Printf("--> key condition updated with sk: %s", skAttributeValue)
 	}
 
 	builder := expression.NewBuilder().WithKeyCondition(keyCondition)
@@ -137,7 +166,7 @@ func (d *Dynamo) getElementByPkAndSk(pkAttributeValue, skAttributeValue string)
 		TableName:                 &d.tableName,
 	}
 
-	out, err := d.client.Query(&input)
+	out, err := d.client.QueryWithContext(ctx, &input)
 	if err != nil {
 		return nil, fmt.Errorf("error - building expression: %w", err)
 	}
@@ -145,8 +174,78 @@ func (d *Dynamo) getElementByPkAndSk(pkAttributeValue, skAttributeValue string)
 	return out, nil
 }
 
-func (d *Dynamo) UpdateProduct(input UpdateProductInput) error {
-	p, err := d.GetProductById(input.ProductId)
+// getElementsByPkAndSkPrefix returns the elements of a partition whose sort
+// key starts with the given prefix.
+func (d *Dynamo) getElementsByPkAndSkPrefix(ctx context.Context, pkAttributeValue, skPrefix string) (*dynamodb.QueryOutput, error) {
+	keyCondition := expression.Key(PartitionKeyAttributeName).Equal(expression.Value(pkAttributeValue)).And(
+		expression.Key(SortkeyAttributeName).BeginsWith(skPrefix),
+	)
+
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCondition).Build()
+	if err != nil {
+		return nil, fmt.Errorf("error - building expression: %w", err)
+	}
+
+	out, err := d.client.QueryWithContext(ctx, &dynamodb.QueryInput{
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		TableName:                 &d.tableName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error - run query: %w", err)
+	}
+
+	return out, nil
+}
+
+// putElement stores v under the given keys, when mustExist is true the
+// element is replaced only if it already exists.
+func (d *Dynamo) putElement(ctx context.Context, pkAttributeValue, skAttributeValue string, v interface{}, mustExist bool) error {
+	item, err := dynamodbattribute.MarshalMap(v)
+	if err != nil {
+		return fmt.Errorf("error - marshal element: %w", err)
+	}
+
+	item[PartitionKeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(pkAttributeValue)}
+	item[SortkeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(skAttributeValue)}
+
+	input := dynamodb.PutItemInput{
+		TableName: &d.tableName,
+		Item:      item,
+	}
+	if mustExist {
+		input.ConditionExpression = aws.String("attribute_exists(" + SortkeyAttributeName + ")")
+	}
+
+	_, err = d.client.PutItemWithContext(ctx, &input)
+	if err != nil {
+		var conditionFailed *dynamodb.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return ErrorNotFound
+		}
+		return fmt.Errorf("error - Put item in db: %w", err)
+	}
+	return nil
+}
+
+// deleteElement removes the element stored under the given keys.
+func (d *Dynamo) deleteElement(ctx context.Context, pkAttributeValue, skAttributeValue string) error {
+	_, err := d.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: &d.tableName,
+		Key: map[string]*dynamodb.AttributeValue{
+			PartitionKeyAttributeName: {S: aws.String(pkAttributeValue)},
+			SortkeyAttributeName:      {S: aws.String(skAttributeValue)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error - delete item in db: %w", err)
+	}
+	return nil
+}
+
+func (d *Dynamo) UpdateProduct(ctx context.Context, input UpdateProductInput) error {
+	p, err := d.GetProductById(ctx, input.ProductId)
 	if err != nil {
 		return fmt.Errorf("error - to retrieve product: %w", err)
 	}
@@ -165,6 +264,9 @@ func (d *Dynamo) UpdateProduct(input UpdateProductInput) error {
 	update := expression.Set(expression.Name("version"), expression.Value(p.Version+1))
 
 	// update the non-nil values
+	if input.SKU != "" {
+		update.Set(expression.Name("sku"), expression.Value(input.SKU))
+	}
 	if input.Name != "" {
 		update.Set(expression.Name("name"), expression.Value(input.Name))
 	}
@@ -186,6 +288,33 @@ func (d *Dynamo) UpdateProduct(input UpdateProductInput) error {
 	if input.TotalPrice != (types.Money{}) {
 		update.Set(expression.Name("totalPrice"), expression.Value(input.TotalPrice))
 	}
+	if input.Backorder != nil {
+		update.Set(expression.Name("backorder"), expression.Value(*input.Backorder))
+	}
+	if input.PreOrder != nil {
+		update.Set(expression.Name("preOrder"), expression.Value(*input.PreOrder))
+	}
+	if input.MaxBackorder != nil {
+		update.Set(expression.Name("maxBackorder"), expression.Value(*input.MaxBackorder))
+	}
+	if input.AvailableAt != nil {
+		update.Set(expression.Name("availableAt"), expression.Value(*input.AvailableAt))
+	}
+	if input.MinPerOrder != nil {
+		update.Set(expression.Name("minPerOrder"), expression.Value(*input.MinPerOrder))
+	}
+	if input.MaxPerOrder != nil {
+		update.Set(expression.Name("maxPerOrder"), expression.Value(*input.MaxPerOrder))
+	}
+	if input.Weight != nil {
+		update.Set(expression.Name("weight"), expression.Value(*input.Weight))
+	}
+	if input.Dimensions != nil {
+		update.Set(expression.Name("dimensions"), expression.Value(*input.Dimensions))
+	}
+	if input.CategoryIDs != nil {
+		update.Set(expression.Name("categoryIds"), expression.Value(input.CategoryIDs))
+	}
 
 	// build the expression with expression builder
 	builder := expression.NewBuilder().WithCondition(condition).WithUpdate(update)
@@ -204,7 +333,7 @@ func (d *Dynamo) UpdateProduct(input UpdateProductInput) error {
 		UpdateExpression:          expr.Update(),
 	}
 
-	_, err = d.client.UpdateItem(&item)
+	_, err = d.client.UpdateItemWithContext(ctx, &item)
 	if err != nil {
 		return fmt.Errorf("error - run update item request: %w", err)
 	}
@@ -212,7 +341,7 @@ func (d *Dynamo) UpdateProduct(input UpdateProductInput) error {
 	return nil
 }
 
-func (d *Dynamo) CreateCart(cart types.Cart, userId string) error {
+func (d *Dynamo) CreateCart(ctx context.Context, cart types.Cart, userId string) error {
 	item, err := dynamodbattribute.MarshalMap(cart)
 	if err != nil {
 		return fmt.Errorf("error - marshal product: %w", err)
@@ -225,7 +354,7 @@ func (d *Dynamo) CreateCart(cart types.Cart, userId string) error {
 		S: aws.String(userId),
 	}
 
-	_, err = d.client.PutItem(&dynamodb.PutItemInput{
+	_, err = d.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
 		TableName: &d.tableName,
 		Item:      item,
 	})
@@ -235,9 +364,9 @@ func (d *Dynamo) CreateCart(cart types.Cart, userId string) error {
 	return nil
 }
 
-func (d *Dynamo) GetCart(userID string) (types.Cart, error) {
+func (d *Dynamo) GetCart(ctx context.Context, userID string) (types.Cart, error) {
 
-	out, err := d.getElementByPkAndSk(pkCart, userID)
+	out, err := d.getElementByPkAndSk(ctx, pkCart, userID)
 	if err != nil {
 		return types.Cart{}, fmt.Errorf("error - retreiving Cart in db: %w", err)
 	}
@@ -256,76 +385,30 @@ func (d *Dynamo) GetCart(userID string) (types.Cart, error) {
 		return types.Cart{}, fmt.Errorf("error - Unmarshalling cart: %w", err)
 	}
 
-	log.Printf("--fetched cart: %+v", c)
-
 	return c, nil
 }
 
-func (d *Dynamo) CreateOrUpdateCart(userID string, productID string, delta int) (types.Cart, error) {
+func (d *Dynamo) CreateOrUpdateCart(ctx context.Context, userID string, productID string, delta int) (types.Cart, error) {
 
-	cart, err := d.GetCart(userID)
+	cart, err := d.getOrCreateCart(ctx, userID)
 	if err != nil {
-		if errors.Is(err, ErrorNotFound) {
-			cart = types.Cart{
-				Version: 1,
-			}
-			err = d.CreateCart(cart, userID)
-			if err != nil {
-				return types.Cart{}, fmt.Errorf("error - creating new cart: %w", err)
-			}
-		} else {
-			return types.Cart{}, fmt.Errorf("error - retreiving the cart: %w", err)
-		}
+		return types.Cart{}, err
 	}
 
-	// add remove the item from the cart
-	err = cart.UpsertItem(productID, delta)
+	// add remove the item from the cart and reserve the stock
+	actions, err := d.buildReserveRequests(ctx, &cart, userID, productID, delta)
 	if err != nil {
-		return types.Cart{}, fmt.Errorf("error - adding item tp the cart: %w", err)
+		return types.Cart{}, err
 	}
 
-	productDB, err := d.GetProductById(productID)
-	if err != nil {
-		return types.Cart{}, fmt.Errorf("error - getting the product of id %s: %w", productID, err)
+	cart, err = d.runCartTransaction(ctx, cart, userID, actions)
+	if err == nil {
+		d.carts.unitsReserved(delta)
 	}
-
-	// slice of actions in the transaction
-	actions := make([]*dynamodb.TransactWriteItem, 0)
-
-	// update stock query
-	updateStockReq, err := d.buildUpdateStockRequest(productDB, delta)
-	if err != nil {
-		return types.Cart{}, fmt.Errorf("error - build the update stock request: %w", err)
-	}
-	actions = append(actions, updateStockReq)
-
-	// update cart query
-	updateCartReq, err := d.buildUpdateCartRequest(cart, userID)
-	if err != nil {
-		return types.Cart{}, fmt.Errorf("error - update cart request: %w", err)
-	}
-	actions = append(actions, updateCartReq)
-
-	// group that into a transaction & execute it
-	_, err = d.client.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
-		TransactItems:      actions,
-		ClientRequestToken: aws.String(uuid.NewV4().String()),
-	})
-	if err != nil {
-		return types.Cart{}, fmt.Errorf("error - run the transaction: %w", err)
-	}
-
-	return cart, nil
+	return cart, err
 }
 
-func (d Dynamo) buildUpdateStockRequest(p types.Product, delta int) (*dynamodb.TransactWriteItem, error) {
-
-	newStock := int(p.Stock) - delta
-	newReserved := int(p.Reserved) + delta
-
-	if newStock < 0 || newReserved < 0 {
-		return nil, fmt.Errorf("error - negative quantity is not allowed, newStock: %d, newReserved: %d", newStock, newReserved)
-	}
+func (d Dynamo) buildUpdateStockRequest(p types.Product, change types.StockChange) (*dynamodb.TransactWriteItem, error) {
 
 	// key
 	primaryKey := map[string]*dynamodb.AttributeValue{
@@ -338,10 +421,13 @@ func (d Dynamo) buildUpdateStockRequest(p types.Product, delta int) (*dynamodb.T
 
 	update := expression.Set(
 		expression.Name("stock"),
-		expression.Value(newStock),
+		expression.Value(change.Stock),
 	).Set(
 		expression.Name("reserved"),
-		expression.Value(newReserved),
+		expression.Value(change.Reserved),
+	).Set(
+		expression.Name("backordered"),
+		expression.Value(change.Backordered),
 	).Set(
 		expression.Name("version"),
 		expression.Value(p.Version+1),
@@ -381,6 +467,21 @@ func (d Dynamo) buildUpdateCartRequest(cart types.Cart, userId string) (*dynamod
 	update := expression.Set(
 		expression.Name("items"),
 		expression.Value(cart.Items),
+	).Set(
+		expression.Name("currencyCode"),
+		expression.Value(cart.CurrencyCode),
+	).Set(
+		expression.Name("coupons"),
+		expression.Value(cart.Coupons),
+	).Set(
+		expression.Name("shippingCountry"),
+		expression.Value(cart.ShippingCountry),
+	).Set(
+		expression.Name("shippingMethodId"),
+		expression.Value(cart.ShippingMethodID),
+	).Set(
+		expression.Name("updatedAt"),
+		expression.Value(time.Now().UTC()),
 	).Set(
 		expression.Name("version"),
 		expression.Value(cart.Version+1),
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pratbacknd/internal/types"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+)
+
+var ErrorProductChanged = errors.New("product was modified concurrently")
+
+// UpdateProductImages replaces the gallery of a product read at the given
+// version, the main image becomes the first image of the gallery. It fails
+// with ErrorProductChanged if the product was updated in the meantime.
+func (d *Dynamo) UpdateProductImages(ctx context.Context, productID string, version uint, images []types.ProductImage) error {
+	image := ""
+	if len(images) > 0 {
+		image = images[0].URL
+	}
+	update := expression.Set(
+		expression.Name("images"), expression.Value(images),
+	).Set(
+		expression.Name("image"), expression.Value(image),
+	)
+	return d.updateProductVersion(ctx, productID, version, update)
+}
+
+// updateProductVersion applies the update to a product read at the given
+// version and increments the version.
+func (d *Dynamo) updateProductVersion(ctx context.Context, productID string, version uint, update expression.UpdateBuilder) error {
+	keyCondition := map[string]*dynamodb.AttributeValue{
+		PartitionKeyAttributeName: {S: aws.String(pkProduct)},
+		SortkeyAttributeName:      {S: aws.String(productID)},
+	}
+
+	condition := expression.Name("version").Equal(expression.Value(version))
+	update = update.Set(expression.Name("version"), expression.Value(version+1))
+
+	expr, err := expression.NewBuilder().WithCondition(condition).WithUpdate(update).Build()
+	if err != nil {
+		return fmt.Errorf("error - building the expression: %w", err)
+	}
+
+	_, err = d.client.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 &d.tableName,
+		Key:                       keyCondition,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+	})
+	if err != nil {
+		var conditionFailed *dynamodb.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return ErrorProductChanged
+		}
+		return fmt.Errorf("error - run update item request: %w", err)
+	}
+
+	return nil
+}
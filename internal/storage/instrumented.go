@@ -0,0 +1,446 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"pratbacknd/internal/metrics"
+	"pratbacknd/internal/types"
+	"reflect"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Instrumented records the latency, the errors and the conflicts of every
+// call to the storage it wraps.
+type Instrumented struct {
+	storage   Storage
+	duration  *metrics.Histogram
+	errors    *metrics.Counter
+	conflicts *metrics.Counter
+}
+
+var _ Storage = (*Instrumented)(nil)
+
+// instrumentable is implemented by the storages recording their own
+// metrics, e.g. the carts created or the consumed capacity.
+type instrumentable interface {
+	instrument(registry *metrics.Registry)
+}
+
+// NewInstrumented wraps the storage, the metrics are registered in the
+// registry.
+func NewInstrumented(s Storage, registry *metrics.Registry) *Instrumented {
+	if s, ok := s.(instrumentable); ok {
+		s.instrument(registry)
+	}
+	return &Instrumented{
+		storage:   s,
+		duration:  registry.Histogram("storage_operation_duration_seconds", "Latency of the storage operations, by method.", metrics.LatencyBuckets, "method"),
+		errors:    registry.Counter("storage_errors_total", "Storage operations failed, not found excluded, by method.", "method"),
+		conflicts: registry.Counter("storage_conflicts_total", "Storage operations refused by a conditional check, by method.", "method"),
+	}
+}
+
+func (i *Instrumented) observe(method string, start time.Time, err *error) {
+	i.duration.Observe(time.Since(start).Seconds(), method)
+	switch {
+	case *err == nil || errors.Is(*err, ErrorNotFound):
+	case isConflict(*err):
+		i.conflicts.Inc(method)
+	default:
+		i.errors.Inc(method)
+	}
+}
+
+// isConflict tells whether the error comes from an optimistic lock or a
+// condition of DynamoDB.
+func isConflict(err error) bool {
+	if errors.Is(err, ErrorProductChanged) {
+		return true
+	}
+	var conditionFailed *dynamodb.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return true
+	}
+	var canceled *dynamodb.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		for _, reason := range canceled.CancellationReasons {
+			if reason != nil && aws.StringValue(reason.Code) == "ConditionalCheckFailed" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cartMetrics counts the carts created and the units reserved, a nil
+// cartMetrics records nothing.
+type cartMetrics struct {
+	created  *metrics.Counter
+	reserved *metrics.Counter
+}
+
+func newCartMetrics(registry *metrics.Registry) *cartMetrics {
+	return &cartMetrics{
+		created:  registry.Counter("carts_created_total", "Carts created."),
+		reserved: registry.Counter("cart_units_reserved_total", "Units added to the carts and reserved in the inventory."),
+	}
+}
+
+func (c *cartMetrics) cartCreated() {
+	if c != nil {
+		c.created.Inc()
+	}
+}
+
+func (c *cartMetrics) unitsReserved(delta int) {
+	if c != nil && delta > 0 {
+		c.reserved.Add(float64(delta))
+	}
+}
+
+func (m *Memory) instrument(registry *metrics.Registry) {
+	m.carts = newCartMetrics(registry)
+}
+
+// instrument asks DynamoDB for the capacity consumed by every request and
+// counts it per operation.
+func (d *Dynamo) instrument(registry *metrics.Registry) {
+	d.carts = newCartMetrics(registry)
+	capacity := registry.Counter("storage_consumed_capacity_units_total", "Capacity units consumed in DynamoDB, by operation.", "operation")
+
+	d.client.Handlers.Build.PushFront(func(r *request.Request) {
+		field := reflect.Indirect(reflect.ValueOf(r.Params)).FieldByName("ReturnConsumedCapacity")
+		if field.IsValid() && field.CanSet() && field.IsNil() {
+			field.Set(reflect.ValueOf(aws.String(dynamodb.ReturnConsumedCapacityTotal)))
+		}
+	})
+	d.client.Handlers.Complete.PushBack(func(r *request.Request) {
+		if r.Error != nil || r.Data == nil {
+			return
+		}
+		var units float64
+		switch consumed := reflect.Indirect(reflect.ValueOf(r.Data)).FieldByName("ConsumedCapacity"); {
+		case !consumed.IsValid():
+		case consumed.Type() == reflect.TypeOf(&dynamodb.ConsumedCapacity{}):
+			units = consumedUnits(consumed.Interface().(*dynamodb.ConsumedCapacity))
+		case consumed.Type() == reflect.TypeOf([]*dynamodb.ConsumedCapacity{}):
+			for _, c := range consumed.Interface().([]*dynamodb.ConsumedCapacity) {
+				units += consumedUnits(c)
+			}
+		}
+		if units > 0 {
+			capacity.Add(units, r.Operation.Name)
+		}
+	})
+}
+
+func consumedUnits(c *dynamodb.ConsumedCapacity) float64 {
+	if c == nil {
+		return 0
+	}
+	return aws.Float64Value(c.CapacityUnits)
+}
+
+func (i *Instrumented) Products(ctx context.Context) (res []types.Product, err error) {
+	defer i.observe("Products", time.Now(), &err)
+	return i.storage.Products(ctx)
+}
+
+func (i *Instrumented) GetProductById(ctx context.Context, productID string) (res types.Product, err error) {
+	defer i.observe("GetProductById", time.Now(), &err)
+	return i.storage.GetProductById(ctx, productID)
+}
+
+func (i *Instrumented) CreateProduct(ctx context.Context, p types.Product) (err error) {
+	defer i.observe("CreateProduct", time.Now(), &err)
+	return i.storage.CreateProduct(ctx, p)
+}
+
+func (i *Instrumented) UpdateProduct(ctx context.Context, input UpdateProductInput) (err error) {
+	defer i.observe("UpdateProduct", time.Now(), &err)
+	return i.storage.UpdateProduct(ctx, input)
+}
+
+func (i *Instrumented) UpdateProductImages(ctx context.Context, productID string, version uint, images []types.ProductImage) (err error) {
+	defer i.observe("UpdateProductImages", time.Now(), &err)
+	return i.storage.UpdateProductImages(ctx, productID, version, images)
+}
+
+func (i *Instrumented) UpdateProductTranslations(ctx context.Context, productID string, version uint, translations map[string]types.ProductTranslation) (err error) {
+	defer i.observe("UpdateProductTranslations", time.Now(), &err)
+	return i.storage.UpdateProductTranslations(ctx, productID, version, translations)
+}
+
+func (i *Instrumented) UpdatePriceSchedule(ctx context.Context, p types.Product, entry types.PriceHistoryEntry) (err error) {
+	defer i.observe("UpdatePriceSchedule", time.Now(), &err)
+	return i.storage.UpdatePriceSchedule(ctx, p, entry)
+}
+
+func (i *Instrumented) PriceHistory(ctx context.Context, productID string) (res []types.PriceHistoryEntry, err error) {
+	defer i.observe("PriceHistory", time.Now(), &err)
+	return i.storage.PriceHistory(ctx, productID)
+}
+
+func (i *Instrumented) UpdateProductRelations(ctx context.Context, productID string, version uint, relations []types.ProductRelation) (err error) {
+	defer i.observe("UpdateProductRelations", time.Now(), &err)
+	return i.storage.UpdateProductRelations(ctx, productID, version, relations)
+}
+
+func (i *Instrumented) RecordPurchase(ctx context.Context, orderID string, productIDs []string) (err error) {
+	defer i.observe("RecordPurchase", time.Now(), &err)
+	return i.storage.RecordPurchase(ctx, orderID, productIDs)
+}
+
+func (i *Instrumented) CoPurchases(ctx context.Context, productID string, limit int) (res []types.CoPurchase, err error) {
+	defer i.observe("CoPurchases", time.Now(), &err)
+	return i.storage.CoPurchases(ctx, productID, limit)
+}
+
+func (i *Instrumented) Categories(ctx context.Context) (res []types.Category, err error) {
+	defer i.observe("Categories", time.Now(), &err)
+	return i.storage.Categories(ctx)
+}
+
+func (i *Instrumented) GetCategoryById(ctx context.Context, categoryID string) (res types.Category, err error) {
+	defer i.observe("GetCategoryById", time.Now(), &err)
+	return i.storage.GetCategoryById(ctx, categoryID)
+}
+
+func (i *Instrumented) CreateCategory(ctx context.Context, c types.Category) (err error) {
+	defer i.observe("CreateCategory", time.Now(), &err)
+	return i.storage.CreateCategory(ctx, c)
+}
+
+func (i *Instrumented) UpdateCategoryTranslations(ctx context.Context, categoryID string, translations map[string]types.CategoryTranslation) (err error) {
+	defer i.observe("UpdateCategoryTranslations", time.Now(), &err)
+	return i.storage.UpdateCategoryTranslations(ctx, categoryID, translations)
+}
+
+func (i *Instrumented) UpdateInventory(ctx context.Context, productId string, delta int) (err error) {
+	defer i.observe("UpdateInventory", time.Now(), &err)
+	return i.storage.UpdateInventory(ctx, productId, delta)
+}
+
+func (i *Instrumented) CreateCart(ctx context.Context, cart types.Cart, userId string) (err error) {
+	defer i.observe("CreateCart", time.Now(), &err)
+	return i.storage.CreateCart(ctx, cart, userId)
+}
+
+func (i *Instrumented) GetCart(ctx context.Context, userID string) (res types.Cart, err error) {
+	defer i.observe("GetCart", time.Now(), &err)
+	return i.storage.GetCart(ctx, userID)
+}
+
+func (i *Instrumented) CreateOrUpdateCart(ctx context.Context, userID string, productID string, delta int) (res types.Cart, err error) {
+	defer i.observe("CreateOrUpdateCart", time.Now(), &err)
+	return i.storage.CreateOrUpdateCart(ctx, userID, productID, delta)
+}
+
+func (i *Instrumented) MergeCarts(ctx context.Context, guestCartID string, userID string) (res types.Cart, err error) {
+	defer i.observe("MergeCarts", time.Now(), &err)
+	return i.storage.MergeCarts(ctx, guestCartID, userID)
+}
+
+func (i *Instrumented) ClearCart(ctx context.Context, cartID string) (res types.Cart, err error) {
+	defer i.observe("ClearCart", time.Now(), &err)
+	return i.storage.ClearCart(ctx, cartID)
+}
+
+func (i *Instrumented) RemoveCartItem(ctx context.Context, cartID string, productID string) (res types.Cart, err error) {
+	defer i.observe("RemoveCartItem", time.Now(), &err)
+	return i.storage.RemoveCartItem(ctx, cartID, productID)
+}
+
+func (i *Instrumented) SetCartItemQuantity(ctx context.Context, cartID string, productID string, quantity int) (res types.Cart, err error) {
+	defer i.observe("SetCartItemQuantity", time.Now(), &err)
+	return i.storage.SetCartItemQuantity(ctx, cartID, productID, quantity)
+}
+
+func (i *Instrumented) CreatePromotion(ctx context.Context, p types.Promotion) (err error) {
+	defer i.observe("CreatePromotion", time.Now(), &err)
+	return i.storage.CreatePromotion(ctx, p)
+}
+
+func (i *Instrumented) Promotions(ctx context.Context) (res []types.Promotion, err error) {
+	defer i.observe("Promotions", time.Now(), &err)
+	return i.storage.Promotions(ctx)
+}
+
+func (i *Instrumented) GetPromotionById(ctx context.Context, promotionID string) (res types.Promotion, err error) {
+	defer i.observe("GetPromotionById", time.Now(), &err)
+	return i.storage.GetPromotionById(ctx, promotionID)
+}
+
+func (i *Instrumented) GetPromotionByCode(ctx context.Context, code string) (res types.Promotion, err error) {
+	defer i.observe("GetPromotionByCode", time.Now(), &err)
+	return i.storage.GetPromotionByCode(ctx, code)
+}
+
+func (i *Instrumented) UpdatePromotion(ctx context.Context, p types.Promotion) (err error) {
+	defer i.observe("UpdatePromotion", time.Now(), &err)
+	return i.storage.UpdatePromotion(ctx, p)
+}
+
+func (i *Instrumented) DeletePromotion(ctx context.Context, promotionID string) (err error) {
+	defer i.observe("DeletePromotion", time.Now(), &err)
+	return i.storage.DeletePromotion(ctx, promotionID)
+}
+
+func (i *Instrumented) ApplyCoupon(ctx context.Context, cartID string, userID string, p types.Promotion) (res types.Cart, err error) {
+	defer i.observe("ApplyCoupon", time.Now(), &err)
+	return i.storage.ApplyCoupon(ctx, cartID, userID, p)
+}
+
+func (i *Instrumented) RemoveCoupon(ctx context.Context, cartID string, userID string, p types.Promotion) (res types.Cart, err error) {
+	defer i.observe("RemoveCoupon", time.Now(), &err)
+	return i.storage.RemoveCoupon(ctx, cartID, userID, p)
+}
+
+func (i *Instrumented) CreateShippingZone(ctx context.Context, z types.ShippingZone) (err error) {
+	defer i.observe("CreateShippingZone", time.Now(), &err)
+	return i.storage.CreateShippingZone(ctx, z)
+}
+
+func (i *Instrumented) UpdateShippingZone(ctx context.Context, z types.ShippingZone) (err error) {
+	defer i.observe("UpdateShippingZone", time.Now(), &err)
+	return i.storage.UpdateShippingZone(ctx, z)
+}
+
+func (i *Instrumented) DeleteShippingZone(ctx context.Context, zoneID string) (err error) {
+	defer i.observe("DeleteShippingZone", time.Now(), &err)
+	return i.storage.DeleteShippingZone(ctx, zoneID)
+}
+
+func (i *Instrumented) ShippingZones(ctx context.Context) (res []types.ShippingZone, err error) {
+	defer i.observe("ShippingZones", time.Now(), &err)
+	return i.storage.ShippingZones(ctx)
+}
+
+func (i *Instrumented) CreateShippingMethod(ctx context.Context, m types.ShippingMethod) (err error) {
+	defer i.observe("CreateShippingMethod", time.Now(), &err)
+	return i.storage.CreateShippingMethod(ctx, m)
+}
+
+func (i *Instrumented) UpdateShippingMethod(ctx context.Context, m types.ShippingMethod) (err error) {
+	defer i.observe("UpdateShippingMethod", time.Now(), &err)
+	return i.storage.UpdateShippingMethod(ctx, m)
+}
+
+func (i *Instrumented) DeleteShippingMethod(ctx context.Context, methodID string) (err error) {
+	defer i.observe("DeleteShippingMethod", time.Now(), &err)
+	return i.storage.DeleteShippingMethod(ctx, methodID)
+}
+
+func (i *Instrumented) ShippingMethods(ctx context.Context) (res []types.ShippingMethod, err error) {
+	defer i.observe("ShippingMethods", time.Now(), &err)
+	return i.storage.ShippingMethods(ctx)
+}
+
+func (i *Instrumented) SelectShipping(ctx context.Context, cartID string, country string, methodID string) (res types.Cart, err error) {
+	defer i.observe("SelectShipping", time.Now(), &err)
+	return i.storage.SelectShipping(ctx, cartID, country, methodID)
+}
+
+func (i *Instrumented) Addresses(ctx context.Context, userID string) (res []types.Address, err error) {
+	defer i.observe("Addresses", time.Now(), &err)
+	return i.storage.Addresses(ctx, userID)
+}
+
+func (i *Instrumented) GetAddress(ctx context.Context, userID string, addressID string) (res types.Address, err error) {
+	defer i.observe("GetAddress", time.Now(), &err)
+	return i.storage.GetAddress(ctx, userID, addressID)
+}
+
+func (i *Instrumented) CreateAddress(ctx context.Context, userID string, a types.Address) (res types.Address, err error) {
+	defer i.observe("CreateAddress", time.Now(), &err)
+	return i.storage.CreateAddress(ctx, userID, a)
+}
+
+func (i *Instrumented) UpdateAddress(ctx context.Context, userID string, a types.Address) (res types.Address, err error) {
+	defer i.observe("UpdateAddress", time.Now(), &err)
+	return i.storage.UpdateAddress(ctx, userID, a)
+}
+
+func (i *Instrumented) DeleteAddress(ctx context.Context, userID string, addressID string) (err error) {
+	defer i.observe("DeleteAddress", time.Now(), &err)
+	return i.storage.DeleteAddress(ctx, userID, addressID)
+}
+
+func (i *Instrumented) GetProfile(ctx context.Context, userID string) (res types.Profile, err error) {
+	defer i.observe("GetProfile", time.Now(), &err)
+	return i.storage.GetProfile(ctx, userID)
+}
+
+func (i *Instrumented) CreateProfile(ctx context.Context, p types.Profile) (err error) {
+	defer i.observe("CreateProfile", time.Now(), &err)
+	return i.storage.CreateProfile(ctx, p)
+}
+
+func (i *Instrumented) UpdateProfile(ctx context.Context, p types.Profile) (err error) {
+	defer i.observe("UpdateProfile", time.Now(), &err)
+	return i.storage.UpdateProfile(ctx, p)
+}
+
+func (i *Instrumented) Wishlist(ctx context.Context, userID string) (res []types.WishlistItem, err error) {
+	defer i.observe("Wishlist", time.Now(), &err)
+	return i.storage.Wishlist(ctx, userID)
+}
+
+func (i *Instrumented) AddToWishlist(ctx context.Context, userID string, item types.WishlistItem) (err error) {
+	defer i.observe("AddToWishlist", time.Now(), &err)
+	return i.storage.AddToWishlist(ctx, userID, item)
+}
+
+func (i *Instrumented) RemoveFromWishlist(ctx context.Context, userID string, productID string) (err error) {
+	defer i.observe("RemoveFromWishlist", time.Now(), &err)
+	return i.storage.RemoveFromWishlist(ctx, userID, productID)
+}
+
+func (i *Instrumented) SaveForLater(ctx context.Context, cartID string, userID string, productID string) (res types.Cart, err error) {
+	defer i.observe("SaveForLater", time.Now(), &err)
+	return i.storage.SaveForLater(ctx, cartID, userID, productID)
+}
+
+func (i *Instrumented) CreateReview(ctx context.Context, r types.Review) (err error) {
+	defer i.observe("CreateReview", time.Now(), &err)
+	return i.storage.CreateReview(ctx, r)
+}
+
+func (i *Instrumented) Reviews(ctx context.Context, productID string, status string) (res []types.Review, err error) {
+	defer i.observe("Reviews", time.Now(), &err)
+	return i.storage.Reviews(ctx, productID, status)
+}
+
+func (i *Instrumented) PendingReviews(ctx context.Context) (res []types.Review, err error) {
+	defer i.observe("PendingReviews", time.Now(), &err)
+	return i.storage.PendingReviews(ctx)
+}
+
+func (i *Instrumented) UserReviews(ctx context.Context, userID string) (res []types.Review, err error) {
+	defer i.observe("UserReviews", time.Now(), &err)
+	return i.storage.UserReviews(ctx, userID)
+}
+
+func (i *Instrumented) ModerateReview(ctx context.Context, productID string, reviewID string, status string) (res types.Review, err error) {
+	defer i.observe("ModerateReview", time.Now(), &err)
+	return i.storage.ModerateReview(ctx, productID, reviewID, status)
+}
+
+func (i *Instrumented) DeleteCart(ctx context.Context, cartID string) (err error) {
+	defer i.observe("DeleteCart", time.Now(), &err)
+	return i.storage.DeleteCart(ctx, cartID)
+}
+
+func (i *Instrumented) ReleaseExpiredCarts(ctx context.Context, cartIDPrefix string, updatedBefore time.Time) (n int, err error) {
+	defer i.observe("ReleaseExpiredCarts", time.Now(), &err)
+	return i.storage.ReleaseExpiredCarts(ctx, cartIDPrefix, updatedBefore)
+}
+
+func (i *Instrumented) DeleteUserData(ctx context.Context, userID string) (err error) {
+	defer i.observe("DeleteUserData", time.Now(), &err)
+	return i.storage.DeleteUserData(ctx, userID)
+}
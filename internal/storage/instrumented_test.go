@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"pratbacknd/internal/metrics"
+	"pratbacknd/internal/types"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrumented(t *testing.T) {
+	t.Run("records the latency, errors and conflicts", func(t *testing.T) {
+		// given
+		ctrl := gomock.NewController(t)
+		mockedStorage := NewMockStorage(ctrl)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "p1").Return(types.Product{ID: "p1"}, nil)
+		mockedStorage.EXPECT().GetProductById(gomock.Any(), "p2").Return(types.Product{}, fmt.Errorf("error - no product: %w", ErrorNotFound))
+		mockedStorage.EXPECT().UpdateProductImages(gomock.Any(), "p1", uint(1), nil).Return(ErrorProductChanged)
+		mockedStorage.EXPECT().Products(gomock.Any()).Return(nil, errors.New("timeout"))
+		registry := metrics.NewRegistry()
+		s := NewInstrumented(mockedStorage, registry)
+
+		// when
+		p, err := s.GetProductById(context.Background(), "p1")
+		_, notFound := s.GetProductById(context.Background(), "p2")
+		conflict := s.UpdateProductImages(context.Background(), "p1", 1, nil)
+		_, failed := s.Products(context.Background())
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, "p1", p.ID)
+		assert.ErrorIs(t, notFound, ErrorNotFound, "the errors are returned as they are")
+		assert.ErrorIs(t, conflict, ErrorProductChanged)
+		assert.EqualError(t, failed, "timeout")
+
+		var out bytes.Buffer
+		assert.NoError(t, registry.WritePrometheus(&out))
+		assert.Contains(t, out.String(), `storage_operation_duration_seconds_count{method="GetProductById"} 2`)
+		assert.Contains(t, out.String(), `storage_errors_total{method="Products"} 1`)
+		assert.NotContains(t, out.String(), `storage_errors_total{method="GetProductById"}`, "not found is not an error")
+		assert.Contains(t, out.String(), `storage_conflicts_total{method="UpdateProductImages"} 1`)
+	})
+
+	t.Run("counts the carts created and the units reserved", func(t *testing.T) {
+		// given
+		m := NewMemory(types.CartLimits{})
+		assert.NoError(t, m.CreateProduct(context.Background(), types.Product{ID: "p1", Stock: 5}))
+		registry := metrics.NewRegistry()
+		s := NewInstrumented(m, registry)
+
+		// when
+		_, err := s.CreateOrUpdateCart(context.Background(), "u1", "p1", 2)
+		assert.NoError(t, err)
+		_, err = s.SetCartItemQuantity(context.Background(), "u1", "p1", 3)
+		assert.NoError(t, err)
+		_, err = s.CreateOrUpdateCart(context.Background(), "u1", "p1", -1)
+		assert.NoError(t, err)
+		_, err = s.CreateOrUpdateCart(context.Background(), "u2", "p1", 10)
+		assert.Error(t, err)
+
+		// then
+		var out bytes.Buffer
+		assert.NoError(t, registry.WritePrometheus(&out))
+		assert.Contains(t, out.String(), "carts_created_total 1", "the failed cart is not created")
+		assert.Contains(t, out.String(), "cart_units_reserved_total 3", "released units are not counted")
+	})
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"pratbacknd/internal/types"
@@ -13,12 +14,22 @@ import (
 
 var ErrorNotFound = errors.New("item not found")
 
-func (d *Dynamo) UpdateInventory(productId string, delta int) error {
-	p, err := d.GetProductById(productId)
+// ErrorBackordersPending is returned once the restocked units are recorded
+// but some backorders could not be served, the next restock serves them
+// from the shelf so the update must not be retried.
+var ErrorBackordersPending = errors.New("backorders left to serve")
+
+func (d *Dynamo) UpdateInventory(ctx context.Context, productId string, delta int) error {
+	p, err := d.GetProductById(ctx, productId)
 	if err != nil {
 		return fmt.Errorf("error - to retrieve product: %w", err)
 	}
 
+	// incoming units go to the backorders first
+	if delta > 0 && p.Backordered > 0 {
+		return d.restock(ctx, p, uint(delta))
+	}
+
 	newStock := int(p.Stock) + delta
 	if newStock < 0 {
 		return fmt.Errorf("error - stock should not be less than 0")
@@ -53,7 +64,7 @@ func (d *Dynamo) UpdateInventory(productId string, delta int) error {
 		UpdateExpression:          expr.Update(),
 	}
 
-	_, err = d.client.UpdateItem(&input)
+	_, err = d.client.UpdateItemWithContext(ctx, &input)
 	if err != nil {
 		return fmt.Errorf("error - run update item request: %w", err)
 	}
@@ -61,7 +72,7 @@ func (d *Dynamo) UpdateInventory(productId string, delta int) error {
 	return nil
 }
 
-func (d *Dynamo) GetProductById(productID string) (types.Product, error) {
+func (d *Dynamo) GetProductById(ctx context.Context, productID string) (types.Product, error) {
 	getItemInput := dynamodb.GetItemInput{
 		ConsistentRead: aws.Bool(true),
 		Key: map[string]*dynamodb.AttributeValue{
@@ -75,7 +86,7 @@ func (d *Dynamo) GetProductById(productID string) (types.Product, error) {
 		TableName: &d.tableName,
 	}
 
-	out, err := d.client.GetItem(&getItemInput)
+	out, err := d.client.GetItemWithContext(ctx, &getItemInput)
 	if err != nil {
 		return types.Product{}, fmt.Errorf("error - geeting item: %w", err)
 	}
@@ -0,0 +1,608 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"pratbacknd/internal/types"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Memory keeps the elements in memory under the keys of the DynamoDB table,
+// for the local server. Each operation is atomic, like the transactions of
+// the DynamoDB storage.
+type Memory struct {
+	mu         sync.Mutex
+	items      map[memoryKey]map[string]*dynamodb.AttributeValue
+	cartLimits types.CartLimits
+	carts      *cartMetrics
+}
+
+type memoryKey struct {
+	pk string
+	sk string
+}
+
+// NewMemory keeps the limits of the DynamoDB storage: a limit above what a
+// transaction holds, or no limit, is the transaction limit.
+func NewMemory(cartLimits types.CartLimits) *Memory {
+	if cartLimits.MaxDistinctItems <= 0 || cartLimits.MaxDistinctItems > maxItemsPerTransaction {
+		cartLimits.MaxDistinctItems = maxItemsPerTransaction
+	}
+	return &Memory{
+		items:      make(map[memoryKey]map[string]*dynamodb.AttributeValue),
+		cartLimits: cartLimits,
+	}
+}
+
+// memoryTx stages the writes of an operation, a nil element is a delete
+type memoryTx struct {
+	items  map[memoryKey]map[string]*dynamodb.AttributeValue
+	writes map[memoryKey]map[string]*dynamodb.AttributeValue
+	// cartCreated is counted once the writes are committed
+	cartCreated bool
+}
+
+// tx locks the storage for an operation, unlock releases it.
+func (m *Memory) tx() (tx *memoryTx, unlock func()) {
+	m.mu.Lock()
+	return &memoryTx{
+		items:  m.items,
+		writes: make(map[memoryKey]map[string]*dynamodb.AttributeValue),
+	}, m.mu.Unlock
+}
+
+// commit applies the staged writes.
+func (tx *memoryTx) commit() {
+	for k, item := range tx.writes {
+		if item == nil {
+			delete(tx.items, k)
+			continue
+		}
+		tx.items[k] = item
+	}
+}
+
+func (tx *memoryTx) item(k memoryKey) (map[string]*dynamodb.AttributeValue, bool) {
+	if item, staged := tx.writes[k]; staged {
+		return item, item != nil
+	}
+	item, found := tx.items[k]
+	return item, found
+}
+
+// get unmarshals the element stored under the keys into v, it returns false
+// when there is none.
+func (tx *memoryTx) get(pk string, sk string, v interface{}) (bool, error) {
+	item, found := tx.item(memoryKey{pk, sk})
+	if !found {
+		return false, nil
+	}
+	err := dynamodbattribute.UnmarshalMap(item, v)
+	if err != nil {
+		return false, fmt.Errorf("error - Unmarshalling element: %w", err)
+	}
+	return true, nil
+}
+
+func (tx *memoryTx) exists(pk string, sk string) bool {
+	_, found := tx.item(memoryKey{pk, sk})
+	return found
+}
+
+func (tx *memoryTx) put(pk string, sk string, v interface{}) error {
+	item, err := dynamodbattribute.MarshalMap(v)
+	if err != nil {
+		return fmt.Errorf("error - marshal element: %w", err)
+	}
+	item[PartitionKeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(pk)}
+	item[SortkeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(sk)}
+	tx.writes[memoryKey{pk, sk}] = item
+	return nil
+}
+
+func (tx *memoryTx) delete(pk string, sk string) {
+	tx.writes[memoryKey{pk, sk}] = nil
+}
+
+// query unmarshals the elements of a partition whose sort key starts with
+// the prefix into v, in the order of the sort keys.
+func (tx *memoryTx) query(pk string, skPrefix string, v interface{}) error {
+	keys := make([]memoryKey, 0)
+	for _, items := range []map[memoryKey]map[string]*dynamodb.AttributeValue{tx.items, tx.writes} {
+		for k := range items {
+			if k.pk == pk && strings.HasPrefix(k.sk, skPrefix) {
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].sk < keys[j].sk
+	})
+
+	items := make([]map[string]*dynamodb.AttributeValue, 0, len(keys))
+	for i, k := range keys {
+		if i > 0 && keys[i-1] == k {
+			continue
+		}
+		if item, found := tx.item(k); found {
+			items = append(items, item)
+		}
+	}
+	err := dynamodbattribute.UnmarshalListOfMaps(items, v)
+	if err != nil {
+		return fmt.Errorf("error - Unmarshalling results: %w", err)
+	}
+	return nil
+}
+
+func (tx *memoryTx) product(productID string) (types.Product, error) {
+	var p types.Product
+	found, err := tx.get(pkProduct, productID, &p)
+	if err != nil {
+		return types.Product{}, err
+	}
+	if !found {
+		return types.Product{}, ErrorNotFound
+	}
+	return p, nil
+}
+
+// updateProductVersion applies the update to a product read at the given
+// version and increments the version.
+func (tx *memoryTx) updateProductVersion(productID string, version uint, update func(p *types.Product)) error {
+	p, err := tx.product(productID)
+	if err != nil || p.Version != version {
+		return ErrorProductChanged
+	}
+	update(&p)
+	p.Version++
+	return tx.put(pkProduct, productID, p)
+}
+
+func (m *Memory) CreateProduct(ctx context.Context, p types.Product) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	now := time.Now()
+	err := tx.put(pkProduct, p.ID, p)
+	if err != nil {
+		return err
+	}
+	err = tx.put(pkPriceHistoryPrefix+p.ID, p.ID, types.PriceHistoryEntry{
+		ID:               p.ID,
+		ProductID:        p.ID,
+		PriceVATExcluded: p.PriceVATExcluded,
+		VAT:              p.VAT,
+		TotalPrice:       p.TotalPrice,
+		StartsAt:         now,
+		RecordedAt:       now,
+	})
+	if err != nil {
+		return err
+	}
+	tx.commit()
+	return nil
+}
+
+func (m *Memory) Products(ctx context.Context) ([]types.Product, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	products := make([]types.Product, 0)
+	err := tx.query(pkProduct, "", &products)
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (m *Memory) GetProductById(ctx context.Context, productID string) (types.Product, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	return tx.product(productID)
+}
+
+func (m *Memory) UpdateProduct(ctx context.Context, input UpdateProductInput) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	p, err := tx.product(input.ProductId)
+	if err != nil {
+		return fmt.Errorf("error - to retrieve product: %w", err)
+	}
+
+	// update the non-nil values
+	if input.SKU != "" {
+		p.SKU = input.SKU
+	}
+	if input.Name != "" {
+		p.Name = input.Name
+	}
+	if input.Image != "" {
+		p.Image = input.Image
+	}
+	if input.ShortDescription != "" {
+		p.ShortDescription = input.ShortDescription
+	}
+	if input.Description != "" {
+		p.Description = input.Description
+	}
+	if input.PriceVATExcluded != (types.Money{}) {
+		p.PriceVATExcluded = input.PriceVATExcluded
+	}
+	if input.VAT != (types.Money{}) {
+		p.VAT = input.VAT
+	}
+	if input.TotalPrice != (types.Money{}) {
+		p.TotalPrice = input.TotalPrice
+	}
+	if input.Backorder != nil {
+		p.Backorder = *input.Backorder
+	}
+	if input.PreOrder != nil {
+		p.PreOrder = *input.PreOrder
+	}
+	if input.MaxBackorder != nil {
+		p.MaxBackorder = *input.MaxBackorder
+	}
+	if input.AvailableAt != nil {
+		p.AvailableAt = input.AvailableAt
+	}
+	if input.MinPerOrder != nil {
+		p.MinPerOrder = *input.MinPerOrder
+	}
+	if input.MaxPerOrder != nil {
+		p.MaxPerOrder = *input.MaxPerOrder
+	}
+	if input.Weight != nil {
+		p.Weight = *input.Weight
+	}
+	if input.Dimensions != nil {
+		p.Dimensions = input.Dimensions
+	}
+	if input.CategoryIDs != nil {
+		p.CategoryIDs = input.CategoryIDs
+	}
+	p.Version++
+
+	err = tx.put(pkProduct, p.ID, p)
+	if err != nil {
+		return err
+	}
+	tx.commit()
+	return nil
+}
+
+func (m *Memory) UpdateProductImages(ctx context.Context, productID string, version uint, images []types.ProductImage) error {
+	return m.updateProductVersion(productID, version, func(p *types.Product) {
+		p.Images = images
+		p.Image = ""
+		if len(images) > 0 {
+			p.Image = images[0].URL
+		}
+	})
+}
+
+func (m *Memory) UpdateProductTranslations(ctx context.Context, productID string, version uint, translations map[string]types.ProductTranslation) error {
+	return m.updateProductVersion(productID, version, func(p *types.Product) {
+		p.Translations = translations
+	})
+}
+
+func (m *Memory) UpdateProductRelations(ctx context.Context, productID string, version uint, relations []types.ProductRelation) error {
+	return m.updateProductVersion(productID, version, func(p *types.Product) {
+		p.Relations = relations
+	})
+}
+
+func (m *Memory) updateProductVersion(productID string, version uint, update func(p *types.Product)) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	err := tx.updateProductVersion(productID, version, update)
+	if err != nil {
+		return err
+	}
+	tx.commit()
+	return nil
+}
+
+func (m *Memory) UpdatePriceSchedule(ctx context.Context, p types.Product, entry types.PriceHistoryEntry) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	err := tx.updateProductVersion(p.ID, p.Version, func(stored *types.Product) {
+		stored.PriceVATExcluded = p.PriceVATExcluded
+		stored.VAT = p.VAT
+		stored.TotalPrice = p.TotalPrice
+		stored.PriceSchedule = p.PriceSchedule
+	})
+	if err != nil {
+		return err
+	}
+	err = tx.put(pkPriceHistoryPrefix+entry.ProductID, entry.ID, entry)
+	if err != nil {
+		return err
+	}
+	tx.commit()
+	return nil
+}
+
+func (m *Memory) PriceHistory(ctx context.Context, productID string) ([]types.PriceHistoryEntry, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	entries := make([]types.PriceHistoryEntry, 0)
+	err := tx.query(pkPriceHistoryPrefix+productID, "", &entries)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].StartsAt.Equal(entries[j].StartsAt) {
+			return entries[i].StartsAt.Before(entries[j].StartsAt)
+		}
+		return entries[i].RecordedAt.Before(entries[j].RecordedAt)
+	})
+	return entries, nil
+}
+
+func (m *Memory) RecordPurchase(ctx context.Context, orderID string, productIDs []string) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	for i, chunk := range purchaseChunks(productIDs) {
+		if tx.exists(pkPurchasePrefix+orderID, strconv.Itoa(i)) {
+			continue
+		}
+		err := tx.put(pkPurchasePrefix+orderID, strconv.Itoa(i), struct{}{})
+		if err != nil {
+			return err
+		}
+		for _, pair := range chunk {
+			var counter coPurchaseCounter
+			_, err = tx.get(pkCoPurchasePrefix+pair.productID, pair.other, &counter)
+			if err != nil {
+				return err
+			}
+			counter.Count++
+			err = tx.put(pkCoPurchasePrefix+pair.productID, pair.other, counter)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	products := purchasedProducts(productIDs)
+	for _, id := range products {
+		var top coPurchaseTop
+		_, err := tx.get(pkCoPurchaseTop, id, &top)
+		if err != nil {
+			return err
+		}
+		counted := make([]types.CoPurchase, 0, len(products))
+		for _, other := range products {
+			var counter coPurchaseCounter
+			found, err := tx.get(pkCoPurchasePrefix+id, other, &counter)
+			if err != nil {
+				return err
+			}
+			if found {
+				counted = append(counted, types.CoPurchase{ProductID: other, Count: counter.Count})
+			}
+		}
+		top.Products = mergeCoPurchases(top.Products, counted)
+		top.Version++
+		err = tx.put(pkCoPurchaseTop, id, top)
+		if err != nil {
+			return err
+		}
+	}
+	tx.commit()
+	return nil
+}
+
+func (m *Memory) CoPurchases(ctx context.Context, productID string, limit int) ([]types.CoPurchase, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	var top coPurchaseTop
+	_, err := tx.get(pkCoPurchaseTop, productID, &top)
+	if err != nil {
+		return nil, err
+	}
+
+	coPurchases := top.Products
+	if coPurchases == nil {
+		coPurchases = []types.CoPurchase{}
+	}
+	if len(coPurchases) > limit {
+		coPurchases = coPurchases[:limit]
+	}
+	return coPurchases, nil
+}
+
+func (m *Memory) Categories(ctx context.Context) ([]types.Category, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	categories := make([]types.Category, 0)
+	err := tx.query(pkCategory, "", &categories)
+	if err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (m *Memory) GetCategoryById(ctx context.Context, categoryID string) (types.Category, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	var c types.Category
+	found, err := tx.get(pkCategory, categoryID, &c)
+	if err != nil {
+		return types.Category{}, err
+	}
+	if !found {
+		return types.Category{}, fmt.Errorf("error - no category %s: %w", categoryID, ErrorNotFound)
+	}
+	return c, nil
+}
+
+func (m *Memory) CreateCategory(ctx context.Context, c types.Category) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	err := tx.put(pkCategory, c.ID, c)
+	if err != nil {
+		return err
+	}
+	tx.commit()
+	return nil
+}
+
+func (m *Memory) UpdateCategoryTranslations(ctx context.Context, categoryID string, translations map[string]types.CategoryTranslation) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	var c types.Category
+	found, err := tx.get(pkCategory, categoryID, &c)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrorNotFound
+	}
+	c.Translations = translations
+	err = tx.put(pkCategory, categoryID, c)
+	if err != nil {
+		return err
+	}
+	tx.commit()
+	return nil
+}
+
+func (m *Memory) UpdateInventory(ctx context.Context, productId string, delta int) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	p, err := tx.product(productId)
+	if err != nil {
+		return fmt.Errorf("error - to retrieve product: %w", err)
+	}
+
+	// incoming units go to the backorders first
+	if delta > 0 && p.Backordered > 0 {
+		err = tx.restock(p, uint(delta))
+	} else {
+		newStock := int(p.Stock) + delta
+		if newStock < 0 {
+			return fmt.Errorf("error - stock should not be less than 0")
+		}
+		p.Stock = uint(newStock)
+		p.Version++
+		err = tx.put(pkProduct, p.ID, p)
+	}
+	if err != nil {
+		return err
+	}
+	tx.commit()
+	return nil
+}
+
+// restock adds units to a product, serving its backorders first-in-first-out
+// and putting the remaining units back on the shelf.
+func (tx *memoryTx) restock(p types.Product, units uint) error {
+	backorders := make([]types.Backorder, 0)
+	err := tx.query(pkBackorderPrefix+p.ID, "", &backorders)
+	if err != nil {
+		return fmt.Errorf("error - retrieving backorders: %w", err)
+	}
+
+	current := make(map[string]int)
+	for _, b := range backorders {
+		current[b.CartID] = b.Quantity
+	}
+
+	// never allocate more than what the product counts as backordered
+	available := p.Stock + units
+	toAllocate := available
+	if toAllocate > p.Backordered {
+		toAllocate = p.Backordered
+	}
+	allocations, _ := types.AllocateBackorders(backorders, toAllocate)
+
+	// allocated leaves the backorder queue, served reaches the carts
+	allocated, served := uint(0), uint(0)
+	for _, a := range allocations {
+		cart, err := tx.cart(a.CartID)
+		if err != nil {
+			return fmt.Errorf("error - retrieving backordered cart %s: %w", a.CartID, err)
+		}
+
+		item, found := cart.Items[p.ID]
+		if found {
+			n := uint(a.Quantity)
+			if n > item.Backordered {
+				n = item.Backordered
+			}
+			item.Backordered -= n
+			if item.Backordered == 0 {
+				item.ExpectedShipDate = nil
+			}
+			cart.Items[p.ID] = item
+			served += n
+
+			err = tx.saveCart(cart, a.CartID)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = tx.allocateBackorder(a, current[a.CartID])
+		if err != nil {
+			return err
+		}
+		allocated += uint(a.Quantity)
+	}
+
+	p.Stock = available - served
+	p.Backordered -= allocated
+	p.Version++
+	return tx.put(pkProduct, p.ID, p)
+}
+
+// updateBackorder adds delta units to the backorder of a cart, the
+// backorder keeps its original position in the queue.
+func (tx *memoryTx) updateBackorder(productID string, cartID string, delta int) error {
+	var b types.Backorder
+	found, err := tx.get(pkBackorderPrefix+productID, cartID, &b)
+	if err != nil {
+		return err
+	}
+	if !found {
+		b.CreatedAt = time.Now().UTC()
+	}
+	b.ProductID = productID
+	b.CartID = cartID
+	b.Quantity += delta
+	return tx.put(pkBackorderPrefix+productID, cartID, b)
+}
+
+// allocateBackorder removes the allocated units from a backorder and
+// deletes it once it is fully served.
+func (tx *memoryTx) allocateBackorder(allocation types.Backorder, currentQuantity int) error {
+	if allocation.Quantity >= currentQuantity {
+		tx.delete(pkBackorderPrefix+allocation.ProductID, allocation.CartID)
+		return nil
+	}
+	return tx.updateBackorder(allocation.ProductID, allocation.CartID, -allocation.Quantity)
+}
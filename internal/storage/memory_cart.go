@@ -0,0 +1,633 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pratbacknd/internal/types"
+	"time"
+)
+
+func (tx *memoryTx) cart(cartID string) (types.Cart, error) {
+	var c types.Cart
+	found, err := tx.get(pkCart, cartID, &c)
+	if err != nil {
+		return types.Cart{}, fmt.Errorf("error - retreiving Cart in db: %w", err)
+	}
+	if !found {
+		return types.Cart{}, fmt.Errorf("error - no cart found: %w", ErrorNotFound)
+	}
+	return c, nil
+}
+
+func (tx *memoryTx) getOrCreateCart(cartID string) (types.Cart, error) {
+	cart, err := tx.cart(cartID)
+	if errors.Is(err, ErrorNotFound) {
+		now := time.Now().UTC()
+		cart = types.Cart{Version: 1, UpdatedAt: &now}
+		tx.cartCreated = true
+		return cart, tx.put(pkCart, cartID, cart)
+	}
+	return cart, err
+}
+
+// saveCart stores the cart and increments its stored version, the cart
+// given is returned to the callers as it is, like the DynamoDB storage does.
+func (tx *memoryTx) saveCart(cart types.Cart, cartID string) error {
+	now := time.Now().UTC()
+	cart.UpdatedAt = &now
+	cart.Version++
+	return tx.put(pkCart, cartID, cart)
+}
+
+// reserve adds delta units of a product to the cart and reserves (or
+// releases) them in the inventory.
+func (tx *memoryTx) reserve(cart *types.Cart, cartID string, productID string, delta int, limits types.CartLimits) error {
+	previousItem := cart.Items[productID]
+	err := cart.UpsertItem(productID, delta)
+	if err != nil {
+		return fmt.Errorf("error - adding item tp the cart: %w", err)
+	}
+
+	productDB, err := tx.product(productID)
+	if err != nil {
+		return fmt.Errorf("error - getting the product of id %s: %w", productID, err)
+	}
+
+	if delta > 0 {
+		err = cart.CheckCurrency(productDB)
+		if err != nil {
+			return fmt.Errorf("error - checking the cart currency: %w", err)
+		}
+	}
+	err = cart.CheckLimits(productDB, previousItem.Quantity)
+	if err != nil {
+		return fmt.Errorf("error - checking the cart limits: %w", err)
+	}
+	if _, found := cart.Items[productID]; found && previousItem.Quantity == 0 {
+		err = cart.CheckDistinctItems(limits)
+		if err != nil {
+			return fmt.Errorf("error - checking the cart limits: %w", err)
+		}
+	}
+
+	change, err := productDB.Reserve(delta, previousItem.Backordered)
+	if err != nil {
+		return fmt.Errorf("error - reserving the product of id %s: %w", productID, err)
+	}
+
+	if item, found := cart.Items[productID]; found {
+		item.Backordered = uint(int(previousItem.Backordered) + change.BackorderDelta)
+		item.ExpectedShipDate = nil
+		if item.Backordered > 0 {
+			item.ExpectedShipDate = productDB.AvailableAt
+		}
+		cart.Items[productID] = item
+	}
+
+	err = tx.updateStock(productDB, change)
+	if err != nil {
+		return err
+	}
+	if change.BackorderDelta != 0 {
+		return tx.updateBackorder(productID, cartID, change.BackorderDelta)
+	}
+	return nil
+}
+
+func (tx *memoryTx) updateStock(p types.Product, change types.StockChange) error {
+	p.Stock = change.Stock
+	p.Reserved = change.Reserved
+	p.Backordered = change.Backordered
+	p.Version++
+	return tx.put(pkProduct, p.ID, p)
+}
+
+func (m *Memory) CreateCart(ctx context.Context, cart types.Cart, userId string) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	err := tx.put(pkCart, userId, cart)
+	if err != nil {
+		return err
+	}
+	tx.commit()
+	return nil
+}
+
+func (m *Memory) GetCart(ctx context.Context, userID string) (types.Cart, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	return tx.cart(userID)
+}
+
+func (m *Memory) CreateOrUpdateCart(ctx context.Context, userID string, productID string, delta int) (types.Cart, error) {
+	cart, err := m.updateCart(func(tx *memoryTx) (types.Cart, error) {
+		cart, err := tx.getOrCreateCart(userID)
+		if err != nil {
+			return types.Cart{}, err
+		}
+		err = tx.reserve(&cart, userID, productID, delta, m.cartLimits)
+		if err != nil {
+			return types.Cart{}, err
+		}
+		return cart, tx.saveCart(cart, userID)
+	})
+	if err == nil {
+		m.carts.unitsReserved(delta)
+	}
+	return cart, err
+}
+
+func (m *Memory) ClearCart(ctx context.Context, cartID string) (types.Cart, error) {
+	return m.updateCart(func(tx *memoryTx) (types.Cart, error) {
+		cart, err := tx.cart(cartID)
+		if err != nil {
+			return types.Cart{}, fmt.Errorf("error - retreiving the cart: %w", err)
+		}
+		for productID, item := range cart.Items {
+			err = tx.reserve(&cart, cartID, productID, -int(item.Quantity), m.cartLimits)
+			if err != nil {
+				return types.Cart{}, err
+			}
+		}
+		return cart, tx.saveCart(cart, cartID)
+	})
+}
+
+func (m *Memory) RemoveCartItem(ctx context.Context, cartID string, productID string) (types.Cart, error) {
+	return m.updateCart(func(tx *memoryTx) (types.Cart, error) {
+		cart, err := tx.cart(cartID)
+		if err != nil {
+			return types.Cart{}, fmt.Errorf("error - retreiving the cart: %w", err)
+		}
+		item, found := cart.Items[productID]
+		if !found {
+			return types.Cart{}, fmt.Errorf("error - product %s is not in the cart: %w", productID, ErrorNotFound)
+		}
+		err = tx.reserve(&cart, cartID, productID, -int(item.Quantity), m.cartLimits)
+		if err != nil {
+			return types.Cart{}, err
+		}
+		return cart, tx.saveCart(cart, cartID)
+	})
+}
+
+func (m *Memory) SetCartItemQuantity(ctx context.Context, cartID string, productID string, quantity int) (types.Cart, error) {
+	if quantity < 0 {
+		return types.Cart{}, fmt.Errorf("error - quantity cannot be less than zero: %d", quantity)
+	}
+
+	var delta int
+	cart, err := m.updateCart(func(tx *memoryTx) (types.Cart, error) {
+		cart, err := tx.getOrCreateCart(cartID)
+		if err != nil {
+			return types.Cart{}, err
+		}
+		delta = quantity - int(cart.Items[productID].Quantity)
+		if delta == 0 {
+			return cart, nil
+		}
+		err = tx.reserve(&cart, cartID, productID, delta, m.cartLimits)
+		if err != nil {
+			return types.Cart{}, err
+		}
+		return cart, tx.saveCart(cart, cartID)
+	})
+	if err == nil {
+		m.carts.unitsReserved(delta)
+	}
+	return cart, err
+}
+
+// MergeCarts folds the guest cart into the cart of the user, every
+// reservation moves from the guest cart to the user cart.
+func (m *Memory) MergeCarts(ctx context.Context, guestCartID string, userID string) (types.Cart, error) {
+	return m.updateCart(func(tx *memoryTx) (types.Cart, error) {
+		guestCart, err := tx.cart(guestCartID)
+		if err != nil {
+			return types.Cart{}, fmt.Errorf("error - retreiving the guest cart: %w", err)
+		}
+		cart, err := tx.getOrCreateCart(userID)
+		if err != nil {
+			return types.Cart{}, err
+		}
+
+		for productID, guestItem := range guestCart.Items {
+			err = tx.reserve(&guestCart, guestCartID, productID, -int(guestItem.Quantity), m.cartLimits)
+			if err != nil {
+				return types.Cart{}, fmt.Errorf("error - releasing the guest reservation of %s: %w", productID, err)
+			}
+			err = tx.reserve(&cart, userID, productID, int(guestItem.Quantity), m.cartLimits)
+			if err != nil {
+				return types.Cart{}, err
+			}
+		}
+
+		tx.delete(pkCart, guestCartID)
+		return cart, tx.saveCart(cart, userID)
+	})
+}
+
+func (m *Memory) DeleteCart(ctx context.Context, cartID string) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	err := tx.deleteCart(cartID, m.cartLimits)
+	if err != nil {
+		return err
+	}
+	tx.commit()
+	return nil
+}
+
+// ReleaseExpiredCarts deletes the carts whose id starts with the prefix and
+// which were last written before updatedBefore, releasing their
+// reservations.
+func (m *Memory) ReleaseExpiredCarts(ctx context.Context, cartIDPrefix string, updatedBefore time.Time) (int, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	var carts []struct {
+		CartID    string     `dynamodbav:"SK"`
+		UpdatedAt *time.Time `dynamodbav:"updatedAt"`
+	}
+	err := tx.query(pkCart, cartIDPrefix, &carts)
+	if err != nil {
+		return 0, fmt.Errorf("error - retreiving the carts: %w", err)
+	}
+	released := 0
+	for _, c := range carts {
+		if !(types.Cart{UpdatedAt: c.UpdatedAt}).UpdatedBefore(updatedBefore) {
+			continue
+		}
+		err = tx.deleteCart(c.CartID, m.cartLimits)
+		if err != nil {
+			return 0, fmt.Errorf("error - releasing the cart %s: %w", c.CartID, err)
+		}
+		released++
+	}
+	tx.commit()
+	return released, nil
+}
+
+// deleteCart releases every reservation of the cart and deletes it.
+func (tx *memoryTx) deleteCart(cartID string, limits types.CartLimits) error {
+	cart, err := tx.cart(cartID)
+	if err != nil {
+		return fmt.Errorf("error - retreiving the cart: %w", err)
+	}
+	for productID, item := range cart.Items {
+		err = tx.reserve(&cart, cartID, productID, -int(item.Quantity), limits)
+		if err != nil {
+			return err
+		}
+	}
+	tx.delete(pkCart, cartID)
+	return nil
+}
+
+// updateCart runs a cart operation and commits its writes when it succeeds.
+func (m *Memory) updateCart(operation func(tx *memoryTx) (types.Cart, error)) (types.Cart, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	cart, err := operation(tx)
+	if err != nil {
+		return types.Cart{}, err
+	}
+	tx.commit()
+	if tx.cartCreated {
+		m.carts.cartCreated()
+	}
+	return cart, nil
+}
+
+func (m *Memory) CreatePromotion(ctx context.Context, p types.Promotion) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	if tx.exists(pkPromotionCode, p.Code) {
+		return fmt.Errorf("error - code %s is taken by another promotion", p.Code)
+	}
+	err := tx.put(pkPromotion, p.ID, p)
+	if err != nil {
+		return err
+	}
+	err = tx.put(pkPromotionCode, p.Code, promotionCode{PromotionID: p.ID})
+	if err != nil {
+		return err
+	}
+	tx.commit()
+	return nil
+}
+
+func (m *Memory) Promotions(ctx context.Context) ([]types.Promotion, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	return tx.promotions()
+}
+
+func (tx *memoryTx) promotions() ([]types.Promotion, error) {
+	promotions := make([]types.Promotion, 0)
+	err := tx.query(pkPromotion, "", &promotions)
+	if err != nil {
+		return nil, err
+	}
+	return promotions, nil
+}
+
+func (m *Memory) GetPromotionById(ctx context.Context, promotionID string) (types.Promotion, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	var p types.Promotion
+	found, err := tx.get(pkPromotion, promotionID, &p)
+	if err != nil {
+		return types.Promotion{}, err
+	}
+	if !found {
+		return types.Promotion{}, ErrorNotFound
+	}
+	return p, nil
+}
+
+func (m *Memory) GetPromotionByCode(ctx context.Context, code string) (types.Promotion, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	code = types.NormalizeCode(code)
+	var pc promotionCode
+	found, err := tx.get(pkPromotionCode, code, &pc)
+	if err != nil {
+		return types.Promotion{}, err
+	}
+	var p types.Promotion
+	if found {
+		found, err = tx.get(pkPromotion, pc.PromotionID, &p)
+		if err != nil {
+			return types.Promotion{}, err
+		}
+	}
+	if !found {
+		return types.Promotion{}, fmt.Errorf("error - no promotion with code %s: %w", code, ErrorNotFound)
+	}
+	return p, nil
+}
+
+// UpdatePromotion replaces the rules of a promotion, its usage counter is
+// left untouched.
+func (m *Memory) UpdatePromotion(ctx context.Context, p types.Promotion) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	var current types.Promotion
+	found, err := tx.get(pkPromotion, p.ID, &current)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("error - to retrieve promotion: %w", ErrorNotFound)
+	}
+
+	p.Uses = current.Uses
+	p.Version = current.Version + 1
+	err = tx.put(pkPromotion, p.ID, p)
+	if err != nil {
+		return err
+	}
+	if p.Code != current.Code {
+		if tx.exists(pkPromotionCode, p.Code) {
+			return fmt.Errorf("error - code %s is taken by another promotion", p.Code)
+		}
+		err = tx.put(pkPromotionCode, p.Code, promotionCode{PromotionID: p.ID})
+		if err != nil {
+			return err
+		}
+		tx.delete(pkPromotionCode, current.Code)
+	}
+	tx.commit()
+	return nil
+}
+
+func (m *Memory) DeletePromotion(ctx context.Context, promotionID string) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	var p types.Promotion
+	found, err := tx.get(pkPromotion, promotionID, &p)
+	if err != nil {
+		return err
+	}
+	if found {
+		tx.delete(pkPromotionCode, p.Code)
+	}
+	tx.delete(pkPromotion, promotionID)
+
+	var redemptions []struct {
+		UserID string `dynamodbav:"SK"`
+	}
+	err = tx.query(pkRedemptionPrefix+promotionID, "", &redemptions)
+	if err != nil {
+		return fmt.Errorf("error - retreiving the redemptions: %w", err)
+	}
+	for _, r := range redemptions {
+		tx.delete(pkRedemptionPrefix+promotionID, r.UserID)
+	}
+	tx.commit()
+	return nil
+}
+
+func (m *Memory) ApplyCoupon(ctx context.Context, cartID string, userID string, p types.Promotion) (types.Cart, error) {
+	return m.updateCart(func(tx *memoryTx) (types.Cart, error) {
+		cart, err := tx.cart(cartID)
+		if err != nil {
+			return types.Cart{}, fmt.Errorf("error - retreiving the cart: %w", err)
+		}
+		if cart.HasCoupon(p.Code) {
+			return cart, nil
+		}
+		cart.Coupons = append(cart.Coupons, p.Code)
+
+		err = tx.redeem(p, userID)
+		if err != nil {
+			return types.Cart{}, err
+		}
+		return cart, tx.saveCart(cart, cartID)
+	})
+}
+
+func (m *Memory) RemoveCoupon(ctx context.Context, cartID string, userID string, p types.Promotion) (types.Cart, error) {
+	return m.updateCart(func(tx *memoryTx) (types.Cart, error) {
+		cart, err := tx.cart(cartID)
+		if err != nil {
+			return types.Cart{}, fmt.Errorf("error - retreiving the cart: %w", err)
+		}
+		if !cart.HasCoupon(p.Code) {
+			return types.Cart{}, fmt.Errorf("error - coupon %s is not applied to the cart: %w", p.Code, ErrorNotFound)
+		}
+
+		coupons := make([]string, 0, len(cart.Coupons))
+		for _, code := range cart.Coupons {
+			if code != p.Code {
+				coupons = append(coupons, code)
+			}
+		}
+		cart.Coupons = coupons
+		return cart, tx.saveCart(cart, cartID)
+	})
+}
+
+// redemptionCounter is the element counting the uses of a promotion by a
+// user
+type redemptionCounter struct {
+	Count int `dynamodbav:"count"`
+}
+
+// redeem counts a use of the promotion in the global and the per user usage
+// counters, within the limits of the promotion.
+func (tx *memoryTx) redeem(p types.Promotion, userID string) error {
+	var stored types.Promotion
+	found, err := tx.get(pkPromotion, p.ID, &stored)
+	if err != nil {
+		return err
+	}
+	if p.MaxUses > 0 && (!found || stored.Uses >= p.MaxUses) {
+		return fmt.Errorf("error - redeeming coupon %s: %w", p.Code, ErrorCouponUnavailable)
+	}
+	if found {
+		stored.Uses++
+		err = tx.put(pkPromotion, p.ID, stored)
+		if err != nil {
+			return err
+		}
+	}
+
+	var counter redemptionCounter
+	_, err = tx.get(pkRedemptionPrefix+p.ID, userID, &counter)
+	if err != nil {
+		return err
+	}
+	if p.MaxUsesPerUser > 0 && counter.Count >= int(p.MaxUsesPerUser) {
+		return fmt.Errorf("error - redeeming coupon %s: %w", p.Code, ErrorCouponUnavailable)
+	}
+	counter.Count++
+	return tx.put(pkRedemptionPrefix+p.ID, userID, counter)
+}
+
+func (m *Memory) CreateShippingZone(ctx context.Context, z types.ShippingZone) error {
+	return m.putElement(pkShippingZone, z.ID, z, false)
+}
+
+func (m *Memory) UpdateShippingZone(ctx context.Context, z types.ShippingZone) error {
+	return m.putElement(pkShippingZone, z.ID, z, true)
+}
+
+func (m *Memory) DeleteShippingZone(ctx context.Context, zoneID string) error {
+	return m.deleteElement(pkShippingZone, zoneID)
+}
+
+func (m *Memory) ShippingZones(ctx context.Context) ([]types.ShippingZone, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	zones := make([]types.ShippingZone, 0)
+	err := tx.query(pkShippingZone, "", &zones)
+	if err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+func (m *Memory) CreateShippingMethod(ctx context.Context, sm types.ShippingMethod) error {
+	return m.putElement(pkShippingMethod, sm.ID, sm, false)
+}
+
+func (m *Memory) UpdateShippingMethod(ctx context.Context, sm types.ShippingMethod) error {
+	return m.putElement(pkShippingMethod, sm.ID, sm, true)
+}
+
+func (m *Memory) DeleteShippingMethod(ctx context.Context, methodID string) error {
+	return m.deleteElement(pkShippingMethod, methodID)
+}
+
+func (m *Memory) ShippingMethods(ctx context.Context) ([]types.ShippingMethod, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	methods := make([]types.ShippingMethod, 0)
+	err := tx.query(pkShippingMethod, "", &methods)
+	if err != nil {
+		return nil, err
+	}
+	return methods, nil
+}
+
+func (m *Memory) SelectShipping(ctx context.Context, cartID string, country string, methodID string) (types.Cart, error) {
+	return m.updateCart(func(tx *memoryTx) (types.Cart, error) {
+		cart, err := tx.cart(cartID)
+		if err != nil {
+			return types.Cart{}, fmt.Errorf("error - retreiving the cart: %w", err)
+		}
+		cart.ShippingCountry = country
+		cart.ShippingMethodID = methodID
+		return cart, tx.saveCart(cart, cartID)
+	})
+}
+
+// SaveForLater moves a product from the cart to the wishlist of the user
+// and releases its reservation.
+func (m *Memory) SaveForLater(ctx context.Context, cartID string, userID string, productID string) (types.Cart, error) {
+	return m.updateCart(func(tx *memoryTx) (types.Cart, error) {
+		cart, err := tx.cart(cartID)
+		if err != nil {
+			return types.Cart{}, fmt.Errorf("error - retreiving the cart: %w", err)
+		}
+		item, found := cart.Items[productID]
+		if !found {
+			return types.Cart{}, fmt.Errorf("error - product %s is not in the cart: %w", productID, ErrorNotFound)
+		}
+
+		err = tx.reserve(&cart, cartID, productID, -int(item.Quantity), m.cartLimits)
+		if err != nil {
+			return types.Cart{}, err
+		}
+		err = tx.put(pkUserPrefix+userID, skWishlistPrefix+productID, types.WishlistItem{
+			ProductID: productID,
+			Quantity:  item.Quantity,
+			AddedAt:   time.Now().UTC(),
+		})
+		if err != nil {
+			return types.Cart{}, err
+		}
+		return cart, tx.saveCart(cart, cartID)
+	})
+}
+
+// putElement stores v under the given keys, when mustExist is true the
+// element is replaced only if it already exists.
+func (m *Memory) putElement(pk string, sk string, v interface{}, mustExist bool) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	if mustExist && !tx.exists(pk, sk) {
+		return ErrorNotFound
+	}
+	err := tx.put(pk, sk, v)
+	if err != nil {
+		return err
+	}
+	tx.commit()
+	return nil
+}
+
+func (m *Memory) deleteElement(pk string, sk string) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	tx.delete(pk, sk)
+	tx.commit()
+	return nil
+}
@@ -0,0 +1,413 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pratbacknd/internal/config"
+	"pratbacknd/internal/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory_Products(t *testing.T) {
+	// given
+	m := NewMemory(types.CartLimits{})
+	eur := types.Money{Amount: 120, Currency: "EUR"}
+	assert.NoError(t, m.CreateProduct(context.Background(), types.Product{ID: "p1", Name: "Camera", TotalPrice: eur, ReferencePrice: &eur}))
+
+	// when
+	err := m.UpdateProduct(context.Background(), UpdateProductInput{ProductId: "p1", Description: "A camera"})
+
+	// then
+	assert.NoError(t, err)
+	p, err := m.GetProductById(context.Background(), "p1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Camera", p.Name, "empty values are not updated")
+	assert.Equal(t, "A camera", p.Description)
+	assert.Equal(t, uint(1), p.Version)
+	assert.Nil(t, p.ReferencePrice, "computed fields are never stored")
+
+	history, err := m.PriceHistory(context.Background(), "p1")
+	assert.NoError(t, err)
+	assert.Len(t, history, 1, "the first price is recorded")
+
+	assert.ErrorIs(t, m.UpdateProductRelations(context.Background(), "p1", 0, nil), ErrorProductChanged)
+	_, err = m.GetProductById(context.Background(), "unknown")
+	assert.ErrorIs(t, err, ErrorNotFound)
+}
+
+func TestMemory_Cart(t *testing.T) {
+	t.Run("reserves the stock", func(t *testing.T) {
+		// given
+		m := NewMemory(types.CartLimits{})
+		assert.NoError(t, m.CreateProduct(context.Background(), types.Product{ID: "p1", Stock: 3}))
+
+		// when
+		cart, err := m.CreateOrUpdateCart(context.Background(), "u1", "p1", 2)
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, uint(2), cart.Items["p1"].Quantity)
+		p, _ := m.GetProductById(context.Background(), "p1")
+		assert.Equal(t, uint(1), p.Stock)
+		assert.Equal(t, uint(2), p.Reserved)
+	})
+
+	t.Run("failed operations are not written", func(t *testing.T) {
+		// given
+		m := NewMemory(types.CartLimits{})
+		assert.NoError(t, m.CreateProduct(context.Background(), types.Product{ID: "p1", Stock: 1}))
+
+		// when
+		_, err := m.CreateOrUpdateCart(context.Background(), "u1", "p1", 2)
+
+		// then
+		assert.Error(t, err)
+		_, err = m.GetCart(context.Background(), "u1")
+		assert.ErrorIs(t, err, ErrorNotFound, "the cart created by the operation is rolled back")
+	})
+
+	t.Run("restock serves the backorders", func(t *testing.T) {
+		// given
+		m := NewMemory(types.CartLimits{})
+		assert.NoError(t, m.CreateProduct(context.Background(), types.Product{ID: "p1", Stock: 1, Backorder: true}))
+		_, err := m.CreateOrUpdateCart(context.Background(), "u1", "p1", 3)
+		assert.NoError(t, err)
+
+		// when
+		err = m.UpdateInventory(context.Background(), "p1", 5)
+
+		// then
+		assert.NoError(t, err)
+		p, _ := m.GetProductById(context.Background(), "p1")
+		assert.Equal(t, uint(3), p.Stock)
+		assert.Equal(t, uint(0), p.Backordered)
+		cart, _ := m.GetCart(context.Background(), "u1")
+		assert.Equal(t, uint(0), cart.Items["p1"].Backordered)
+	})
+
+	t.Run("restock shelves the units the carts no longer hold", func(t *testing.T) {
+		// given
+		m := NewMemory(types.CartLimits{})
+		assert.NoError(t, m.CreateProduct(context.Background(), types.Product{ID: "p1", Backorder: true, Backordered: 2}))
+		assert.NoError(t, m.CreateProduct(context.Background(), types.Product{ID: "p2", Stock: 1}))
+		_, err := m.CreateOrUpdateCart(context.Background(), "u1", "p2", 1)
+		assert.NoError(t, err)
+		tx, unlock := m.tx()
+		assert.NoError(t, tx.updateBackorder("p1", "u1", 2))
+		tx.commit()
+		unlock()
+
+		// when
+		err = m.UpdateInventory(context.Background(), "p1", 5)
+
+		// then
+		assert.NoError(t, err)
+		p, _ := m.GetProductById(context.Background(), "p1")
+		assert.Equal(t, uint(5), p.Stock, "no unit reached a cart")
+		assert.Equal(t, uint(0), p.Backordered)
+	})
+
+	t.Run("a product in another currency is refused", func(t *testing.T) {
+		// given
+		m := NewMemory(types.CartLimits{})
+		assert.NoError(t, m.CreateProduct(context.Background(), types.Product{ID: "p1", Stock: 5, TotalPrice: types.Money{Amount: 100, Currency: "EUR"}}))
+		assert.NoError(t, m.CreateProduct(context.Background(), types.Product{ID: "p2", Stock: 5, TotalPrice: types.Money{Amount: 100, Currency: "USD"}}))
+		_, err := m.CreateOrUpdateCart(context.Background(), "u1", "p1", 1)
+		assert.NoError(t, err)
+
+		// when
+		_, err = m.CreateOrUpdateCart(context.Background(), "u1", "p2", 1)
+
+		// then
+		assert.ErrorIs(t, err, types.ErrorCurrencyMismatch)
+		_, err = m.CreateOrUpdateCart(context.Background(), "u1", "p1", -1)
+		assert.NoError(t, err)
+		cart, err := m.CreateOrUpdateCart(context.Background(), "u1", "p2", 1)
+		assert.NoError(t, err, "an emptied cart takes another currency")
+		assert.Equal(t, "USD", cart.CurrencyCode)
+	})
+
+	t.Run("the expired carts are released", func(t *testing.T) {
+		// given
+		m := NewMemory(types.CartLimits{})
+		assert.NoError(t, m.CreateProduct(context.Background(), types.Product{ID: "p1", Stock: 5}))
+		_, err := m.CreateOrUpdateCart(context.Background(), "guest#1", "p1", 2)
+		assert.NoError(t, err)
+		_, err = m.CreateOrUpdateCart(context.Background(), "u1", "p1", 1)
+		assert.NoError(t, err)
+
+		// when
+		kept, err := m.ReleaseExpiredCarts(context.Background(), "guest#", time.Now().Add(-time.Hour))
+		assert.NoError(t, err)
+		released, err := m.ReleaseExpiredCarts(context.Background(), "guest#", time.Now().Add(time.Hour))
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, 0, kept, "the carts written since are kept")
+		assert.Equal(t, 1, released, "only the guest carts are released")
+		_, err = m.GetCart(context.Background(), "guest#1")
+		assert.ErrorIs(t, err, ErrorNotFound)
+		p, _ := m.GetProductById(context.Background(), "p1")
+		assert.Equal(t, uint(4), p.Stock)
+		assert.Equal(t, uint(1), p.Reserved)
+	})
+
+	t.Run("an item above a lowered max per order can be reduced", func(t *testing.T) {
+		// given
+		m := NewMemory(types.CartLimits{})
+		assert.NoError(t, m.CreateProduct(context.Background(), types.Product{ID: "p1", Stock: 5}))
+		_, err := m.CreateOrUpdateCart(context.Background(), "u1", "p1", 4)
+		assert.NoError(t, err)
+		max := uint(2)
+		assert.NoError(t, m.UpdateProduct(context.Background(), UpdateProductInput{ProductId: "p1", MaxPerOrder: &max}))
+
+		// when
+		cart, err := m.CreateOrUpdateCart(context.Background(), "u1", "p1", -1)
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, uint(3), cart.Items["p1"].Quantity)
+		_, err = m.CreateOrUpdateCart(context.Background(), "u1", "p1", 1)
+		var limitErr *types.LimitError
+		assert.ErrorAs(t, err, &limitErr, "the quantity can't grow back")
+	})
+
+	t.Run("merge moves the reservations", func(t *testing.T) {
+		// given
+		m := NewMemory(types.CartLimits{})
+		assert.NoError(t, m.CreateProduct(context.Background(), types.Product{ID: "p1", Stock: 5}))
+		_, err := m.CreateOrUpdateCart(context.Background(), "guest", "p1", 2)
+		assert.NoError(t, err)
+		_, err = m.CreateOrUpdateCart(context.Background(), "u1", "p1", 1)
+		assert.NoError(t, err)
+
+		// when
+		cart, err := m.MergeCarts(context.Background(), "guest", "u1")
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, uint(3), cart.Items["p1"].Quantity)
+		p, _ := m.GetProductById(context.Background(), "p1")
+		assert.Equal(t, uint(3), p.Reserved)
+		_, err = m.GetCart(context.Background(), "guest")
+		assert.ErrorIs(t, err, ErrorNotFound)
+	})
+}
+
+func TestMemory_RecordPurchase(t *testing.T) {
+	// given
+	m := NewMemory(types.CartLimits{})
+	assert.NoError(t, m.RecordPurchase(context.Background(), "order1", []string{"camera", "sd-card", "camera"}))
+
+	// when
+	err := m.RecordPurchase(context.Background(), "order1", []string{"camera", "sd-card"})
+
+	// then
+	assert.NoError(t, err)
+	coPurchases, err := m.CoPurchases(context.Background(), "camera", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.CoPurchase{{ProductID: "sd-card", Count: 1}}, coPurchases, "an order is counted once")
+}
+
+func TestPurchaseChunks(t *testing.T) {
+	productIDs := make([]string, 0, maxPurchaseProducts+5)
+	for i := 0; i < maxPurchaseProducts+5; i++ {
+		productIDs = append(productIDs, fmt.Sprintf("p%d", i))
+	}
+
+	chunks := purchaseChunks(productIDs)
+
+	counters := 0
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, len(chunk), maxCountersPerTransaction)
+		counters += len(chunk)
+	}
+	assert.Equal(t, maxPurchaseProducts*(maxPurchaseProducts-1), counters, "only the first products are counted")
+}
+
+func TestMergeCoPurchases(t *testing.T) {
+	// given
+	top := make([]types.CoPurchase, 0, maxCoPurchasesKept)
+	for i := 0; i < maxCoPurchasesKept; i++ {
+		top = append(top, types.CoPurchase{ProductID: fmt.Sprintf("p%02d", i), Count: uint(100 - i)})
+	}
+
+	// when
+	merged := mergeCoPurchases(top, []types.CoPurchase{
+		{ProductID: "p05", Count: 200},
+		{ProductID: "new", Count: 90},
+		{ProductID: "rare", Count: 1},
+	})
+
+	// then
+	assert.Len(t, merged, maxCoPurchasesKept, "the top is bounded")
+	assert.Equal(t, types.CoPurchase{ProductID: "p05", Count: 200}, merged[0], "a counter of the order is updated")
+	assert.Contains(t, merged, types.CoPurchase{ProductID: "new", Count: 90})
+	assert.NotContains(t, merged, types.CoPurchase{ProductID: "rare", Count: 1})
+	assert.NotContains(t, merged, types.CoPurchase{ProductID: fmt.Sprintf("p%02d", maxCoPurchasesKept-1), Count: uint(100 - maxCoPurchasesKept + 1)}, "the least frequent leaves the top")
+}
+
+func TestMemory_ApplyCoupon(t *testing.T) {
+	// given
+	m := NewMemory(types.CartLimits{})
+	promotion := types.Promotion{ID: "promo", Code: "WELCOME", MaxUsesPerUser: 1}
+	assert.NoError(t, m.CreatePromotion(context.Background(), promotion))
+	assert.NoError(t, m.CreateCart(context.Background(), types.Cart{Version: 1}, "u1"))
+	assert.NoError(t, m.CreateCart(context.Background(), types.Cart{Version: 1}, "u1-other"))
+
+	// when
+	_, err := m.ApplyCoupon(context.Background(), "u1", "u1", promotion)
+	assert.NoError(t, err)
+	_, err = m.ApplyCoupon(context.Background(), "u1-other", "u1", promotion)
+
+	// then
+	assert.True(t, errors.Is(err, ErrorCouponUnavailable), "the user already used the coupon")
+	stored, _ := m.GetPromotionById(context.Background(), "promo")
+	assert.Equal(t, uint(1), stored.Uses)
+}
+
+func TestMemory_RemoveCoupon(t *testing.T) {
+	// given
+	m := NewMemory(types.CartLimits{})
+	promotion := types.Promotion{ID: "promo", Code: "WELCOME", MaxUsesPerUser: 1}
+	assert.NoError(t, m.CreatePromotion(context.Background(), promotion))
+	assert.NoError(t, m.CreateCart(context.Background(), types.Cart{Version: 1}, "u1"))
+	_, err := m.ApplyCoupon(context.Background(), "u1", "u1", promotion)
+	assert.NoError(t, err)
+
+	// when
+	_, err = m.RemoveCoupon(context.Background(), "u1", "u1", promotion)
+	assert.NoError(t, err)
+	_, err = m.ApplyCoupon(context.Background(), "u1", "u1", promotion)
+
+	// then
+	assert.True(t, errors.Is(err, ErrorCouponUnavailable), "removing the coupon does not give the use back")
+	stored, _ := m.GetPromotionById(context.Background(), "promo")
+	assert.Equal(t, uint(1), stored.Uses)
+}
+
+func TestMemory_GetPromotionByCode(t *testing.T) {
+	// given
+	m := NewMemory(types.CartLimits{})
+	assert.NoError(t, m.CreatePromotion(context.Background(), types.Promotion{ID: "promo", Code: "WELCOME"}))
+	assert.Error(t, m.CreatePromotion(context.Background(), types.Promotion{ID: "other", Code: "WELCOME"}), "the code is taken")
+
+	// when
+	assert.NoError(t, m.UpdatePromotion(context.Background(), types.Promotion{ID: "promo", Code: "HELLO"}))
+
+	// then
+	p, err := m.GetPromotionByCode(context.Background(), "hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "promo", p.ID)
+	_, err = m.GetPromotionByCode(context.Background(), "WELCOME")
+	assert.ErrorIs(t, err, ErrorNotFound, "the old code is released")
+
+	assert.NoError(t, m.DeletePromotion(context.Background(), "promo"))
+	_, err = m.GetPromotionByCode(context.Background(), "HELLO")
+	assert.ErrorIs(t, err, ErrorNotFound)
+}
+
+func TestMemory_DeletePromotion(t *testing.T) {
+	// given
+	m := NewMemory(types.CartLimits{})
+	promotion := types.Promotion{ID: "promo", Code: "WELCOME", MaxUsesPerUser: 1}
+	assert.NoError(t, m.CreatePromotion(context.Background(), promotion))
+	assert.NoError(t, m.CreateCart(context.Background(), types.Cart{Version: 1}, "u1"))
+	_, err := m.ApplyCoupon(context.Background(), "u1", "u1", promotion)
+	assert.NoError(t, err)
+
+	// when
+	err = m.DeletePromotion(context.Background(), "promo")
+
+	// then
+	assert.NoError(t, err)
+	var redemptions []struct{}
+	tx, unlock := m.tx()
+	assert.NoError(t, tx.query(pkRedemptionPrefix+"promo", "", &redemptions))
+	unlock()
+	assert.Empty(t, redemptions, "the redemptions are deleted with the promotion")
+}
+
+func TestMemory_DeleteUserData(t *testing.T) {
+	t.Run("erases the profile, addresses, reviews, redemptions and backorders", func(t *testing.T) {
+		// given
+		m := NewMemory(types.CartLimits{})
+		assert.NoError(t, m.CreateProfile(context.Background(), types.Profile{UserID: "u1"}))
+		_, err := m.CreateAddress(context.Background(), "u1", types.Address{ID: "a1"})
+		assert.NoError(t, err)
+		assert.ErrorIs(t, m.CreateProfile(context.Background(), types.Profile{UserID: "u1"}), ErrorProfileExists)
+		assert.NoError(t, m.CreateProduct(context.Background(), types.Product{ID: "p1", Backorder: true}))
+		_, err = m.CreateOrUpdateCart(context.Background(), "u1", "p1", 1)
+		assert.NoError(t, err)
+		promotion := types.Promotion{ID: "promo", Code: "WELCOME"}
+		assert.NoError(t, m.CreatePromotion(context.Background(), promotion))
+		_, err = m.ApplyCoupon(context.Background(), "u1", "u1", promotion)
+		assert.NoError(t, err)
+		assert.NoError(t, m.CreateReview(context.Background(), types.Review{ID: "r1", ProductID: "p1", UserID: "u1", Rating: 4, Status: types.ReviewPending}))
+		_, err = m.ModerateReview(context.Background(), "p1", "r1", types.ReviewApproved)
+		assert.NoError(t, err)
+		reviews, err := m.UserReviews(context.Background(), "u1")
+		assert.NoError(t, err)
+		assert.Len(t, reviews, 1)
+
+		// when
+		err = m.DeleteUserData(context.Background(), "u1")
+
+		// then
+		assert.NoError(t, err)
+		_, err = m.GetProfile(context.Background(), "u1")
+		assert.ErrorIs(t, err, ErrorNotFound)
+		addresses, err := m.Addresses(context.Background(), "u1")
+		assert.NoError(t, err)
+		assert.Empty(t, addresses)
+		_, err = m.GetCart(context.Background(), "u1")
+		assert.ErrorIs(t, err, ErrorNotFound)
+		reviews, err = m.Reviews(context.Background(), "p1", types.ReviewApproved)
+		assert.NoError(t, err)
+		assert.Empty(t, reviews)
+		p, _ := m.GetProductById(context.Background(), "p1")
+		assert.Equal(t, uint(0), p.RatingCount, "the review is out of the rating")
+		for _, key := range []memoryKey{{pk: pkRedemptionPrefix + "promo", sk: "u1"}, {pk: pkBackorderPrefix + "p1", sk: "u1"}} {
+			_, found := m.items[key]
+			assert.False(t, found, "%s/%s is erased", key.pk, key.sk)
+		}
+	})
+
+	t.Run("a cart that can't be deleted is an error", func(t *testing.T) {
+		// given a cart holding a product deleted since
+		m := NewMemory(types.CartLimits{})
+		assert.NoError(t, m.CreateProfile(context.Background(), types.Profile{UserID: "u1"}))
+		assert.NoError(t, m.CreateCart(context.Background(), types.Cart{Version: 1, Items: map[string]types.Item{"p1": {ID: "p1", Quantity: 1}}}, "u1"))
+
+		// when
+		err := m.DeleteUserData(context.Background(), "u1")
+
+		// then
+		assert.ErrorIs(t, err, ErrorNotFound)
+		_, err = m.GetProfile(context.Background(), "u1")
+		assert.NoError(t, err, "nothing is erased")
+	})
+}
+
+func TestNew_CartLimits(t *testing.T) {
+	assert.Equal(t, config.MaxCartDistinctItems, maxItemsPerTransaction, "the config validates the limit of the storage")
+
+	for _, max := range []int{-1, maxItemsPerTransaction + 1} {
+		// when
+		_, err := New(config.Storage{Kind: config.StorageMemory, CartMaxDistinctItems: max})
+
+		// then
+		assert.Error(t, err, "a cart of %d items can't be cleared in one transaction", max)
+	}
+
+	// when
+	s, err := New(config.Storage{Kind: config.StorageMemory})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, maxItemsPerTransaction, s.(*Memory).cartLimits.MaxDistinctItems)
+}
@@ -0,0 +1,460 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pratbacknd/internal/types"
+	"sort"
+	"time"
+)
+
+func (m *Memory) Addresses(ctx context.Context, userID string) ([]types.Address, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	return tx.addresses(userID)
+}
+
+func (tx *memoryTx) addresses(userID string) ([]types.Address, error) {
+	addresses := make([]types.Address, 0)
+	err := tx.query(pkUserPrefix+userID, skAddressPrefix, &addresses)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(addresses, func(i, j int) bool {
+		return addresses[i].CreatedAt.Before(addresses[j].CreatedAt)
+	})
+	return addresses, nil
+}
+
+func (m *Memory) GetAddress(ctx context.Context, userID string, addressID string) (types.Address, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	var a types.Address
+	found, err := tx.get(pkUserPrefix+userID, skAddressPrefix+addressID, &a)
+	if err != nil {
+		return types.Address{}, err
+	}
+	if !found {
+		return types.Address{}, fmt.Errorf("error - no address %s: %w", addressID, ErrorNotFound)
+	}
+	return a, nil
+}
+
+// CreateAddress adds an address to the book of the user. The first address
+// of the book becomes the default one.
+func (m *Memory) CreateAddress(ctx context.Context, userID string, a types.Address) (types.Address, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	addresses, err := tx.addresses(userID)
+	if err != nil {
+		return types.Address{}, fmt.Errorf("error - retreiving the address book: %w", err)
+	}
+	if len(addresses) >= types.MaxAddresses {
+		return types.Address{}, ErrorAddressBookFull
+	}
+	if len(addresses) == 0 {
+		a.Default = true
+	}
+	if tx.exists(pkUserPrefix+userID, skAddressPrefix+a.ID) {
+		return types.Address{}, fmt.Errorf("error - address %s already exists", a.ID)
+	}
+
+	err = tx.saveAddress(userID, a, addresses)
+	if err != nil {
+		return types.Address{}, err
+	}
+	tx.commit()
+	return a, nil
+}
+
+// UpdateAddress replaces an address of the user. An address stops being the
+// default one only when another address is made default.
+func (m *Memory) UpdateAddress(ctx context.Context, userID string, a types.Address) (types.Address, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	addresses, err := tx.addresses(userID)
+	if err != nil {
+		return types.Address{}, fmt.Errorf("error - retreiving the address book: %w", err)
+	}
+
+	var current *types.Address
+	for i := range addresses {
+		if addresses[i].ID == a.ID {
+			current = &addresses[i]
+		}
+	}
+	if current == nil {
+		return types.Address{}, fmt.Errorf("error - no address %s: %w", a.ID, ErrorNotFound)
+	}
+	a.CreatedAt = current.CreatedAt
+	a.Default = a.Default || current.Default
+
+	err = tx.saveAddress(userID, a, addresses)
+	if err != nil {
+		return types.Address{}, err
+	}
+	tx.commit()
+	return a, nil
+}
+
+// DeleteAddress removes an address of the user, when it was the default one
+// the oldest remaining address becomes the default.
+func (m *Memory) DeleteAddress(ctx context.Context, userID string, addressID string) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	addresses, err := tx.addresses(userID)
+	if err != nil {
+		return fmt.Errorf("error - retreiving the address book: %w", err)
+	}
+
+	var deleted *types.Address
+	remaining := make([]types.Address, 0, len(addresses))
+	for i := range addresses {
+		if addresses[i].ID == addressID {
+			deleted = &addresses[i]
+			continue
+		}
+		remaining = append(remaining, addresses[i])
+	}
+	if deleted == nil {
+		return fmt.Errorf("error - no address %s: %w", addressID, ErrorNotFound)
+	}
+
+	tx.delete(pkUserPrefix+userID, skAddressPrefix+addressID)
+	if deleted.Default && len(remaining) > 0 {
+		remaining[0].Default = true
+		err = tx.put(pkUserPrefix+userID, skAddressPrefix+remaining[0].ID, remaining[0])
+		if err != nil {
+			return err
+		}
+	}
+	tx.commit()
+	return nil
+}
+
+// saveAddress puts the address and, when it is the default one, unsets the
+// default flag of the other addresses.
+func (tx *memoryTx) saveAddress(userID string, a types.Address, addresses []types.Address) error {
+	err := tx.put(pkUserPrefix+userID, skAddressPrefix+a.ID, a)
+	if err != nil {
+		return err
+	}
+	if !a.Default {
+		return nil
+	}
+	for _, other := range addresses {
+		if other.ID == a.ID || !other.Default {
+			continue
+		}
+		other.Default = false
+		err = tx.put(pkUserPrefix+userID, skAddressPrefix+other.ID, other)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Memory) GetProfile(ctx context.Context, userID string) (types.Profile, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	var p types.Profile
+	found, err := tx.get(pkUserPrefix+userID, skProfile, &p)
+	if err != nil {
+		return types.Profile{}, err
+	}
+	if !found {
+		return types.Profile{}, fmt.Errorf("error - no profile for user %s: %w", userID, ErrorNotFound)
+	}
+	return p, nil
+}
+
+// CreateProfile stores the profile of a user, it fails with
+// ErrorProfileExists if the user already has one.
+func (m *Memory) CreateProfile(ctx context.Context, p types.Profile) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	if tx.exists(pkUserPrefix+p.UserID, skProfile) {
+		return ErrorProfileExists
+	}
+	err := tx.put(pkUserPrefix+p.UserID, skProfile, p)
+	if err != nil {
+		return err
+	}
+	tx.commit()
+	return nil
+}
+
+func (m *Memory) UpdateProfile(ctx context.Context, p types.Profile) error {
+	return m.putElement(pkUserPrefix+p.UserID, skProfile, p, true)
+}
+
+func (m *Memory) Wishlist(ctx context.Context, userID string) ([]types.WishlistItem, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	items := make([]types.WishlistItem, 0)
+	err := tx.query(pkUserPrefix+userID, skWishlistPrefix, &items)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].AddedAt.After(items[j].AddedAt)
+	})
+	return items, nil
+}
+
+func (m *Memory) AddToWishlist(ctx context.Context, userID string, item types.WishlistItem) error {
+	return m.putElement(pkUserPrefix+userID, skWishlistPrefix+item.ProductID, item, false)
+}
+
+func (m *Memory) RemoveFromWishlist(ctx context.Context, userID string, productID string) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	if !tx.exists(pkUserPrefix+userID, skWishlistPrefix+productID) {
+		return fmt.Errorf("error - product %s is not in the wishlist: %w", productID, ErrorNotFound)
+	}
+	tx.delete(pkUserPrefix+userID, skWishlistPrefix+productID)
+	tx.commit()
+	return nil
+}
+
+// CreateReview stores a pending review, adds it to the moderation queue and
+// to the reviews of the user.
+func (m *Memory) CreateReview(ctx context.Context, r types.Review) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	if tx.exists(pkReviewPrefix+r.ProductID, r.UserID) {
+		return ErrorReviewExists
+	}
+	err := tx.put(pkReviewPrefix+r.ProductID, r.UserID, r)
+	if err != nil {
+		return err
+	}
+	err = tx.put(pkReviewQueue, r.ID, r)
+	if err != nil {
+		return err
+	}
+	err = tx.put(pkUserPrefix+r.UserID, skReviewPrefix+r.ProductID, userReview{ProductID: r.ProductID})
+	if err != nil {
+		return err
+	}
+	tx.commit()
+	return nil
+}
+
+// UserReviews returns the reviews written by the user, most recent first.
+func (m *Memory) UserReviews(ctx context.Context, userID string) ([]types.Review, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	return tx.userReviews(userID)
+}
+
+func (tx *memoryTx) userReviews(userID string) ([]types.Review, error) {
+	reviewed := make([]userReview, 0)
+	err := tx.query(pkUserPrefix+userID, skReviewPrefix, &reviewed)
+	if err != nil {
+		return nil, err
+	}
+
+	reviews := make([]types.Review, 0, len(reviewed))
+	for _, ur := range reviewed {
+		var r types.Review
+		found, err := tx.get(pkReviewPrefix+ur.ProductID, userID, &r)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			reviews = append(reviews, r)
+		}
+	}
+	sort.SliceStable(reviews, func(i, j int) bool {
+		return reviews[i].CreatedAt.After(reviews[j].CreatedAt)
+	})
+	return reviews, nil
+}
+
+// deleteReview deletes the review and its copy in the moderation queue, an
+// approved review is taken out of the rating of its product.
+func (tx *memoryTx) deleteReview(r types.Review) error {
+	count, total := r.RatingDelta(types.ReviewRejected)
+	if count != 0 {
+		p, err := tx.product(r.ProductID)
+		if err != nil && !errors.Is(err, ErrorNotFound) {
+			return fmt.Errorf("error - getting the product of id %s: %w", r.ProductID, err)
+		}
+		// the rating of a deleted product is gone with it
+		if err == nil {
+			p.RatingCount = uint(int(p.RatingCount) + count)
+			p.RatingTotal = uint(int(p.RatingTotal) + total)
+			err = tx.put(pkProduct, r.ProductID, p)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	tx.delete(pkReviewPrefix+r.ProductID, r.UserID)
+	tx.delete(pkReviewQueue, r.ID)
+	return nil
+}
+
+func (m *Memory) Reviews(ctx context.Context, productID string, status string) ([]types.Review, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	reviews, err := tx.reviews(pkReviewPrefix + productID)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]types.Review, 0, len(reviews))
+	for _, r := range reviews {
+		if r.Status == status {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered, nil
+}
+
+func (m *Memory) PendingReviews(ctx context.Context) ([]types.Review, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	return tx.reviews(pkReviewQueue)
+}
+
+// ModerateReview approves or rejects a review and maintains the rating
+// aggregates of the product.
+func (m *Memory) ModerateReview(ctx context.Context, productID string, reviewID string, status string) (types.Review, error) {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	reviews, err := tx.reviews(pkReviewPrefix + productID)
+	if err != nil {
+		return types.Review{}, err
+	}
+
+	var review *types.Review
+	for i := range reviews {
+		if reviews[i].ID == reviewID {
+			review = &reviews[i]
+		}
+	}
+	if review == nil {
+		return types.Review{}, fmt.Errorf("error - no review %s for product %s: %w", reviewID, productID, ErrorNotFound)
+	}
+
+	count, total := review.RatingDelta(status)
+	if count != 0 {
+		p, err := tx.product(productID)
+		if err != nil {
+			return types.Review{}, fmt.Errorf("error - getting the product of id %s: %w", productID, err)
+		}
+		p.RatingCount = uint(int(p.RatingCount) + count)
+		p.RatingTotal = uint(int(p.RatingTotal) + total)
+		err = tx.put(pkProduct, productID, p)
+		if err != nil {
+			return types.Review{}, err
+		}
+	}
+
+	if review.Status == types.ReviewPending {
+		tx.delete(pkReviewQueue, review.ID)
+	}
+
+	now := time.Now().UTC()
+	review.Status = status
+	review.ModeratedAt = &now
+	err = tx.put(pkReviewPrefix+productID, review.UserID, *review)
+	if err != nil {
+		return types.Review{}, err
+	}
+	tx.commit()
+	return *review, nil
+}
+
+func (tx *memoryTx) reviews(pk string) ([]types.Review, error) {
+	reviews := make([]types.Review, 0)
+	err := tx.query(pk, "", &reviews)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(reviews, func(i, j int) bool {
+		return reviews[i].CreatedAt.After(reviews[j].CreatedAt)
+	})
+	return reviews, nil
+}
+
+// DeleteUserData erases the personal data of the user: the cart is deleted
+// with its reservations released, then every element of the user partition
+// with the reviews, the coupon redemptions and the backorders of the user.
+func (m *Memory) DeleteUserData(ctx context.Context, userID string) error {
+	tx, unlock := m.tx()
+	defer unlock()
+
+	_, err := tx.cart(userID)
+	switch {
+	case errors.Is(err, ErrorNotFound):
+		// the user has no cart
+	case err != nil:
+		return err
+	default:
+		err = tx.deleteCart(userID, m.cartLimits)
+		if err != nil {
+			return fmt.Errorf("error - deleting the cart: %w", err)
+		}
+	}
+
+	reviews, err := tx.userReviews(userID)
+	if err != nil {
+		return fmt.Errorf("error - retreiving the reviews: %w", err)
+	}
+	for _, r := range reviews {
+		err = tx.deleteReview(r)
+		if err != nil {
+			return fmt.Errorf("error - deleting the review of product %s: %w", r.ProductID, err)
+		}
+	}
+
+	var elements []struct {
+		SK string `dynamodbav:"SK"`
+	}
+	err = tx.query(pkUserPrefix+userID, "", &elements)
+	if err != nil {
+		return fmt.Errorf("error - retreiving the user data: %w", err)
+	}
+	for _, e := range elements {
+		tx.delete(pkUserPrefix+userID, e.SK)
+	}
+
+	promotions, err := tx.promotions()
+	if err != nil {
+		return fmt.Errorf("error - retreiving the promotions: %w", err)
+	}
+	for _, p := range promotions {
+		tx.delete(pkRedemptionPrefix+p.ID, userID)
+	}
+
+	var products []types.Product
+	err = tx.query(pkProduct, "", &products)
+	if err != nil {
+		return fmt.Errorf("error - retreiving the products: %w", err)
+	}
+	for _, p := range products {
+		tx.delete(pkBackorderPrefix+p.ID, userID)
+	}
+
+	tx.commit()
+	return nil
+}
@@ -1,12 +1,14 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: internal/storage/storage.go
+// Source: storage.go
 
 // Package storage is a generated GoMock package.
 package storage
 
 import (
+	context "context"
 	types "pratbacknd/internal/types"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -34,147 +36,874 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// AddToWishlist mocks base method.
+func (m *MockStorage) AddToWishlist(ctx context.Context, userID string, item types.WishlistItem) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddToWishlist", ctx, userID, item)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddToWishlist indicates an expected call of AddToWishlist.
+func (mr *MockStorageMockRecorder) AddToWishlist(ctx, userID, item interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddToWishlist", reflect.TypeOf((*MockStorage)(nil).AddToWishlist), ctx, userID, item)
+}
+
+// Addresses mocks base method.
+func (m *MockStorage) Addresses(ctx context.Context, userID string) ([]types.Address, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Addresses", ctx, userID)
+	ret0, _ := ret[0].([]types.Address)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Addresses indicates an expected call of Addresses.
+func (mr *MockStorageMockRecorder) Addresses(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Addresses", reflect.TypeOf((*MockStorage)(nil).Addresses), ctx, userID)
+}
+
+// ApplyCoupon mocks base method.
+func (m *MockStorage) ApplyCoupon(ctx context.Context, cartID, userID string, p types.Promotion) (types.Cart, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApplyCoupon", ctx, cartID, userID, p)
+	ret0, _ := ret[0].(types.Cart)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApplyCoupon indicates an expected call of ApplyCoupon.
+func (mr *MockStorageMockRecorder) ApplyCoupon(ctx, cartID, userID, p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyCoupon", reflect.TypeOf((*MockStorage)(nil).ApplyCoupon), ctx, cartID, userID, p)
+}
+
 // Categories mocks base method.
-func (m *MockStorage) Categories() ([]types.Category, error) {
+func (m *MockStorage) Categories(ctx context.Context) ([]types.Category, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Categories")
+	ret := m.ctrl.Call(m, "Categories", ctx)
 	ret0, _ := ret[0].([]types.Category)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Categories indicates an expected call of Categories.
-func (mr *MockStorageMockRecorder) Categories() *gomock.Call {
+func (mr *MockStorageMockRecorder) Categories(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Categories", reflect.TypeOf((*MockStorage)(nil).Categories))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Categories", reflect.TypeOf((*MockStorage)(nil).Categories), ctx)
+}
+
+// ClearCart mocks base method.
+func (m *MockStorage) ClearCart(ctx context.Context, cartID string) (types.Cart, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClearCart", ctx, cartID)
+	ret0, _ := ret[0].(types.Cart)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClearCart indicates an expected call of ClearCart.
+func (mr *MockStorageMockRecorder) ClearCart(ctx, cartID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearCart", reflect.TypeOf((*MockStorage)(nil).ClearCart), ctx, cartID)
+}
+
+// CoPurchases mocks base method.
+func (m *MockStorage) CoPurchases(ctx context.Context, productID string, limit int) ([]types.CoPurchase, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CoPurchases", ctx, productID, limit)
+	ret0, _ := ret[0].([]types.CoPurchase)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CoPurchases indicates an expected call of CoPurchases.
+func (mr *MockStorageMockRecorder) CoPurchases(ctx, productID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CoPurchases", reflect.TypeOf((*MockStorage)(nil).CoPurchases), ctx, productID, limit)
+}
+
+// CreateAddress mocks base method.
+func (m *MockStorage) CreateAddress(ctx context.Context, userID string, a types.Address) (types.Address, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAddress", ctx, userID, a)
+	ret0, _ := ret[0].(types.Address)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAddress indicates an expected call of CreateAddress.
+func (mr *MockStorageMockRecorder) CreateAddress(ctx, userID, a interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAddress", reflect.TypeOf((*MockStorage)(nil).CreateAddress), ctx, userID, a)
 }
 
 // CreateCart mocks base method.
-func (m *MockStorage) CreateCart(cart types.Cart, userId string) error {
+func (m *MockStorage) CreateCart(ctx context.Context, cart types.Cart, userId string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateCart", cart, userId)
+	ret := m.ctrl.Call(m, "CreateCart", ctx, cart, userId)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CreateCart indicates an expected call of CreateCart.
-func (mr *MockStorageMockRecorder) CreateCart(cart, userId interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) CreateCart(ctx, cart, userId interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCart", reflect.TypeOf((*MockStorage)(nil).CreateCart), cart, userId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCart", reflect.TypeOf((*MockStorage)(nil).CreateCart), ctx, cart, userId)
 }
 
 // CreateCategory mocks base method.
-func (m *MockStorage) CreateCategory(c types.Category) error {
+func (m *MockStorage) CreateCategory(ctx context.Context, c types.Category) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateCategory", c)
+	ret := m.ctrl.Call(m, "CreateCategory", ctx, c)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CreateCategory indicates an expected call of CreateCategory.
-func (mr *MockStorageMockRecorder) CreateCategory(c interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) CreateCategory(ctx, c interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCategory", reflect.TypeOf((*MockStorage)(nil).CreateCategory), c)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCategory", reflect.TypeOf((*MockStorage)(nil).CreateCategory), ctx, c)
 }
 
 // CreateOrUpdateCart mocks base method.
-func (m *MockStorage) CreateOrUpdateCart(userID, productID string, delta int) (types.Cart, error) {
+func (m *MockStorage) CreateOrUpdateCart(ctx context.Context, userID, productID string, delta int) (types.Cart, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateOrUpdateCart", userID, productID, delta)
+	ret := m.ctrl.Call(m, "CreateOrUpdateCart", ctx, userID, productID, delta)
 	ret0, _ := ret[0].(types.Cart)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateOrUpdateCart indicates an expected call of CreateOrUpdateCart.
-func (mr *MockStorageMockRecorder) CreateOrUpdateCart(userID, productID, delta interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) CreateOrUpdateCart(ctx, userID, productID, delta interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrUpdateCart", reflect.TypeOf((*MockStorage)(nil).CreateOrUpdateCart), userID, productID, delta)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrUpdateCart", reflect.TypeOf((*MockStorage)(nil).CreateOrUpdateCart), ctx, userID, productID, delta)
 }
 
 // CreateProduct mocks base method.
-func (m *MockStorage) CreateProduct(p types.Product) error {
+func (m *MockStorage) CreateProduct(ctx context.Context, p types.Product) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateProduct", p)
+	ret := m.ctrl.Call(m, "CreateProduct", ctx, p)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CreateProduct indicates an expected call of CreateProduct.
-func (mr *MockStorageMockRecorder) CreateProduct(p interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) CreateProduct(ctx, p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateProduct", reflect.TypeOf((*MockStorage)(nil).CreateProduct), ctx, p)
+}
+
+// CreateProfile mocks base method.
+func (m *MockStorage) CreateProfile(ctx context.Context, p types.Profile) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateProfile", ctx, p)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateProfile indicates an expected call of CreateProfile.
+func (mr *MockStorageMockRecorder) CreateProfile(ctx, p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateProfile", reflect.TypeOf((*MockStorage)(nil).CreateProfile), ctx, p)
+}
+
+// CreatePromotion mocks base method.
+func (m *MockStorage) CreatePromotion(ctx context.Context, p types.Promotion) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePromotion", ctx, p)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePromotion indicates an expected call of CreatePromotion.
+func (mr *MockStorageMockRecorder) CreatePromotion(ctx, p interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateProduct", reflect.TypeOf((*MockStorage)(nil).CreateProduct), p)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePromotion", reflect.TypeOf((*MockStorage)(nil).CreatePromotion), ctx, p)
+}
+
+// CreateReview mocks base method.
+func (m *MockStorage) CreateReview(ctx context.Context, r types.Review) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateReview", ctx, r)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateReview indicates an expected call of CreateReview.
+func (mr *MockStorageMockRecorder) CreateReview(ctx, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateReview", reflect.TypeOf((*MockStorage)(nil).CreateReview), ctx, r)
+}
+
+// CreateShippingMethod mocks base method.
+func (m_2 *MockStorage) CreateShippingMethod(ctx context.Context, m types.ShippingMethod) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "CreateShippingMethod", ctx, m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateShippingMethod indicates an expected call of CreateShippingMethod.
+func (mr *MockStorageMockRecorder) CreateShippingMethod(ctx, m interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateShippingMethod", reflect.TypeOf((*MockStorage)(nil).CreateShippingMethod), ctx, m)
+}
+
+// CreateShippingZone mocks base method.
+func (m *MockStorage) CreateShippingZone(ctx context.Context, z types.ShippingZone) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateShippingZone", ctx, z)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateShippingZone indicates an expected call of CreateShippingZone.
+func (mr *MockStorageMockRecorder) CreateShippingZone(ctx, z interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateShippingZone", reflect.TypeOf((*MockStorage)(nil).CreateShippingZone), ctx, z)
+}
+
+// DeleteAddress mocks base method.
+func (m *MockStorage) DeleteAddress(ctx context.Context, userID, addressID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAddress", ctx, userID, addressID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAddress indicates an expected call of DeleteAddress.
+func (mr *MockStorageMockRecorder) DeleteAddress(ctx, userID, addressID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAddress", reflect.TypeOf((*MockStorage)(nil).DeleteAddress), ctx, userID, addressID)
+}
+
+// DeleteCart mocks base method.
+func (m *MockStorage) DeleteCart(ctx context.Context, cartID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCart", ctx, cartID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteCart indicates an expected call of DeleteCart.
+func (mr *MockStorageMockRecorder) DeleteCart(ctx, cartID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCart", reflect.TypeOf((*MockStorage)(nil).DeleteCart), ctx, cartID)
+}
+
+// DeletePromotion mocks base method.
+func (m *MockStorage) DeletePromotion(ctx context.Context, promotionID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePromotion", ctx, promotionID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeletePromotion indicates an expected call of DeletePromotion.
+func (mr *MockStorageMockRecorder) DeletePromotion(ctx, promotionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePromotion", reflect.TypeOf((*MockStorage)(nil).DeletePromotion), ctx, promotionID)
+}
+
+// DeleteShippingMethod mocks base method.
+func (m *MockStorage) DeleteShippingMethod(ctx context.Context, methodID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteShippingMethod", ctx, methodID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteShippingMethod indicates an expected call of DeleteShippingMethod.
+func (mr *MockStorageMockRecorder) DeleteShippingMethod(ctx, methodID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteShippingMethod", reflect.TypeOf((*MockStorage)(nil).DeleteShippingMethod), ctx, methodID)
+}
+
+// DeleteShippingZone mocks base method.
+func (m *MockStorage) DeleteShippingZone(ctx context.Context, zoneID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteShippingZone", ctx, zoneID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteShippingZone indicates an expected call of DeleteShippingZone.
+func (mr *MockStorageMockRecorder) DeleteShippingZone(ctx, zoneID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteShippingZone", reflect.TypeOf((*MockStorage)(nil).DeleteShippingZone), ctx, zoneID)
+}
+
+// DeleteUserData mocks base method.
+func (m *MockStorage) DeleteUserData(ctx context.Context, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserData", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserData indicates an expected call of DeleteUserData.
+func (mr *MockStorageMockRecorder) DeleteUserData(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserData", reflect.TypeOf((*MockStorage)(nil).DeleteUserData), ctx, userID)
+}
+
+// GetAddress mocks base method.
+func (m *MockStorage) GetAddress(ctx context.Context, userID, addressID string) (types.Address, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAddress", ctx, userID, addressID)
+	ret0, _ := ret[0].(types.Address)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAddress indicates an expected call of GetAddress.
+func (mr *MockStorageMockRecorder) GetAddress(ctx, userID, addressID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAddress", reflect.TypeOf((*MockStorage)(nil).GetAddress), ctx, userID, addressID)
 }
 
 // GetCart mocks base method.
-func (m *MockStorage) GetCart(userID string) (types.Cart, error) {
+func (m *MockStorage) GetCart(ctx context.Context, userID string) (types.Cart, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetCart", userID)
+	ret := m.ctrl.Call(m, "GetCart", ctx, userID)
 	ret0, _ := ret[0].(types.Cart)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetCart indicates an expected call of GetCart.
-func (mr *MockStorageMockRecorder) GetCart(userID interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) GetCart(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCart", reflect.TypeOf((*MockStorage)(nil).GetCart), ctx, userID)
+}
+
+// GetCategoryById mocks base method.
+func (m *MockStorage) GetCategoryById(ctx context.Context, categoryID string) (types.Category, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCategoryById", ctx, categoryID)
+	ret0, _ := ret[0].(types.Category)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCategoryById indicates an expected call of GetCategoryById.
+func (mr *MockStorageMockRecorder) GetCategoryById(ctx, categoryID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCart", reflect.TypeOf((*MockStorage)(nil).GetCart), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCategoryById", reflect.TypeOf((*MockStorage)(nil).GetCategoryById), ctx, categoryID)
 }
 
 // GetProductById mocks base method.
-func (m *MockStorage) GetProductById(productID string) (types.Product, error) {
+func (m *MockStorage) GetProductById(ctx context.Context, productID string) (types.Product, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetProductById", productID)
+	ret := m.ctrl.Call(m, "GetProductById", ctx, productID)
 	ret0, _ := ret[0].(types.Product)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetProductById indicates an expected call of GetProductById.
-func (mr *MockStorageMockRecorder) GetProductById(productID interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) GetProductById(ctx, productID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProductById", reflect.TypeOf((*MockStorage)(nil).GetProductById), ctx, productID)
+}
+
+// GetProfile mocks base method.
+func (m *MockStorage) GetProfile(ctx context.Context, userID string) (types.Profile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProfile", ctx, userID)
+	ret0, _ := ret[0].(types.Profile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProfile indicates an expected call of GetProfile.
+func (mr *MockStorageMockRecorder) GetProfile(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfile", reflect.TypeOf((*MockStorage)(nil).GetProfile), ctx, userID)
+}
+
+// GetPromotionByCode mocks base method.
+func (m *MockStorage) GetPromotionByCode(ctx context.Context, code string) (types.Promotion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPromotionByCode", ctx, code)
+	ret0, _ := ret[0].(types.Promotion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPromotionByCode indicates an expected call of GetPromotionByCode.
+func (mr *MockStorageMockRecorder) GetPromotionByCode(ctx, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPromotionByCode", reflect.TypeOf((*MockStorage)(nil).GetPromotionByCode), ctx, code)
+}
+
+// GetPromotionById mocks base method.
+func (m *MockStorage) GetPromotionById(ctx context.Context, promotionID string) (types.Promotion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPromotionById", ctx, promotionID)
+	ret0, _ := ret[0].(types.Promotion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPromotionById indicates an expected call of GetPromotionById.
+func (mr *MockStorageMockRecorder) GetPromotionById(ctx, promotionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPromotionById", reflect.TypeOf((*MockStorage)(nil).GetPromotionById), ctx, promotionID)
+}
+
+// MergeCarts mocks base method.
+func (m *MockStorage) MergeCarts(ctx context.Context, guestCartID, userID string) (types.Cart, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MergeCarts", ctx, guestCartID, userID)
+	ret0, _ := ret[0].(types.Cart)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MergeCarts indicates an expected call of MergeCarts.
+func (mr *MockStorageMockRecorder) MergeCarts(ctx, guestCartID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MergeCarts", reflect.TypeOf((*MockStorage)(nil).MergeCarts), ctx, guestCartID, userID)
+}
+
+// ModerateReview mocks base method.
+func (m *MockStorage) ModerateReview(ctx context.Context, productID, reviewID, status string) (types.Review, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ModerateReview", ctx, productID, reviewID, status)
+	ret0, _ := ret[0].(types.Review)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ModerateReview indicates an expected call of ModerateReview.
+func (mr *MockStorageMockRecorder) ModerateReview(ctx, productID, reviewID, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ModerateReview", reflect.TypeOf((*MockStorage)(nil).ModerateReview), ctx, productID, reviewID, status)
+}
+
+// PendingReviews mocks base method.
+func (m *MockStorage) PendingReviews(ctx context.Context) ([]types.Review, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PendingReviews", ctx)
+	ret0, _ := ret[0].([]types.Review)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PendingReviews indicates an expected call of PendingReviews.
+func (mr *MockStorageMockRecorder) PendingReviews(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PendingReviews", reflect.TypeOf((*MockStorage)(nil).PendingReviews), ctx)
+}
+
+// PriceHistory mocks base method.
+func (m *MockStorage) PriceHistory(ctx context.Context, productID string) ([]types.PriceHistoryEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PriceHistory", ctx, productID)
+	ret0, _ := ret[0].([]types.PriceHistoryEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PriceHistory indicates an expected call of PriceHistory.
+func (mr *MockStorageMockRecorder) PriceHistory(ctx, productID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProductById", reflect.TypeOf((*MockStorage)(nil).GetProductById), productID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PriceHistory", reflect.TypeOf((*MockStorage)(nil).PriceHistory), ctx, productID)
 }
 
 // Products mocks base method.
-func (m *MockStorage) Products() ([]types.Product, error) {
+func (m *MockStorage) Products(ctx context.Context) ([]types.Product, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Products")
+	ret := m.ctrl.Call(m, "Products", ctx)
 	ret0, _ := ret[0].([]types.Product)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Products indicates an expected call of Products.
-func (mr *MockStorageMockRecorder) Products() *gomock.Call {
+func (mr *MockStorageMockRecorder) Products(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Products", reflect.TypeOf((*MockStorage)(nil).Products), ctx)
+}
+
+// Promotions mocks base method.
+func (m *MockStorage) Promotions(ctx context.Context) ([]types.Promotion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Promotions", ctx)
+	ret0, _ := ret[0].([]types.Promotion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Promotions indicates an expected call of Promotions.
+func (mr *MockStorageMockRecorder) Promotions(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Promotions", reflect.TypeOf((*MockStorage)(nil).Promotions), ctx)
+}
+
+// RecordPurchase mocks base method.
+func (m *MockStorage) RecordPurchase(ctx context.Context, orderID string, productIDs []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordPurchase", ctx, orderID, productIDs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordPurchase indicates an expected call of RecordPurchase.
+func (mr *MockStorageMockRecorder) RecordPurchase(ctx, orderID, productIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordPurchase", reflect.TypeOf((*MockStorage)(nil).RecordPurchase), ctx, orderID, productIDs)
+}
+
+// ReleaseExpiredCarts mocks base method.
+func (m *MockStorage) ReleaseExpiredCarts(ctx context.Context, cartIDPrefix string, updatedBefore time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseExpiredCarts", ctx, cartIDPrefix, updatedBefore)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReleaseExpiredCarts indicates an expected call of ReleaseExpiredCarts.
+func (mr *MockStorageMockRecorder) ReleaseExpiredCarts(ctx, cartIDPrefix, updatedBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseExpiredCarts", reflect.TypeOf((*MockStorage)(nil).ReleaseExpiredCarts), ctx, cartIDPrefix, updatedBefore)
+}
+
+// RemoveCartItem mocks base method.
+func (m *MockStorage) RemoveCartItem(ctx context.Context, cartID, productID string) (types.Cart, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveCartItem", ctx, cartID, productID)
+	ret0, _ := ret[0].(types.Cart)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveCartItem indicates an expected call of RemoveCartItem.
+func (mr *MockStorageMockRecorder) RemoveCartItem(ctx, cartID, productID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveCartItem", reflect.TypeOf((*MockStorage)(nil).RemoveCartItem), ctx, cartID, productID)
+}
+
+// RemoveCoupon mocks base method.
+func (m *MockStorage) RemoveCoupon(ctx context.Context, cartID, userID string, p types.Promotion) (types.Cart, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveCoupon", ctx, cartID, userID, p)
+	ret0, _ := ret[0].(types.Cart)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveCoupon indicates an expected call of RemoveCoupon.
+func (mr *MockStorageMockRecorder) RemoveCoupon(ctx, cartID, userID, p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveCoupon", reflect.TypeOf((*MockStorage)(nil).RemoveCoupon), ctx, cartID, userID, p)
+}
+
+// RemoveFromWishlist mocks base method.
+func (m *MockStorage) RemoveFromWishlist(ctx context.Context, userID, productID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveFromWishlist", ctx, userID, productID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveFromWishlist indicates an expected call of RemoveFromWishlist.
+func (mr *MockStorageMockRecorder) RemoveFromWishlist(ctx, userID, productID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveFromWishlist", reflect.TypeOf((*MockStorage)(nil).RemoveFromWishlist), ctx, userID, productID)
+}
+
+// Reviews mocks base method.
+func (m *MockStorage) Reviews(ctx context.Context, productID, status string) ([]types.Review, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reviews", ctx, productID, status)
+	ret0, _ := ret[0].([]types.Review)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reviews indicates an expected call of Reviews.
+func (mr *MockStorageMockRecorder) Reviews(ctx, productID, status interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Products", reflect.TypeOf((*MockStorage)(nil).Products))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reviews", reflect.TypeOf((*MockStorage)(nil).Reviews), ctx, productID, status)
+}
+
+// SaveForLater mocks base method.
+func (m *MockStorage) SaveForLater(ctx context.Context, cartID, userID, productID string) (types.Cart, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveForLater", ctx, cartID, userID, productID)
+	ret0, _ := ret[0].(types.Cart)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveForLater indicates an expected call of SaveForLater.
+func (mr *MockStorageMockRecorder) SaveForLater(ctx, cartID, userID, productID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveForLater", reflect.TypeOf((*MockStorage)(nil).SaveForLater), ctx, cartID, userID, productID)
+}
+
+// SelectShipping mocks base method.
+func (m *MockStorage) SelectShipping(ctx context.Context, cartID, country, methodID string) (types.Cart, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectShipping", ctx, cartID, country, methodID)
+	ret0, _ := ret[0].(types.Cart)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectShipping indicates an expected call of SelectShipping.
+func (mr *MockStorageMockRecorder) SelectShipping(ctx, cartID, country, methodID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectShipping", reflect.TypeOf((*MockStorage)(nil).SelectShipping), ctx, cartID, country, methodID)
+}
+
+// SetCartItemQuantity mocks base method.
+func (m *MockStorage) SetCartItemQuantity(ctx context.Context, cartID, productID string, quantity int) (types.Cart, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetCartItemQuantity", ctx, cartID, productID, quantity)
+	ret0, _ := ret[0].(types.Cart)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetCartItemQuantity indicates an expected call of SetCartItemQuantity.
+func (mr *MockStorageMockRecorder) SetCartItemQuantity(ctx, cartID, productID, quantity interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCartItemQuantity", reflect.TypeOf((*MockStorage)(nil).SetCartItemQuantity), ctx, cartID, productID, quantity)
+}
+
+// ShippingMethods mocks base method.
+func (m *MockStorage) ShippingMethods(ctx context.Context) ([]types.ShippingMethod, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ShippingMethods", ctx)
+	ret0, _ := ret[0].([]types.ShippingMethod)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ShippingMethods indicates an expected call of ShippingMethods.
+func (mr *MockStorageMockRecorder) ShippingMethods(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShippingMethods", reflect.TypeOf((*MockStorage)(nil).ShippingMethods), ctx)
+}
+
+// ShippingZones mocks base method.
+func (m *MockStorage) ShippingZones(ctx context.Context) ([]types.ShippingZone, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ShippingZones", ctx)
+	ret0, _ := ret[0].([]types.ShippingZone)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ShippingZones indicates an expected call of ShippingZones.
+func (mr *MockStorageMockRecorder) ShippingZones(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShippingZones", reflect.TypeOf((*MockStorage)(nil).ShippingZones), ctx)
+}
+
+// UpdateAddress mocks base method.
+func (m *MockStorage) UpdateAddress(ctx context.Context, userID string, a types.Address) (types.Address, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAddress", ctx, userID, a)
+	ret0, _ := ret[0].(types.Address)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAddress indicates an expected call of UpdateAddress.
+func (mr *MockStorageMockRecorder) UpdateAddress(ctx, userID, a interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAddress", reflect.TypeOf((*MockStorage)(nil).UpdateAddress), ctx, userID, a)
+}
+
+// UpdateCategoryTranslations mocks base method.
+func (m *MockStorage) UpdateCategoryTranslations(ctx context.Context, categoryID string, translations map[string]types.CategoryTranslation) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCategoryTranslations", ctx, categoryID, translations)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateCategoryTranslations indicates an expected call of UpdateCategoryTranslations.
+func (mr *MockStorageMockRecorder) UpdateCategoryTranslations(ctx, categoryID, translations interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCategoryTranslations", reflect.TypeOf((*MockStorage)(nil).UpdateCategoryTranslations), ctx, categoryID, translations)
 }
 
 // UpdateInventory mocks base method.
-func (m *MockStorage) UpdateInventory(productId string, delta int) error {
+func (m *MockStorage) UpdateInventory(ctx context.Context, productId string, delta int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateInventory", productId, delta)
+	ret := m.ctrl.Call(m, "UpdateInventory", ctx, productId, delta)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateInventory indicates an expected call of UpdateInventory.
-func (mr *MockStorageMockRecorder) UpdateInventory(productId, delta interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) UpdateInventory(ctx, productId, delta interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateInventory", reflect.TypeOf((*MockStorage)(nil).UpdateInventory), productId, delta)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateInventory", reflect.TypeOf((*MockStorage)(nil).UpdateInventory), ctx, productId, delta)
+}
+
+// UpdatePriceSchedule mocks base method.
+func (m *MockStorage) UpdatePriceSchedule(ctx context.Context, p types.Product, entry types.PriceHistoryEntry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePriceSchedule", ctx, p, entry)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePriceSchedule indicates an expected call of UpdatePriceSchedule.
+func (mr *MockStorageMockRecorder) UpdatePriceSchedule(ctx, p, entry interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePriceSchedule", reflect.TypeOf((*MockStorage)(nil).UpdatePriceSchedule), ctx, p, entry)
 }
 
 // UpdateProduct mocks base method.
-func (m *MockStorage) UpdateProduct(input UpdateProductInput) error {
+func (m *MockStorage) UpdateProduct(ctx context.Context, input UpdateProductInput) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateProduct", input)
+	ret := m.ctrl.Call(m, "UpdateProduct", ctx, input)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateProduct indicates an expected call of UpdateProduct.
-func (mr *MockStorageMockRecorder) UpdateProduct(input interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) UpdateProduct(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProduct", reflect.TypeOf((*MockStorage)(nil).UpdateProduct), ctx, input)
+}
+
+// UpdateProductImages mocks base method.
+func (m *MockStorage) UpdateProductImages(ctx context.Context, productID string, version uint, images []types.ProductImage) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateProductImages", ctx, productID, version, images)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateProductImages indicates an expected call of UpdateProductImages.
+func (mr *MockStorageMockRecorder) UpdateProductImages(ctx, productID, version, images interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProductImages", reflect.TypeOf((*MockStorage)(nil).UpdateProductImages), ctx, productID, version, images)
+}
+
+// UpdateProductRelations mocks base method.
+func (m *MockStorage) UpdateProductRelations(ctx context.Context, productID string, version uint, relations []types.ProductRelation) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateProductRelations", ctx, productID, version, relations)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateProductRelations indicates an expected call of UpdateProductRelations.
+func (mr *MockStorageMockRecorder) UpdateProductRelations(ctx, productID, version, relations interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProductRelations", reflect.TypeOf((*MockStorage)(nil).UpdateProductRelations), ctx, productID, version, relations)
+}
+
+// UpdateProductTranslations mocks base method.
+func (m *MockStorage) UpdateProductTranslations(ctx context.Context, productID string, version uint, translations map[string]types.ProductTranslation) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateProductTranslations", ctx, productID, version, translations)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateProductTranslations indicates an expected call of UpdateProductTranslations.
+func (mr *MockStorageMockRecorder) UpdateProductTranslations(ctx, productID, version, translations interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProductTranslations", reflect.TypeOf((*MockStorage)(nil).UpdateProductTranslations), ctx, productID, version, translations)
+}
+
+// UpdateProfile mocks base method.
+func (m *MockStorage) UpdateProfile(ctx context.Context, p types.Profile) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateProfile", ctx, p)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateProfile indicates an expected call of UpdateProfile.
+func (mr *MockStorageMockRecorder) UpdateProfile(ctx, p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProfile", reflect.TypeOf((*MockStorage)(nil).UpdateProfile), ctx, p)
+}
+
+// UpdatePromotion mocks base method.
+func (m *MockStorage) UpdatePromotion(ctx context.Context, p types.Promotion) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePromotion", ctx, p)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePromotion indicates an expected call of UpdatePromotion.
+func (mr *MockStorageMockRecorder) UpdatePromotion(ctx, p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePromotion", reflect.TypeOf((*MockStorage)(nil).UpdatePromotion), ctx, p)
+}
+
+// UpdateShippingMethod mocks base method.
+func (m_2 *MockStorage) UpdateShippingMethod(ctx context.Context, m types.ShippingMethod) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "UpdateShippingMethod", ctx, m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateShippingMethod indicates an expected call of UpdateShippingMethod.
+func (mr *MockStorageMockRecorder) UpdateShippingMethod(ctx, m interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateShippingMethod", reflect.TypeOf((*MockStorage)(nil).UpdateShippingMethod), ctx, m)
+}
+
+// UpdateShippingZone mocks base method.
+func (m *MockStorage) UpdateShippingZone(ctx context.Context, z types.ShippingZone) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateShippingZone", ctx, z)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateShippingZone indicates an expected call of UpdateShippingZone.
+func (mr *MockStorageMockRecorder) UpdateShippingZone(ctx, z interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateShippingZone", reflect.TypeOf((*MockStorage)(nil).UpdateShippingZone), ctx, z)
+}
+
+// UserReviews mocks base method.
+func (m *MockStorage) UserReviews(ctx context.Context, userID string) ([]types.Review, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserReviews", ctx, userID)
+	ret0, _ := ret[0].([]types.Review)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserReviews indicates an expected call of UserReviews.
+func (mr *MockStorageMockRecorder) UserReviews(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserReviews", reflect.TypeOf((*MockStorage)(nil).UserReviews), ctx, userID)
+}
+
+// Wishlist mocks base method.
+func (m *MockStorage) Wishlist(ctx context.Context, userID string) ([]types.WishlistItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Wishlist", ctx, userID)
+	ret0, _ := ret[0].([]types.WishlistItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Wishlist indicates an expected call of Wishlist.
+func (mr *MockStorageMockRecorder) Wishlist(ctx, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProduct", reflect.TypeOf((*MockStorage)(nil).UpdateProduct), input)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Wishlist", reflect.TypeOf((*MockStorage)(nil).Wishlist), ctx, userID)
 }
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pratbacknd/internal/types"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	uuid "github.com/satori/go.uuid"
+)
+
+// the price history of a product is keyed by price id
+const pkPriceHistoryPrefix = "priceHistory#"
+
+// UpdatePriceSchedule stores the prices and the schedule of a product read
+// at its version, and records the entry in the price history. It fails with
+// ErrorProductChanged if the product was updated in the meantime.
+func (d *Dynamo) UpdatePriceSchedule(ctx context.Context, p types.Product, entry types.PriceHistoryEntry) error {
+	condition := expression.Name("version").Equal(expression.Value(p.Version))
+	update := expression.Set(
+		expression.Name("priceVatExcluded"), expression.Value(p.PriceVATExcluded),
+	).Set(
+		expression.Name("vat"), expression.Value(p.VAT),
+	).Set(
+		expression.Name("totalPrice"), expression.Value(p.TotalPrice),
+	).Set(
+		expression.Name("priceSchedule"), expression.Value(p.PriceSchedule),
+	).Set(
+		expression.Name("version"), expression.Value(p.Version+1),
+	)
+	expr, err := expression.NewBuilder().WithCondition(condition).WithUpdate(update).Build()
+	if err != nil {
+		return fmt.Errorf("error - building the expression: %w", err)
+	}
+
+	history, err := d.priceHistoryItem(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Update: &dynamodb.Update{
+					TableName: &d.tableName,
+					Key: map[string]*dynamodb.AttributeValue{
+						PartitionKeyAttributeName: {S: aws.String(pkProduct)},
+						SortkeyAttributeName:      {S: aws.String(p.ID)},
+					},
+					ConditionExpression:       expr.Condition(),
+					ExpressionAttributeNames:  expr.Names(),
+					ExpressionAttributeValues: expr.Values(),
+					UpdateExpression:          expr.Update(),
+				},
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName: &d.tableName,
+					Item:      history,
+				},
+			},
+		},
+		ClientRequestToken: aws.String(uuid.NewV4().String()),
+	})
+	if err != nil {
+		var canceled *dynamodb.TransactionCanceledException
+		if errors.As(err, &canceled) && len(canceled.CancellationReasons) > 0 &&
+			aws.StringValue(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			return ErrorProductChanged
+		}
+		return fmt.Errorf("error - run the transaction: %w", err)
+	}
+	return nil
+}
+
+// PriceHistory returns the prices of a product in the order they started.
+func (d *Dynamo) PriceHistory(ctx context.Context, productID string) ([]types.PriceHistoryEntry, error) {
+	out, err := d.getElementByPkAndSk(ctx, pkPriceHistoryPrefix+productID, "")
+	if err != nil {
+		return nil, fmt.Errorf("error - retreiving price history in db: %w", err)
+	}
+
+	entries := make([]types.PriceHistoryEntry, 0, len(out.Items))
+	err = dynamodbattribute.UnmarshalListOfMaps(out.Items, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("error - Unmarshalling price history: %w", err)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].StartsAt.Equal(entries[j].StartsAt) {
+			return entries[i].StartsAt.Before(entries[j].StartsAt)
+		}
+		return entries[i].RecordedAt.Before(entries[j].RecordedAt)
+	})
+	return entries, nil
+}
+
+func (d *Dynamo) priceHistoryItem(entry types.PriceHistoryEntry) (map[string]*dynamodb.AttributeValue, error) {
+	item, err := dynamodbattribute.MarshalMap(entry)
+	if err != nil {
+		return nil, fmt.Errorf("error - marshal price history: %w", err)
+	}
+	item[PartitionKeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(pkPriceHistoryPrefix + entry.ProductID)}
+	item[SortkeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(entry.ID)}
+	return item, nil
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pratbacknd/internal/types"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// the profile is stored in the partition of its user
+const skProfile = "profile"
+
+var ErrorProfileExists = errors.New("profile already exists")
+
+func (d *Dynamo) GetProfile(ctx context.Context, userID string) (types.Profile, error) {
+	out, err := d.getElementByPkAndSk(ctx, pkUserPrefix+userID, skProfile)
+	if err != nil {
+		return types.Profile{}, fmt.Errorf("error - retreiving profile in db: %w", err)
+	}
+
+	if len(out.Items) == 0 {
+		return types.Profile{}, fmt.Errorf("error - no profile for user %s: %w", userID, ErrorNotFound)
+	}
+
+	var p types.Profile
+	err = dynamodbattribute.UnmarshalMap(out.Items[0], &p)
+	if err != nil {
+		return types.Profile{}, fmt.Errorf("error - Unmarshalling profile: %w", err)
+	}
+	return p, nil
+}
+
+// CreateProfile stores the profile of a user, it fails with
+// ErrorProfileExists if the user already has one.
+func (d *Dynamo) CreateProfile(ctx context.Context, p types.Profile) error {
+	item, err := dynamodbattribute.MarshalMap(p)
+	if err != nil {
+		return fmt.Errorf("error - marshal profile: %w", err)
+	}
+
+	item[PartitionKeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(pkUserPrefix + p.UserID)}
+	item[SortkeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(skProfile)}
+
+	_, err = d.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:           &d.tableName,
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(" + SortkeyAttributeName + ")"),
+	})
+	if err != nil {
+		var conditionFailed *dynamodb.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return ErrorProfileExists
+		}
+		return fmt.Errorf("error - Put item in db: %w", err)
+	}
+	return nil
+}
+
+func (d *Dynamo) UpdateProfile(ctx context.Context, p types.Profile) error {
+	return d.putElement(ctx, pkUserPrefix+p.UserID, skProfile, p, true)
+}
@@ -0,0 +1,427 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pratbacknd/internal/types"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	pkPromotion        = "promotion"
+	pkPromotionCode    = "promotionCode"
+	pkRedemptionPrefix = "redemption#"
+	// the promotions redeemed by a user are listed in the user partition, to
+	// erase the redemptions with the user data
+	skRedemptionPrefix = "redemption#"
+)
+
+// userRedemption is the element of the user partition pointing to a
+// redemption of the user
+type userRedemption struct {
+	PromotionID string `dynamodbav:"promotionId"`
+}
+
+// promotionCode is the element, keyed by the coupon code, that points to
+// the promotion of the code
+type promotionCode struct {
+	PromotionID string `dynamodbav:"promotionId"`
+}
+
+var ErrorCouponUnavailable = errors.New("coupon usage limit reached")
+
+// CreatePromotion stores the promotion and the element of its code, the
+// transaction fails if the code is taken.
+func (d *Dynamo) CreatePromotion(ctx context.Context, p types.Promotion) error {
+	item, err := dynamodbattribute.MarshalMap(p)
+	if err != nil {
+		return fmt.Errorf("error - marshal promotion: %w", err)
+	}
+
+	item[PartitionKeyAttributeName] = &dynamodb.AttributeValue{
+		S: aws.String(pkPromotion),
+	}
+	item[SortkeyAttributeName] = &dynamodb.AttributeValue{
+		S: aws.String(p.ID),
+	}
+
+	codeRequest, err := d.buildPutPromotionCodeRequest(p)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Put: &dynamodb.Put{TableName: &d.tableName, Item: item}},
+			codeRequest,
+		},
+		ClientRequestToken: aws.String(uuid.NewV4().String()),
+	})
+	if err != nil {
+		return fmt.Errorf("error - run the transaction: %w", err)
+	}
+	return nil
+}
+
+func (d *Dynamo) promotionCodeKey(code string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		PartitionKeyAttributeName: {S: aws.String(pkPromotionCode)},
+		SortkeyAttributeName:      {S: aws.String(code)},
+	}
+}
+
+// buildPutPromotionCodeRequest creates the element of the code of the
+// promotion, unless another promotion has it
+func (d *Dynamo) buildPutPromotionCodeRequest(p types.Promotion) (*dynamodb.TransactWriteItem, error) {
+	item, err := dynamodbattribute.MarshalMap(promotionCode{PromotionID: p.ID})
+	if err != nil {
+		return nil, fmt.Errorf("error - marshal promotion code: %w", err)
+	}
+	for k, v := range d.promotionCodeKey(p.Code) {
+		item[k] = v
+	}
+
+	return &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			TableName:           &d.tableName,
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(" + SortkeyAttributeName + ")"),
+		},
+	}, nil
+}
+
+func (d *Dynamo) Promotions(ctx context.Context) ([]types.Promotion, error) {
+	out, err := d.getElementByPkAndSk(ctx, pkPromotion, "")
+	if err != nil {
+		return nil, err
+	}
+
+	promotions := make([]types.Promotion, 0)
+	err = dynamodbattribute.UnmarshalListOfMaps(out.Items, &promotions)
+	if err != nil {
+		return nil, fmt.Errorf("error - Unmarshalling results: %w", err)
+	}
+	return promotions, nil
+}
+
+func (d *Dynamo) GetPromotionById(ctx context.Context, promotionID string) (types.Promotion, error) {
+	out, err := d.getElementByPkAndSk(ctx, pkPromotion, promotionID)
+	if err != nil {
+		return types.Promotion{}, fmt.Errorf("error - retreiving promotion in db: %w", err)
+	}
+
+	if len(out.Items) == 0 {
+		return types.Promotion{}, ErrorNotFound
+	}
+
+	var p types.Promotion
+	err = dynamodbattribute.UnmarshalMap(out.Items[0], &p)
+	if err != nil {
+		return types.Promotion{}, fmt.Errorf("error - Unmarshalling promotion: %w", err)
+	}
+	return p, nil
+}
+
+// GetPromotionByCode returns the promotion of a coupon code.
+func (d *Dynamo) GetPromotionByCode(ctx context.Context, code string) (types.Promotion, error) {
+	code = types.NormalizeCode(code)
+	out, err := d.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: &d.tableName,
+		Key:       d.promotionCodeKey(code),
+	})
+	if err != nil {
+		return types.Promotion{}, fmt.Errorf("error - retreiving promotion code in db: %w", err)
+	}
+	if out.Item == nil {
+		return types.Promotion{}, fmt.Errorf("error - no promotion with code %s: %w", code, ErrorNotFound)
+	}
+
+	var pc promotionCode
+	err = dynamodbattribute.UnmarshalMap(out.Item, &pc)
+	if err != nil {
+		return types.Promotion{}, fmt.Errorf("error - Unmarshalling promotion code: %w", err)
+	}
+	return d.GetPromotionById(ctx, pc.PromotionID)
+}
+
+// UpdatePromotion replaces the rules of a promotion, its usage counter is
+// left untouched.
+func (d *Dynamo) UpdatePromotion(ctx context.Context, p types.Promotion) error {
+	current, err := d.GetPromotionById(ctx, p.ID)
+	if err != nil {
+		return fmt.Errorf("error - to retrieve promotion: %w", err)
+	}
+
+	keyCondition := map[string]*dynamodb.AttributeValue{
+		PartitionKeyAttributeName: {S: aws.String(pkPromotion)},
+		SortkeyAttributeName:      {S: aws.String(p.ID)},
+	}
+
+	// condition expression
+	condition := expression.Name("version").Equal(expression.Value(current.Version))
+
+	update := expression.Set(
+		expression.Name("code"), expression.Value(p.Code),
+	).Set(
+		expression.Name("description"), expression.Value(p.Description),
+	).Set(
+		expression.Name("type"), expression.Value(p.Type),
+	).Set(
+		expression.Name("percentage"), expression.Value(p.Percentage),
+	).Set(
+		expression.Name("amount"), expression.Value(p.Amount),
+	).Set(
+		expression.Name("buyQuantity"), expression.Value(p.BuyQuantity),
+	).Set(
+		expression.Name("getQuantity"), expression.Value(p.GetQuantity),
+	).Set(
+		expression.Name("productIds"), expression.Value(p.ProductIDs),
+	).Set(
+		expression.Name("categoryIds"), expression.Value(p.CategoryIDs),
+	).Set(
+		expression.Name("startsAt"), expression.Value(p.StartsAt),
+	).Set(
+		expression.Name("endsAt"), expression.Value(p.EndsAt),
+	).Set(
+		expression.Name("maxUses"), expression.Value(p.MaxUses),
+	).Set(
+		expression.Name("maxUsesPerUser"), expression.Value(p.MaxUsesPerUser),
+	).Set(
+		expression.Name("version"), expression.Value(current.Version+1),
+	)
+
+	expr, err := expression.NewBuilder().WithCondition(condition).WithUpdate(update).Build()
+	if err != nil {
+		return fmt.Errorf("error - building the expression: %w", err)
+	}
+
+	actions := []*dynamodb.TransactWriteItem{
+		{
+			Update: &dynamodb.Update{
+				TableName:                 &d.tableName,
+				Key:                       keyCondition,
+				ConditionExpression:       expr.Condition(),
+				ExpressionAttributeNames:  expr.Names(),
+				ExpressionAttributeValues: expr.Values(),
+				UpdateExpression:          expr.Update(),
+			},
+		},
+	}
+
+	// the element of the code moves with the code
+	if p.Code != current.Code {
+		codeRequest, err := d.buildPutPromotionCodeRequest(p)
+		if err != nil {
+			return err
+		}
+		actions = append(actions, codeRequest, &dynamodb.TransactWriteItem{
+			Delete: &dynamodb.Delete{
+				TableName: &d.tableName,
+				Key:       d.promotionCodeKey(current.Code),
+			},
+		})
+	}
+
+	_, err = d.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems:      actions,
+		ClientRequestToken: aws.String(uuid.NewV4().String()),
+	})
+	if err != nil {
+		return fmt.Errorf("error - run the transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePromotion deletes the promotion, the element of its code and its
+// redemptions. The redemptions go first so that a failed deletion is
+// retried with the promotion still there.
+func (d *Dynamo) DeletePromotion(ctx context.Context, promotionID string) error {
+	p, err := d.GetPromotionById(ctx, promotionID)
+	if errors.Is(err, ErrorNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error - to retrieve promotion: %w", err)
+	}
+
+	redemptions, err := d.partitionKeys(ctx, pkRedemptionPrefix+p.ID)
+	if err != nil {
+		return fmt.Errorf("error - retreiving the redemptions: %w", err)
+	}
+	err = d.deleteKeys(ctx, redemptions)
+	if err != nil {
+		return fmt.Errorf("error - deleting the redemptions: %w", err)
+	}
+
+	_, err = d.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Delete: &dynamodb.Delete{
+					TableName: &d.tableName,
+					Key: map[string]*dynamodb.AttributeValue{
+						PartitionKeyAttributeName: {S: aws.String(pkPromotion)},
+						SortkeyAttributeName:      {S: aws.String(p.ID)},
+					},
+				},
+			},
+			{
+				Delete: &dynamodb.Delete{
+					TableName: &d.tableName,
+					Key:       d.promotionCodeKey(p.Code),
+				},
+			},
+		},
+		ClientRequestToken: aws.String(uuid.NewV4().String()),
+	})
+	if err != nil {
+		return fmt.Errorf("error - run the transaction: %w", err)
+	}
+	return nil
+}
+
+// ApplyCoupon adds the code of the promotion to the cart and redeems it for
+// the user. The transaction fails if a usage limit is reached.
+func (d *Dynamo) ApplyCoupon(ctx context.Context, cartID string, userID string, p types.Promotion) (types.Cart, error) {
+	cart, err := d.GetCart(ctx, cartID)
+	if err != nil {
+		return types.Cart{}, fmt.Errorf("error - retreiving the cart: %w", err)
+	}
+
+	if cart.HasCoupon(p.Code) {
+		return cart, nil
+	}
+	cart.Coupons = append(cart.Coupons, p.Code)
+
+	actions, err := d.buildRedemptionRequests(p, userID)
+	if err != nil {
+		return types.Cart{}, err
+	}
+
+	cart, err = d.runCartTransaction(ctx, cart, cartID, actions)
+	if couponUnavailable(err) {
+		return types.Cart{}, fmt.Errorf("error - redeeming coupon %s: %w", p.Code, ErrorCouponUnavailable)
+	}
+	return cart, err
+}
+
+// RemoveCoupon removes the code of the promotion from the cart. The
+// redemption is not given back: a coupon counts as used once applied, or
+// applying and removing it again would never reach the limits.
+func (d *Dynamo) RemoveCoupon(ctx context.Context, cartID string, userID string, p types.Promotion) (types.Cart, error) {
+	cart, err := d.GetCart(ctx, cartID)
+	if err != nil {
+		return types.Cart{}, fmt.Errorf("error - retreiving the cart: %w", err)
+	}
+
+	if !cart.HasCoupon(p.Code) {
+		return types.Cart{}, fmt.Errorf("error - coupon %s is not applied to the cart: %w", p.Code, ErrorNotFound)
+	}
+
+	coupons := make([]string, 0, len(cart.Coupons))
+	for _, code := range cart.Coupons {
+		if code != p.Code {
+			coupons = append(coupons, code)
+		}
+	}
+	cart.Coupons = coupons
+
+	return d.runCartTransaction(ctx, cart, cartID, nil)
+}
+
+// buildRedemptionRequests counts a use of the promotion in the global and
+// the per user usage counters, within the limits of the promotion, and
+// lists the promotion in the user partition.
+func (d Dynamo) buildRedemptionRequests(p types.Promotion, userID string) ([]*dynamodb.TransactWriteItem, error) {
+	// global usage
+	update := expression.Add(expression.Name("uses"), expression.Value(1))
+	builder := expression.NewBuilder().WithUpdate(update)
+	if p.MaxUses > 0 {
+		builder = builder.WithCondition(expression.Name("uses").LessThan(expression.Value(p.MaxUses)))
+	}
+	expr, err := builder.Build()
+	if err != nil {
+		return nil, fmt.Errorf("error - building the expression %w", err)
+	}
+
+	usesRequest := &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			Key: map[string]*dynamodb.AttributeValue{
+				PartitionKeyAttributeName: {S: aws.String(pkPromotion)},
+				SortkeyAttributeName:      {S: aws.String(p.ID)},
+			},
+			TableName:        &d.tableName,
+			UpdateExpression: expr.Update(),
+		},
+	}
+
+	// per user usage
+	update = expression.Add(expression.Name("count"), expression.Value(1))
+	builder = expression.NewBuilder().WithUpdate(update)
+	if p.MaxUsesPerUser > 0 {
+		condition := expression.Name("count").AttributeNotExists().Or(
+			expression.Name("count").LessThan(expression.Value(p.MaxUsesPerUser)),
+		)
+		builder = builder.WithCondition(condition)
+	}
+	expr, err = builder.Build()
+	if err != nil {
+		return nil, fmt.Errorf("error - building the expression %w", err)
+	}
+
+	redemptionRequest := &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			Key: map[string]*dynamodb.AttributeValue{
+				PartitionKeyAttributeName: {S: aws.String(pkRedemptionPrefix + p.ID)},
+				SortkeyAttributeName:      {S: aws.String(userID)},
+			},
+			TableName:        &d.tableName,
+			UpdateExpression: expr.Update(),
+		},
+	}
+
+	pointer, err := dynamodbattribute.MarshalMap(userRedemption{PromotionID: p.ID})
+	if err != nil {
+		return nil, fmt.Errorf("error - marshal redemption: %w", err)
+	}
+	pointer[PartitionKeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(pkUserPrefix + userID)}
+	pointer[SortkeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(skRedemptionPrefix + p.ID)}
+	pointerRequest := &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			TableName: &d.tableName,
+			Item:      pointer,
+		},
+	}
+
+	return []*dynamodb.TransactWriteItem{usesRequest, redemptionRequest, pointerRequest}, nil
+}
+
+// couponUnavailable tells whether a redemption transaction was cancelled by
+// the usage limits of the promotion (the first two actions).
+func couponUnavailable(err error) bool {
+	var canceled *dynamodb.TransactionCanceledException
+	if !errors.As(err, &canceled) {
+		return false
+	}
+	for i, reason := range canceled.CancellationReasons {
+		if i > 1 {
+			break
+		}
+		if reason != nil && aws.StringValue(reason.Code) == "ConditionalCheckFailed" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,352 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pratbacknd/internal/types"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	uuid "github.com/satori/go.uuid"
+)
+
+// the co-purchase counters of a product are keyed by the other product
+const pkCoPurchasePrefix = "coPurchase#"
+
+// the orders already counted, one element per transaction of counters
+const pkPurchasePrefix = "purchase#"
+
+// the products most often bought with a product, keyed by the product, so
+// that reading them does not depend on the size of its counters partition
+const pkCoPurchaseTop = "coPurchaseTop"
+
+const (
+	// maxPurchaseProducts bounds the counters updated for one order, they
+	// grow with the square of the number of products
+	maxPurchaseProducts = 20
+	// maxCountersPerTransaction leaves room in a transaction for the
+	// element recording the order
+	maxCountersPerTransaction = 99
+	// maxCoPurchasesKept is the number of products kept in the top of a
+	// product, the largest limit CoPurchases serves
+	maxCoPurchasesKept = 20
+	// maxCoPurchaseTopAttempts bounds the updates of a top lost to a
+	// concurrent order
+	maxCoPurchaseTopAttempts = 3
+)
+
+// coPurchaseCounter is the element counting the orders of two products
+type coPurchaseCounter struct {
+	ProductID string `dynamodbav:"SK"`
+	Count     uint   `dynamodbav:"count"`
+}
+
+// coPurchaseTop is the element keeping the products most often bought with
+// a product, the most frequent first
+type coPurchaseTop struct {
+	Products []types.CoPurchase `dynamodbav:"products"`
+	Version  uint               `dynamodbav:"version"`
+}
+
+// coPurchasePair is a counter to increment, the products of the order are
+// counted in both directions
+type coPurchasePair struct {
+	productID string
+	other     string
+}
+
+// purchasedProducts returns the products of an order which are counted,
+// only the first products of large orders are.
+func purchasedProducts(productIDs []string) []string {
+	distinct := make([]string, 0, len(productIDs))
+	seen := make(map[string]bool, len(productIDs))
+	for _, id := range productIDs {
+		if !seen[id] && len(distinct) < maxPurchaseProducts {
+			seen[id] = true
+			distinct = append(distinct, id)
+		}
+	}
+	return distinct
+}
+
+// purchaseChunks returns the counters of an order split by transaction.
+func purchaseChunks(productIDs []string) [][]coPurchasePair {
+	distinct := purchasedProducts(productIDs)
+
+	var chunks [][]coPurchasePair
+	var chunk []coPurchasePair
+	for _, id := range distinct {
+		for _, other := range distinct {
+			if id == other {
+				continue
+			}
+			chunk = append(chunk, coPurchasePair{productID: id, other: other})
+			if len(chunk) == maxCountersPerTransaction {
+				chunks = append(chunks, chunk)
+				chunk = nil
+			}
+		}
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// mergeCoPurchases returns the top updated with the counters of an order.
+// Only the counters of the order changed and a counter never decreases, so
+// the products left out of the top stay below it.
+func mergeCoPurchases(top []types.CoPurchase, counted []types.CoPurchase) []types.CoPurchase {
+	counts := make(map[string]uint, len(top)+len(counted))
+	for _, c := range top {
+		counts[c.ProductID] = c.Count
+	}
+	for _, c := range counted {
+		counts[c.ProductID] = c.Count
+	}
+
+	merged := make([]types.CoPurchase, 0, len(counts))
+	for id, count := range counts {
+		merged = append(merged, types.CoPurchase{ProductID: id, Count: count})
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].Count != merged[j].Count {
+			return merged[i].Count > merged[j].Count
+		}
+		return merged[i].ProductID < merged[j].ProductID
+	})
+	if len(merged) > maxCoPurchasesKept {
+		merged = merged[:maxCoPurchasesKept]
+	}
+	return merged
+}
+
+// UpdateProductRelations replaces the curated relations of a product read
+// at the given version, it fails with ErrorProductChanged if the product
+// was updated in the meantime.
+func (d *Dynamo) UpdateProductRelations(ctx context.Context, productID string, version uint, relations []types.ProductRelation) error {
+	update := expression.Set(expression.Name("relations"), expression.Value(relations))
+	return d.updateProductVersion(ctx, productID, version, update)
+}
+
+// RecordPurchase counts the products of an order as bought together. Each
+// transaction of counters records the order under its index, an order
+// recorded again only runs the transactions that failed. The tops of the
+// products are then refreshed from the counters, also when the order was
+// already counted, for a previous call may have failed before.
+func (d *Dynamo) RecordPurchase(ctx context.Context, orderID string, productIDs []string) error {
+	update := expression.Add(expression.Name("count"), expression.Value(1))
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	if err != nil {
+		return fmt.Errorf("error - building the expression: %w", err)
+	}
+	condition, err := expression.NewBuilder().
+		WithCondition(expression.AttributeNotExists(expression.Name(PartitionKeyAttributeName))).
+		Build()
+	if err != nil {
+		return fmt.Errorf("error - building the expression: %w", err)
+	}
+
+	for i, chunk := range purchaseChunks(productIDs) {
+		actions := make([]*dynamodb.TransactWriteItem, 0, len(chunk)+1)
+		actions = append(actions, &dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{
+				TableName: &d.tableName,
+				Item: map[string]*dynamodb.AttributeValue{
+					PartitionKeyAttributeName: {S: aws.String(pkPurchasePrefix + orderID)},
+					SortkeyAttributeName:      {S: aws.String(strconv.Itoa(i))},
+				},
+				ConditionExpression:      condition.Condition(),
+				ExpressionAttributeNames: condition.Names(),
+			},
+		})
+		for _, pair := range chunk {
+			actions = append(actions, &dynamodb.TransactWriteItem{
+				Update: &dynamodb.Update{
+					TableName: &d.tableName,
+					Key: map[string]*dynamodb.AttributeValue{
+						PartitionKeyAttributeName: {S: aws.String(pkCoPurchasePrefix + pair.productID)},
+						SortkeyAttributeName:      {S: aws.String(pair.other)},
+					},
+					ExpressionAttributeNames:  expr.Names(),
+					ExpressionAttributeValues: expr.Values(),
+					UpdateExpression:          expr.Update(),
+				},
+			})
+		}
+
+		_, err = d.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems:      actions,
+			ClientRequestToken: aws.String(uuid.NewV4().String()),
+		})
+		if err != nil {
+			var canceled *dynamodb.TransactionCanceledException
+			if errors.As(err, &canceled) && len(canceled.CancellationReasons) > 0 &&
+				aws.StringValue(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+				// counted by a previous call
+				continue
+			}
+			return fmt.Errorf("error - run the transaction: %w", err)
+		}
+	}
+
+	products := purchasedProducts(productIDs)
+	for _, id := range products {
+		err = d.updateCoPurchaseTop(ctx, id, products)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateCoPurchaseTop merges the counters of the product with the other
+// products of an order into its top, the update is retried when another
+// order updated the top meanwhile.
+func (d *Dynamo) updateCoPurchaseTop(ctx context.Context, productID string, products []string) error {
+	for attempt := 0; attempt < maxCoPurchaseTopAttempts; attempt++ {
+		top, exists, err := d.coPurchaseTop(ctx, productID)
+		if err != nil {
+			return err
+		}
+		counted, err := d.coPurchaseCounters(ctx, productID, products)
+		if err != nil {
+			return err
+		}
+
+		item, err := dynamodbattribute.MarshalMap(coPurchaseTop{
+			Products: mergeCoPurchases(top.Products, counted),
+			Version:  top.Version + 1,
+		})
+		if err != nil {
+			return fmt.Errorf("error - marshal co-purchases: %w", err)
+		}
+		item[PartitionKeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(pkCoPurchaseTop)}
+		item[SortkeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(productID)}
+
+		condition := expression.Name(PartitionKeyAttributeName).AttributeNotExists()
+		if exists {
+			condition = expression.Name("version").Equal(expression.Value(top.Version))
+		}
+		expr, err := expression.NewBuilder().WithCondition(condition).Build()
+		if err != nil {
+			return fmt.Errorf("error - building the expression: %w", err)
+		}
+
+		_, err = d.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+			TableName:                 &d.tableName,
+			Item:                      item,
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		})
+		var conditionFailed *dynamodb.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error - Put item in db: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("error - the co-purchases of %s kept changing", productID)
+}
+
+// coPurchaseTop returns the top of the product and whether it exists.
+func (d *Dynamo) coPurchaseTop(ctx context.Context, productID string) (coPurchaseTop, bool, error) {
+	out, err := d.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]*dynamodb.AttributeValue{
+			PartitionKeyAttributeName: {S: aws.String(pkCoPurchaseTop)},
+			SortkeyAttributeName:      {S: aws.String(productID)},
+		},
+		TableName: &d.tableName,
+	})
+	if err != nil {
+		return coPurchaseTop{}, false, fmt.Errorf("error - retreiving co-purchases in db: %w", err)
+	}
+	if len(out.Item) == 0 {
+		return coPurchaseTop{}, false, nil
+	}
+
+	var top coPurchaseTop
+	err = dynamodbattribute.UnmarshalMap(out.Item, &top)
+	if err != nil {
+		return coPurchaseTop{}, false, fmt.Errorf("error - Unmarshalling co-purchases: %w", err)
+	}
+	return top, true, nil
+}
+
+// coPurchaseCounters reads the counters of the product with the other
+// products, an order has fewer products than a batch get reads at once.
+func (d *Dynamo) coPurchaseCounters(ctx context.Context, productID string, products []string) ([]types.CoPurchase, error) {
+	keys := make([]map[string]*dynamodb.AttributeValue, 0, len(products))
+	for _, other := range products {
+		if other == productID {
+			continue
+		}
+		keys = append(keys, map[string]*dynamodb.AttributeValue{
+			PartitionKeyAttributeName: {S: aws.String(pkCoPurchasePrefix + productID)},
+			SortkeyAttributeName:      {S: aws.String(other)},
+		})
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	counted := make([]types.CoPurchase, 0, len(keys))
+	pending := map[string]*dynamodb.KeysAndAttributes{d.tableName: {Keys: keys, ConsistentRead: aws.Bool(true)}}
+	backoff := batchWriteBackoff
+	for retry := 0; len(pending) > 0; retry++ {
+		if retry > maxBatchWriteRetries {
+			return nil, fmt.Errorf("error - co-purchases left unprocessed after %d retries", maxBatchWriteRetries)
+		}
+		if retry > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("error - retreiving co-purchases in db: %w", ctx.Err())
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		out, err := d.client.BatchGetItemWithContext(ctx, &dynamodb.BatchGetItemInput{RequestItems: pending})
+		if err != nil {
+			return nil, fmt.Errorf("error - retreiving co-purchases in db: %w", err)
+		}
+		var counters []coPurchaseCounter
+		err = dynamodbattribute.UnmarshalListOfMaps(out.Responses[d.tableName], &counters)
+		if err != nil {
+			return nil, fmt.Errorf("error - Unmarshalling co-purchases: %w", err)
+		}
+		for _, c := range counters {
+			counted = append(counted, types.CoPurchase{ProductID: c.ProductID, Count: c.Count})
+		}
+		pending = out.UnprocessedKeys
+	}
+	return counted, nil
+}
+
+// CoPurchases returns the products most often bought with a product, the
+// most frequent first, at most maxCoPurchasesKept.
+func (d *Dynamo) CoPurchases(ctx context.Context, productID string, limit int) ([]types.CoPurchase, error) {
+	top, _, err := d.coPurchaseTop(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	coPurchases := top.Products
+	if coPurchases == nil {
+		coPurchases = []types.CoPurchase{}
+	}
+	if len(coPurchases) > limit {
+		coPurchases = coPurchases[:limit]
+	}
+	return coPurchases, nil
+}
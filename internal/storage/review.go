@@ -0,0 +1,334 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pratbacknd/internal/types"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// reviews of a product are keyed by user, one review per user
+	pkReviewPrefix = "review#"
+	// pending reviews are copied in the moderation queue
+	pkReviewQueue = "reviewQueue"
+	// the products reviewed by a user are listed in the user partition
+	skReviewPrefix = "review#"
+)
+
+// userReview is the element of the user partition pointing to a review of
+// the user
+type userReview struct {
+	ProductID string `dynamodbav:"productId"`
+}
+
+var ErrorReviewExists = errors.New("the user already reviewed this product")
+
+// CreateReview stores a pending review, adds it to the moderation queue and
+// to the reviews of the user.
+func (d *Dynamo) CreateReview(ctx context.Context, r types.Review) error {
+	item, err := dynamodbattribute.MarshalMap(r)
+	if err != nil {
+		return fmt.Errorf("error - marshal review: %w", err)
+	}
+	queued, err := dynamodbattribute.MarshalMap(r)
+	if err != nil {
+		return fmt.Errorf("error - marshal review: %w", err)
+	}
+	reviewed, err := dynamodbattribute.MarshalMap(userReview{ProductID: r.ProductID})
+	if err != nil {
+		return fmt.Errorf("error - marshal review: %w", err)
+	}
+
+	item[PartitionKeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(pkReviewPrefix + r.ProductID)}
+	item[SortkeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(r.UserID)}
+	queued[PartitionKeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(pkReviewQueue)}
+	queued[SortkeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(r.ID)}
+	reviewed[PartitionKeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(pkUserPrefix + r.UserID)}
+	reviewed[SortkeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(skReviewPrefix + r.ProductID)}
+
+	_, err = d.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Put: &dynamodb.Put{
+					TableName:           &d.tableName,
+					Item:                item,
+					ConditionExpression: aws.String("attribute_not_exists(" + SortkeyAttributeName + ")"),
+				},
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName: &d.tableName,
+					Item:      queued,
+				},
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName: &d.tableName,
+					Item:      reviewed,
+				},
+			},
+		},
+		ClientRequestToken: aws.String(uuid.NewV4().String()),
+	})
+	if err != nil {
+		var canceled *dynamodb.TransactionCanceledException
+		if errors.As(err, &canceled) && len(canceled.CancellationReasons) > 0 &&
+			aws.StringValue(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			return ErrorReviewExists
+		}
+		return fmt.Errorf("error - run the transaction: %w", err)
+	}
+	return nil
+}
+
+// Reviews returns the reviews of a product with the given status, most
+// recent first.
+func (d *Dynamo) Reviews(ctx context.Context, productID string, status string) ([]types.Review, error) {
+	reviews, err := d.reviews(ctx, pkReviewPrefix+productID)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]types.Review, 0, len(reviews))
+	for _, r := range reviews {
+		if r.Status == status {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered, nil
+}
+
+// PendingReviews returns the moderation queue, most recent first.
+func (d *Dynamo) PendingReviews(ctx context.Context) ([]types.Review, error) {
+	return d.reviews(ctx, pkReviewQueue)
+}
+
+// ModerateReview approves or rejects a review and maintains the rating
+// aggregates of the product in the same transaction.
+func (d *Dynamo) ModerateReview(ctx context.Context, productID string, reviewID string, status string) (types.Review, error) {
+	reviews, err := d.reviews(ctx, pkReviewPrefix+productID)
+	if err != nil {
+		return types.Review{}, err
+	}
+
+	var review *types.Review
+	for i := range reviews {
+		if reviews[i].ID == reviewID {
+			review = &reviews[i]
+		}
+	}
+	if review == nil {
+		return types.Review{}, fmt.Errorf("error - no review %s for product %s: %w", reviewID, productID, ErrorNotFound)
+	}
+
+	now := time.Now().UTC()
+	actions := make([]*dynamodb.TransactWriteItem, 0)
+
+	// review status, conditioned on the status read to serialize moderations
+	condition := expression.Name("status").Equal(expression.Value(review.Status))
+	update := expression.Set(
+		expression.Name("status"), expression.Value(status),
+	).Set(
+		expression.Name("moderatedAt"), expression.Value(now),
+	)
+	expr, err := expression.NewBuilder().WithCondition(condition).WithUpdate(update).Build()
+	if err != nil {
+		return types.Review{}, fmt.Errorf("error - building the expression %w", err)
+	}
+	actions = append(actions, &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			Key: map[string]*dynamodb.AttributeValue{
+				PartitionKeyAttributeName: {S: aws.String(pkReviewPrefix + productID)},
+				SortkeyAttributeName:      {S: aws.String(review.UserID)},
+			},
+			TableName:        &d.tableName,
+			UpdateExpression: expr.Update(),
+		},
+	})
+
+	// rating aggregates of the product
+	count, total := review.RatingDelta(status)
+	if count != 0 {
+		update := expression.Add(
+			expression.Name("ratingCount"), expression.Value(count),
+		).Add(
+			expression.Name("ratingTotal"), expression.Value(total),
+		)
+		expr, err := expression.NewBuilder().WithUpdate(update).Build()
+		if err != nil {
+			return types.Review{}, fmt.Errorf("error - building the expression %w", err)
+		}
+		actions = append(actions, &dynamodb.TransactWriteItem{
+			Update: &dynamodb.Update{
+				ConditionExpression:       aws.String("attribute_exists(" + SortkeyAttributeName + ")"),
+				ExpressionAttributeNames:  expr.Names(),
+				ExpressionAttributeValues: expr.Values(),
+				Key: map[string]*dynamodb.AttributeValue{
+					PartitionKeyAttributeName: {S: aws.String(pkProduct)},
+					SortkeyAttributeName:      {S: aws.String(productID)},
+				},
+				TableName:        &d.tableName,
+				UpdateExpression: expr.Update(),
+			},
+		})
+	}
+
+	// moderation queue
+	if review.Status == types.ReviewPending {
+		actions = append(actions, &dynamodb.TransactWriteItem{
+			Delete: &dynamodb.Delete{
+				TableName: &d.tableName,
+				Key: map[string]*dynamodb.AttributeValue{
+					PartitionKeyAttributeName: {S: aws.String(pkReviewQueue)},
+					SortkeyAttributeName:      {S: aws.String(review.ID)},
+				},
+			},
+		})
+	}
+
+	_, err = d.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems:      actions,
+		ClientRequestToken: aws.String(uuid.NewV4().String()),
+	})
+	if err != nil {
+		return types.Review{}, fmt.Errorf("error - run the transaction: %w", err)
+	}
+
+	review.Status = status
+	review.ModeratedAt = &now
+	return *review, nil
+}
+
+// UserReviews returns the reviews written by the user, most recent first.
+func (d *Dynamo) UserReviews(ctx context.Context, userID string) ([]types.Review, error) {
+	out, err := d.getElementsByPkAndSkPrefix(ctx, pkUserPrefix+userID, skReviewPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	reviewed := make([]userReview, 0)
+	err = dynamodbattribute.UnmarshalListOfMaps(out.Items, &reviewed)
+	if err != nil {
+		return nil, fmt.Errorf("error - Unmarshalling reviews: %w", err)
+	}
+
+	reviews := make([]types.Review, 0, len(reviewed))
+	for _, ur := range reviewed {
+		out, err := d.getElementByPkAndSk(ctx, pkReviewPrefix+ur.ProductID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if len(out.Items) == 0 {
+			continue
+		}
+
+		var r types.Review
+		err = dynamodbattribute.UnmarshalMap(out.Items[0], &r)
+		if err != nil {
+			return nil, fmt.Errorf("error - Unmarshalling review: %w", err)
+		}
+		reviews = append(reviews, r)
+	}
+
+	sort.SliceStable(reviews, func(i, j int) bool {
+		return reviews[i].CreatedAt.After(reviews[j].CreatedAt)
+	})
+	return reviews, nil
+}
+
+// deleteReview deletes the review and its copy in the moderation queue, an
+// approved review is taken out of the rating of its product.
+func (d *Dynamo) deleteReview(ctx context.Context, r types.Review) error {
+	actions := []*dynamodb.TransactWriteItem{
+		{
+			Delete: &dynamodb.Delete{
+				TableName: &d.tableName,
+				Key: map[string]*dynamodb.AttributeValue{
+					PartitionKeyAttributeName: {S: aws.String(pkReviewPrefix + r.ProductID)},
+					SortkeyAttributeName:      {S: aws.String(r.UserID)},
+				},
+			},
+		},
+		{
+			Delete: &dynamodb.Delete{
+				TableName: &d.tableName,
+				Key: map[string]*dynamodb.AttributeValue{
+					PartitionKeyAttributeName: {S: aws.String(pkReviewQueue)},
+					SortkeyAttributeName:      {S: aws.String(r.ID)},
+				},
+			},
+		},
+	}
+
+	count, total := r.RatingDelta(types.ReviewRejected)
+	if count != 0 {
+		_, err := d.GetProductById(ctx, r.ProductID)
+		if err != nil && !errors.Is(err, ErrorNotFound) {
+			return fmt.Errorf("error - getting the product of id %s: %w", r.ProductID, err)
+		}
+		// the rating of a deleted product is gone with it
+		if err == nil {
+			update := expression.Add(
+				expression.Name("ratingCount"), expression.Value(count),
+			).Add(
+				expression.Name("ratingTotal"), expression.Value(total),
+			)
+			expr, err := expression.NewBuilder().WithUpdate(update).Build()
+			if err != nil {
+				return fmt.Errorf("error - building the expression %w", err)
+			}
+			actions = append(actions, &dynamodb.TransactWriteItem{
+				Update: &dynamodb.Update{
+					ConditionExpression:       aws.String("attribute_exists(" + SortkeyAttributeName + ")"),
+					ExpressionAttributeNames:  expr.Names(),
+					ExpressionAttributeValues: expr.Values(),
+					Key: map[string]*dynamodb.AttributeValue{
+						PartitionKeyAttributeName: {S: aws.String(pkProduct)},
+						SortkeyAttributeName:      {S: aws.String(r.ProductID)},
+					},
+					TableName:        &d.tableName,
+					UpdateExpression: expr.Update(),
+				},
+			})
+		}
+	}
+
+	_, err := d.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems:      actions,
+		ClientRequestToken: aws.String(uuid.NewV4().String()),
+	})
+	if err != nil {
+		return fmt.Errorf("error - run the transaction: %w", err)
+	}
+	return nil
+}
+
+func (d *Dynamo) reviews(ctx context.Context, pk string) ([]types.Review, error) {
+	out, err := d.getElementByPkAndSk(ctx, pk, "")
+	if err != nil {
+		return nil, err
+	}
+
+	reviews := make([]types.Review, 0)
+	err = dynamodbattribute.UnmarshalListOfMaps(out.Items, &reviews)
+	if err != nil {
+		return nil, fmt.Errorf("error - Unmarshalling reviews: %w", err)
+	}
+
+	sort.SliceStable(reviews, func(i, j int) bool {
+		return reviews[i].CreatedAt.After(reviews[j].CreatedAt)
+	})
+	return reviews, nil
+}
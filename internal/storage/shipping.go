@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"pratbacknd/internal/types"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+const (
+	pkShippingZone   = "shippingZone"
+	pkShippingMethod = "shippingMethod"
+)
+
+func (d *Dynamo) CreateShippingZone(ctx context.Context, z types.ShippingZone) error {
+	return d.putElement(ctx, pkShippingZone, z.ID, z, false)
+}
+
+func (d *Dynamo) UpdateShippingZone(ctx context.Context, z types.ShippingZone) error {
+	return d.putElement(ctx, pkShippingZone, z.ID, z, true)
+}
+
+func (d *Dynamo) DeleteShippingZone(ctx context.Context, zoneID string) error {
+	return d.deleteElement(ctx, pkShippingZone, zoneID)
+}
+
+func (d *Dynamo) ShippingZones(ctx context.Context) ([]types.ShippingZone, error) {
+	out, err := d.getElementByPkAndSk(ctx, pkShippingZone, "")
+	if err != nil {
+		return nil, err
+	}
+
+	zones := make([]types.ShippingZone, 0)
+	err = dynamodbattribute.UnmarshalListOfMaps(out.Items, &zones)
+	if err != nil {
+		return nil, fmt.Errorf("error - Unmarshalling results: %w", err)
+	}
+	return zones, nil
+}
+
+func (d *Dynamo) CreateShippingMethod(ctx context.Context, m types.ShippingMethod) error {
+	return d.putElement(ctx, pkShippingMethod, m.ID, m, false)
+}
+
+func (d *Dynamo) UpdateShippingMethod(ctx context.Context, m types.ShippingMethod) error {
+	return d.putElement(ctx, pkShippingMethod, m.ID, m, true)
+}
+
+func (d *Dynamo) DeleteShippingMethod(ctx context.Context, methodID string) error {
+	return d.deleteElement(ctx, pkShippingMethod, methodID)
+}
+
+func (d *Dynamo) ShippingMethods(ctx context.Context) ([]types.ShippingMethod, error) {
+	out, err := d.getElementByPkAndSk(ctx, pkShippingMethod, "")
+	if err != nil {
+		return nil, err
+	}
+
+	methods := make([]types.ShippingMethod, 0)
+	err = dynamodbattribute.UnmarshalListOfMaps(out.Items, &methods)
+	if err != nil {
+		return nil, fmt.Errorf("error - Unmarshalling results: %w", err)
+	}
+	return methods, nil
+}
+
+// SelectShipping stores the delivery country and the shipping method
+// chosen for the cart.
+func (d *Dynamo) SelectShipping(ctx context.Context, cartID string, country string, methodID string) (types.Cart, error) {
+	cart, err := d.GetCart(ctx, cartID)
+	if err != nil {
+		return types.Cart{}, fmt.Errorf("error - retreiving the cart: %w", err)
+	}
+
+	cart.ShippingCountry = country
+	cart.ShippingMethodID = methodID
+
+	return d.runCartTransaction(ctx, cart, cartID, nil)
+}
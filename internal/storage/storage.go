@@ -1,33 +1,128 @@
 package storage
 
 import (
+	"context"
+	"fmt"
+	"pratbacknd/internal/config"
 	"pratbacknd/internal/types"
+	"time"
 )
 
 type UpdateProductInput struct {
-	ProductId        string      `json:"productId"`
-	Name             string      `json:"name"`
-	Image            string      `json:"image"`
-	ShortDescription string      `json:"shortDescription"`
-	Description      string      `json:"description"`
-	PriceVATExcluded types.Money `json:"priceVATExcluded"`
-	VAT              types.Money `json:"vat"`
-	TotalPrice       types.Money `json:"totalPrice"`
+	ProductId        string            `json:"productId"`
+	SKU              string            `json:"sku"`
+	Name             string            `json:"name"`
+	Image            string            `json:"image"`
+	ShortDescription string            `json:"shortDescription"`
+	Description      string            `json:"description"`
+	PriceVATExcluded types.Money       `json:"priceVATExcluded"`
+	VAT              types.Money       `json:"vat"`
+	TotalPrice       types.Money       `json:"totalPrice"`
+	Backorder        *bool             `json:"backorder"`
+	PreOrder         *bool             `json:"preOrder"`
+	MaxBackorder     *uint             `json:"maxBackorder"`
+	AvailableAt      *time.Time        `json:"availableAt"`
+	MinPerOrder      *uint             `json:"minPerOrder"`
+	MaxPerOrder      *uint             `json:"maxPerOrder"`
+	Weight           *uint             `json:"weight"`
+	Dimensions       *types.Dimensions `json:"dimensions"`
+	// CategoryIDs replace the categories when not nil
+	CategoryIDs []string `json:"categoryIds"`
 }
 
+// Storage keeps the catalog, the carts and the user data. The context of
+// every method cancels its DynamoDB calls and carries the trace.
 type Storage interface {
-	Products() ([]types.Product, error)
-	GetProductById(productID string) (types.Product, error)
-	CreateProduct(p types.Product) error
-	UpdateProduct(input UpdateProductInput) error
+	Products(ctx context.Context) ([]types.Product, error)
+	GetProductById(ctx context.Context, productID string) (types.Product, error)
+	CreateProduct(ctx context.Context, p types.Product) error
+	UpdateProduct(ctx context.Context, input UpdateProductInput) error
+	UpdateProductImages(ctx context.Context, productID string, version uint, images []types.ProductImage) error
+	UpdateProductTranslations(ctx context.Context, productID string, version uint, translations map[string]types.ProductTranslation) error
+	UpdatePriceSchedule(ctx context.Context, p types.Product, entry types.PriceHistoryEntry) error
+	PriceHistory(ctx context.Context, productID string) ([]types.PriceHistoryEntry, error)
+	UpdateProductRelations(ctx context.Context, productID string, version uint, relations []types.ProductRelation) error
+	RecordPurchase(ctx context.Context, orderID string, productIDs []string) error
+	CoPurchases(ctx context.Context, productID string, limit int) ([]types.CoPurchase, error)
 
-	Categories() ([]types.Category, error)
-	CreateCategory(c types.Category) error
+	Categories(ctx context.Context) ([]types.Category, error)
+	GetCategoryById(ctx context.Context, categoryID string) (types.Category, error)
+	CreateCategory(ctx context.Context, c types.Category) error
+	UpdateCategoryTranslations(ctx context.Context, categoryID string, translations map[string]types.CategoryTranslation) error
 
-	UpdateInventory(productId string, delta int) error
+	UpdateInventory(ctx context.Context, productId string, delta int) error
 
-	CreateCart(cart types.Cart, userId string) error
-	GetCart(userID string) (types.Cart, error)
+	CreateCart(ctx context.Context, cart types.Cart, userId string) error
+	GetCart(ctx context.Context, userID string) (types.Cart, error)
 
-	CreateOrUpdateCart(userID string, productID string, delta int) (types.Cart, error)
+	CreateOrUpdateCart(ctx context.Context, userID string, productID string, delta int) (types.Cart, error)
+	MergeCarts(ctx context.Context, guestCartID string, userID string) (types.Cart, error)
+	ClearCart(ctx context.Context, cartID string) (types.Cart, error)
+	RemoveCartItem(ctx context.Context, cartID string, productID string) (types.Cart, error)
+	SetCartItemQuantity(ctx context.Context, cartID string, productID string, quantity int) (types.Cart, error)
+
+	CreatePromotion(ctx context.Context, p types.Promotion) error
+	Promotions(ctx context.Context) ([]types.Promotion, error)
+	GetPromotionById(ctx context.Context, promotionID string) (types.Promotion, error)
+	GetPromotionByCode(ctx context.Context, code string) (types.Promotion, error)
+	UpdatePromotion(ctx context.Context, p types.Promotion) error
+	DeletePromotion(ctx context.Context, promotionID string) error
+	ApplyCoupon(ctx context.Context, cartID string, userID string, p types.Promotion) (types.Cart, error)
+	RemoveCoupon(ctx context.Context, cartID string, userID string, p types.Promotion) (types.Cart, error)
+
+	CreateShippingZone(ctx context.Context, z types.ShippingZone) error
+	UpdateShippingZone(ctx context.Context, z types.ShippingZone) error
+	DeleteShippingZone(ctx context.Context, zoneID string) error
+	ShippingZones(ctx context.Context) ([]types.ShippingZone, error)
+	CreateShippingMethod(ctx context.Context, m types.ShippingMethod) error
+	UpdateShippingMethod(ctx context.Context, m types.ShippingMethod) error
+	DeleteShippingMethod(ctx context.Context, methodID string) error
+	ShippingMethods(ctx context.Context) ([]types.ShippingMethod, error)
+	SelectShipping(ctx context.Context, cartID string, country string, methodID string) (types.Cart, error)
+
+	Addresses(ctx context.Context, userID string) ([]types.Address, error)
+	GetAddress(ctx context.Context, userID string, addressID string) (types.Address, error)
+	CreateAddress(ctx context.Context, userID string, a types.Address) (types.Address, error)
+	UpdateAddress(ctx context.Context, userID string, a types.Address) (types.Address, error)
+	DeleteAddress(ctx context.Context, userID string, addressID string) error
+
+	GetProfile(ctx context.Context, userID string) (types.Profile, error)
+	CreateProfile(ctx context.Context, p types.Profile) error
+	UpdateProfile(ctx context.Context, p types.Profile) error
+
+	Wishlist(ctx context.Context, userID string) ([]types.WishlistItem, error)
+	AddToWishlist(ctx context.Context, userID string, item types.WishlistItem) error
+	RemoveFromWishlist(ctx context.Context, userID string, productID string) error
+	SaveForLater(ctx context.Context, cartID string, userID string, productID string) (types.Cart, error)
+
+	CreateReview(ctx context.Context, r types.Review) error
+	Reviews(ctx context.Context, productID string, status string) ([]types.Review, error)
+	PendingReviews(ctx context.Context) ([]types.Review, error)
+	UserReviews(ctx context.Context, userID string) ([]types.Review, error)
+	ModerateReview(ctx context.Context, productID string, reviewID string, status string) (types.Review, error)
+
+	DeleteCart(ctx context.Context, cartID string) error
+	ReleaseExpiredCarts(ctx context.Context, cartIDPrefix string, updatedBefore time.Time) (int, error)
+	DeleteUserData(ctx context.Context, userID string) error
+}
+
+var (
+	_ Storage = (*Dynamo)(nil)
+	_ Storage = (*Memory)(nil)
+)
+
+// New returns the storage selected by the config.
+func New(cfg config.Storage) (Storage, error) {
+	cartLimits, err := checkCartLimits(types.CartLimits{MaxDistinctItems: cfg.CartMaxDistinctItems})
+	if err != nil {
+		return nil, err
+	}
+	switch cfg.Kind {
+	case config.StorageMemory:
+		return NewMemory(cartLimits), nil
+	case config.StorageDynamo:
+		return NewDynamo(cfg.Table, cartLimits)
+	default:
+		return nil, fmt.Errorf("error - unknown storage %q", cfg.Kind)
+	}
 }
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "pratbacknd/internal/storage"
+
+type requestSpanKeyType struct{}
+
+// requestSpanKey keeps the span of the DynamoDB request apart from the span
+// of the caller, which must not be ended with it
+var requestSpanKey requestSpanKeyType
+
+// traceRequests starts a client span around every DynamoDB request, retries
+// included, in the trace of the request context.
+func traceRequests(handlers *request.Handlers, tableName string) {
+	handlers.Build.PushFront(func(r *request.Request) {
+		ctx, span := otel.Tracer(tracerName).Start(r.Context(), "DynamoDB."+r.Operation.Name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemDynamoDB,
+				semconv.DBOperation(r.Operation.Name),
+				semconv.AWSDynamoDBTableNames(tableName),
+			),
+		)
+		r.SetContext(context.WithValue(ctx, requestSpanKey, span))
+	})
+	// the complete handlers run even when the request fails to be built
+	handlers.Complete.PushBack(func(r *request.Request) {
+		span, ok := r.Context().Value(requestSpanKey).(trace.Span)
+		if !ok {
+			return
+		}
+		span.SetAttributes(attribute.Int("aws.retries", r.RetryCount))
+		if r.RequestID != "" {
+			span.SetAttributes(attribute.String("aws.request_id", r.RequestID))
+		}
+		if r.Error != nil {
+			span.RecordError(r.Error)
+			span.SetStatus(codes.Error, r.Error.Error())
+		}
+		span.End()
+	})
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"pratbacknd/internal/tracing"
+	"pratbacknd/internal/types"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func TestDynamo_Trace(t *testing.T) {
+	// given
+	provider, exporter := tracing.NewInMemory()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	// a DynamoDB finding no cart and refusing the writes
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		if r.Header.Get("X-Amz-Target") == "DynamoDB_20120810.Query" {
+			_, _ = w.Write([]byte(`{"Items": []}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"__type": "com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException", "message": "The conditional request failed"}`))
+	}))
+	defer endpoint.Close()
+	awsSession := session.Must(session.NewSession(&aws.Config{
+		Endpoint:    aws.String(endpoint.URL),
+		Region:      aws.String("eu-west-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	}))
+	client := dynamodb.New(awsSession)
+	traceRequests(&client.Handlers, "ecommerce-test")
+	d := &Dynamo{tableName: "ecommerce-test", awsSession: awsSession, client: client}
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	// when
+	_, notFound := d.GetCart(ctx, "u1")
+	conflict := d.CreateCart(ctx, types.Cart{Version: 1}, "u1")
+	_, stopped := d.GetCart(canceled, "u1")
+	parent.End()
+
+	// then
+	assert.ErrorIs(t, notFound, ErrorNotFound)
+	assert.Error(t, conflict)
+	var awsErr awserr.Error
+	assert.ErrorAs(t, stopped, &awsErr)
+	assert.Equal(t, request.CanceledErrorCode, awsErr.Code(), "the request is canceled with its context")
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 4)
+
+	assert.Equal(t, "DynamoDB.Query", spans[0].Name)
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent.SpanID(), "the call is a child of the request")
+	assert.Equal(t, codes.Unset, spans[0].Status.Code)
+	assert.Contains(t, spans[0].Attributes, semconv.DBSystemDynamoDB)
+	assert.Contains(t, spans[0].Attributes, semconv.AWSDynamoDBTableNames("ecommerce-test"))
+
+	assert.Equal(t, "DynamoDB.PutItem", spans[1].Name)
+	assert.Equal(t, codes.Error, spans[1].Status.Code)
+	assert.Len(t, spans[1].Events, 1, "the error is recorded")
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pratbacknd/internal/types"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+)
+
+// UpdateProductTranslations replaces the translations of a product read at
+// the given version, it fails with ErrorProductChanged if the product was
+// updated in the meantime.
+func (d *Dynamo) UpdateProductTranslations(ctx context.Context, productID string, version uint, translations map[string]types.ProductTranslation) error {
+	update := expression.Set(expression.Name("translations"), expression.Value(translations))
+	return d.updateProductVersion(ctx, productID, version, update)
+}
+
+func (d *Dynamo) GetCategoryById(ctx context.Context, categoryID string) (types.Category, error) {
+	out, err := d.getElementByPkAndSk(ctx, pkCategory, categoryID)
+	if err != nil {
+		return types.Category{}, fmt.Errorf("error - retreiving category in db: %w", err)
+	}
+
+	if len(out.Items) == 0 {
+		return types.Category{}, fmt.Errorf("error - no category %s: %w", categoryID, ErrorNotFound)
+	}
+
+	var c types.Category
+	err = dynamodbattribute.UnmarshalMap(out.Items[0], &c)
+	if err != nil {
+		return types.Category{}, fmt.Errorf("error - Unmarshalling category: %w", err)
+	}
+	return c, nil
+}
+
+// UpdateCategoryTranslations replaces the translations of a category.
+func (d *Dynamo) UpdateCategoryTranslations(ctx context.Context, categoryID string, translations map[string]types.CategoryTranslation) error {
+	condition := expression.AttributeExists(expression.Name(SortkeyAttributeName))
+	update := expression.Set(expression.Name("translations"), expression.Value(translations))
+
+	expr, err := expression.NewBuilder().WithCondition(condition).WithUpdate(update).Build()
+	if err != nil {
+		return fmt.Errorf("error - building the expression: %w", err)
+	}
+
+	_, err = d.client.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName: &d.tableName,
+		Key: map[string]*dynamodb.AttributeValue{
+			PartitionKeyAttributeName: {S: aws.String(pkCategory)},
+			SortkeyAttributeName:      {S: aws.String(categoryID)},
+		},
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+	})
+	if err != nil {
+		var conditionFailed *dynamodb.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return ErrorNotFound
+		}
+		return fmt.Errorf("error - run update item request: %w", err)
+	}
+
+	return nil
+}
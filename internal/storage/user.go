@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pratbacknd/internal/types"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	uuid "github.com/satori/go.uuid"
+)
+
+// DeleteCart releases every reservation of the cart and deletes it in a
+// single transaction.
+func (d *Dynamo) DeleteCart(ctx context.Context, cartID string) error {
+	cart, err := d.GetCart(ctx, cartID)
+	if err != nil {
+		return fmt.Errorf("error - retreiving the cart: %w", err)
+	}
+	return d.deleteCart(ctx, cart, cartID)
+}
+
+// deleteCart deletes the cart as it was read, the transaction fails if the
+// cart changed since.
+func (d *Dynamo) deleteCart(ctx context.Context, cart types.Cart, cartID string) error {
+	if len(cart.Items) > maxItemsPerTransaction {
+		return fmt.Errorf("error - cart has too many items to be deleted at once: %d", len(cart.Items))
+	}
+
+	actions := make([]*dynamodb.TransactWriteItem, 0)
+	for productID, item := range cart.Items {
+		reqs, err := d.buildReserveRequests(ctx, &cart, cartID, productID, -int(item.Quantity))
+		if err != nil {
+			return err
+		}
+		actions = append(actions, reqs...)
+	}
+
+	deleteCartReq, err := d.buildDeleteCartRequest(cart, cartID)
+	if err != nil {
+		return fmt.Errorf("error - delete cart request: %w", err)
+	}
+	actions = append(actions, deleteCartReq)
+
+	_, err = d.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems:      actions,
+		ClientRequestToken: aws.String(uuid.NewV4().String()),
+	})
+	if err != nil {
+		return fmt.Errorf("error - run the transaction: %w", err)
+	}
+	return nil
+}
+
+// ReleaseExpiredCarts deletes the carts whose id starts with the prefix and
+// which were last written before updatedBefore, releasing their
+// reservations and deleting their backorders. A cart written meanwhile is
+// kept.
+func (d *Dynamo) ReleaseExpiredCarts(ctx context.Context, cartIDPrefix string, updatedBefore time.Time) (int, error) {
+	keyCondition := expression.Key(PartitionKeyAttributeName).Equal(expression.Value(pkCart)).And(
+		expression.Key(SortkeyAttributeName).BeginsWith(cartIDPrefix),
+	)
+	projection := expression.NamesList(expression.Name(SortkeyAttributeName), expression.Name("updatedAt"))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCondition).WithProjection(projection).Build()
+	if err != nil {
+		return 0, fmt.Errorf("error - building expression: %w", err)
+	}
+
+	expired := make([]string, 0)
+	input := &dynamodb.QueryInput{
+		KeyConditionExpression:    expr.KeyCondition(),
+		ProjectionExpression:      expr.Projection(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		TableName:                 &d.tableName,
+	}
+	for {
+		out, err := d.client.QueryWithContext(ctx, input)
+		if err != nil {
+			return 0, fmt.Errorf("error - run query: %w", err)
+		}
+		var carts []struct {
+			CartID    string     `dynamodbav:"SK"`
+			UpdatedAt *time.Time `dynamodbav:"updatedAt"`
+		}
+		err = dynamodbattribute.UnmarshalListOfMaps(out.Items, &carts)
+		if err != nil {
+			return 0, fmt.Errorf("error - Unmarshalling carts: %w", err)
+		}
+		for _, c := range carts {
+			if (types.Cart{UpdatedAt: c.UpdatedAt}).UpdatedBefore(updatedBefore) {
+				expired = append(expired, c.CartID)
+			}
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	released := 0
+	var errs []error
+	for _, cartID := range expired {
+		cart, err := d.GetCart(ctx, cartID)
+		if errors.Is(err, ErrorNotFound) {
+			continue
+		}
+		if err == nil && cart.UpdatedBefore(updatedBefore) {
+			err = d.deleteCart(ctx, cart, cartID)
+			if err == nil {
+				released++
+				// the backorders of the guest
+				err = d.deleteUserPartition(ctx, cartID)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error - releasing the cart %s: %w", cartID, err))
+		}
+	}
+	return released, errors.Join(errs...)
+}
+
+// DeleteUserData erases the personal data of the user: the cart is deleted
+// with its reservations released, then every element of the user partition
+// (profile, addresses...) is removed with the reviews, and the coupon
+// redemptions and the backorders of the user, kept under the promotions and
+// the products, are found from their pointers in the user partition.
+func (d *Dynamo) DeleteUserData(ctx context.Context, userID string) error {
+	_, err := d.GetCart(ctx, userID)
+	switch {
+	case errors.Is(err, ErrorNotFound):
+		// the user has no cart
+	case err != nil:
+		return fmt.Errorf("error - retreiving the cart: %w", err)
+	default:
+		err = d.DeleteCart(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("error - deleting the cart: %w", err)
+		}
+	}
+
+	reviews, err := d.UserReviews(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("error - retreiving the reviews: %w", err)
+	}
+	for _, r := range reviews {
+		err = d.deleteReview(ctx, r)
+		if err != nil {
+			return fmt.Errorf("error - deleting the review of product %s: %w", r.ProductID, err)
+		}
+	}
+
+	return d.deleteUserPartition(ctx, userID)
+}
+
+// deleteUserPartition deletes the user partition with the elements its
+// pointers point to.
+func (d *Dynamo) deleteUserPartition(ctx context.Context, userID string) error {
+	keys, err := d.partitionKeys(ctx, pkUserPrefix+userID)
+	if err != nil {
+		return fmt.Errorf("error - retreiving the user data: %w", err)
+	}
+
+	// the pointers are deleted with the partition, after the elements they
+	// point to
+	pointed := make([]map[string]*dynamodb.AttributeValue, 0)
+	for _, key := range keys {
+		sk := aws.StringValue(key[SortkeyAttributeName].S)
+		switch {
+		case strings.HasPrefix(sk, skRedemptionPrefix):
+			pointed = append(pointed, map[string]*dynamodb.AttributeValue{
+				PartitionKeyAttributeName: {S: aws.String(pkRedemptionPrefix + strings.TrimPrefix(sk, skRedemptionPrefix))},
+				SortkeyAttributeName:      {S: aws.String(userID)},
+			})
+		case strings.HasPrefix(sk, skBackorderPrefix):
+			pointed = append(pointed, backorderKey(strings.TrimPrefix(sk, skBackorderPrefix), userID))
+		}
+	}
+
+	return d.deleteKeys(ctx, append(pointed, keys...))
+}
+
+const (
+	// maxBatchWriteItems is the DynamoDB limit of requests in a batch write
+	maxBatchWriteItems = 25
+	// maxBatchWriteRetries bounds the retries of the unprocessed items of a
+	// batch, the table is throttled beyond
+	maxBatchWriteRetries = 8
+	// batchWriteBackoff is the wait before the first retry, doubled at each
+	// retry
+	batchWriteBackoff = 25 * time.Millisecond
+)
+
+// deleteKeys deletes the elements in batches, deleting an element that does
+// not exist is not an error. The unprocessed items are retried with an
+// exponential backoff.
+func (d *Dynamo) deleteKeys(ctx context.Context, keys []map[string]*dynamodb.AttributeValue) error {
+	for start := 0; start < len(keys); start += maxBatchWriteItems {
+		end := start + maxBatchWriteItems
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		requests := make([]*dynamodb.WriteRequest, 0, end-start)
+		for _, key := range keys[start:end] {
+			requests = append(requests, &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: key}})
+		}
+
+		pending := map[string][]*dynamodb.WriteRequest{d.tableName: requests}
+		backoff := batchWriteBackoff
+		for retry := 0; len(pending) > 0; retry++ {
+			if retry > maxBatchWriteRetries {
+				return fmt.Errorf("error - %d items left unprocessed after %d retries", len(pending[d.tableName]), maxBatchWriteRetries)
+			}
+			if retry > 0 {
+				select {
+				case <-ctx.Done():
+					return fmt.Errorf("error - delete items in db: %w", ctx.Err())
+				case <-time.After(backoff):
+				}
+				backoff *= 2
+			}
+
+			out, err := d.client.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{RequestItems: pending})
+			if err != nil {
+				return fmt.Errorf("error - delete items in db: %w", err)
+			}
+			pending = out.UnprocessedItems
+		}
+	}
+	return nil
+}
+
+// partitionKeys returns the keys of every element of a partition.
+func (d *Dynamo) partitionKeys(ctx context.Context, pk string) ([]map[string]*dynamodb.AttributeValue, error) {
+	keyCondition := expression.Key(PartitionKeyAttributeName).Equal(expression.Value(pk))
+	projection := expression.NamesList(expression.Name(PartitionKeyAttributeName), expression.Name(SortkeyAttributeName))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCondition).WithProjection(projection).Build()
+	if err != nil {
+		return nil, fmt.Errorf("error - building expression: %w", err)
+	}
+
+	keys := make([]map[string]*dynamodb.AttributeValue, 0)
+	input := &dynamodb.QueryInput{
+		KeyConditionExpression:    expr.KeyCondition(),
+		ProjectionExpression:      expr.Projection(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		TableName:                 &d.tableName,
+	}
+	for {
+		out, err := d.client.QueryWithContext(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("error - run query: %w", err)
+		}
+		keys = append(keys, out.Items...)
+		if len(out.LastEvaluatedKey) == 0 {
+			return keys, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"pratbacknd/internal/types"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// wishlist items are stored in the partition of their user
+const skWishlistPrefix = "wishlist#"
+
+// Wishlist returns the wishlist of the user, most recent first.
+func (d *Dynamo) Wishlist(ctx context.Context, userID string) ([]types.WishlistItem, error) {
+	out, err := d.getElementsByPkAndSkPrefix(ctx, pkUserPrefix+userID, skWishlistPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]types.WishlistItem, 0)
+	err = dynamodbattribute.UnmarshalListOfMaps(out.Items, &items)
+	if err != nil {
+		return nil, fmt.Errorf("error - Unmarshalling wishlist: %w", err)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].AddedAt.After(items[j].AddedAt)
+	})
+	return items, nil
+}
+
+func (d *Dynamo) AddToWishlist(ctx context.Context, userID string, item types.WishlistItem) error {
+	return d.putElement(ctx, pkUserPrefix+userID, skWishlistPrefix+item.ProductID, item, false)
+}
+
+func (d *Dynamo) RemoveFromWishlist(ctx context.Context, userID string, productID string) error {
+	out, err := d.getElementByPkAndSk(ctx, pkUserPrefix+userID, skWishlistPrefix+productID)
+	if err != nil {
+		return fmt.Errorf("error - retreiving wishlist item in db: %w", err)
+	}
+	if len(out.Items) == 0 {
+		return fmt.Errorf("error - product %s is not in the wishlist: %w", productID, ErrorNotFound)
+	}
+
+	return d.deleteElement(ctx, pkUserPrefix+userID, skWishlistPrefix+productID)
+}
+
+// SaveForLater moves a product from the cart to the wishlist of the user,
+// its reservation is released in the same transaction.
+func (d *Dynamo) SaveForLater(ctx context.Context, cartID string, userID string, productID string) (types.Cart, error) {
+	cart, err := d.GetCart(ctx, cartID)
+	if err != nil {
+		return types.Cart{}, fmt.Errorf("error - retreiving the cart: %w", err)
+	}
+
+	item, found := cart.Items[productID]
+	if !found {
+		return types.Cart{}, fmt.Errorf("error - product %s is not in the cart: %w", productID, ErrorNotFound)
+	}
+
+	actions, err := d.buildReserveRequests(ctx, &cart, cartID, productID, -int(item.Quantity))
+	if err != nil {
+		return types.Cart{}, err
+	}
+
+	wishlistItem, err := dynamodbattribute.MarshalMap(types.WishlistItem{
+		ProductID: productID,
+		Quantity:  item.Quantity,
+		AddedAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		return types.Cart{}, fmt.Errorf("error - marshal wishlist item: %w", err)
+	}
+	wishlistItem[PartitionKeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(pkUserPrefix + userID)}
+	wishlistItem[SortkeyAttributeName] = &dynamodb.AttributeValue{S: aws.String(skWishlistPrefix + productID)}
+
+	actions = append(actions, &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			TableName: &d.tableName,
+			Item:      wishlistItem,
+		},
+	})
+
+	return d.runCartTransaction(ctx, cart, cartID, actions)
+}
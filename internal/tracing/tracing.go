@@ -0,0 +1,54 @@
+// Package tracing builds the OpenTelemetry tracer provider of the commands.
+// The spans are started with the global provider, see otel.Tracer, and the
+// exporter is chosen by the config.
+package tracing
+
+import (
+	"fmt"
+	"io"
+	"pratbacknd/internal/config"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// NewExporter returns the exporter of the kind, the stdout exporter writes
+// the spans as JSON lines to w. There is no exporter for config.TracesNone.
+func NewExporter(kind string, w io.Writer) (sdktrace.SpanExporter, error) {
+	switch kind {
+	case config.TracesNone:
+		return nil, nil
+	case config.TracesStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			return nil, fmt.Errorf("error - creating the stdout exporter: %w", err)
+		}
+		return exporter, nil
+	default:
+		return nil, fmt.Errorf("error - unknown trace exporter %q", kind)
+	}
+}
+
+// NewProvider returns a provider sampling the traces not sampled out by the
+// caller. The spans are batched to the exporter, a nil exporter only keeps
+// the trace IDs for the propagation and the logs.
+func NewProvider(service string, exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(service))),
+	}
+	if exporter != nil {
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+	return sdktrace.NewTracerProvider(options...)
+}
+
+// NewInMemory returns a provider recording the ended spans in the exporter
+// as they end, for the tests.
+func NewInMemory() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), exporter
+}
@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"pratbacknd/internal/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewExporter(t *testing.T) {
+	t.Run("writes the spans as JSON lines", func(t *testing.T) {
+		// given
+		var out bytes.Buffer
+		exporter, err := NewExporter(config.TracesStdout, &out)
+		assert.NoError(t, err)
+		provider := NewProvider("ecommerce-test", exporter)
+
+		// when
+		_, span := provider.Tracer("test").Start(context.Background(), "GET /products")
+		span.End()
+		assert.NoError(t, provider.Shutdown(context.Background()))
+
+		// then
+		var exported map[string]interface{}
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &exported))
+		assert.Equal(t, "GET /products", exported["Name"])
+	})
+
+	t.Run("no exporter drops the spans", func(t *testing.T) {
+		// when
+		exporter, err := NewExporter(config.TracesNone, nil)
+
+		// then
+		assert.NoError(t, err)
+		assert.Nil(t, exporter)
+		_, span := NewProvider("ecommerce-test", nil).Tracer("test").Start(context.Background(), "GET /products")
+		assert.True(t, span.SpanContext().HasTraceID(), "the trace IDs are still propagated")
+	})
+
+	t.Run("unknown exporter", func(t *testing.T) {
+		// when
+		_, err := NewExporter("jaeger", nil)
+
+		// then
+		assert.EqualError(t, err, `error - unknown trace exporter "jaeger"`)
+	})
+}
@@ -0,0 +1,112 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	AddressShipping = "shipping"
+	AddressBilling  = "billing"
+	AddressBoth     = "both"
+)
+
+// MaxAddresses is the size limit of an address book.
+const MaxAddresses = 20
+
+type Address struct {
+	ID string `json:"id"`
+	// Type tells if the address is used for shipping, billing or both
+	Type       string    `json:"type"`
+	Label      string    `json:"label,omitempty"`
+	FirstName  string    `json:"firstName"`
+	LastName   string    `json:"lastName"`
+	Company    string    `json:"company,omitempty"`
+	Line1      string    `json:"line1"`
+	Line2      string    `json:"line2,omitempty"`
+	City       string    `json:"city"`
+	PostalCode string    `json:"postalCode,omitempty"`
+	Region     string    `json:"region,omitempty"`
+	Country    string    `json:"country"`
+	Phone      string    `json:"phone,omitempty"`
+	Default    bool      `json:"default"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// addressRules are the fields required by the postal service of a country.
+type addressRules struct {
+	postalCode bool
+	region     bool
+	// postalCodePattern validates the postal code when it is set
+	postalCodePattern *regexp.Regexp
+}
+
+// defaultAddressRules apply to the countries which are not listed below.
+var defaultAddressRules = addressRules{postalCode: true}
+
+var countryAddressRules = map[string]addressRules{
+	"FR": {postalCode: true, postalCodePattern: regexp.MustCompile(`^\d{5}$`)},
+	"DE": {postalCode: true, postalCodePattern: regexp.MustCompile(`^\d{5}$`)},
+	"ES": {postalCode: true, postalCodePattern: regexp.MustCompile(`^\d{5}$`)},
+	"IT": {postalCode: true, postalCodePattern: regexp.MustCompile(`^\d{5}$`)},
+	"BE": {postalCode: true, postalCodePattern: regexp.MustCompile(`^\d{4}$`)},
+	"NL": {postalCode: true, postalCodePattern: regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`)},
+	"GB": {postalCode: true, postalCodePattern: regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`)},
+	"US": {postalCode: true, region: true, postalCodePattern: regexp.MustCompile(`^\d{5}(-\d{4})?$`)},
+	"CA": {postalCode: true, region: true, postalCodePattern: regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`)},
+	// no postal code in use
+	"IE": {},
+	"HK": {},
+	"AE": {},
+}
+
+// Normalize trims the fields of the address and puts its codes in upper case.
+func (a *Address) Normalize() {
+	a.Type = strings.TrimSpace(a.Type)
+	if a.Type == "" {
+		a.Type = AddressBoth
+	}
+	a.FirstName = strings.TrimSpace(a.FirstName)
+	a.LastName = strings.TrimSpace(a.LastName)
+	a.Line1 = strings.TrimSpace(a.Line1)
+	a.Line2 = strings.TrimSpace(a.Line2)
+	a.City = strings.TrimSpace(a.City)
+	a.PostalCode = strings.ToUpper(strings.TrimSpace(a.PostalCode))
+	a.Region = strings.TrimSpace(a.Region)
+	a.Country = NormalizeCountry(a.Country)
+}
+
+// Validate checks the address has the fields required in its country.
+func (a Address) Validate() error {
+	if a.Type != AddressShipping && a.Type != AddressBilling && a.Type != AddressBoth {
+		return fmt.Errorf("unknown address type: %q", a.Type)
+	}
+	if a.FirstName == "" || a.LastName == "" {
+		return errors.New("firstName and lastName are mandatory")
+	}
+	if a.Line1 == "" || a.City == "" {
+		return errors.New("line1 and city are mandatory")
+	}
+	if len(a.Country) != 2 {
+		return fmt.Errorf("invalid country code: %q", a.Country)
+	}
+
+	rules, found := countryAddressRules[a.Country]
+	if !found {
+		rules = defaultAddressRules
+	}
+	if rules.postalCode && a.PostalCode == "" {
+		return fmt.Errorf("postalCode is mandatory in %s", a.Country)
+	}
+	if rules.region && a.Region == "" {
+		return fmt.Errorf("region is mandatory in %s", a.Country)
+	}
+	if rules.postalCodePattern != nil && a.PostalCode != "" && !rules.postalCodePattern.MatchString(a.PostalCode) {
+		return fmt.Errorf("invalid postalCode for %s: %q", a.Country, a.PostalCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddress_Validate(t *testing.T) {
+	valid := func() Address {
+		return Address{
+			Type:       AddressShipping,
+			FirstName:  "Adil",
+			LastName:   "Doe",
+			Line1:      "10 rue de Rivoli",
+			City:       "Paris",
+			PostalCode: "75001",
+			Country:    "FR",
+		}
+	}
+
+	t.Run("nominal", func(t *testing.T) {
+		a := valid()
+		assert.NoError(t, a.Validate())
+	})
+
+	t.Run("invalid postal code for the country", func(t *testing.T) {
+		a := valid()
+		a.PostalCode = "7500"
+		assert.EqualError(t, a.Validate(), `invalid postalCode for FR: "7500"`)
+	})
+
+	t.Run("region is mandatory in the US", func(t *testing.T) {
+		a := valid()
+		a.Country = "US"
+		a.PostalCode = "10001"
+		assert.EqualError(t, a.Validate(), "region is mandatory in US")
+	})
+
+	t.Run("no postal code in Ireland", func(t *testing.T) {
+		a := valid()
+		a.Country = "IE"
+		a.PostalCode = ""
+		assert.NoError(t, a.Validate())
+	})
+
+	t.Run("postal code is mandatory in unlisted countries", func(t *testing.T) {
+		a := valid()
+		a.Country = "PT"
+		a.PostalCode = ""
+		assert.EqualError(t, a.Validate(), "postalCode is mandatory in PT")
+	})
+
+	t.Run("normalized country and type", func(t *testing.T) {
+		a := valid()
+		a.Type = ""
+		a.Country = " gb "
+		a.PostalCode = "sw1a 1aa"
+
+		a.Normalize()
+
+		assert.Equal(t, AddressBoth, a.Type)
+		assert.Equal(t, "GB", a.Country)
+		assert.NoError(t, a.Validate())
+	})
+}
@@ -1,25 +1,79 @@
 package types
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/Rhymond/go-money"
 )
 
+// ErrorCurrencyMismatch is returned when a product priced in another
+// currency than the cart is added to it.
+var ErrorCurrencyMismatch = errors.New("the product is not priced in the currency of the cart")
+
 type Cart struct {
 	ID           string          `json:"id"`
 	CurrencyCode string          `json:"currencyCode"`
 	Items        map[string]Item `json:"items"`
 	Version      uint            `json:"version"`
+	Coupons      []string        `json:"coupons,omitempty"`
+	// selected delivery
+	ShippingCountry  string `json:"shippingCountry,omitempty"`
+	ShippingMethodID string `json:"shippingMethodId,omitempty"`
+	// UpdatedAt is the last time the cart was written, the guest carts
+	// expire some time after it
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	// Totals are computed when the cart is returned, they are never stored
+	Totals *CartTotals `json:"totals,omitempty" dynamodbav:"-"`
+	// Suggestions are products to add to the cart, they are never stored
+	Suggestions []Product `json:"suggestions,omitempty" dynamodbav:"-"`
 }
 
 type Item struct {
 	ID               string       `json:"id"`
 	ShortDescription string       `json:"shortDescription"`
-	Quantity         uint8        `json:"quantity"`
+	Quantity         uint         `json:"quantity"`
 	UnitPriceVATExc  *money.Money `json:"unitPriceVATExc"`
 	VAT              *money.Money `json:"vat"`
 	UnitPriceVATInc  *money.Money `json:"unitPriceVATInc"`
+	// units waiting for a restock and when they are expected to ship
+	Backordered      uint        `json:"backordered,omitempty"`
+	ExpectedShipDate *time.Time  `json:"expectedShipDate,omitempty"`
+	CategoryIDs      []string    `json:"categoryIds,omitempty"`
+	Weight           uint        `json:"weight,omitempty"`
+	Dimensions       *Dimensions `json:"dimensions,omitempty"`
+	// DisplayUnitPrice is the unit price formatted for the locale of the
+	// request, it is never stored
+	DisplayUnitPrice string `json:"displayUnitPrice,omitempty" dynamodbav:"-"`
+}
+
+// CartLimits are the limits applied to every cart.
+type CartLimits struct {
+	// MaxDistinctItems is the maximum number of different products in a
+	// cart, 0 means the most a storage transaction holds
+	MaxDistinctItems int
+}
+
+// LimitError is returned when a cart update breaks a quantity limit.
+type LimitError struct {
+	Limit     string `json:"limit"`
+	Value     uint   `json:"value"`
+	ProductID string `json:"productId,omitempty"`
+}
+
+const (
+	LimitMinPerOrder      = "minPerOrder"
+	LimitMaxPerOrder      = "maxPerOrder"
+	LimitMaxDistinctItems = "maxDistinctItems"
+)
+
+func (e *LimitError) Error() string {
+	if e.ProductID == "" {
+		return fmt.Sprintf("%s limit of %d reached", e.Limit, e.Value)
+	}
+	return fmt.Sprintf("%s limit of %d reached for product %s", e.Limit, e.Value, e.ProductID)
 }
 
 type UpdateUserCartInput struct {
@@ -27,6 +81,16 @@ type UpdateUserCartInput struct {
 	Delta     int    `json:"delta"`
 }
 
+type SetCartItemQuantityInput struct {
+	Quantity int `json:"quantity"`
+}
+
+// UpdatedBefore returns true if the cart was last written before t, the
+// carts written before they were dated count as such.
+func (c Cart) UpdatedBefore(t time.Time) bool {
+	return c.UpdatedAt == nil || c.UpdatedAt.Before(t)
+}
+
 func (c Cart) TotalPriceVATInc() (*money.Money, error) {
 	totalPrice := money.New(0, c.CurrencyCode)
 	for _, item := range c.Items {
@@ -40,6 +104,56 @@ func (c Cart) TotalPriceVATInc() (*money.Money, error) {
 	return totalPrice, nil
 }
 
+// PriceItems fills the items of the cart with the current details and price
+// of their product, items without a known product are left untouched. A
+// cart stored without a currency takes the one of its first product.
+func (c *Cart) PriceItems(products map[string]Product) {
+	ids := make([]string, 0, len(c.Items))
+	for id := range c.Items {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		item := c.Items[id]
+		p, found := products[id]
+		if !found {
+			continue
+		}
+		if c.CurrencyCode == "" {
+			c.CurrencyCode = p.TotalPrice.Currency
+		}
+		item.ShortDescription = p.ShortDescription
+		item.CategoryIDs = p.CategoryIDs
+		item.Weight = p.Weight
+		item.Dimensions = p.Dimensions
+		item.UnitPriceVATExc = money.New(p.PriceVATExcluded.Amount, p.PriceVATExcluded.Currency)
+		item.VAT = money.New(p.VAT.Amount, p.VAT.Currency)
+		item.UnitPriceVATInc = money.New(p.TotalPrice.Amount, p.TotalPrice.Currency)
+		c.Items[id] = item
+	}
+}
+
+// Format sets the display prices of the priced cart for the locale.
+func (c *Cart) Format(locale string) {
+	for id, item := range c.Items {
+		item.DisplayUnitPrice = FormatMoney(item.UnitPriceVATInc, locale)
+		c.Items[id] = item
+	}
+	if c.Totals != nil {
+		c.Totals.DisplayTotalVATInc = FormatMoney(c.Totals.TotalVATInc, locale)
+	}
+}
+
+// HasCoupon returns true if the coupon code is applied to the cart.
+func (c Cart) HasCoupon(code string) bool {
+	for _, applied := range c.Coupons {
+		if applied == code {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Cart) UpsertItem(productID string, delta int) error {
 
 	if c.Items == nil {
@@ -54,7 +168,7 @@ func (c *Cart) UpsertItem(productID string, delta int) error {
 		}
 		c.Items[productID] = Item{
 			ID:       productID,
-			Quantity: uint8(delta),
+			Quantity: uint(delta),
 		}
 	} else {
 		// a product with this id is already in the cart
@@ -62,14 +176,59 @@ func (c *Cart) UpsertItem(productID string, delta int) error {
 		if newQuantity < 0 {
 			return fmt.Errorf("error - new quantity cannot be less than zero")
 		} else if newQuantity > 0 {
-			item.Quantity = uint8(newQuantity)
+			item.Quantity = uint(newQuantity)
 			c.Items[productID] = item
 		} else {
 			// equal to zero
 			// we need to remove from the cart
 			delete(c.Items, productID)
+			// an empty cart takes the currency of the next product
+			if len(c.Items) == 0 {
+				c.CurrencyCode = ""
+			}
 		}
 	}
 
 	return nil
 }
+
+// CheckCurrency sets the currency of the cart to the one of the product
+// added when the cart has none, another currency is refused.
+func (c *Cart) CheckCurrency(p Product) error {
+	if c.CurrencyCode == "" {
+		c.CurrencyCode = p.TotalPrice.Currency
+		return nil
+	}
+	if p.TotalPrice.Currency != c.CurrencyCode {
+		return fmt.Errorf("%w: %s is priced in %s, the cart in %s", ErrorCurrencyMismatch, p.ID, p.TotalPrice.Currency, c.CurrencyCode)
+	}
+	return nil
+}
+
+// CheckDistinctItems verifies that the number of products in the cart is
+// within the limits.
+func (c Cart) CheckDistinctItems(limits CartLimits) error {
+	if limits.MaxDistinctItems > 0 && len(c.Items) > limits.MaxDistinctItems {
+		return &LimitError{Limit: LimitMaxDistinctItems, Value: uint(limits.MaxDistinctItems)}
+	}
+	return nil
+}
+
+// CheckLimits verifies that the quantity of a product in the cart is within
+// the limits of the product. The maximum only applies when the quantity
+// grows from previousQuantity, an item above a lowered maximum can still be
+// reduced.
+func (c Cart) CheckLimits(p Product, previousQuantity uint) error {
+	item, found := c.Items[p.ID]
+	if !found {
+		return nil
+	}
+	if p.MinPerOrder > 0 && item.Quantity < p.MinPerOrder {
+		return &LimitError{Limit: LimitMinPerOrder, Value: p.MinPerOrder, ProductID: p.ID}
+	}
+	if p.MaxPerOrder > 0 && item.Quantity > p.MaxPerOrder && item.Quantity > previousQuantity {
+		return &LimitError{Limit: LimitMaxPerOrder, Value: p.MaxPerOrder, ProductID: p.ID}
+	}
+
+	return nil
+}
@@ -94,3 +94,72 @@ func TestCart_TotalPriceVATInc(t *testing.T) {
 		assert.Error(t, err, "when I add an item with a currency X to a basket of currency Y the method TotalPriceVATInc should fail")
 	})
 }
+
+func TestCart_UpsertItem(t *testing.T) {
+	t.Run("quantity greater than 255 does not wrap", func(t *testing.T) {
+		// given
+		cart := Cart{ID: "42", CurrencyCode: "EUR"}
+
+		// when
+		err := cart.UpsertItem("43", 200)
+		assert.NoError(t, err, "adding a new item should not fail")
+		err = cart.UpsertItem("43", 100)
+
+		// then
+		assert.NoError(t, err, "adding to an existing item should not fail")
+		assert.Equal(t, uint(300), cart.Items["43"].Quantity)
+	})
+}
+
+func TestCart_CheckCurrency(t *testing.T) {
+	// given
+	cart := Cart{}
+	assert.NoError(t, cart.CheckCurrency(Product{ID: "42", TotalPrice: Money{Amount: 100, Currency: "EUR"}}))
+
+	// when
+	err := cart.CheckCurrency(Product{ID: "43", TotalPrice: Money{Amount: 100, Currency: "USD"}})
+
+	// then
+	assert.ErrorIs(t, err, ErrorCurrencyMismatch)
+	assert.Equal(t, "EUR", cart.CurrencyCode, "the first product sets the currency")
+}
+
+func TestCart_CheckLimits(t *testing.T) {
+	cart := Cart{
+		ID: "42",
+		Items: map[string]Item{
+			"43": {ID: "43", Quantity: 3},
+			"44": {ID: "44", Quantity: 1},
+		},
+	}
+
+	t.Run("within limits", func(t *testing.T) {
+		err := cart.CheckLimits(Product{ID: "43", MinPerOrder: 1, MaxPerOrder: 3}, 2)
+
+		assert.NoError(t, err, "a quantity within the limits should not fail")
+	})
+
+	t.Run("max per order", func(t *testing.T) {
+		err := cart.CheckLimits(Product{ID: "43", MaxPerOrder: 2}, 2)
+
+		assert.Equal(t, &LimitError{Limit: LimitMaxPerOrder, Value: 2, ProductID: "43"}, err)
+	})
+
+	t.Run("reduced above a lowered max per order", func(t *testing.T) {
+		err := cart.CheckLimits(Product{ID: "43", MaxPerOrder: 2}, 4)
+
+		assert.NoError(t, err, "the quantity goes toward the max")
+	})
+
+	t.Run("min per order", func(t *testing.T) {
+		err := cart.CheckLimits(Product{ID: "44", MinPerOrder: 2}, 0)
+
+		assert.Equal(t, &LimitError{Limit: LimitMinPerOrder, Value: 2, ProductID: "44"}, err)
+	})
+
+	t.Run("max distinct items", func(t *testing.T) {
+		err := cart.CheckDistinctItems(CartLimits{MaxDistinctItems: 1})
+
+		assert.Equal(t, &LimitError{Limit: LimitMaxDistinctItems, Value: 1}, err)
+	})
+}
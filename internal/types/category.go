@@ -4,4 +4,7 @@ type Category struct {
 	ID          string `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	// Translations of the content by locale, the fields above are the
+	// content in the default locale
+	Translations map[string]CategoryTranslation `json:"translations,omitempty"`
 }
@@ -0,0 +1,14 @@
+package types
+
+import "time"
+
+// UserExport is the archive of every personal data held on a user.
+type UserExport struct {
+	ExportedAt time.Time      `json:"exportedAt"`
+	UserID     string         `json:"userId"`
+	Profile    *Profile       `json:"profile"`
+	Addresses  []Address      `json:"addresses"`
+	Cart       *Cart          `json:"cart"`
+	Wishlist   []WishlistItem `json:"wishlist"`
+	Reviews    []Review       `json:"reviews"`
+}
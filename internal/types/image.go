@@ -0,0 +1,104 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxProductImages is the size limit of a product gallery
+const MaxProductImages = 12
+
+// maxAltLength bounds the alternative text of an image
+const maxAltLength = 250
+
+// ProductImage is an image of the product gallery, stored in the object
+// store with its renditions.
+type ProductImage struct {
+	ID          string `json:"id"`
+	Key         string `json:"key"`
+	URL         string `json:"url"`
+	Alt         string `json:"alt"`
+	ContentType string `json:"contentType"`
+	Size        int    `json:"size"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	// Renditions by name, e.g. thumbnail and medium
+	Renditions map[string]ImageRendition `json:"renditions,omitempty"`
+}
+
+// ImageRendition is a resized copy of a product image.
+type ImageRendition struct {
+	Key    string `json:"key"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// ImageUploadInput is the image a client is about to upload.
+type ImageUploadInput struct {
+	ContentType string `json:"contentType"`
+	Size        int    `json:"size"`
+}
+
+// ImageUpload is the presigned URL the client uploads the image to, the key
+// is then sent back to add the image to the gallery.
+type ImageUpload struct {
+	Key       string `json:"key"`
+	UploadURL string `json:"uploadUrl"`
+	Method    string `json:"method"`
+	// Headers the upload request must send
+	Headers map[string]string `json:"headers"`
+}
+
+// AddImageInput adds an uploaded image to the gallery.
+type AddImageInput struct {
+	Key string `json:"key"`
+	Alt string `json:"alt"`
+}
+
+func (i AddImageInput) Validate() error {
+	if i.Key == "" {
+		return errors.New("key is required")
+	}
+	return validateAlt(i.Alt)
+}
+
+// ImageOrder is an image of the reordered gallery.
+type ImageOrder struct {
+	ID  string `json:"id"`
+	Alt string `json:"alt"`
+}
+
+// Reorder returns the gallery in the given order with the new alt texts,
+// the order must list every image exactly once.
+func Reorder(images []ProductImage, order []ImageOrder) ([]ProductImage, error) {
+	if len(order) != len(images) {
+		return nil, errors.New("order must list every image of the product")
+	}
+	byID := make(map[string]ProductImage, len(images))
+	for _, img := range images {
+		byID[img.ID] = img
+	}
+
+	reordered := make([]ProductImage, 0, len(order))
+	for _, o := range order {
+		img, found := byID[o.ID]
+		if !found {
+			return nil, fmt.Errorf("unknown or duplicated image %s", o.ID)
+		}
+		if err := validateAlt(o.Alt); err != nil {
+			return nil, err
+		}
+		delete(byID, o.ID)
+		img.Alt = o.Alt
+		reordered = append(reordered, img)
+	}
+	return reordered, nil
+}
+
+func validateAlt(alt string) error {
+	if len([]rune(alt)) > maxAltLength {
+		return fmt.Errorf("alt cannot be longer than %d characters", maxAltLength)
+	}
+	return nil
+}
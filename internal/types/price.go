@@ -0,0 +1,268 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ReferencePeriod is the period before a price reduction whose lowest price
+// must be displayed as the reference price
+const ReferencePeriod = 30 * 24 * time.Hour
+
+var ErrorPriceNotFound = errors.New("scheduled price not found")
+
+// ScheduledPrice replaces the regular price of a product from StartsAt,
+// until EndsAt for a sale or for good when EndsAt is nil. When several
+// prices apply, the one that started last wins.
+type ScheduledPrice struct {
+	ID               string     `json:"id"`
+	PriceVATExcluded Money      `json:"priceVatExcluded"`
+	VAT              Money      `json:"vat"`
+	TotalPrice       Money      `json:"totalPrice"`
+	StartsAt         time.Time  `json:"startsAt"`
+	EndsAt           *time.Time `json:"endsAt,omitempty"`
+}
+
+// PriceHistoryEntry records a price of a product and the period it was
+// scheduled for, the entries are never deleted.
+type PriceHistoryEntry struct {
+	ID               string     `json:"id"`
+	ProductID        string     `json:"productId"`
+	PriceVATExcluded Money      `json:"priceVatExcluded"`
+	VAT              Money      `json:"vat"`
+	TotalPrice       Money      `json:"totalPrice"`
+	StartsAt         time.Time  `json:"startsAt"`
+	EndsAt           *time.Time `json:"endsAt,omitempty"`
+	RecordedAt       time.Time  `json:"recordedAt"`
+	// CanceledAt is set when the price was canceled before it ended
+	CanceledAt *time.Time `json:"canceledAt,omitempty"`
+}
+
+// SchedulePriceInput schedules a price, it starts immediately when StartsAt
+// is nil.
+type SchedulePriceInput struct {
+	PriceVATExcluded Money      `json:"priceVatExcluded"`
+	VAT              Money      `json:"vat"`
+	TotalPrice       Money      `json:"totalPrice"`
+	StartsAt         *time.Time `json:"startsAt"`
+	EndsAt           *time.Time `json:"endsAt"`
+}
+
+// Validate checks the input against the product it prices.
+func (i SchedulePriceInput) Validate(p Product, now time.Time) error {
+	for _, m := range []Money{i.PriceVATExcluded, i.VAT, i.TotalPrice} {
+		if m.Amount < 0 {
+			return errors.New("prices cannot be negative")
+		}
+		if m.Currency != p.TotalPrice.Currency {
+			return fmt.Errorf("prices must be in %s", p.TotalPrice.Currency)
+		}
+	}
+	if i.PriceVATExcluded.Amount+i.VAT.Amount != i.TotalPrice.Amount {
+		return errors.New("totalPrice must be priceVatExcluded plus vat")
+	}
+	if i.EndsAt != nil {
+		if !i.EndsAt.After(now) {
+			return errors.New("endsAt must be in the future")
+		}
+		if i.StartsAt != nil && !i.EndsAt.After(*i.StartsAt) {
+			return errors.New("endsAt must be after startsAt")
+		}
+	}
+	return nil
+}
+
+// ScheduledPrice builds the scheduled price of the input.
+func (i SchedulePriceInput) ScheduledPrice(id string, now time.Time) ScheduledPrice {
+	startsAt := now
+	if i.StartsAt != nil && i.StartsAt.After(now) {
+		startsAt = *i.StartsAt
+	}
+	return ScheduledPrice{
+		ID:               id,
+		PriceVATExcluded: i.PriceVATExcluded,
+		VAT:              i.VAT,
+		TotalPrice:       i.TotalPrice,
+		StartsAt:         startsAt,
+		EndsAt:           i.EndsAt,
+	}
+}
+
+// HistoryEntry returns the history record of the scheduled price.
+func (s ScheduledPrice) HistoryEntry(productID string, now time.Time) PriceHistoryEntry {
+	return PriceHistoryEntry{
+		ID:               s.ID,
+		ProductID:        productID,
+		PriceVATExcluded: s.PriceVATExcluded,
+		VAT:              s.VAT,
+		TotalPrice:       s.TotalPrice,
+		StartsAt:         s.StartsAt,
+		EndsAt:           s.EndsAt,
+		RecordedAt:       now,
+	}
+}
+
+func (s ScheduledPrice) activeAt(t time.Time) bool {
+	return !s.StartsAt.After(t) && (s.EndsAt == nil || t.Before(*s.EndsAt))
+}
+
+// priceAt returns the scheduled price applying at t, nil when the regular
+// price applies.
+func (p Product) priceAt(t time.Time) *ScheduledPrice {
+	var current *ScheduledPrice
+	for i := range p.PriceSchedule {
+		s := &p.PriceSchedule[i]
+		if s.activeAt(t) && (current == nil || !s.StartsAt.Before(current.StartsAt)) {
+			current = s
+		}
+	}
+	return current
+}
+
+// TotalPriceAt returns the total price applying at t, sales included.
+func (p Product) TotalPriceAt(t time.Time) Money {
+	if s := p.priceAt(t); s != nil {
+		return s.TotalPrice
+	}
+	return p.TotalPrice
+}
+
+// RegularPrice returns the price applying at t once the sales are left
+// out, the ID is empty when it is the regular price of the product.
+func (p Product) RegularPrice(t time.Time) ScheduledPrice {
+	regular := ScheduledPrice{
+		PriceVATExcluded: p.PriceVATExcluded,
+		VAT:              p.VAT,
+		TotalPrice:       p.TotalPrice,
+	}
+	for _, s := range p.PriceSchedule {
+		if s.EndsAt == nil && s.activeAt(t) && (regular.ID == "" || !s.StartsAt.Before(regular.StartsAt)) {
+			regular = s
+		}
+	}
+	return regular
+}
+
+// LowestPrice returns the lowest total price of the product between from
+// and to.
+func (p Product) LowestPrice(from time.Time, to time.Time) Money {
+	lowest := p.TotalPriceAt(from)
+	for _, s := range p.PriceSchedule {
+		for _, t := range []*time.Time{&s.StartsAt, s.EndsAt} {
+			if t == nil || !t.After(from) || !t.Before(to) {
+				continue
+			}
+			if price := p.TotalPriceAt(*t); price.Amount < lowest.Amount {
+				lowest = price
+			}
+		}
+	}
+	return lowest
+}
+
+// ApplyPrice sets the prices of the product to the ones applying now. The
+// reference price is the lowest price of the ReferencePeriod before the
+// current scheduled price started, or before now for the regular price.
+// The schedule is dropped from the product.
+func (p *Product) ApplyPrice(now time.Time) {
+	current := p.priceAt(now)
+	since := now
+	if current != nil {
+		since = current.StartsAt
+	}
+	if p.TotalPrice.Currency != "" {
+		reference := p.LowestPrice(since.Add(-ReferencePeriod), since)
+		p.ReferencePrice = &reference
+	}
+	if current != nil {
+		p.PriceVATExcluded = current.PriceVATExcluded
+		p.VAT = current.VAT
+		p.TotalPrice = current.TotalPrice
+		p.PriceEndsAt = current.EndsAt
+	}
+	p.PriceSchedule = nil
+}
+
+// SchedulePrice adds a price to the schedule of the product.
+func (p *Product) SchedulePrice(s ScheduledPrice, now time.Time) {
+	p.PriceSchedule = append(p.PriceSchedule, s)
+	p.pruneSchedule(now)
+}
+
+// CancelScheduledPrice removes a price that has not started yet or ends a
+// running one now, it returns the canceled price.
+func (p *Product) CancelScheduledPrice(id string, now time.Time) (ScheduledPrice, error) {
+	for i, s := range p.PriceSchedule {
+		if s.ID != id {
+			continue
+		}
+		if s.StartsAt.After(now) {
+			p.PriceSchedule = append(p.PriceSchedule[:i], p.PriceSchedule[i+1:]...)
+			return s, nil
+		}
+		if s.EndsAt != nil && !now.Before(*s.EndsAt) {
+			return ScheduledPrice{}, errors.New("the price already ended")
+		}
+		end := now
+		p.PriceSchedule[i].EndsAt = &end
+		p.pruneSchedule(now)
+		return s, nil
+	}
+	return ScheduledPrice{}, ErrorPriceNotFound
+}
+
+// pruneSchedule keeps the schedule small: the permanent prices started
+// before the reference period become the regular price and the prices that
+// can no longer apply are dropped. The history keeps all of them.
+func (p *Product) pruneSchedule(now time.Time) {
+	// the last permanent price started overrides the ones before it for good
+	var latest *ScheduledPrice
+	for i := range p.PriceSchedule {
+		s := &p.PriceSchedule[i]
+		if s.EndsAt == nil && !s.StartsAt.After(now) && (latest == nil || !s.StartsAt.Before(latest.StartsAt)) {
+			latest = s
+		}
+	}
+	// the reference of any price that can still apply must be computable
+	horizon := now
+	for _, s := range p.PriceSchedule {
+		if latest != nil && s.StartsAt.Before(latest.StartsAt) {
+			continue
+		}
+		if (s.activeAt(now) || s.StartsAt.After(now)) && s.StartsAt.Before(horizon) {
+			horizon = s.StartsAt
+		}
+	}
+	horizon = horizon.Add(-ReferencePeriod)
+
+	var regular *ScheduledPrice
+	for i := range p.PriceSchedule {
+		s := &p.PriceSchedule[i]
+		if s.EndsAt == nil && !s.StartsAt.After(horizon) && (regular == nil || !s.StartsAt.Before(regular.StartsAt)) {
+			regular = s
+		}
+	}
+
+	kept := make([]ScheduledPrice, 0, len(p.PriceSchedule))
+	for _, s := range p.PriceSchedule {
+		if regular != nil && !s.StartsAt.After(regular.StartsAt) {
+			// never applies again, the regular price started after it
+			continue
+		}
+		if s.EndsAt != nil && !s.EndsAt.After(horizon) {
+			continue
+		}
+		kept = append(kept, s)
+	}
+	if regular != nil {
+		p.PriceVATExcluded = regular.PriceVATExcluded
+		p.VAT = regular.VAT
+		p.TotalPrice = regular.TotalPrice
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].StartsAt.Before(kept[j].StartsAt)
+	})
+	p.PriceSchedule = kept
+}
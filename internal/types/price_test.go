@@ -0,0 +1,130 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func eur(amount int64) Money {
+	return Money{Amount: amount, Currency: "EUR"}
+}
+
+func scheduled(id string, total int64, startsAt time.Time, endsAt *time.Time) ScheduledPrice {
+	return ScheduledPrice{ID: id, PriceVATExcluded: eur(total), VAT: eur(0), TotalPrice: eur(total), StartsAt: startsAt, EndsAt: endsAt}
+}
+
+func TestProduct_ApplyPrice(t *testing.T) {
+	now := time.Date(2023, 6, 15, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	saleEnd := now.Add(2 * day)
+
+	t.Run("applies the running sale with the lowest price before it", func(t *testing.T) {
+		// Given
+		p := Product{TotalPrice: eur(1000), PriceSchedule: []ScheduledPrice{
+			scheduled("change", 900, now.Add(-20*day), nil),
+			scheduled("sale", 700, now.Add(-day), &saleEnd),
+		}}
+
+		// When
+		p.ApplyPrice(now)
+
+		// Then
+		assert.Equal(t, eur(700), p.TotalPrice)
+		assert.Equal(t, &saleEnd, p.PriceEndsAt)
+		assert.Equal(t, eur(900), *p.ReferencePrice, "lowest of the 30 days before the sale")
+		assert.Nil(t, p.PriceSchedule)
+	})
+
+	t.Run("reverts to the previous price after the sale", func(t *testing.T) {
+		p := Product{TotalPrice: eur(1000), PriceSchedule: []ScheduledPrice{
+			scheduled("change", 900, now.Add(-20*day), nil),
+			scheduled("sale", 700, now.Add(-day), &saleEnd),
+		}}
+
+		p.ApplyPrice(now.Add(3 * day))
+
+		assert.Equal(t, eur(900), p.TotalPrice)
+		assert.Nil(t, p.PriceEndsAt)
+		assert.Equal(t, eur(1000), *p.ReferencePrice, "lowest of the 30 days before the 900 price started")
+	})
+
+	t.Run("ignores future prices", func(t *testing.T) {
+		start := now.Add(day)
+		p := Product{TotalPrice: eur(1000), PriceSchedule: []ScheduledPrice{scheduled("sale", 500, start, nil)}}
+
+		p.ApplyPrice(now)
+
+		assert.Equal(t, eur(1000), p.TotalPrice)
+		assert.Equal(t, eur(1000), *p.ReferencePrice)
+	})
+}
+
+func TestProduct_SchedulePrice(t *testing.T) {
+	now := time.Date(2023, 6, 15, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	oldSaleEnd := now.Add(-50 * day)
+
+	// Given
+	p := Product{TotalPrice: eur(1000), PriceSchedule: []ScheduledPrice{
+		scheduled("old-sale", 500, now.Add(-60*day), &oldSaleEnd),
+		scheduled("old-change", 800, now.Add(-40*day), nil),
+		scheduled("recent-change", 900, now.Add(-10*day), nil),
+	}}
+
+	// When
+	p.SchedulePrice(scheduled("next", 850, now.Add(day), nil), now)
+
+	// Then
+	assert.Equal(t, eur(800), p.TotalPrice, "the change older than 30 days becomes the regular price")
+	ids := make([]string, 0)
+	for _, s := range p.PriceSchedule {
+		ids = append(ids, s.ID)
+	}
+	assert.Equal(t, []string{"recent-change", "next"}, ids)
+}
+
+func TestProduct_CancelScheduledPrice(t *testing.T) {
+	now := time.Date(2023, 6, 15, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	saleEnd := now.Add(2 * day)
+
+	t.Run("removes a future price", func(t *testing.T) {
+		p := Product{TotalPrice: eur(1000), PriceSchedule: []ScheduledPrice{scheduled("sale", 500, now.Add(day), nil)}}
+
+		_, err := p.CancelScheduledPrice("sale", now)
+
+		assert.NoError(t, err)
+		assert.Empty(t, p.PriceSchedule)
+	})
+
+	t.Run("ends a running sale now", func(t *testing.T) {
+		p := Product{TotalPrice: eur(1000), PriceSchedule: []ScheduledPrice{scheduled("sale", 500, now.Add(-day), &saleEnd)}}
+
+		_, err := p.CancelScheduledPrice("sale", now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, now, *p.PriceSchedule[0].EndsAt)
+		p.ApplyPrice(now)
+		assert.Equal(t, eur(1000), p.TotalPrice)
+		assert.Equal(t, eur(500), *p.ReferencePrice)
+	})
+
+	t.Run("fails for an unknown price", func(t *testing.T) {
+		p := Product{}
+		_, err := p.CancelScheduledPrice("sale", now)
+		assert.ErrorIs(t, err, ErrorPriceNotFound)
+	})
+}
+
+func TestSchedulePriceInput_Validate(t *testing.T) {
+	now := time.Date(2023, 6, 15, 12, 0, 0, 0, time.UTC)
+	p := Product{TotalPrice: eur(1000)}
+	past := now.Add(-time.Hour)
+
+	assert.NoError(t, SchedulePriceInput{PriceVATExcluded: eur(800), VAT: eur(160), TotalPrice: eur(960)}.Validate(p, now))
+	assert.Error(t, SchedulePriceInput{PriceVATExcluded: eur(800), VAT: eur(160), TotalPrice: eur(900)}.Validate(p, now))
+	assert.Error(t, SchedulePriceInput{PriceVATExcluded: Money{Amount: 800, Currency: "USD"}, VAT: eur(0), TotalPrice: eur(800)}.Validate(p, now))
+	assert.Error(t, SchedulePriceInput{PriceVATExcluded: eur(800), VAT: eur(0), TotalPrice: eur(800), EndsAt: &past}.Validate(p, now))
+}
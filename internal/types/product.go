@@ -1,25 +1,225 @@
 package types
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/Rhymond/go-money"
 )
 
 type Product struct {
-	ID               string `json:"id"`
-	Name             string `json:"name"`
-	Image            string `json:"image"`
-	ShortDescription string `json:"shortDescription"`
-	Description      string `json:"description"`
-	PriceVATExcluded Money  `json:"priceVatExcluded"`
-	VAT              Money  `json:"vat"`
-	TotalPrice       Money  `json:"totalPrice"`
+	ID string `json:"id"`
+	// SKU is the stable reference of the product in the supplier feeds
+	SKU   string `json:"sku,omitempty"`
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	// Images is the ordered gallery, Image is the url of the first one
+	Images           []ProductImage `json:"images,omitempty"`
+	ShortDescription string         `json:"shortDescription"`
+	Description      string         `json:"description"`
+	// Translations of the content by locale, the fields above are the
+	// content in the default locale
+	Translations     map[string]ProductTranslation `json:"translations,omitempty"`
+	PriceVATExcluded Money                         `json:"priceVatExcluded"`
+	VAT              Money                         `json:"vat"`
+	TotalPrice       Money                         `json:"totalPrice"`
+	// PriceSchedule are the sales and price changes replacing the prices
+	// above, the regular prices
+	PriceSchedule []ScheduledPrice `json:"priceSchedule,omitempty"`
+	// ReferencePrice is the lowest recent price and PriceEndsAt the end of
+	// the current sale, both are computed when the product is returned
+	ReferencePrice *Money     `json:"referencePrice,omitempty" dynamodbav:"-"`
+	PriceEndsAt    *time.Time `json:"priceEndsAt,omitempty" dynamodbav:"-"`
+	CategoryIDs    []string   `json:"categoryIds,omitempty"`
+	// Relations are the products curated by the admins
+	Relations []ProductRelation `json:"relations,omitempty"`
+	// shipping, weight in grams
+	Weight     uint        `json:"weight,omitempty"`
+	Dimensions *Dimensions `json:"dimensions,omitempty"`
 	// inventory
 	Stock    uint `json:"stock"`
 	Reserved uint `json:"reserved"`
 	Version  uint `json:"version"`
+	// backorder / pre-order policy
+	Backorder    bool       `json:"backorder,omitempty"`
+	PreOrder     bool       `json:"preOrder,omitempty"`
+	MaxBackorder uint       `json:"maxBackorder,omitempty"` // 0 means no limit
+	AvailableAt  *time.Time `json:"availableAt,omitempty"`
+	Backordered  uint       `json:"backordered,omitempty"`
+	// quantity limits per order, 0 means no limit
+	MinPerOrder uint `json:"minPerOrder,omitempty"`
+	MaxPerOrder uint `json:"maxPerOrder,omitempty"`
+	// aggregates of the approved reviews
+	RatingCount uint `json:"ratingCount,omitempty"`
+	RatingTotal uint `json:"ratingTotal,omitempty"`
+	// Rating is the average rating, computed when the product is returned
+	Rating float64 `json:"rating,omitempty" dynamodbav:"-"`
+	// DisplayPrice is the total price formatted for the locale of the
+	// request, it is never stored
+	DisplayPrice string `json:"displayPrice,omitempty" dynamodbav:"-"`
+}
+
+// Validate checks the content and the prices of the product.
+func (p Product) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("name is required")
+	}
+	if money.GetCurrency(p.TotalPrice.Currency) == nil {
+		return fmt.Errorf("invalid currency %q", p.TotalPrice.Currency)
+	}
+	for _, m := range []Money{p.PriceVATExcluded, p.VAT, p.TotalPrice} {
+		if m.Amount < 0 {
+			return errors.New("prices cannot be negative")
+		}
+		if m.Currency != p.TotalPrice.Currency {
+			return errors.New("prices must be in the same currency")
+		}
+	}
+	if p.PriceVATExcluded.Amount+p.VAT.Amount != p.TotalPrice.Amount {
+		return errors.New("totalPrice must be priceVatExcluded plus vat")
+	}
+	return nil
 }
 
 type Amount struct {
 	Money   *money.Money `json:"money"`
 	Display string       `json:"display"`
 }
+
+// StockChange is the inventory state of a product after a reservation
+// has been applied to it.
+type StockChange struct {
+	Stock       uint
+	Reserved    uint
+	Backordered uint
+	// BackorderDelta is the number of units added to (positive) or removed
+	// from (negative) the backorder queue of the cart.
+	BackorderDelta int
+}
+
+// Backorder is a cart reservation that exceeds the physical stock of a
+// product and is waiting for a restock.
+type Backorder struct {
+	ProductID string    `json:"productId"`
+	CartID    string    `json:"cartId"`
+	Quantity  int       `json:"quantity"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AllowsBackorder returns true if reservations can exceed the physical stock.
+func (p Product) AllowsBackorder() bool {
+	return p.Backorder || p.PreOrder
+}
+
+// Available returns true if at least one unit of the product can be
+// reserved.
+func (p Product) Available() bool {
+	if p.Stock > 0 && !p.PreOrder {
+		return true
+	}
+	return p.AllowsBackorder() && (p.MaxBackorder == 0 || p.Backordered < p.MaxBackorder)
+}
+
+// Reserve computes the inventory of the product once delta units have been
+// reserved (delta > 0) or released (delta < 0) by a cart that currently
+// holds cartBackordered backordered units of this product.
+func (p Product) Reserve(delta int, cartBackordered uint) (StockChange, error) {
+	change := StockChange{
+		Stock:       p.Stock,
+		Reserved:    p.Reserved,
+		Backordered: p.Backordered,
+	}
+
+	if delta < 0 {
+		released := uint(-delta)
+		if released > p.Reserved {
+			return StockChange{}, fmt.Errorf("error - cannot release %d units, only %d are reserved", released, p.Reserved)
+		}
+		// backordered units are released first, they never hit the shelf
+		fromBackorder := released
+		if cartBackordered < fromBackorder {
+			fromBackorder = cartBackordered
+		}
+		if fromBackorder > p.Backordered {
+			fromBackorder = p.Backordered
+		}
+		change.Reserved -= released
+		change.Backordered -= fromBackorder
+		change.Stock += released - fromBackorder
+		change.BackorderDelta = -int(fromBackorder)
+		return change, nil
+	}
+
+	requested := uint(delta)
+	fromStock := requested
+	if p.PreOrder {
+		// pre-orders are never served from the shelf before release
+		fromStock = 0
+	} else if fromStock > p.Stock {
+		fromStock = p.Stock
+	}
+
+	toBackorder := requested - fromStock
+	if toBackorder > 0 {
+		if !p.AllowsBackorder() {
+			return StockChange{}, fmt.Errorf("error - negative quantity is not allowed, stock: %d, requested: %d", p.Stock, requested)
+		}
+		if p.MaxBackorder > 0 && p.Backordered+toBackorder > p.MaxBackorder {
+			return StockChange{}, fmt.Errorf("error - max backorder quantity reached, backordered: %d, requested: %d, max: %d", p.Backordered, toBackorder, p.MaxBackorder)
+		}
+	}
+
+	change.Stock -= fromStock
+	change.Reserved += requested
+	change.Backordered += toBackorder
+	change.BackorderDelta = int(toBackorder)
+	return change, nil
+}
+
+// AllocateBackorders distributes units to the backorders first-in-first-out.
+// It returns, in allocation order, the quantity given to each backorder and
+// the units left once every backorder has been served.
+func AllocateBackorders(backorders []Backorder, units uint) ([]Backorder, uint) {
+	sorted := make([]Backorder, len(backorders))
+	copy(sorted, backorders)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+	})
+
+	allocations := make([]Backorder, 0)
+	for _, b := range sorted {
+		if units == 0 {
+			break
+		}
+		if b.Quantity <= 0 {
+			continue
+		}
+		n := uint(b.Quantity)
+		if n > units {
+			n = units
+		}
+		allocation := b
+		allocation.Quantity = int(n)
+		allocations = append(allocations, allocation)
+		units -= n
+	}
+
+	return allocations, units
+}
+
+// Format sets the display price and the average rating of the product for
+// the locale.
+func (p *Product) Format(locale string) {
+	p.Rating = 0
+	if p.RatingCount > 0 {
+		p.Rating = math.Round(float64(p.RatingTotal)/float64(p.RatingCount)*10) / 10
+	}
+	if p.TotalPrice.Currency == "" {
+		return
+	}
+	p.DisplayPrice = FormatMoney(money.New(p.TotalPrice.Amount, p.TotalPrice.Currency), locale)
+}
@@ -0,0 +1,124 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProduct_Reserve(t *testing.T) {
+	t.Run("nominal from stock", func(t *testing.T) {
+		// given
+		p := Product{ID: "42", Stock: 10, Reserved: 2}
+
+		// when
+		change, err := p.Reserve(3, 0)
+
+		// then
+		assert.NoError(t, err, "reserving units in stock should not fail")
+		assert.Equal(t, StockChange{Stock: 7, Reserved: 5}, change)
+	})
+
+	t.Run("error when out of stock without backorder", func(t *testing.T) {
+		p := Product{ID: "42", Stock: 1}
+
+		_, err := p.Reserve(2, 0)
+
+		assert.Error(t, err, "reserving more than the stock should fail when backorders are not allowed")
+	})
+
+	t.Run("backorder beyond stock", func(t *testing.T) {
+		// given
+		p := Product{ID: "42", Stock: 1, Backorder: true, MaxBackorder: 5}
+
+		// when
+		change, err := p.Reserve(3, 0)
+
+		// then
+		assert.NoError(t, err, "reserving beyond the stock should be allowed on backorder")
+		assert.Equal(t, StockChange{Stock: 0, Reserved: 3, Backordered: 2, BackorderDelta: 2}, change)
+	})
+
+	t.Run("error when max backorder is reached", func(t *testing.T) {
+		p := Product{ID: "42", Backorder: true, MaxBackorder: 5, Backordered: 4}
+
+		_, err := p.Reserve(2, 0)
+
+		assert.Error(t, err, "reserving beyond the max backorder quantity should fail")
+	})
+
+	t.Run("pre-order never uses the stock", func(t *testing.T) {
+		p := Product{ID: "42", Stock: 10, PreOrder: true}
+
+		change, err := p.Reserve(2, 0)
+
+		assert.NoError(t, err, "pre-ordering should not fail")
+		assert.Equal(t, StockChange{Stock: 10, Reserved: 2, Backordered: 2, BackorderDelta: 2}, change)
+	})
+
+	t.Run("release backordered units first", func(t *testing.T) {
+		// given
+		p := Product{ID: "42", Stock: 0, Reserved: 4, Backorder: true, Backordered: 2}
+
+		// when
+		change, err := p.Reserve(-3, 2)
+
+		// then
+		assert.NoError(t, err, "releasing reserved units should not fail")
+		assert.Equal(t, StockChange{Stock: 1, Reserved: 1, Backordered: 0, BackorderDelta: -2}, change)
+	})
+}
+
+func TestAllocateBackorders(t *testing.T) {
+	t.Run("first in first out", func(t *testing.T) {
+		// given
+		now := time.Now()
+		backorders := []Backorder{
+			{ProductID: "42", CartID: "late", Quantity: 3, CreatedAt: now.Add(time.Hour)},
+			{ProductID: "42", CartID: "early", Quantity: 2, CreatedAt: now},
+			{ProductID: "42", CartID: "empty", Quantity: 0, CreatedAt: now.Add(-time.Hour)},
+		}
+
+		// when
+		allocations, left := AllocateBackorders(backorders, 4)
+
+		// then
+		assert.Equal(t, uint(0), left)
+		assert.Equal(t, []Backorder{
+			{ProductID: "42", CartID: "early", Quantity: 2, CreatedAt: now},
+			{ProductID: "42", CartID: "late", Quantity: 2, CreatedAt: now.Add(time.Hour)},
+		}, allocations)
+	})
+
+	t.Run("units left once every backorder is served", func(t *testing.T) {
+		backorders := []Backorder{{ProductID: "42", CartID: "a", Quantity: 1}}
+
+		allocations, left := AllocateBackorders(backorders, 5)
+
+		assert.Len(t, allocations, 1)
+		assert.Equal(t, uint(4), left)
+	})
+}
+
+func TestProduct_Validate(t *testing.T) {
+	eur := func(amount int64) Money { return Money{Amount: amount, Currency: "EUR"} }
+	valid := Product{Name: "Camera", PriceVATExcluded: eur(1000), VAT: eur(200), TotalPrice: eur(1200)}
+	assert.NoError(t, valid.Validate())
+
+	p := valid
+	p.Name = " "
+	assert.EqualError(t, p.Validate(), "name is required")
+
+	p = valid
+	p.PriceVATExcluded.Currency, p.VAT.Currency, p.TotalPrice.Currency = "XXY", "XXY", "XXY"
+	assert.EqualError(t, p.Validate(), `invalid currency "XXY"`)
+
+	p = valid
+	p.VAT = Money{Amount: 200, Currency: "USD"}
+	assert.EqualError(t, p.Validate(), "prices must be in the same currency")
+
+	p = valid
+	p.TotalPrice = eur(1300)
+	assert.EqualError(t, p.Validate(), "totalPrice must be priceVatExcluded plus vat")
+}
@@ -0,0 +1,133 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Rhymond/go-money"
+)
+
+// DefaultLocale is used when neither the profile nor the request tells the
+// locale of the user.
+const DefaultLocale = "en"
+
+var localePattern = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+
+type Profile struct {
+	UserID string `json:"userId"`
+	// Email and Name come from the identity provider
+	Email       string `json:"email,omitempty"`
+	Name        string `json:"name,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+	// Locale is a language tag (fr or fr-FR), Currency an ISO 4217 code
+	Locale             string     `json:"locale,omitempty"`
+	Currency           string     `json:"currency,omitempty"`
+	MarketingConsent   bool       `json:"marketingConsent"`
+	MarketingConsentAt *time.Time `json:"marketingConsentAt,omitempty"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+}
+
+type UpdateProfileInput struct {
+	DisplayName      *string `json:"displayName"`
+	Locale           *string `json:"locale"`
+	Currency         *string `json:"currency"`
+	MarketingConsent *bool   `json:"marketingConsent"`
+}
+
+// Preferences are the locale and currency used to price and format the
+// responses of a request.
+type Preferences struct {
+	Locale   string
+	Currency string
+}
+
+// NormalizeLocale returns the canonical form of a language tag (fr_fr
+// becomes fr-FR).
+func NormalizeLocale(locale string) string {
+	locale = strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+	parts := strings.SplitN(locale, "-", 2)
+	if len(parts) == 2 {
+		return strings.ToLower(parts[0]) + "-" + strings.ToUpper(parts[1])
+	}
+	return strings.ToLower(locale)
+}
+
+// ValidLocale returns true if the normalized locale is a language tag
+// with an optional region.
+func ValidLocale(locale string) bool {
+	return localePattern.MatchString(locale)
+}
+
+// Language returns the language part of a locale (fr for fr-FR).
+func Language(locale string) string {
+	return strings.SplitN(locale, "-", 2)[0]
+}
+
+// Apply updates the profile with the fields set in the input.
+func (p *Profile) Apply(input UpdateProfileInput, now time.Time) error {
+	if input.DisplayName != nil {
+		p.DisplayName = strings.TrimSpace(*input.DisplayName)
+	}
+	if input.Locale != nil {
+		locale := NormalizeLocale(*input.Locale)
+		if locale != "" && !ValidLocale(locale) {
+			return fmt.Errorf("invalid locale: %q", *input.Locale)
+		}
+		p.Locale = locale
+	}
+	if input.Currency != nil {
+		currency := strings.ToUpper(strings.TrimSpace(*input.Currency))
+		if currency != "" && money.GetCurrency(currency) == nil {
+			return fmt.Errorf("unknown currency: %q", *input.Currency)
+		}
+		p.Currency = currency
+	}
+	if input.MarketingConsent != nil && *input.MarketingConsent != p.MarketingConsent {
+		p.MarketingConsent = *input.MarketingConsent
+		// keep the date the consent was given as a proof
+		p.MarketingConsentAt = nil
+		if p.MarketingConsent {
+			p.MarketingConsentAt = &now
+		}
+	}
+	p.UpdatedAt = now
+	return nil
+}
+
+// Preferences returns the locale and currency chosen by the user.
+func (p Profile) Preferences() Preferences {
+	return Preferences{Locale: p.Locale, Currency: p.Currency}
+}
+
+// localeFormats are the separators and symbol position of the languages
+// whose conventions differ from the currency defaults. French separates
+// thousands with a narrow no-break space.
+var localeFormats = map[string]struct {
+	decimal, thousand, template string
+}{
+	"en": {decimal: ".", thousand: ",", template: "$1"},
+	"fr": {decimal: ",", thousand: "\u202f", template: "1\u00a0$"},
+	"de": {decimal: ",", thousand: ".", template: "1 $"},
+	"es": {decimal: ",", thousand: ".", template: "1 $"},
+	"it": {decimal: ",", thousand: ".", template: "1 $"},
+	"nl": {decimal: ",", thousand: ".", template: "$ 1"},
+	"pt": {decimal: ",", thousand: ".", template: "1 $"},
+}
+
+// FormatMoney formats an amount with the conventions of the locale, the
+// currency defaults are used for unknown locales.
+func FormatMoney(m *money.Money, locale string) string {
+	if m == nil {
+		return ""
+	}
+	formatter := m.Currency().Formatter()
+	if f, found := localeFormats[Language(locale)]; found {
+		formatter.Decimal = f.decimal
+		formatter.Thousand = f.thousand
+		formatter.Template = f.template
+	}
+	return formatter.Format(m.Amount())
+}
@@ -0,0 +1,63 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Rhymond/go-money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatMoney(t *testing.T) {
+	amount := money.New(123456, "EUR")
+
+	t.Run("english", func(t *testing.T) {
+		assert.Equal(t, "€1,234.56", FormatMoney(amount, "en-GB"))
+	})
+
+	t.Run("french", func(t *testing.T) {
+		assert.Equal(t, "1\u202f234,56\u00a0€", FormatMoney(amount, "fr"))
+	})
+
+	t.Run("currency defaults for an unknown locale", func(t *testing.T) {
+		assert.Equal(t, amount.Display(), FormatMoney(amount, "ja"))
+	})
+}
+
+func TestProfile_Apply(t *testing.T) {
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("normalized locale and currency", func(t *testing.T) {
+		// given
+		p := Profile{UserID: "adil"}
+		locale, currency := "fr_fr", "eur"
+
+		// when
+		err := p.Apply(UpdateProfileInput{Locale: &locale, Currency: &currency}, now)
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, "fr-FR", p.Locale)
+		assert.Equal(t, "EUR", p.Currency)
+	})
+
+	t.Run("unknown currency", func(t *testing.T) {
+		p := Profile{UserID: "adil"}
+		currency := "XYZ"
+
+		err := p.Apply(UpdateProfileInput{Currency: &currency}, now)
+
+		assert.EqualError(t, err, `unknown currency: "XYZ"`)
+	})
+
+	t.Run("consent date is recorded", func(t *testing.T) {
+		p := Profile{UserID: "adil"}
+		consent := true
+
+		err := p.Apply(UpdateProfileInput{MarketingConsent: &consent}, now)
+
+		assert.NoError(t, err)
+		assert.True(t, p.MarketingConsent)
+		assert.Equal(t, &now, p.MarketingConsentAt)
+	})
+}
@@ -0,0 +1,272 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Rhymond/go-money"
+)
+
+const (
+	PromotionPercentage  = "percentage"
+	PromotionFixedAmount = "fixedAmount"
+	PromotionBuyXGetY    = "buyXGetY"
+)
+
+type Promotion struct {
+	ID          string `json:"id"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Type        string `json:"type"`
+	// Percentage is the discount of a percentage promotion (1 to 100)
+	Percentage int64 `json:"percentage,omitempty"`
+	// Amount is the discount VAT included of a fixed amount promotion
+	Amount Money `json:"amount"`
+	// buy BuyQuantity units, get GetQuantity units for free
+	BuyQuantity uint `json:"buyQuantity,omitempty"`
+	GetQuantity uint `json:"getQuantity,omitempty"`
+	// scope, the whole cart when both are empty
+	ProductIDs  []string `json:"productIds,omitempty"`
+	CategoryIDs []string `json:"categoryIds,omitempty"`
+	// validity window
+	StartsAt *time.Time `json:"startsAt,omitempty"`
+	EndsAt   *time.Time `json:"endsAt,omitempty"`
+	// usage limits, 0 means no limit
+	MaxUses        uint `json:"maxUses,omitempty"`
+	MaxUsesPerUser uint `json:"maxUsesPerUser,omitempty"`
+	Uses           uint `json:"uses"`
+	Version        uint `json:"version"`
+}
+
+type ApplyCouponInput struct {
+	Code string `json:"code"`
+}
+
+// DiscountLine is the discount granted by a promotion on a cart.
+type DiscountLine struct {
+	PromotionID  string       `json:"promotionId"`
+	Code         string       `json:"code"`
+	Description  string       `json:"description"`
+	AmountVATExc *money.Money `json:"amountVATExc"`
+	VAT          *money.Money `json:"vat"`
+	AmountVATInc *money.Money `json:"amountVATInc"`
+}
+
+// CartTotals are the amounts of a cart once its promotions and shipping are
+// applied.
+type CartTotals struct {
+	SubtotalVATInc *money.Money    `json:"subtotalVATInc"`
+	Discounts      []DiscountLine  `json:"discounts"`
+	Shipping       *ShippingOption `json:"shipping,omitempty"`
+	TotalVATExc    *money.Money    `json:"totalVATExc"`
+	TotalVAT       *money.Money    `json:"totalVAT"`
+	TotalVATInc    *money.Money    `json:"totalVATInc"`
+	// DisplayTotalVATInc is the total formatted for the locale of the request
+	DisplayTotalVATInc string `json:"displayTotalVATInc,omitempty"`
+}
+
+// NormalizeCode returns the canonical form of a coupon code.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Validate checks the consistency of the promotion rules.
+func (p Promotion) Validate() error {
+	if NormalizeCode(p.Code) == "" {
+		return errors.New("code is mandatory")
+	}
+
+	switch p.Type {
+	case PromotionPercentage:
+		if p.Percentage <= 0 || p.Percentage > 100 {
+			return fmt.Errorf("percentage should be between 1 and 100, got %d", p.Percentage)
+		}
+	case PromotionFixedAmount:
+		if p.Amount.Amount <= 0 || p.Amount.Currency == "" {
+			return errors.New("amount should be positive with a currency")
+		}
+	case PromotionBuyXGetY:
+		if p.BuyQuantity == 0 || p.GetQuantity == 0 {
+			return errors.New("buyQuantity and getQuantity should be positive")
+		}
+	default:
+		return fmt.Errorf("unknown promotion type: %q", p.Type)
+	}
+
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return errors.New("endsAt should be after startsAt")
+	}
+
+	return nil
+}
+
+// ActiveAt returns true if the promotion can be used at the given time.
+func (p Promotion) ActiveAt(now time.Time) bool {
+	if p.StartsAt != nil && now.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && !now.Before(*p.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// Exhausted returns true if the promotion reached its global usage limit.
+func (p Promotion) Exhausted() bool {
+	return p.MaxUses > 0 && p.Uses >= p.MaxUses
+}
+
+// AppliesTo returns true if the item is in the scope of the promotion.
+func (p Promotion) AppliesTo(item Item) bool {
+	if len(p.ProductIDs) == 0 && len(p.CategoryIDs) == 0 {
+		return true
+	}
+	for _, id := range p.ProductIDs {
+		if id == item.ID {
+			return true
+		}
+	}
+	for _, id := range p.CategoryIDs {
+		for _, categoryID := range item.CategoryIDs {
+			if id == categoryID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cartLine is the amount still due for an item while discounts are applied.
+type cartLine struct {
+	item Item
+	inc  int64
+	vat  int64
+}
+
+// discounts returns the discount VAT included granted on each line.
+func (p Promotion) discounts(lines []cartLine) []int64 {
+	discounts := make([]int64, len(lines))
+
+	eligible := make([]int, 0)
+	eligibleTotal := int64(0)
+	for i, l := range lines {
+		if l.inc > 0 && p.AppliesTo(l.item) {
+			eligible = append(eligible, i)
+			eligibleTotal += l.inc
+		}
+	}
+	if eligibleTotal == 0 {
+		return discounts
+	}
+
+	switch p.Type {
+	case PromotionPercentage:
+		for _, i := range eligible {
+			discounts[i] = (lines[i].inc*p.Percentage + 50) / 100
+		}
+	case PromotionFixedAmount:
+		amount := p.Amount.Amount
+		if amount > eligibleTotal {
+			amount = eligibleTotal
+		}
+		// allocate the amount proportionally to the lines, the last line
+		// takes the rounding remainder
+		allocated := int64(0)
+		for n, i := range eligible {
+			if n == len(eligible)-1 {
+				discounts[i] = amount - allocated
+				break
+			}
+			discounts[i] = amount * lines[i].inc / eligibleTotal
+			allocated += discounts[i]
+		}
+	case PromotionBuyXGetY:
+		group := p.BuyQuantity + p.GetQuantity
+		for _, i := range eligible {
+			quantity := lines[i].item.Quantity
+			free := quantity / group * p.GetQuantity
+			discounts[i] = lines[i].inc * int64(free) / int64(quantity)
+		}
+	}
+
+	return discounts
+}
+
+// ComputeTotals computes the amounts of a priced cart, applying the promotions in
+// order on what is left to pay. The VAT of each discount is reallocated
+// with the VAT rate of the items it applies to.
+func (c Cart) ComputeTotals(promotions []Promotion) (CartTotals, error) {
+	ids := make([]string, 0, len(c.Items))
+	for id := range c.Items {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	lines := make([]cartLine, 0, len(ids))
+	subtotal := int64(0)
+	for _, id := range ids {
+		item := c.Items[id]
+		if item.UnitPriceVATInc == nil || item.VAT == nil {
+			continue
+		}
+		if item.UnitPriceVATInc.Currency().Code != c.CurrencyCode {
+			return CartTotals{}, fmt.Errorf("error - item %s currency %s does not match the cart currency %s", id, item.UnitPriceVATInc.Currency().Code, c.CurrencyCode)
+		}
+		l := cartLine{
+			item: item,
+			inc:  item.UnitPriceVATInc.Amount() * int64(item.Quantity),
+			vat:  item.VAT.Amount() * int64(item.Quantity),
+		}
+		subtotal += l.inc
+		lines = append(lines, l)
+	}
+
+	totals := CartTotals{
+		SubtotalVATInc: money.New(subtotal, c.CurrencyCode),
+		Discounts:      make([]DiscountLine, 0),
+	}
+
+	for _, p := range promotions {
+		if p.Type == PromotionFixedAmount && p.Amount.Currency != c.CurrencyCode {
+			continue
+		}
+
+		discountInc, discountVAT := int64(0), int64(0)
+		for i, d := range p.discounts(lines) {
+			if d == 0 {
+				continue
+			}
+			vat := (d*lines[i].vat + lines[i].inc/2) / lines[i].inc
+			lines[i].inc -= d
+			lines[i].vat -= vat
+			discountInc += d
+			discountVAT += vat
+		}
+		if discountInc == 0 {
+			continue
+		}
+
+		totals.Discounts = append(totals.Discounts, DiscountLine{
+			PromotionID:  p.ID,
+			Code:         p.Code,
+			Description:  p.Description,
+			AmountVATExc: money.New(discountInc-discountVAT, c.CurrencyCode),
+			VAT:          money.New(discountVAT, c.CurrencyCode),
+			AmountVATInc: money.New(discountInc, c.CurrencyCode),
+		})
+	}
+
+	totalInc, totalVAT := int64(0), int64(0)
+	for _, l := range lines {
+		totalInc += l.inc
+		totalVAT += l.vat
+	}
+	totals.TotalVATExc = money.New(totalInc-totalVAT, c.CurrencyCode)
+	totals.TotalVAT = money.New(totalVAT, c.CurrencyCode)
+	totals.TotalVATInc = money.New(totalInc, c.CurrencyCode)
+
+	return totals, nil
+}
@@ -0,0 +1,119 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Rhymond/go-money"
+	"github.com/stretchr/testify/assert"
+)
+
+func pricedCart() Cart {
+	return Cart{
+		ID:           "42",
+		CurrencyCode: "EUR",
+		Items: map[string]Item{
+			"socks": {
+				ID:              "socks",
+				Quantity:        3,
+				CategoryIDs:     []string{"clothes"},
+				UnitPriceVATExc: money.New(1000, "EUR"),
+				VAT:             money.New(200, "EUR"),
+				UnitPriceVATInc: money.New(1200, "EUR"),
+			},
+			"book": {
+				ID:              "book",
+				Quantity:        1,
+				UnitPriceVATExc: money.New(2000, "EUR"),
+				VAT:             money.New(110, "EUR"),
+				UnitPriceVATInc: money.New(2110, "EUR"),
+			},
+		},
+	}
+}
+
+func TestCart_ComputeTotals(t *testing.T) {
+	t.Run("no promotion", func(t *testing.T) {
+		// when
+		totals, err := pricedCart().ComputeTotals(nil)
+
+		// then
+		assert.NoError(t, err, "computing totals should not fail")
+		assert.Equal(t, money.New(5710, "EUR"), totals.SubtotalVATInc)
+		assert.Equal(t, money.New(5710, "EUR"), totals.TotalVATInc)
+		assert.Equal(t, money.New(710, "EUR"), totals.TotalVAT)
+		assert.Empty(t, totals.Discounts)
+	})
+
+	t.Run("percentage scoped to a category", func(t *testing.T) {
+		// given
+		promotion := Promotion{ID: "1", Code: "CLOTHES", Type: PromotionPercentage, Percentage: 10, CategoryIDs: []string{"clothes"}}
+
+		// when
+		totals, err := pricedCart().ComputeTotals([]Promotion{promotion})
+
+		// then
+		assert.NoError(t, err, "computing totals should not fail")
+		assert.Len(t, totals.Discounts, 1)
+		assert.Equal(t, money.New(360, "EUR"), totals.Discounts[0].AmountVATInc)
+		assert.Equal(t, money.New(60, "EUR"), totals.Discounts[0].VAT)
+		assert.Equal(t, money.New(5350, "EUR"), totals.TotalVATInc)
+		assert.Equal(t, money.New(650, "EUR"), totals.TotalVAT)
+	})
+
+	t.Run("fixed amount reallocates the VAT on every item", func(t *testing.T) {
+		// given
+		promotion := Promotion{ID: "1", Code: "TEN", Type: PromotionFixedAmount, Amount: Money{Amount: 1000, Currency: "EUR"}}
+
+		// when
+		totals, err := pricedCart().ComputeTotals([]Promotion{promotion})
+
+		// then
+		assert.NoError(t, err, "computing totals should not fail")
+		assert.Equal(t, money.New(1000, "EUR"), totals.Discounts[0].AmountVATInc)
+		assert.Equal(t, money.New(4710, "EUR"), totals.TotalVATInc)
+		// 370 of book (VAT 110/2110) and 630 of socks (VAT 600/3600)
+		assert.Equal(t, money.New(124, "EUR"), totals.Discounts[0].VAT)
+	})
+
+	t.Run("buy 2 get 1 on a product", func(t *testing.T) {
+		// given
+		promotion := Promotion{ID: "1", Code: "B2G1", Type: PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1, ProductIDs: []string{"socks"}}
+
+		// when
+		totals, err := pricedCart().ComputeTotals([]Promotion{promotion})
+
+		// then
+		assert.NoError(t, err, "computing totals should not fail")
+		assert.Equal(t, money.New(1200, "EUR"), totals.Discounts[0].AmountVATInc)
+		assert.Equal(t, money.New(200, "EUR"), totals.Discounts[0].VAT)
+	})
+
+	t.Run("promotions never make the total negative", func(t *testing.T) {
+		// given
+		promotions := []Promotion{
+			{ID: "1", Code: "HALF", Type: PromotionPercentage, Percentage: 50},
+			{ID: "2", Code: "BIG", Type: PromotionFixedAmount, Amount: Money{Amount: 100000, Currency: "EUR"}},
+		}
+
+		// when
+		totals, err := pricedCart().ComputeTotals(promotions)
+
+		// then
+		assert.NoError(t, err, "computing totals should not fail")
+		assert.Len(t, totals.Discounts, 2)
+		assert.Equal(t, money.New(0, "EUR"), totals.TotalVATInc)
+		assert.Equal(t, money.New(0, "EUR"), totals.TotalVAT)
+	})
+}
+
+func TestPromotion_Validate(t *testing.T) {
+	start := time.Now()
+	end := start.Add(-time.Hour)
+
+	assert.Error(t, Promotion{Code: "", Type: PromotionPercentage, Percentage: 10}.Validate(), "the code is mandatory")
+	assert.Error(t, Promotion{Code: "A", Type: PromotionPercentage, Percentage: 101}.Validate(), "a percentage cannot exceed 100")
+	assert.Error(t, Promotion{Code: "A", Type: "unknown"}.Validate(), "the type should be known")
+	assert.Error(t, Promotion{Code: "A", Type: PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1, StartsAt: &start, EndsAt: &end}.Validate(), "the window should end after it starts")
+	assert.NoError(t, Promotion{Code: "A", Type: PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1}.Validate(), "a valid promotion should not fail")
+}
@@ -0,0 +1,113 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const (
+	RelationRelated   = "related"
+	RelationAccessory = "accessory"
+	RelationUpsell    = "upsell"
+)
+
+// MaxRelations bounds the curated relations of a product
+const MaxRelations = 50
+
+// ProductRelation is a product curated by the admins next to another one.
+type ProductRelation struct {
+	ProductID string `json:"productId"`
+	Type      string `json:"type"`
+}
+
+// CoPurchase counts the orders containing a product and another one.
+type CoPurchase struct {
+	ProductID string `json:"productId"`
+	Count     uint   `json:"count"`
+}
+
+// RelatedProducts are the products shown next to a product.
+type RelatedProducts struct {
+	Related                  []Product `json:"related"`
+	Accessories              []Product `json:"accessories"`
+	Upsells                  []Product `json:"upsells"`
+	FrequentlyBoughtTogether []Product `json:"frequentlyBoughtTogether"`
+}
+
+// RecordPurchaseInput lists the products of an order, an order is counted
+// once however many times it is recorded.
+type RecordPurchaseInput struct {
+	OrderID    string   `json:"orderId"`
+	ProductIDs []string `json:"productIds"`
+}
+
+func (i RecordPurchaseInput) Validate() error {
+	if i.OrderID == "" {
+		return errors.New("orderId is mandatory")
+	}
+	return nil
+}
+
+// ValidateRelations checks the relations of a product.
+func ValidateRelations(productID string, relations []ProductRelation) error {
+	if len(relations) > MaxRelations {
+		return fmt.Errorf("a product cannot have more than %d relations", MaxRelations)
+	}
+	seen := make(map[ProductRelation]bool, len(relations))
+	for _, r := range relations {
+		switch r.Type {
+		case RelationRelated, RelationAccessory, RelationUpsell:
+		default:
+			return fmt.Errorf("invalid relation type %q", r.Type)
+		}
+		if r.ProductID == "" || r.ProductID == productID {
+			return fmt.Errorf("invalid related product %q", r.ProductID)
+		}
+		if seen[r] {
+			return fmt.Errorf("duplicated relation %s %s", r.Type, r.ProductID)
+		}
+		seen[r] = true
+	}
+	return nil
+}
+
+// Suggest ranks the products to suggest for a cart from the relations and
+// co-purchases of its products: accessories first, then the products most
+// often bought with the cart, then the related products. The products
+// already in the cart are skipped.
+func Suggest(cart Cart, relations map[string][]ProductRelation, coPurchases map[string][]CoPurchase, limit int) []string {
+	// a score high enough to rank curated relations before co-purchases
+	const curated = 1 << 20
+	scores := make(map[string]uint)
+	for id := range cart.Items {
+		for _, r := range relations[id] {
+			switch r.Type {
+			case RelationAccessory:
+				scores[r.ProductID] += 2 * curated
+			case RelationRelated:
+				scores[r.ProductID]++
+			}
+		}
+		for _, c := range coPurchases[id] {
+			scores[c.ProductID] += c.Count
+		}
+	}
+
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		if _, inCart := cart.Items[id]; !inCart {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids
+}
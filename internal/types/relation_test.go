@@ -0,0 +1,43 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRelations(t *testing.T) {
+	assert.NoError(t, ValidateRelations("p1", []ProductRelation{
+		{ProductID: "p2", Type: RelationAccessory},
+		{ProductID: "p2", Type: RelationRelated},
+	}))
+	assert.Error(t, ValidateRelations("p1", []ProductRelation{{ProductID: "p2", Type: "similar"}}))
+	assert.Error(t, ValidateRelations("p1", []ProductRelation{{ProductID: "p1", Type: RelationRelated}}))
+	assert.Error(t, ValidateRelations("p1", []ProductRelation{
+		{ProductID: "p2", Type: RelationRelated},
+		{ProductID: "p2", Type: RelationRelated},
+	}))
+}
+
+func TestSuggest(t *testing.T) {
+	// Given
+	cart := Cart{Items: map[string]Item{"camera": {}, "tripod": {}}}
+	relations := map[string][]ProductRelation{
+		"camera": {
+			{ProductID: "lens", Type: RelationRelated},
+			{ProductID: "battery", Type: RelationAccessory},
+			{ProductID: "tripod", Type: RelationAccessory},
+			{ProductID: "pro-camera", Type: RelationUpsell},
+		},
+	}
+	coPurchases := map[string][]CoPurchase{
+		"camera": {{ProductID: "sd-card", Count: 12}, {ProductID: "bag", Count: 3}},
+		"tripod": {{ProductID: "bag", Count: 10}},
+	}
+
+	// When
+	ids := Suggest(cart, relations, coPurchases, 3)
+
+	// Then
+	assert.Equal(t, []string{"battery", "bag", "sd-card"}, ids, "accessories first, then the co-purchases summed over the cart")
+}
@@ -0,0 +1,73 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+type Review struct {
+	ID        string `json:"id"`
+	ProductID string `json:"productId"`
+	// UserID is kept in the database only
+	UserID      string     `json:"-" dynamodbav:"userId"`
+	AuthorName  string     `json:"authorName,omitempty"`
+	Rating      uint       `json:"rating"`
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ModeratedAt *time.Time `json:"moderatedAt,omitempty"`
+}
+
+type CreateReviewInput struct {
+	Rating uint   `json:"rating"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+type ModerateReviewInput struct {
+	Status string `json:"status"`
+}
+
+// Validate checks the rating is between 1 and 5 stars and the review has
+// some content.
+func (i CreateReviewInput) Validate() error {
+	if i.Rating < 1 || i.Rating > 5 {
+		return fmt.Errorf("rating should be between 1 and 5, got %d", i.Rating)
+	}
+	if strings.TrimSpace(i.Title) == "" && strings.TrimSpace(i.Body) == "" {
+		return errors.New("a review needs a title or a body")
+	}
+	return nil
+}
+
+func (i ModerateReviewInput) Validate() error {
+	if i.Status != ReviewApproved && i.Status != ReviewRejected {
+		return fmt.Errorf("status should be %s or %s, got %q", ReviewApproved, ReviewRejected, i.Status)
+	}
+	return nil
+}
+
+// RatingDelta returns the change of the rating aggregates of the product
+// when the review moves from its status to the given one: only approved
+// reviews are counted.
+func (r Review) RatingDelta(status string) (count int, total int) {
+	if r.Status == status {
+		return 0, 0
+	}
+	if status == ReviewApproved {
+		return 1, int(r.Rating)
+	}
+	if r.Status == ReviewApproved {
+		return -1, -int(r.Rating)
+	}
+	return 0, 0
+}
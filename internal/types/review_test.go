@@ -0,0 +1,27 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReview_RatingDelta(t *testing.T) {
+	t.Run("approving a pending review counts its rating", func(t *testing.T) {
+		count, total := Review{Rating: 4, Status: ReviewPending}.RatingDelta(ReviewApproved)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, 4, total)
+	})
+
+	t.Run("rejecting an approved review removes its rating", func(t *testing.T) {
+		count, total := Review{Rating: 4, Status: ReviewApproved}.RatingDelta(ReviewRejected)
+		assert.Equal(t, -1, count)
+		assert.Equal(t, -4, total)
+	})
+
+	t.Run("rejecting a pending review changes nothing", func(t *testing.T) {
+		count, total := Review{Rating: 4, Status: ReviewPending}.RatingDelta(ReviewRejected)
+		assert.Equal(t, 0, count)
+		assert.Equal(t, 0, total)
+	})
+}
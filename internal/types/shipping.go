@@ -0,0 +1,222 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/Rhymond/go-money"
+)
+
+const (
+	ShippingRateByWeight    = "weight"
+	ShippingRateByCartValue = "cartValue"
+)
+
+// Dimensions of a product in millimeters.
+type Dimensions struct {
+	Length uint `json:"length"`
+	Width  uint `json:"width"`
+	Height uint `json:"height"`
+}
+
+// ShippingZone is a set of countries (ISO 3166-1 alpha-2 codes).
+type ShippingZone struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Countries []string `json:"countries"`
+}
+
+// ShippingRate is the price VAT included of a shipment up to a weight (in
+// grams) or a cart value (in cents), 0 means no upper bound.
+type ShippingRate struct {
+	UpTo  int64 `json:"upTo"`
+	Price Money `json:"price"`
+}
+
+type ShippingMethod struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	ZoneID string `json:"zoneId"`
+	// RateBasis tells if the rates apply on the weight or the value of the cart
+	RateBasis string         `json:"rateBasis"`
+	Rates     []ShippingRate `json:"rates"`
+	// FreeAbove is the cart value VAT included from which shipping is free,
+	// no free shipping when the amount is 0
+	FreeAbove Money `json:"freeAbove"`
+	// VATRate in basis points (2000 is 20%)
+	VATRate int64 `json:"vatRate"`
+	// VolumetricDivisor turns the volume of a product into a weight, in
+	// cm³ per kg (5000 for most carriers). A product weighs the greater of
+	// its weight and its volumetric weight, 0 only counts the weight.
+	VolumetricDivisor uint `json:"volumetricDivisor,omitempty"`
+}
+
+// ShippingOption is the price of a shipping method for a cart.
+type ShippingOption struct {
+	MethodID    string       `json:"methodId"`
+	Name        string       `json:"name"`
+	Free        bool         `json:"free"`
+	PriceVATExc *money.Money `json:"priceVATExc"`
+	VAT         *money.Money `json:"vat"`
+	PriceVATInc *money.Money `json:"priceVATInc"`
+}
+
+type SelectShippingInput struct {
+	Country  string `json:"country"`
+	MethodID string `json:"methodId"`
+}
+
+// NormalizeCountry returns the canonical form of a country code.
+func NormalizeCountry(country string) string {
+	return strings.ToUpper(strings.TrimSpace(country))
+}
+
+func (z ShippingZone) Validate() error {
+	if z.Name == "" {
+		return errors.New("name is mandatory")
+	}
+	if len(z.Countries) == 0 {
+		return errors.New("a zone should contain at least one country")
+	}
+	for _, c := range z.Countries {
+		if len(NormalizeCountry(c)) != 2 {
+			return fmt.Errorf("invalid country code: %q", c)
+		}
+	}
+	return nil
+}
+
+// Contains returns true if the country is part of the zone.
+func (z ShippingZone) Contains(country string) bool {
+	country = NormalizeCountry(country)
+	for _, c := range z.Countries {
+		if NormalizeCountry(c) == country {
+			return true
+		}
+	}
+	return false
+}
+
+func (m ShippingMethod) Validate() error {
+	if m.Name == "" || m.ZoneID == "" {
+		return errors.New("name and zoneId are mandatory")
+	}
+	if m.RateBasis != ShippingRateByWeight && m.RateBasis != ShippingRateByCartValue {
+		return fmt.Errorf("unknown rate basis: %q", m.RateBasis)
+	}
+	if len(m.Rates) == 0 {
+		return errors.New("a method should have at least one rate")
+	}
+	for _, r := range m.Rates {
+		if r.UpTo < 0 || r.Price.Amount < 0 || r.Price.Currency == "" {
+			return errors.New("rates should have a positive bound and price with a currency")
+		}
+	}
+	if m.VATRate < 0 {
+		return errors.New("vatRate cannot be negative")
+	}
+	return nil
+}
+
+// Quote prices the method for a cart of the given weight (in grams) and
+// value VAT included. It returns false if no rate covers the cart.
+func (m ShippingMethod) Quote(weight uint, cartValue *money.Money) (ShippingOption, bool) {
+	currency := cartValue.Currency().Code
+
+	rates := make([]ShippingRate, 0, len(m.Rates))
+	for _, r := range m.Rates {
+		if r.Price.Currency == currency {
+			rates = append(rates, r)
+		}
+	}
+	// bounded rates first in increasing order, the unbounded rate last
+	bound := func(r ShippingRate) int64 {
+		if r.UpTo == 0 {
+			return math.MaxInt64
+		}
+		return r.UpTo
+	}
+	sort.SliceStable(rates, func(i, j int) bool {
+		return bound(rates[i]) < bound(rates[j])
+	})
+
+	measure := int64(weight)
+	if m.RateBasis == ShippingRateByCartValue {
+		measure = cartValue.Amount()
+	}
+
+	option := ShippingOption{MethodID: m.ID, Name: m.Name}
+	found := false
+	price := int64(0)
+	for _, r := range rates {
+		if r.UpTo == 0 || measure <= r.UpTo {
+			price = r.Price.Amount
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ShippingOption{}, false
+	}
+
+	if m.FreeAbove.Amount > 0 && m.FreeAbove.Currency == currency && cartValue.Amount() >= m.FreeAbove.Amount {
+		option.Free = true
+		price = 0
+	}
+
+	vat := (price*m.VATRate + (10000+m.VATRate)/2) / (10000 + m.VATRate)
+	option.PriceVATInc = money.New(price, currency)
+	option.VAT = money.New(vat, currency)
+	option.PriceVATExc = money.New(price-vat, currency)
+
+	return option, true
+}
+
+// Volume returns the volume of the dimensions in mm³.
+func (d Dimensions) Volume() uint {
+	return d.Length * d.Width * d.Height
+}
+
+// Weight returns the weight of the cart in grams, the volumetric weight of
+// the items with dimensions is used when it is greater and the divisor (in
+// cm³ per kg) is set.
+func (c Cart) Weight(volumetricDivisor uint) uint {
+	weight := uint(0)
+	for _, item := range c.Items {
+		itemWeight := item.Weight
+		if volumetricDivisor > 0 && item.Dimensions != nil {
+			// mm³ / (cm³/kg) is a weight in grams
+			volumetric := (item.Dimensions.Volume() + volumetricDivisor - 1) / volumetricDivisor
+			if volumetric > itemWeight {
+				itemWeight = volumetric
+			}
+		}
+		weight += itemWeight * item.Quantity
+	}
+	return weight
+}
+
+// AddShipping adds the price of the shipping option to the totals.
+func (t *CartTotals) AddShipping(option ShippingOption) error {
+	totalVATExc, err := t.TotalVATExc.Add(option.PriceVATExc)
+	if err != nil {
+		return fmt.Errorf("error - add shipping to total price: %w", err)
+	}
+	totalVAT, err := t.TotalVAT.Add(option.VAT)
+	if err != nil {
+		return fmt.Errorf("error - add shipping to total VAT: %w", err)
+	}
+	totalVATInc, err := t.TotalVATInc.Add(option.PriceVATInc)
+	if err != nil {
+		return fmt.Errorf("error - add shipping to total price: %w", err)
+	}
+
+	t.Shipping = &option
+	t.TotalVATExc = totalVATExc
+	t.TotalVAT = totalVAT
+	t.TotalVATInc = totalVATInc
+	return nil
+}
@@ -0,0 +1,70 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/Rhymond/go-money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShippingMethod_Quote(t *testing.T) {
+	method := ShippingMethod{
+		ID:        "colissimo",
+		Name:      "Colissimo",
+		ZoneID:    "fr",
+		RateBasis: ShippingRateByWeight,
+		Rates: []ShippingRate{
+			{UpTo: 0, Price: Money{Amount: 1800, Currency: "EUR"}},
+			{UpTo: 500, Price: Money{Amount: 600, Currency: "EUR"}},
+			{UpTo: 2000, Price: Money{Amount: 1200, Currency: "EUR"}},
+		},
+		FreeAbove: Money{Amount: 5000, Currency: "EUR"},
+		VATRate:   2000,
+	}
+
+	t.Run("rate of the weight band", func(t *testing.T) {
+		// when
+		option, ok := method.Quote(800, money.New(2000, "EUR"))
+
+		// then
+		assert.True(t, ok, "the method should cover the cart")
+		assert.Equal(t, money.New(1200, "EUR"), option.PriceVATInc)
+		assert.Equal(t, money.New(200, "EUR"), option.VAT)
+		assert.Equal(t, money.New(1000, "EUR"), option.PriceVATExc)
+		assert.False(t, option.Free)
+	})
+
+	t.Run("unbounded rate for heavy carts", func(t *testing.T) {
+		option, ok := method.Quote(10000, money.New(2000, "EUR"))
+
+		assert.True(t, ok, "the method should cover the cart")
+		assert.Equal(t, money.New(1800, "EUR"), option.PriceVATInc)
+	})
+
+	t.Run("free above the threshold", func(t *testing.T) {
+		option, ok := method.Quote(800, money.New(5000, "EUR"))
+
+		assert.True(t, ok, "the method should cover the cart")
+		assert.True(t, option.Free)
+		assert.Equal(t, money.New(0, "EUR"), option.PriceVATInc)
+	})
+
+	t.Run("no rate in the cart currency", func(t *testing.T) {
+		_, ok := method.Quote(800, money.New(2000, "USD"))
+
+		assert.False(t, ok, "the method should not cover a cart in another currency")
+	})
+}
+
+func TestCart_Weight(t *testing.T) {
+	// given a 2 kg box of 40x30x25 cm, 6 kg at 5000 cm³ per kg
+	cart := Cart{Items: map[string]Item{
+		"box":  {ID: "box", Quantity: 2, Weight: 2000, Dimensions: &Dimensions{Length: 400, Width: 300, Height: 250}},
+		"book": {ID: "book", Quantity: 1, Weight: 500},
+	}}
+
+	// then
+	assert.Equal(t, uint(4500), cart.Weight(0), "without divisor only the weight counts")
+	assert.Equal(t, uint(12500), cart.Weight(5000), "the box weighs its volumetric weight")
+	assert.Equal(t, uint(4500), cart.Weight(50000), "the box weighs more than its volume")
+}
@@ -0,0 +1,99 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ProductTranslation is the content of a product in a locale, the empty
+// fields fall back to the default content.
+type ProductTranslation struct {
+	Name             string `json:"name,omitempty"`
+	ShortDescription string `json:"shortDescription,omitempty"`
+	Description      string `json:"description,omitempty"`
+}
+
+func (t ProductTranslation) Validate() error {
+	if t == (ProductTranslation{}) {
+		return errors.New("translation cannot be empty")
+	}
+	return nil
+}
+
+// CategoryTranslation is the content of a category in a locale, the empty
+// fields fall back to the default content.
+type CategoryTranslation struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+func (t CategoryTranslation) Validate() error {
+	if t == (CategoryTranslation{}) {
+		return errors.New("translation cannot be empty")
+	}
+	return nil
+}
+
+// ValidateTranslationLocale checks the locale a translation is stored
+// under, the default locale is the content of the product itself.
+func ValidateTranslationLocale(locale string) error {
+	if !ValidLocale(locale) {
+		return fmt.Errorf("invalid locale %q", locale)
+	}
+	if locale == DefaultLocale {
+		return fmt.Errorf("%s is the default locale, update the content instead", DefaultLocale)
+	}
+	return nil
+}
+
+// translationLocales are the locales tried for a requested locale, from the
+// most to the least specific (fr-CA then fr).
+func translationLocales(locale string) []string {
+	if lang := Language(locale); lang != locale {
+		return []string{locale, lang}
+	}
+	return []string{locale}
+}
+
+// Localize replaces the content of the product with its translation in the
+// locale, the translations are dropped from the product.
+func (p *Product) Localize(locale string) {
+	translations := p.Translations
+	p.Translations = nil
+	for _, l := range translationLocales(locale) {
+		t, found := translations[l]
+		if !found {
+			continue
+		}
+		if t.Name != "" {
+			p.Name = t.Name
+		}
+		if t.ShortDescription != "" {
+			p.ShortDescription = t.ShortDescription
+		}
+		if t.Description != "" {
+			p.Description = t.Description
+		}
+		return
+	}
+}
+
+// Localize replaces the content of the category with its translation in
+// the locale, the translations are dropped from the category.
+func (c *Category) Localize(locale string) {
+	translations := c.Translations
+	c.Translations = nil
+	for _, l := range translationLocales(locale) {
+		t, found := translations[l]
+		if !found {
+			continue
+		}
+		if t.Name != "" {
+			c.Name = t.Name
+		}
+		if t.Description != "" {
+			c.Description = t.Description
+		}
+		return
+	}
+}
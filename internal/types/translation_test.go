@@ -0,0 +1,55 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProduct_Localize(t *testing.T) {
+	product := func() Product {
+		return Product{
+			Name:             "Socks",
+			ShortDescription: "Warm socks",
+			Description:      "Wool socks",
+			Translations: map[string]ProductTranslation{
+				"fr":    {Name: "Chaussettes", ShortDescription: "Chaussettes chaudes"},
+				"fr-CA": {Name: "Bas"},
+			},
+		}
+	}
+
+	t.Run("uses the translation of the locale", func(t *testing.T) {
+		// Given
+		p := product()
+
+		// When
+		p.Localize("fr-FR")
+
+		// Then
+		assert.Equal(t, "Chaussettes", p.Name)
+		assert.Equal(t, "Chaussettes chaudes", p.ShortDescription)
+		assert.Equal(t, "Wool socks", p.Description, "missing fields fall back to the default content")
+		assert.Nil(t, p.Translations)
+	})
+
+	t.Run("prefers the regional translation", func(t *testing.T) {
+		p := product()
+		p.Localize("fr-CA")
+		assert.Equal(t, "Bas", p.Name)
+		assert.Equal(t, "Warm socks", p.ShortDescription, "regional translations are not merged with the language")
+	})
+
+	t.Run("falls back to the default content", func(t *testing.T) {
+		p := product()
+		p.Localize("de")
+		assert.Equal(t, "Socks", p.Name)
+		assert.Nil(t, p.Translations)
+	})
+}
+
+func TestValidateTranslationLocale(t *testing.T) {
+	assert.NoError(t, ValidateTranslationLocale("fr-CA"))
+	assert.Error(t, ValidateTranslationLocale("french"))
+	assert.Error(t, ValidateTranslationLocale(DefaultLocale))
+}
@@ -2,4 +2,7 @@ package types
 
 type User struct {
 	ID string
+	// claims of the verified identity token
+	Email string
+	Name  string
 }
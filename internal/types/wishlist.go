@@ -0,0 +1,24 @@
+package types
+
+import "time"
+
+// WishlistItem is a product kept by a user without reserving its stock.
+type WishlistItem struct {
+	ProductID string `json:"productId"`
+	// Quantity is the quantity the item had in the cart when it was saved
+	// for later, 1 for products added directly
+	Quantity uint      `json:"quantity"`
+	AddedAt  time.Time `json:"addedAt"`
+}
+
+// WishlistEntry is a wishlist item with the current state of its product,
+// Product is nil when the product no longer exists.
+type WishlistEntry struct {
+	WishlistItem
+	Product   *Product `json:"product,omitempty"`
+	Available bool     `json:"available"`
+}
+
+type AddToWishlistInput struct {
+	ProductID string `json:"productId"`
+}